@@ -2,26 +2,298 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/erkindilekci/product-api/pkg/common/app"
+	"github.com/erkindilekci/product-api/pkg/common/auth"
+	"github.com/erkindilekci/product-api/pkg/common/events"
+	"github.com/erkindilekci/product-api/pkg/common/logging"
+	"github.com/erkindilekci/product-api/pkg/common/metrics"
+	"github.com/erkindilekci/product-api/pkg/common/openapi"
 	"github.com/erkindilekci/product-api/pkg/common/postgresql"
+	"github.com/erkindilekci/product-api/pkg/common/ratelimit"
+	"github.com/erkindilekci/product-api/pkg/common/tracing"
 	"github.com/erkindilekci/product-api/pkg/controller"
+	"github.com/erkindilekci/product-api/pkg/controller/middleware"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/graphqlapi"
+	"github.com/erkindilekci/product-api/pkg/grpcapi"
 	"github.com/erkindilekci/product-api/pkg/repository"
 	"github.com/erkindilekci/product-api/pkg/service"
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/gommon/log"
+	"google.golang.org/grpc"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func main() {
-	ctx := context.Background()
 	configurationManager := app.NewConfigurationManager()
-	dbPool := postgresql.GetConnectionPool(ctx, configurationManager.PostgresqlConfig)
-	productRepository := repository.NewProductRepository(dbPool)
-	productService := service.NewProductService(productRepository)
-	productController := controller.NewProductController(productService)
+	logger, err := logging.NewLogger(configurationManager.LoggingConfig, os.Stdout)
+	if err != nil {
+		slog.Error("Failed to set up logging", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+
+	if err := run(configurationManager, logger); err != nil {
+		logger.Error("Product API stopped with an error", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run starts the API and blocks until it receives SIGINT or SIGTERM or the
+// server fails. Deferred calls release everything run acquired, in reverse
+// order, before it returns.
+func run(configurationManager *app.ConfigurationManager, logger *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if configurationManager.TracingConfig.Enabled() {
+		tracerProvider, err := tracing.NewTracerProvider(ctx, configurationManager.TracingConfig)
+		if err != nil {
+			return fmt.Errorf("failed to set up tracing: %w", err)
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tracerProvider.Shutdown(shutdownCtx); err != nil {
+				logger.Error("Failed to flush traces", "error", err)
+			}
+		}()
+	}
+	dbPool, err := postgresql.GetConnectionPool(ctx, configurationManager.PostgresqlConfig, logger)
+	if err != nil {
+		return err
+	}
+	defer dbPool.Close()
+
+	// workers tracks the background goroutines. They stop when ctx is
+	// cancelled and are waited for before the pool is closed.
+	var workers sync.WaitGroup
+	runWorker := func(worker func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker(ctx)
+		}()
+	}
+	defer func() {
+		stop()
+		workers.Wait()
+	}()
+
+	metricsRegistry := metrics.NewRegistry()
+	postgresql.RegisterPoolMetrics(metricsRegistry, dbPool)
+	webhookService := service.NewWebhookService(repository.NewWebhookRepository(dbPool, logger), configurationManager.WebhookConfig, logger)
+	runWorker(func(ctx context.Context) { webhookService.DeliverPeriodically(ctx, time.Second) })
+	eventPublisher, closeEventPublisher, err := newEventPublisher(configurationManager.EventsConfig, webhookService)
+	if err != nil {
+		return fmt.Errorf("failed to set up event publishing: %w", err)
+	}
+	outboxRepository := repository.NewOutboxRepository(dbPool, logger)
+	outboxRelay := service.NewOutboxRelay(outboxRepository, eventPublisher, configurationManager.EventsConfig, logger)
+	runWorker(func(ctx context.Context) {
+		defer closeEventPublisher()
+		outboxRelay.RelayPeriodically(ctx, time.Second)
+	})
+	runWorker(func(ctx context.Context) { outboxRelay.PurgePublishedPeriodically(ctx, time.Hour) })
+	// The product stream ends its subscriptions as soon as a shutdown signal
+	// arrives, so that open streams do not hold up the shutdown; clients
+	// reconnect to another instance and resume from their last event. The
+	// stream polls right away when the database notifies a product change;
+	// the interval only bounds the delay while the listener is reconnecting.
+	roleBindingService := service.NewRoleBindingService(repository.NewRoleBindingRepository(dbPool, logger))
+	productStreamService := service.NewProductStreamService(outboxRepository, roleBindingService, logger)
+	runWorker(func(ctx context.Context) { productStreamService.Run(ctx, 2*time.Second) })
+	productNotificationService := service.NewProductNotificationService(repository.NewProductNotificationRepository(dbPool, logger), 30*time.Second, logger)
+	productNotificationService.Subscribe(func(domain.ProductNotification) { productStreamService.Wake() })
+	runWorker(func(ctx context.Context) { productNotificationService.Run(ctx, time.Second) })
+	productRepository := repository.NewInstrumentedProductRepository(repository.NewProductRepository(dbPool, logger), metricsRegistry)
+	service.RegisterProductMetrics(metricsRegistry, productRepository, logger)
+	productService := service.NewTracedProductService(service.NewProductService(productRepository, roleBindingService, logger))
+	productController := controller.NewProductController(productService, logger)
+	batchController := controller.NewBatchController(productService, logger)
+	productStreamController := controller.NewProductStreamController(productStreamService, configurationManager.StreamKeepAlive, logger)
+	productChangeController := controller.NewProductChangeController(service.NewProductChangeService(repository.NewProductChangeRepository(dbPool, logger), roleBindingService), logger)
+	idempotencyService := service.NewIdempotencyService(repository.NewIdempotencyRepository(dbPool, logger), configurationManager.IdempotencyKeyTTL, logger)
+	runWorker(func(ctx context.Context) { idempotencyService.PurgeExpiredPeriodically(ctx, time.Hour) })
+	apiKeyService := service.NewApiKeyService(repository.NewApiKeyRepository(dbPool, logger))
+	apiKeyController := controller.NewApiKeyController(apiKeyService)
+	roleBindingController := controller.NewRoleBindingController(roleBindingService)
+	auditService := service.NewAuditService(repository.NewAuditRepository(dbPool, logger))
+	auditController := controller.NewAuditController(auditService)
+	webhookController := controller.NewWebhookController(webhookService)
+	metricsController := controller.NewMetricsController(metricsRegistry)
+	healthService := service.NewHealthService(repository.NewHealthRepository(dbPool), logger)
+	healthController := controller.NewHealthController(healthService)
+	docsController := controller.NewDocsController()
+	graphqlLimits := graphqlapi.Limits{MaxDepth: configurationManager.GraphqlMaxDepth, MaxComplexity: configurationManager.GraphqlMaxComplexity}
+	graphqlServer, err := graphqlapi.NewServer(productService, auditService, graphqlLimits, logger)
+	if err != nil {
+		return fmt.Errorf("failed to build graphql schema: %w", err)
+	}
+	graphqlController := controller.NewGraphqlController(graphqlServer)
+	apiDocument, err := openapi.Load(controller.OpenApiSpec)
+	if err != nil {
+		return err
+	}
+
+	if configurationManager.BootstrapAdminApiKey != "" {
+		if err := apiKeyService.EnsureBootstrapKey(configurationManager.BootstrapAdminApiKey); err != nil {
+			return fmt.Errorf("failed to create bootstrap admin api key: %w", err)
+		}
+	}
 
 	e := echo.New()
+	e.HideBanner = true
+	e.IPExtractor = middleware.ClientIpExtractor(configurationManager.TrustedProxies)
+	e.HTTPErrorHandler = middleware.ProblemErrorHandler(logger)
+	e.Use(middleware.Tracing())
+	e.Use(middleware.Metrics(metricsRegistry))
+	e.Use(middleware.RequestMetadata())
+	e.Use(middleware.AccessLog(logger))
+	e.Use(middleware.BodyLimit(configurationManager.MaxRequestBodyBytes))
+	var rateLimitService service.IRateLimitService
+	if configurationManager.RateLimitConfig.Enabled {
+		rateLimitRepository := repository.NewMemoryRateLimitRepository()
+		if configurationManager.RateLimitConfig.Backend == ratelimit.BackendPostgres {
+			rateLimitRepository = repository.NewPostgresRateLimitRepository(dbPool)
+		}
+		rateLimitService = service.NewRateLimitService(rateLimitRepository, configurationManager.RateLimitConfig, logger)
+		runWorker(func(ctx context.Context) { rateLimitService.PurgeIdlePeriodically(ctx, time.Hour) })
+		e.Use(middleware.IpRateLimit(rateLimitService))
+	}
+	e.Use(middleware.ApiKeyAuth(apiKeyService))
+	var jwtService service.IJwtService
+	if configurationManager.JwtConfig.Enabled() {
+		keySet, err := auth.LoadJsonWebKeySetFile(configurationManager.JwtConfig.JwksFile)
+		if err != nil {
+			return fmt.Errorf("failed to load jwks: %w", err)
+		}
+		jwtService = service.NewJwtService(keySet, configurationManager.JwtConfig)
+		e.Use(middleware.JwtAuth(jwtService))
+	}
+	if rateLimitService != nil {
+		e.Use(middleware.RateLimit(rateLimitService))
+	}
+	e.Use(middleware.RequestValidation(apiDocument))
+	e.Use(middleware.Idempotency(idempotencyService, logger))
 	productController.RegisterRoutes(e)
-	if err := e.Start("localhost:8080"); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	batchController.RegisterRoutes(e)
+	productStreamController.RegisterRoutes(e)
+	productChangeController.RegisterRoutes(e)
+	apiKeyController.RegisterRoutes(e)
+	roleBindingController.RegisterRoutes(e)
+	auditController.RegisterRoutes(e)
+	webhookController.RegisterRoutes(e)
+	metricsController.RegisterRoutes(e)
+	healthController.RegisterRoutes(e)
+	docsController.RegisterRoutes(e)
+	graphqlController.RegisterRoutes(e)
+
+	grpcServer := grpc.NewServer(grpcapi.ServerOptions(grpcapi.NewAuthenticator(apiKeyService, jwtService, logger), rateLimitService, metricsRegistry)...)
+	grpcapi.NewProductServer(productService, logger).Register(grpcServer)
+	grpcListener, err := net.Listen("tcp", configurationManager.GrpcAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen for gRPC: %w", err)
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- e.Start(configurationManager.HttpAddress)
+	}()
+	grpcServerErr := make(chan error, 1)
+	go func() {
+		logger.Info("gRPC server started", "address", grpcListener.Addr().String())
+		grpcServerErr <- grpcServer.Serve(grpcListener)
+	}()
+
+	select {
+	case err := <-serverErr:
+		grpcServer.Stop()
+		return fmt.Errorf("server failed: %w", err)
+	case err := <-grpcServerErr:
+		_ = e.Close()
+		return fmt.Errorf("gRPC server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutting down", "drain_delay", configurationManager.DrainDelay.String())
+	healthService.Drain()
+	time.Sleep(configurationManager.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), configurationManager.ShutdownTimeout)
+	defer cancel()
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		grpcServer.Stop()
+		return fmt.Errorf("failed to finish in-flight requests: %w", err)
+	}
+	select {
+	case <-grpcStopped:
+	case <-shutdownCtx.Done():
+		grpcServer.Stop()
+		return errors.New("failed to finish in-flight gRPC calls")
+	}
+	if err := <-serverErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	logger.Info("Server stopped")
+	return nil
+}
+
+// newEventPublisher combines the publishers named in config. The returned
+// function releases their files and connections.
+func newEventPublisher(config events.Config, webhookService service.IWebhookService) (events.EventPublisher, func(), error) {
+	var publishers []events.EventPublisher
+	var closers []func()
+	closeAll := func() {
+		for _, closer := range closers {
+			closer()
+		}
+	}
+
+	for _, name := range config.Publishers {
+		switch strings.TrimSpace(name) {
+		case events.PublisherStdout:
+			publishers = append(publishers, events.NewWriterPublisher(os.Stdout))
+		case events.PublisherFile:
+			filePublisher, err := events.NewFilePublisher(config.File)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			publishers = append(publishers, filePublisher)
+			closers = append(closers, func() { _ = filePublisher.Close() })
+		case events.PublisherWebhook:
+			publishers = append(publishers, webhookService)
+		case events.PublisherNats:
+			natsPublisher, err := events.NewNatsPublisher(config.NatsUrl, config.NatsSubjectPrefix)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			publishers = append(publishers, natsPublisher)
+			closers = append(closers, natsPublisher.Close)
+		default:
+			closeAll()
+			return nil, nil, fmt.Errorf("unknown event publisher %q", name)
+		}
+	}
+	if len(publishers) == 0 {
+		return nil, nil, errors.New("no event publisher configured")
 	}
+	return events.NewMultiPublisher(publishers...), closeAll, nil
 }
@@ -1,9 +1,50 @@
 package app
 
-import "github.com/erkindilekci/product-api/pkg/common/postgresql"
+import (
+	"github.com/erkindilekci/product-api/pkg/common/auth"
+	"github.com/erkindilekci/product-api/pkg/common/events"
+	"github.com/erkindilekci/product-api/pkg/common/logging"
+	"github.com/erkindilekci/product-api/pkg/common/postgresql"
+	"github.com/erkindilekci/product-api/pkg/common/ratelimit"
+	"github.com/erkindilekci/product-api/pkg/common/tracing"
+	"github.com/erkindilekci/product-api/pkg/common/webhook"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
 
 type ConfigurationManager struct {
 	PostgresqlConfig postgresql.Config
+	HttpAddress      string
+	GrpcAddress      string
+	// TrustedProxies are the networks of the reverse proxies whose
+	// X-Forwarded-For header is believed. Without any, the client address is
+	// that of the connection.
+	TrustedProxies      []*net.IPNet
+	IdempotencyKeyTTL   time.Duration
+	MaxRequestBodyBytes int64
+	// GraphqlMaxDepth and GraphqlMaxComplexity bound the queries accepted by
+	// the GraphQL endpoint.
+	GraphqlMaxDepth      int
+	GraphqlMaxComplexity int
+	BootstrapAdminApiKey string
+	JwtConfig            auth.JwtConfig
+	RateLimitConfig      ratelimit.Config
+	TracingConfig        tracing.Config
+	WebhookConfig        webhook.Config
+	EventsConfig         events.Config
+	// StreamKeepAlive is how often idle product streams send a keep-alive.
+	StreamKeepAlive time.Duration
+	LoggingConfig   logging.Config
+	// ShutdownTimeout bounds how long in-flight requests may take to finish
+	// once a shutdown signal was received.
+	ShutdownTimeout time.Duration
+	// DrainDelay is how long the instance keeps serving while reporting
+	// itself as not ready before it stops accepting requests.
+	DrainDelay time.Duration
 }
 
 func NewConfigurationManager() *ConfigurationManager {
@@ -15,6 +56,153 @@ func NewConfigurationManager() *ConfigurationManager {
 		DbName:                "productapp",
 		MaxConnections:        "10",
 		MaxConnectionIdleTime: "30s",
+		ConnectRetry: postgresql.RetryConfig{
+			MaxAttempts:    10,
+			InitialBackoff: 500 * time.Millisecond,
+			MaxBackoff:     10 * time.Second,
+		},
 	}
-	return &ConfigurationManager{postgresqlConfig}
+	jwtConfig := auth.JwtConfig{
+		JwksFile:  os.Getenv("PRODUCT_API_JWKS_FILE"),
+		Issuer:    os.Getenv("PRODUCT_API_JWT_ISSUER"),
+		Audience:  os.Getenv("PRODUCT_API_JWT_AUDIENCE"),
+		RoleClaim: "roles",
+		RoleScopes: map[string][]string{
+			"catalog-viewer":  {domain.ScopeProductsRead},
+			"catalog-editor":  {domain.ScopeProductsRead, domain.ScopeProductsWrite},
+			"catalog-manager": {domain.ScopeProductsRead, domain.ScopeProductsWrite, domain.ScopeProductsDelete},
+			"catalog-admin":   {domain.ScopeAdmin},
+		},
+	}
+	rateLimitConfig := ratelimit.Config{
+		Enabled: os.Getenv("PRODUCT_API_RATE_LIMIT_DISABLED") != "true",
+		Backend: getEnvOrDefault("PRODUCT_API_RATE_LIMIT_BACKEND", ratelimit.BackendMemory),
+		Default: getEnvRateLimitRuleOrDefault("PRODUCT_API_RATE_LIMIT_DEFAULT", ratelimit.Rule{Requests: 120, Period: time.Minute}),
+		PerIp:   getEnvRateLimitRuleOrDefault("PRODUCT_API_RATE_LIMIT_PER_IP", ratelimit.Rule{Requests: 600, Period: time.Minute}),
+		Routes: getEnvRateLimitRoutesOrDefault("PRODUCT_API_RATE_LIMIT_ROUTES", map[string]ratelimit.Rule{
+			"POST /api/v1/batch": {Requests: 20, Period: time.Minute},
+			"GET /api/v1/audit":  {Requests: 30, Period: time.Minute},
+			"GET /healthz":       {},
+			"GET /readyz":        {},
+		}),
+	}
+	tracingConfig := tracing.Config{
+		ServiceName: "product-api",
+		Exporter:    os.Getenv("PRODUCT_API_TRACE_EXPORTER"),
+		File:        getEnvOrDefault("PRODUCT_API_TRACE_FILE", "traces.jsonl"),
+	}
+	webhookConfig := webhook.Config{
+		MaxAttempts:    8,
+		InitialBackoff: 30 * time.Second,
+		MaxBackoff:     time.Hour,
+		Timeout:        10 * time.Second,
+		BatchSize:      50,
+
+		AllowPrivateNetworks: os.Getenv("PRODUCT_API_WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true",
+	}
+	eventsConfig := events.Config{
+		Publishers:        strings.Split(getEnvOrDefault("PRODUCT_API_EVENT_PUBLISHERS", events.PublisherWebhook), ","),
+		File:              getEnvOrDefault("PRODUCT_API_EVENT_FILE", "events.jsonl"),
+		NatsUrl:           getEnvOrDefault("PRODUCT_API_NATS_URL", "nats://localhost:4222"),
+		NatsSubjectPrefix: "product-api",
+		BatchSize:         50,
+		Timeout:           5 * time.Second,
+		InitialBackoff:    time.Second,
+		MaxBackoff:        time.Minute,
+		Retention:         7 * 24 * time.Hour,
+	}
+	loggingConfig := logging.Config{
+		Level:  getEnvOrDefault("PRODUCT_API_LOG_LEVEL", "info"),
+		Format: getEnvOrDefault("PRODUCT_API_LOG_FORMAT", logging.FormatJson),
+	}
+	return &ConfigurationManager{
+		PostgresqlConfig:     postgresqlConfig,
+		HttpAddress:          getEnvOrDefault("PRODUCT_API_HTTP_ADDRESS", "localhost:8080"),
+		GrpcAddress:          getEnvOrDefault("PRODUCT_API_GRPC_ADDRESS", "localhost:9090"),
+		TrustedProxies:       getEnvNetworks("PRODUCT_API_TRUSTED_PROXIES"),
+		IdempotencyKeyTTL:    24 * time.Hour,
+		MaxRequestBodyBytes:  1 << 20,
+		GraphqlMaxDepth:      10,
+		GraphqlMaxComplexity: 1000,
+		BootstrapAdminApiKey: os.Getenv("PRODUCT_API_BOOTSTRAP_ADMIN_KEY"),
+		JwtConfig:            jwtConfig,
+		RateLimitConfig:      rateLimitConfig,
+		TracingConfig:        tracingConfig,
+		WebhookConfig:        webhookConfig,
+		EventsConfig:         eventsConfig,
+		StreamKeepAlive:      15 * time.Second,
+		LoggingConfig:        loggingConfig,
+		ShutdownTimeout:      30 * time.Second,
+		DrainDelay:           getEnvDurationOrDefault("PRODUCT_API_DRAIN_DELAY", 0),
+	}
+}
+
+func getEnvOrDefault(key string, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("Ignoring invalid duration", "key", key, "value", value)
+		return defaultValue
+	}
+	return duration
+}
+
+// getEnvNetworks parses a comma separated list of CIDR networks, skipping
+// invalid ones.
+func getEnvNetworks(key string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			slog.Warn("Ignoring invalid network", "key", key, "value", value, "error", err)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func getEnvRateLimitRuleOrDefault(key string, defaultValue ratelimit.Rule) ratelimit.Rule {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	rule, err := ratelimit.ParseRule(value)
+	if err != nil {
+		slog.Warn("Ignoring invalid rate limit rule", "key", key, "value", value, "error", err)
+		return defaultValue
+	}
+	return rule
+}
+
+// getEnvRateLimitRoutesOrDefault adds the route rules of the environment
+// variable to defaultValue, replacing the defaults of the routes it names.
+func getEnvRateLimitRoutesOrDefault(key string, defaultValue map[string]ratelimit.Rule) map[string]ratelimit.Rule {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	routes, err := ratelimit.ParseRoutes(value)
+	if err != nil {
+		slog.Warn("Ignoring invalid rate limit routes", "key", key, "value", value, "error", err)
+		return defaultValue
+	}
+	for route, rule := range routes {
+		defaultValue[route] = rule
+	}
+	return defaultValue
 }
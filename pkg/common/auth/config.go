@@ -0,0 +1,14 @@
+package auth
+
+type JwtConfig struct {
+	JwksFile   string
+	Issuer     string
+	Audience   string
+	RoleClaim  string
+	RoleScopes map[string][]string
+}
+
+// Enabled reports whether bearer token authentication is configured.
+func (config JwtConfig) Enabled() bool {
+	return config.JwksFile != ""
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// JsonWebKey is a single verification key from a JSON Web Key Set. Key holds an
+// *rsa.PublicKey, *ecdsa.PublicKey or []byte depending on the key type.
+type JsonWebKey struct {
+	Id        string
+	Algorithm string
+	Key       interface{}
+}
+
+type JsonWebKeySet struct {
+	keys []JsonWebKey
+}
+
+type rawJsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func LoadJsonWebKeySetFile(path string) (*JsonWebKeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJsonWebKeySet(data)
+}
+
+func ParseJsonWebKeySet(data []byte) (*JsonWebKeySet, error) {
+	var document struct {
+		Keys []rawJsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("invalid jwks document: %w", err)
+	}
+
+	keySet := &JsonWebKeySet{}
+	for _, webKey := range document.Keys {
+		if webKey.Use != "" && webKey.Use != "sig" {
+			continue
+		}
+
+		key, err := parseKey(webKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwk %q: %w", webKey.Kid, err)
+		}
+		keySet.keys = append(keySet.keys, JsonWebKey{Id: webKey.Kid, Algorithm: webKey.Alg, Key: key})
+	}
+
+	if len(keySet.keys) == 0 {
+		return nil, errors.New("jwks contains no signing keys")
+	}
+
+	return keySet, nil
+}
+
+// Lookup returns the key with the given id. Tokens without a key id are only
+// accepted when the set holds exactly one key.
+func (keySet *JsonWebKeySet) Lookup(keyId string) (JsonWebKey, bool) {
+	if keyId == "" && len(keySet.keys) == 1 {
+		return keySet.keys[0], true
+	}
+	for _, key := range keySet.keys {
+		if key.Id == keyId {
+			return key, true
+		}
+	}
+	return JsonWebKey{}, false
+}
+
+func parseKey(webKey rawJsonWebKey) (interface{}, error) {
+	switch webKey.Kty {
+	case "RSA":
+		n, err := decodeBigInt(webKey.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(webKey.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		if webKey.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", webKey.Crv)
+		}
+		x, err := decodeBigInt(webKey.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(webKey.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(webKey.K)
+		if err != nil {
+			return nil, err
+		}
+		if len(secret) == 0 {
+			return nil, errors.New("empty symmetric key")
+		}
+		return secret, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", webKey.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) == 0 {
+		return nil, errors.New("missing key parameter")
+	}
+	return new(big.Int).SetBytes(decoded), nil
+}
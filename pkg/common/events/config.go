@@ -0,0 +1,42 @@
+package events
+
+import "time"
+
+const (
+	PublisherStdout  = "stdout"
+	PublisherFile    = "file"
+	PublisherWebhook = "webhook"
+	PublisherNats    = "nats"
+)
+
+type Config struct {
+	// Publishers lists the publishers every event is handed to, in order.
+	// An event counts as published once all of them accepted it.
+	Publishers []string
+	// File is where PublisherFile appends its events.
+	File string
+	// NatsUrl is the server PublisherNats connects to. Events are published
+	// on NatsSubjectPrefix followed by a dot and the event type.
+	NatsUrl           string
+	NatsSubjectPrefix string
+	// BatchSize is how many events are claimed per poll.
+	BatchSize int
+	// Timeout bounds a single publishing attempt. A claimed batch is leased
+	// for BatchSize times Timeout.
+	Timeout        time.Duration
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Retention is how long published events are kept before they are
+	// purged.
+	Retention time.Duration
+}
+
+// Backoff is the delay before the next attempt after attempts failed ones. It
+// doubles with every attempt, up to MaxBackoff.
+func (config Config) Backoff(attempts int) time.Duration {
+	backoff := config.InitialBackoff
+	for i := 1; i < attempts && backoff < config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, config.MaxBackoff)
+}
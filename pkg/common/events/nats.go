@@ -0,0 +1,41 @@
+package events
+
+import (
+	"context"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/nats-io/nats.go"
+)
+
+// NatsPublisher publishes events as NATS messages with the event id in the
+// Nats-Msg-Id header, which JetStream streams use to discard duplicates.
+type NatsPublisher struct {
+	connection    *nats.Conn
+	subjectPrefix string
+}
+
+// NewNatsPublisher connects to the NATS server at url. The connection
+// reconnects on its own; publishing fails while it is down.
+func NewNatsPublisher(url string, subjectPrefix string) (*NatsPublisher, error) {
+	connection, err := nats.Connect(url, nats.Name("product-api"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	return &NatsPublisher{connection, subjectPrefix}, nil
+}
+
+func (publisher *NatsPublisher) Publish(ctx context.Context, outboxEvent domain.OutboxEvent) error {
+	message := nats.NewMsg(publisher.subjectPrefix + "." + outboxEvent.EventType)
+	message.Header.Set(nats.MsgIdHdr, outboxEvent.EventId)
+	message.Data = outboxEvent.Payload
+	if err := publisher.connection.PublishMsg(message); err != nil {
+		return err
+	}
+	// The server answers the ping sent by a flush only after it processed
+	// the messages before it, so this returns once the event arrived.
+	return publisher.connection.FlushWithContext(ctx)
+}
+
+// Close flushes pending messages and closes the connection.
+func (publisher *NatsPublisher) Close() {
+	publisher.connection.Close()
+}
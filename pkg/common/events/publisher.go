@@ -0,0 +1,82 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"io"
+	"os"
+	"sync"
+)
+
+// EventPublisher hands an outbox event to its consumers. A nil error means
+// the event was accepted; otherwise it is published again later, so consumers
+// must tolerate duplicates, which they can recognise by the event id.
+type EventPublisher interface {
+	Publish(ctx context.Context, outboxEvent domain.OutboxEvent) error
+}
+
+// WriterPublisher writes every event's payload as one line of JSON.
+type WriterPublisher struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+func NewWriterPublisher(writer io.Writer) *WriterPublisher {
+	return &WriterPublisher{writer: writer}
+}
+
+func (publisher *WriterPublisher) Publish(ctx context.Context, outboxEvent domain.OutboxEvent) error {
+	var line bytes.Buffer
+	if err := json.Compact(&line, outboxEvent.Payload); err != nil {
+		return err
+	}
+	line.WriteByte('\n')
+
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+	_, err := publisher.writer.Write(line.Bytes())
+	return err
+}
+
+// FilePublisher appends the events to a file, one line each.
+type FilePublisher struct {
+	*WriterPublisher
+	file *os.File
+}
+
+// NewFilePublisher opens the file at path for appending, creating it if it
+// does not exist.
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FilePublisher{NewWriterPublisher(file), file}, nil
+}
+
+func (publisher *FilePublisher) Close() error {
+	return publisher.file.Close()
+}
+
+type multiPublisher []EventPublisher
+
+// NewMultiPublisher publishes every event to each of publishers in turn and
+// fails as soon as one of them does. The ones before it then receive the
+// event again on the next attempt.
+func NewMultiPublisher(publishers ...EventPublisher) EventPublisher {
+	if len(publishers) == 1 {
+		return publishers[0]
+	}
+	return multiPublisher(publishers)
+}
+
+func (publishers multiPublisher) Publish(ctx context.Context, outboxEvent domain.OutboxEvent) error {
+	for _, publisher := range publishers {
+		if err := publisher.Publish(ctx, outboxEvent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const (
+	FormatJson = "json"
+	FormatText = "text"
+)
+
+type Config struct {
+	// Level is one of debug, info, warn or error.
+	Level  string
+	Format string
+}
+
+// NewLogger creates a logger writing to w. Records logged with a context get
+// the request id and trace id of that context attached, so that all lines
+// belonging to one request can be correlated.
+func NewLogger(config Config, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", config.Level)
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(config.Format) {
+	case FormatJson:
+		handler = slog.NewJSONHandler(w, options)
+	case FormatText:
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return nil, fmt.Errorf("invalid log format %q", config.Format)
+	}
+
+	return slog.New(&contextHandler{handler}), nil
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (handler *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestId := domain.RequestMetadataFromContext(ctx).RequestId; requestId != "" {
+		record.AddAttrs(slog.String("request_id", requestId))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()), slog.String("span_id", spanContext.SpanID().String()))
+	}
+	return handler.Handler.Handle(ctx, record)
+}
+
+func (handler *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{handler.Handler.WithAttrs(attrs)}
+}
+
+func (handler *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{handler.Handler.WithGroup(name)}
+}
@@ -0,0 +1,264 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are latency buckets in seconds, suitable for HTTP requests
+// and database queries.
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type metric interface {
+	write(writer *bufio.Writer)
+}
+
+// Registry holds metrics and renders them in the Prometheus text format.
+// Collectors registered with OnCollect run before every scrape and can be used
+// to refresh gauges whose values live elsewhere, e.g. pool statistics.
+type Registry struct {
+	mutex      sync.Mutex
+	metrics    []metric
+	collectors []func()
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (registry *Registry) Counter(name string, help string, labelNames ...string) *CounterVec {
+	counter := &CounterVec{newValueVec(name, help, "counter", labelNames)}
+	registry.register(counter)
+	return counter
+}
+
+func (registry *Registry) Gauge(name string, help string, labelNames ...string) *GaugeVec {
+	gauge := &GaugeVec{newValueVec(name, help, "gauge", labelNames)}
+	registry.register(gauge)
+	return gauge
+}
+
+func (registry *Registry) Histogram(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	histogram := &HistogramVec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     map[string]*histogramSeries{},
+	}
+	registry.register(histogram)
+	return histogram
+}
+
+func (registry *Registry) OnCollect(collector func()) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.collectors = append(registry.collectors, collector)
+}
+
+// Write runs the collectors and writes all metrics in registration order.
+func (registry *Registry) Write(w io.Writer) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	for _, collector := range registry.collectors {
+		collector()
+	}
+
+	writer := bufio.NewWriter(w)
+	for _, metric := range registry.metrics {
+		metric.write(writer)
+	}
+	return writer.Flush()
+}
+
+func (registry *Registry) register(metric metric) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.metrics = append(registry.metrics, metric)
+}
+
+type valueSeries struct {
+	labelValues []string
+	value       float64
+}
+
+type valueVec struct {
+	mutex      sync.Mutex
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	series     map[string]*valueSeries
+}
+
+func newValueVec(name string, help string, kind string, labelNames []string) *valueVec {
+	return &valueVec{name: name, help: help, kind: kind, labelNames: labelNames, series: map[string]*valueSeries{}}
+}
+
+func (vec *valueVec) update(labelValues []string, update func(value float64) float64) {
+	vec.mutex.Lock()
+	defer vec.mutex.Unlock()
+
+	key := seriesKey(labelValues)
+	series, found := vec.series[key]
+	if !found {
+		series = &valueSeries{labelValues: labelValues}
+		vec.series[key] = series
+	}
+	series.value = update(series.value)
+}
+
+func (vec *valueVec) write(writer *bufio.Writer) {
+	vec.mutex.Lock()
+	defer vec.mutex.Unlock()
+
+	writeHeader(writer, vec.name, vec.help, vec.kind)
+	for _, key := range sortedKeys(vec.series) {
+		series := vec.series[key]
+		fmt.Fprintf(writer, "%s%s %s\n", vec.name, formatLabels(vec.labelNames, series.labelValues, "", ""), formatValue(series.value))
+	}
+}
+
+type CounterVec struct {
+	*valueVec
+}
+
+func (counter *CounterVec) Inc(labelValues ...string) {
+	counter.Add(1, labelValues...)
+}
+
+func (counter *CounterVec) Add(delta float64, labelValues ...string) {
+	counter.update(labelValues, func(value float64) float64 { return value + delta })
+}
+
+// Set overwrites the counter. It is meant for totals that are counted
+// elsewhere and copied in by a collector.
+func (counter *CounterVec) Set(value float64, labelValues ...string) {
+	counter.update(labelValues, func(float64) float64 { return value })
+}
+
+type GaugeVec struct {
+	*valueVec
+}
+
+func (gauge *GaugeVec) Set(value float64, labelValues ...string) {
+	gauge.update(labelValues, func(float64) float64 { return value })
+}
+
+// Reset removes all series, so that label values which no longer exist
+// disappear from the output.
+func (gauge *GaugeVec) Reset() {
+	gauge.mutex.Lock()
+	defer gauge.mutex.Unlock()
+	gauge.series = map[string]*valueSeries{}
+}
+
+type histogramSeries struct {
+	labelValues  []string
+	bucketCounts []uint64
+	count        uint64
+	sum          float64
+}
+
+type HistogramVec struct {
+	mutex      sync.Mutex
+	name       string
+	help       string
+	labelNames []string
+	buckets    []float64
+	series     map[string]*histogramSeries
+}
+
+func (histogram *HistogramVec) Observe(value float64, labelValues ...string) {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	key := seriesKey(labelValues)
+	series, found := histogram.series[key]
+	if !found {
+		series = &histogramSeries{labelValues: labelValues, bucketCounts: make([]uint64, len(histogram.buckets))}
+		histogram.series[key] = series
+	}
+
+	for i, upperBound := range histogram.buckets {
+		if value <= upperBound {
+			series.bucketCounts[i]++
+		}
+	}
+	series.count++
+	series.sum += value
+}
+
+func (histogram *HistogramVec) write(writer *bufio.Writer) {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+
+	writeHeader(writer, histogram.name, histogram.help, "histogram")
+	for _, key := range sortedKeys(histogram.series) {
+		series := histogram.series[key]
+		for i, upperBound := range histogram.buckets {
+			fmt.Fprintf(writer, "%s_bucket%s %d\n", histogram.name,
+				formatLabels(histogram.labelNames, series.labelValues, "le", formatValue(upperBound)), series.bucketCounts[i])
+		}
+		fmt.Fprintf(writer, "%s_bucket%s %d\n", histogram.name, formatLabels(histogram.labelNames, series.labelValues, "le", "+Inf"), series.count)
+		fmt.Fprintf(writer, "%s_sum%s %s\n", histogram.name, formatLabels(histogram.labelNames, series.labelValues, "", ""), formatValue(series.sum))
+		fmt.Fprintf(writer, "%s_count%s %d\n", histogram.name, formatLabels(histogram.labelNames, series.labelValues, "", ""), series.count)
+	}
+}
+
+func writeHeader(writer *bufio.Writer, name string, help string, kind string) {
+	fmt.Fprintf(writer, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(writer, "# TYPE %s %s\n", name, kind)
+}
+
+func formatLabels(labelNames []string, labelValues []string, extraName string, extraValue string) string {
+	var pairs []string
+	for i, labelName := range labelNames {
+		labelValue := ""
+		if i < len(labelValues) {
+			labelValue = labelValues[i]
+		}
+		pairs = append(pairs, labelName+`="`+escapeLabelValue(labelValue)+`"`)
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+extraValue+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func sortedKeys[T any](series map[string]T) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,138 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Document is the subset of an OpenAPI 3.1 document that is needed to
+// validate requests. Everything else in the document is ignored.
+type Document struct {
+	OpenApi    string              `json:"openapi"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+
+	// operations indexes the operations by method and echo route path, e.g.
+	// "GET /api/v1/products/:id".
+	operations map[string]*Operation
+}
+
+type PathItem map[string]*Operation
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type Operation struct {
+	OperationId string       `json:"operationId"`
+	Parameters  []Parameter  `json:"parameters"`
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+const (
+	InPath   = "path"
+	InQuery  = "query"
+	InHeader = "header"
+	InBody   = "body"
+)
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Format     string             `json:"format"`
+	Required   []string           `json:"required"`
+	Properties map[string]*Schema `json:"properties"`
+	// AdditionalProperties is either a boolean or a schema; only false is
+	// enforced.
+	AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	Items                *Schema         `json:"items"`
+	Enum                 []interface{}   `json:"enum"`
+	Minimum              *float64        `json:"minimum"`
+	Maximum              *float64        `json:"maximum"`
+	MinLength            *int            `json:"minLength"`
+	MaxLength            *int            `json:"maxLength"`
+	MinItems             *int            `json:"minItems"`
+	MaxItems             *int            `json:"maxItems"`
+}
+
+func (schema *Schema) closed() bool {
+	return string(schema.AdditionalProperties) == "false"
+}
+
+var pathTemplateParameter = regexp.MustCompile(`\{([^}]+)\}`)
+
+func Load(data []byte) (*Document, error) {
+	var document Document
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("unable to parse openapi document: %w", err)
+	}
+
+	document.operations = map[string]*Operation{}
+	for path, pathItem := range document.Paths {
+		route := pathTemplateParameter.ReplaceAllString(path, ":$1")
+		for method, operation := range pathItem {
+			document.operations[strings.ToUpper(method)+" "+route] = operation
+		}
+	}
+	return &document, nil
+}
+
+// FindOperation looks up the operation of an echo route, e.g. method GET and
+// route "/api/v1/products/:id".
+func (document *Document) FindOperation(method string, route string) (*Operation, bool) {
+	operation, found := document.operations[method+" "+route]
+	return operation, found
+}
+
+// Routes lists the operations of the document as "METHOD /echo/route".
+func (document *Document) Routes() []string {
+	var routes []string
+	for route := range document.operations {
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+func (document *Document) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = document.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+// AcceptsContentType reports whether the request body of operation may be
+// sent as mediaType.
+func (operation *Operation) AcceptsContentType(mediaType string) bool {
+	if operation.RequestBody == nil {
+		return false
+	}
+	_, found := operation.RequestBody.Content[mediaType]
+	return found
+}
+
+// JsonSchema returns the schema of the JSON request body of operation, or
+// nil if the operation does not take one.
+func (operation *Operation) JsonSchema() *Schema {
+	if operation.RequestBody == nil {
+		return nil
+	}
+	return operation.RequestBody.Content["application/json"].Schema
+}
@@ -0,0 +1,223 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError describes one value of a request that does not match the
+// document. Field is the parameter name, or a JSON pointer into the body.
+type FieldError struct {
+	In      string
+	Field   string
+	Message string
+}
+
+// ValidateParameter checks a path, query or header parameter. present tells
+// a missing parameter apart from an empty one.
+func (document *Document) ValidateParameter(parameter Parameter, value string, present bool) []FieldError {
+	if !present {
+		if parameter.Required {
+			return []FieldError{{parameter.In, parameter.Name, "is required"}}
+		}
+		return nil
+	}
+
+	schema := document.resolve(parameter.Schema)
+	if schema == nil {
+		return nil
+	}
+
+	var typedValue interface{} = value
+	switch schema.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return []FieldError{{parameter.In, parameter.Name, "must be " + describeType(schema.Type)}}
+		}
+		typedValue = json.Number(value)
+	case "boolean":
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return []FieldError{{parameter.In, parameter.Name, "must be " + describeType(schema.Type)}}
+		}
+		typedValue = parsed
+	}
+
+	validator := schemaValidator{document: document, in: parameter.In}
+	validator.validate(schema, typedValue, parameter.Name)
+	return validator.errors
+}
+
+// ValidateBody checks a JSON request body against the schema of operation.
+func (document *Document) ValidateBody(operation *Operation, body []byte) []FieldError {
+	schema := operation.JsonSchema()
+	if schema == nil {
+		return nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if operation.RequestBody.Required {
+			return []FieldError{{InBody, "", "is required"}}
+		}
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return []FieldError{{InBody, "", "must be valid JSON"}}
+	}
+
+	validator := schemaValidator{document: document, in: InBody}
+	validator.validate(schema, value, "")
+	return validator.errors
+}
+
+type schemaValidator struct {
+	document *Document
+	in       string
+	errors   []FieldError
+}
+
+func (validator *schemaValidator) fail(field string, format string, args ...interface{}) {
+	validator.errors = append(validator.errors, FieldError{validator.in, field, fmt.Sprintf(format, args...)})
+}
+
+// validate checks value, as decoded by encoding/json with UseNumber, and
+// records every mismatch instead of stopping at the first one. A JSON null
+// is accepted wherever a value is optional, like encoding/json does.
+func (validator *schemaValidator) validate(schema *Schema, value interface{}, field string) {
+	schema = validator.document.resolve(schema)
+	if schema == nil || value == nil {
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			validator.fail(field, "must be %s", describeType(schema.Type))
+			return
+		}
+		for _, name := range schema.Required {
+			if _, found := object[name]; !found {
+				validator.fail(pointer(field, name), "is required")
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, found := schema.Properties[name]
+			if !found {
+				if schema.closed() {
+					validator.fail(pointer(field, name), "is not a known field")
+				}
+				continue
+			}
+			validator.validate(property, object[name], pointer(field, name))
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			validator.fail(field, "must be %s", describeType(schema.Type))
+			return
+		}
+		if schema.MinItems != nil && len(array) < *schema.MinItems {
+			validator.fail(field, "must contain at least %d item(s)", *schema.MinItems)
+		}
+		if schema.MaxItems != nil && len(array) > *schema.MaxItems {
+			validator.fail(field, "must contain at most %d item(s)", *schema.MaxItems)
+		}
+		for i, item := range array {
+			validator.validate(schema.Items, item, pointer(field, strconv.Itoa(i)))
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			validator.fail(field, "must be %s", describeType(schema.Type))
+			return
+		}
+		length := len([]rune(text))
+		if schema.MinLength != nil && length < *schema.MinLength {
+			if *schema.MinLength == 1 {
+				validator.fail(field, "can't be empty")
+			} else {
+				validator.fail(field, "must be at least %d characters long", *schema.MinLength)
+			}
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			validator.fail(field, "must be at most %d characters long", *schema.MaxLength)
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, text); err != nil {
+				validator.fail(field, "must be an RFC 3339 timestamp")
+			}
+		}
+		validator.validateEnum(schema, text, field)
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			validator.fail(field, "must be %s", describeType(schema.Type))
+			return
+		}
+		if schema.Type == "integer" {
+			if _, err := number.Int64(); err != nil {
+				validator.fail(field, "must be %s", describeType(schema.Type))
+				return
+			}
+		}
+		numericValue, _ := number.Float64()
+		if schema.Minimum != nil && numericValue < *schema.Minimum {
+			validator.fail(field, "must be at least %s", formatNumber(*schema.Minimum))
+		}
+		if schema.Maximum != nil && numericValue > *schema.Maximum {
+			validator.fail(field, "must be at most %s", formatNumber(*schema.Maximum))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			validator.fail(field, "must be %s", describeType(schema.Type))
+		}
+	}
+}
+
+func (validator *schemaValidator) validateEnum(schema *Schema, value string, field string) {
+	if len(schema.Enum) == 0 {
+		return
+	}
+	var allowed []string
+	for _, candidate := range schema.Enum {
+		if candidate == value {
+			return
+		}
+		allowed = append(allowed, fmt.Sprint(candidate))
+	}
+	validator.fail(field, "must be one of %s", strings.Join(allowed, ", "))
+}
+
+// pointer appends name to the JSON pointer parent, escaping it as described
+// in RFC 6901.
+func pointer(parent string, name string) string {
+	name = strings.ReplaceAll(name, "~", "~0")
+	name = strings.ReplaceAll(name, "/", "~1")
+	return parent + "/" + name
+}
+
+func describeType(schemaType string) string {
+	switch schemaType {
+	case "object", "array", "integer":
+		return "an " + schemaType
+	}
+	return "a " + schemaType
+}
+
+func formatNumber(number float64) string {
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
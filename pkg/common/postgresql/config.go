@@ -1,5 +1,7 @@
 package postgresql
 
+import "time"
+
 type Config struct {
 	Host                  string
 	Port                  string
@@ -8,4 +10,14 @@ type Config struct {
 	DbName                string
 	MaxConnections        string
 	MaxConnectionIdleTime string
+	ConnectRetry          RetryConfig
+}
+
+// RetryConfig controls how often connecting is retried at startup. The delay
+// starts at InitialBackoff and doubles after every failed attempt up to
+// MaxBackoff. A zero MaxAttempts means a single attempt.
+type RetryConfig struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
 }
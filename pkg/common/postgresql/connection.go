@@ -4,10 +4,14 @@ import (
 	"context"
 	"fmt"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"github.com/labstack/gommon/log"
+	"log/slog"
+	"time"
 )
 
-func GetConnectionPool(context context.Context, config Config) *pgxpool.Pool {
+// GetConnectionPool connects to the database, retrying with exponential
+// backoff as configured in config.ConnectRetry. It gives up when all attempts
+// failed or ctx is cancelled.
+func GetConnectionPool(ctx context.Context, config Config, logger *slog.Logger) (*pgxpool.Pool, error) {
 	connString := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable statement_cache_mode=describe pool_max_conns=%s pool_max_conn_idle_time=%s",
 		config.Host,
 		config.Port,
@@ -18,16 +22,36 @@ func GetConnectionPool(context context.Context, config Config) *pgxpool.Pool {
 		config.MaxConnectionIdleTime,
 	)
 
-	connConfig, parseConfigErr := pgxpool.ParseConfig(connString)
-	if parseConfigErr != nil {
-		panic(parseConfigErr)
+	connConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database configuration: %w", err)
 	}
 
-	conn, err := pgxpool.ConnectConfig(context, connConfig)
-	if err != nil {
-		log.Error("Unable to connect to database: %v\n", err)
-		panic(err)
+	maxAttempts := config.ConnectRetry.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
 	}
+	backoff := config.ConnectRetry.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		pool, err := pgxpool.ConnectConfig(ctx, connConfig)
+		if err == nil {
+			return pool, nil
+		}
+		if attempt == maxAttempts {
+			return nil, fmt.Errorf("unable to connect to database %s at %s:%s after %d attempts: %w", config.DbName, config.Host, config.Port, attempt, err)
+		}
 
-	return conn
+		logger.Warn("Unable to connect to database, retrying", "attempt", attempt, "backoff", backoff.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("unable to connect to database: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if config.ConnectRetry.MaxBackoff > 0 && backoff > config.ConnectRetry.MaxBackoff {
+			backoff = config.ConnectRetry.MaxBackoff
+		}
+	}
 }
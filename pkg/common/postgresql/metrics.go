@@ -0,0 +1,29 @@
+package postgresql
+
+import (
+	"github.com/erkindilekci/product-api/pkg/common/metrics"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// RegisterPoolMetrics exposes the statistics of dbPool, refreshed on every
+// scrape.
+func RegisterPoolMetrics(registry *metrics.Registry, dbPool *pgxpool.Pool) {
+	acquiredConnections := registry.Gauge("pgxpool_acquired_connections", "Number of connections currently acquired from the pool.")
+	idleConnections := registry.Gauge("pgxpool_idle_connections", "Number of idle connections in the pool.")
+	totalConnections := registry.Gauge("pgxpool_total_connections", "Total number of connections in the pool.")
+	maxConnections := registry.Gauge("pgxpool_max_connections", "Maximum size of the pool.")
+	acquireCount := registry.Counter("pgxpool_acquire_total", "Number of successful connection acquisitions.")
+	emptyAcquireCount := registry.Counter("pgxpool_empty_acquire_total", "Number of acquisitions that had to wait for a connection.")
+	acquireWait := registry.Counter("pgxpool_acquire_wait_seconds_total", "Total time spent acquiring connections.")
+
+	registry.OnCollect(func() {
+		stat := dbPool.Stat()
+		acquiredConnections.Set(float64(stat.AcquiredConns()))
+		idleConnections.Set(float64(stat.IdleConns()))
+		totalConnections.Set(float64(stat.TotalConns()))
+		maxConnections.Set(float64(stat.MaxConns()))
+		acquireCount.Set(float64(stat.AcquireCount()))
+		emptyAcquireCount.Set(float64(stat.EmptyAcquireCount()))
+		acquireWait.Set(stat.AcquireDuration().Seconds())
+	})
+}
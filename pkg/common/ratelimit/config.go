@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	BackendMemory   = "memory"
+	BackendPostgres = "postgres"
+)
+
+// Rule allows Requests requests per Period, refilled continuously. The zero
+// Rule does not limit at all.
+type Rule struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (rule Rule) Unlimited() bool {
+	return rule.Requests == 0
+}
+
+// RefillPerSecond is the number of tokens added to a bucket every second.
+func (rule Rule) RefillPerSecond() float64 {
+	return float64(rule.Requests) / rule.Period.Seconds()
+}
+
+// ParseRule parses a rule written as requests/period, e.g. "120/1m". "0"
+// is the unlimited rule.
+func ParseRule(value string) (Rule, error) {
+	value = strings.TrimSpace(value)
+	if value == "0" {
+		return Rule{}, nil
+	}
+
+	requests, period, found := strings.Cut(value, "/")
+	if !found {
+		return Rule{}, fmt.Errorf("rate limit rule %q is not of the form requests/period", value)
+	}
+	rule := Rule{}
+	var err error
+	rule.Requests, err = strconv.Atoi(requests)
+	if err != nil || rule.Requests <= 0 {
+		return Rule{}, fmt.Errorf("rate limit rule %q must allow a positive number of requests", value)
+	}
+	rule.Period, err = time.ParseDuration(period)
+	if err != nil || rule.Period <= 0 {
+		return Rule{}, fmt.Errorf("rate limit rule %q must have a positive period", value)
+	}
+	return rule, nil
+}
+
+// ParseRoutes parses comma separated route rules, each written as the method
+// and route path, an equals sign and the rule, e.g.
+// "POST /api/v1/batch=20/1m,GET /healthz=0".
+func ParseRoutes(value string) (map[string]Rule, error) {
+	routes := map[string]Rule{}
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		route, ruleValue, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("rate limit route %q has no rule", entry)
+		}
+		rule, err := ParseRule(ruleValue)
+		if err != nil {
+			return nil, err
+		}
+		routes[strings.Join(strings.Fields(route), " ")] = rule
+	}
+	return routes, nil
+}
+
+type Config struct {
+	Enabled bool
+	Backend string
+	Default Rule
+	// PerIp limits all requests of an IP address together. It is checked
+	// before the request is authenticated, so that unauthenticated clients
+	// cannot flood the key and token checks.
+	PerIp Rule
+	// Routes overrides Default for individual routes, keyed by method and
+	// route path, e.g. "GET /api/v1/products".
+	Routes map[string]Rule
+}
+
+func (config Config) RuleFor(route string) Rule {
+	if rule, found := config.Routes[route]; found {
+		return rule
+	}
+	return config.Default
+}
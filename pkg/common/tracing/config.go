@@ -0,0 +1,19 @@
+package tracing
+
+const (
+	ExporterStdout   = "stdout"
+	ExporterOtlpFile = "otlp-file"
+)
+
+type Config struct {
+	ServiceName string
+	// Exporter is ExporterStdout, ExporterOtlpFile or empty to disable
+	// tracing.
+	Exporter string
+	// File is where ExporterOtlpFile appends its spans.
+	File string
+}
+
+func (config Config) Enabled() bool {
+	return config.Exporter != ""
+}
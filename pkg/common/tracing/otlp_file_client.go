@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"os"
+	"sync"
+)
+
+// otlpFileClient writes every batch of spans as one line of OTLP JSON
+// (a TracesData message), the format the OpenTelemetry collector's file
+// exporter and otlpjsonfile receiver use.
+type otlpFileClient struct {
+	mutex sync.Mutex
+	path  string
+	file  *os.File
+}
+
+func newOtlpFileClient(path string) otlptrace.Client {
+	return &otlpFileClient{path: path}
+}
+
+func (client *otlpFileClient) Start(ctx context.Context) error {
+	file, err := os.OpenFile(client.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	client.file = file
+	return nil
+}
+
+func (client *otlpFileClient) Stop(ctx context.Context) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.file.Close()
+}
+
+func (client *otlpFileClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	line, err := protojson.Marshal(&tracepb.TracesData{ResourceSpans: protoSpans})
+	if err != nil {
+		return err
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	_, err = client.file.Write(append(line, '\n'))
+	return err
+}
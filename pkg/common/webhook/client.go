@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+)
+
+// IsPublicAddress reports whether addr may be the target of a delivery:
+// loopback, private, link-local, multicast and unspecified addresses are not.
+func IsPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() && !addr.IsLoopback() && !addr.IsPrivate() && !addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() && !addr.IsInterfaceLocalMulticast() && !addr.IsMulticast() && !addr.IsUnspecified()
+}
+
+// NewHttpClient returns the client deliveries are sent with. Unless
+// config.AllowPrivateNetworks is set, it checks every address it connects to
+// after the host name was resolved, so that a name resolving to an internal
+// address is refused as well. Redirects are not followed, since they could
+// lead anywhere; the delivery fails with the redirect status instead.
+func NewHttpClient(config Config) *http.Client {
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	if !config.AllowPrivateNetworks {
+		dialer.Control = func(network string, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !IsPublicAddress(addrPort.Addr()) {
+				return fmt.Errorf("delivery to %s is not allowed", addrPort.Addr())
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would make the dialer check the proxy instead of the receiver.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   config.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
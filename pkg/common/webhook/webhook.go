@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Headers sent with every delivery. The signature covers the timestamp and
+// the body, so receivers can reject replayed deliveries by their age.
+const (
+	HeaderEventId   = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+
+	signaturePrefix = "sha256="
+)
+
+type Config struct {
+	// MaxAttempts is how often a delivery is tried before it is dead-lettered.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Timeout bounds a single attempt, including reading the response.
+	Timeout time.Duration
+	// BatchSize is how many due deliveries are claimed per poll.
+	BatchSize int
+	// AllowPrivateNetworks permits subscriptions and deliveries to loopback,
+	// private and link-local addresses, e.g. for receivers on a local network.
+	AllowPrivateNetworks bool
+}
+
+// Backoff is the delay before the next attempt after attempts failed ones. It
+// doubles with every attempt, up to MaxBackoff.
+func (config Config) Backoff(attempts int) time.Duration {
+	backoff := config.InitialBackoff
+	for i := 1; i < attempts && backoff < config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, config.MaxBackoff)
+}
+
+// Sign computes the X-Webhook-Signature header: the hex encoded HMAC-SHA256
+// of timestamp, a dot and body, keyed with the subscription secret.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature in constant time, as receivers should.
+func Verify(secret string, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
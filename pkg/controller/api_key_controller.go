@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"github.com/erkindilekci/product-api/pkg/controller/middleware"
+	"github.com/erkindilekci/product-api/pkg/controller/request"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+type ApiKeyController struct {
+	apiKeyService service.IApiKeyService
+}
+
+func NewApiKeyController(apiKeyService service.IApiKeyService) *ApiKeyController {
+	return &ApiKeyController{apiKeyService}
+}
+
+func (controller *ApiKeyController) RegisterRoutes(e *echo.Echo) {
+	admin := middleware.RequireScope(domain.ScopeAdmin)
+
+	e.GET("/api/v1/admin/api-keys", controller.GetAllApiKeys, admin)
+	e.POST("/api/v1/admin/api-keys", controller.CreateApiKey, admin)
+	e.POST("/api/v1/admin/api-keys/:id/rotate", controller.RotateApiKey, admin)
+	e.DELETE("/api/v1/admin/api-keys/:id", controller.RevokeApiKey, admin)
+}
+
+func (controller *ApiKeyController) GetAllApiKeys(c echo.Context) error {
+	return c.JSON(http.StatusOK, response.ToApiKeyResponseList(controller.apiKeyService.GetAll()))
+}
+
+func (controller *ApiKeyController) CreateApiKey(c echo.Context) error {
+	var createApiKeyRequest request.CreateApiKeyRequest
+	err := request.DecodeJson(c, &createApiKeyRequest)
+	if err != nil {
+		return writeDecodeError(c, err)
+	}
+
+	apiKey, token, err := controller.apiKeyService.Create(createApiKeyRequest.ToModel())
+	if err != nil {
+		return writeServiceProblem(c, err)
+	}
+
+	apiKeyResponse := response.ToApiKeyResponse(apiKey)
+	apiKeyResponse.Key = token
+	return c.JSON(http.StatusCreated, apiKeyResponse)
+}
+
+func (controller *ApiKeyController) RotateApiKey(c echo.Context) error {
+	apiKeyId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "api key id must be an integer")
+	}
+
+	apiKey, token, err := controller.apiKeyService.Rotate(int64(apiKeyId))
+	if err != nil {
+		return writeServiceProblem(c, err)
+	}
+
+	apiKeyResponse := response.ToApiKeyResponse(apiKey)
+	apiKeyResponse.Key = token
+	return c.JSON(http.StatusOK, apiKeyResponse)
+}
+
+func (controller *ApiKeyController) RevokeApiKey(c echo.Context) error {
+	apiKeyId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "api key id must be an integer")
+	}
+
+	err = controller.apiKeyService.Revoke(int64(apiKeyId))
+	if err != nil {
+		return writeServiceProblem(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
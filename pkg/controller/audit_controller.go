@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"encoding/csv"
+	"github.com/erkindilekci/product-api/pkg/controller/middleware"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const mimeTextCSV = "text/csv"
+
+var auditCsvHeader = []string{"id", "created_at", "action", "product_id", "actor", "request_id", "client_ip", "before", "after"}
+
+type AuditController struct {
+	auditService service.IAuditService
+}
+
+func NewAuditController(auditService service.IAuditService) *AuditController {
+	return &AuditController{auditService}
+}
+
+func (controller *AuditController) RegisterRoutes(e *echo.Echo) {
+	e.GET("/api/v1/audit", controller.GetAuditEvents, middleware.RequireScope(domain.ScopeAdmin))
+}
+
+// GetAuditEvents lists audit events filtered by product_id, actor and the
+// from/to time range (RFC 3339), oldest first or, with order=desc, newest
+// first. The result is exported as CSV when format=csv is given or the
+// client accepts text/csv.
+func (controller *AuditController) GetAuditEvents(c echo.Context) error {
+	var filter domain.AuditEventFilter
+	var err error
+
+	if productId := c.QueryParam("product_id"); productId != "" {
+		filter.ProductId, err = strconv.ParseInt(productId, 10, 64)
+		if err != nil {
+			return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "product_id must be an integer")
+		}
+	}
+	filter.Actor = c.QueryParam("actor")
+	if from := c.QueryParam("from"); from != "" {
+		filter.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "from must be an RFC 3339 timestamp")
+		}
+	}
+	if to := c.QueryParam("to"); to != "" {
+		filter.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "to must be an RFC 3339 timestamp")
+		}
+	}
+	if limit := c.QueryParam("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "limit must be an integer")
+		}
+	}
+
+	switch c.QueryParam("order") {
+	case "", "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "order must be asc or desc")
+	}
+
+	auditEvents, err := controller.auditService.GetEvents(c.Request().Context(), filter)
+	if err != nil {
+		return writeServiceProblem(c, err)
+	}
+
+	if c.QueryParam("format") == "csv" || strings.Contains(c.Request().Header.Get(echo.HeaderAccept), mimeTextCSV) {
+		return writeAuditCsv(c, auditEvents)
+	}
+
+	return c.JSON(http.StatusOK, response.ToAuditEventResponseList(auditEvents))
+}
+
+func writeAuditCsv(c echo.Context, auditEvents []domain.AuditEvent) error {
+	c.Response().Header().Set(echo.HeaderContentType, mimeTextCSV+"; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="audit.csv"`)
+	c.Response().WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(c.Response())
+	if err := writer.Write(auditCsvHeader); err != nil {
+		return err
+	}
+	for _, auditEvent := range auditEvents {
+		err := writer.Write([]string{
+			strconv.FormatInt(auditEvent.Id, 10),
+			auditEvent.CreatedAt.Format(time.RFC3339Nano),
+			auditEvent.Action,
+			strconv.FormatInt(auditEvent.ProductId, 10),
+			auditEvent.Actor,
+			auditEvent.RequestId,
+			auditEvent.ClientIp,
+			string(auditEvent.Before),
+			string(auditEvent.After),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/controller/middleware"
+	"github.com/erkindilekci/product-api/pkg/controller/request"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/labstack/echo/v4"
+	"log/slog"
+	"net/http"
+)
+
+const maxBatchOperations = 100
+
+const (
+	batchOpAdd         = "add"
+	batchOpUpdatePrice = "update_price"
+	batchOpDelete      = "delete"
+	batchOpGet         = "get"
+)
+
+var errBatchAborted = errors.New("batch aborted")
+
+var batchOperationScopes = map[string]string{
+	batchOpAdd:         domain.ScopeProductsWrite,
+	batchOpUpdatePrice: domain.ScopeProductsWrite,
+	batchOpDelete:      domain.ScopeProductsDelete,
+	batchOpGet:         domain.ScopeProductsRead,
+}
+
+type BatchController struct {
+	productService service.IProductService
+	logger         *slog.Logger
+}
+
+func NewBatchController(productService service.IProductService, logger *slog.Logger) *BatchController {
+	return &BatchController{productService, logger}
+}
+
+func (controller *BatchController) RegisterRoutes(e *echo.Echo) {
+	e.POST("/api/v1/batch", controller.ExecuteBatch, middleware.RequireScope(domain.ScopeProductsWrite))
+}
+
+// ExecuteBatch runs the operations in the given order. In atomic mode all of
+// them share one transaction and the first failure rolls back the whole batch;
+// otherwise every operation is applied independently.
+func (controller *BatchController) ExecuteBatch(c echo.Context) error {
+	var batchRequest request.BatchRequest
+	err := request.DecodeJson(c, &batchRequest)
+	if err != nil {
+		return writeDecodeError(c, err)
+	}
+
+	if len(batchRequest.Operations) == 0 {
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "no operations specified")
+	}
+	if len(batchRequest.Operations) > maxBatchOperations {
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, fmt.Sprintf("a batch can contain at most %d operations", maxBatchOperations))
+	}
+
+	principal, _ := middleware.GetPrincipal(c)
+	for _, operation := range batchRequest.Operations {
+		scope, known := batchOperationScopes[operation.Op]
+		if known && !principal.HasScope(scope) {
+			return response.WriteNewProblem(c, http.StatusForbidden, response.CodeForbidden, fmt.Sprintf("operation %q requires scope %s", operation.Op, scope))
+		}
+	}
+
+	if !batchRequest.Atomic {
+		results := make([]response.BatchOperationResponse, 0, len(batchRequest.Operations))
+		for _, operation := range batchRequest.Operations {
+			results = append(results, controller.executeOperation(c.Request().Context(), controller.productService, operation))
+		}
+		return c.JSON(http.StatusOK, response.BatchResponse{Results: results})
+	}
+
+	var results []response.BatchOperationResponse
+	err = controller.productService.WithTx(c.Request().Context(), func(txService service.IProductService) error {
+		results = make([]response.BatchOperationResponse, 0, len(batchRequest.Operations))
+		for _, operation := range batchRequest.Operations {
+			result := controller.executeOperation(c.Request().Context(), txService, operation)
+			results = append(results, result)
+			if result.Status >= http.StatusBadRequest {
+				return errBatchAborted
+			}
+		}
+		return nil
+	})
+
+	if errors.Is(err, errBatchAborted) {
+		// A batch that failed for an internal reason is not the client's
+		// fault and may succeed when sent again.
+		status := http.StatusUnprocessableEntity
+		if results[len(results)-1].Status >= http.StatusInternalServerError {
+			status = http.StatusInternalServerError
+		}
+		return c.JSON(status, response.BatchResponse{Results: abortBatchResults(results, len(batchRequest.Operations))})
+	}
+	if err != nil {
+		controller.logger.ErrorContext(c.Request().Context(), "atomic batch failed", "error", err)
+		return response.WriteNewProblem(c, http.StatusInternalServerError, response.CodeInternalError, "")
+	}
+
+	return c.JSON(http.StatusOK, response.BatchResponse{Results: results})
+}
+
+func (controller *BatchController) executeOperation(ctx context.Context, productService service.IProductService, operation request.BatchOperationRequest) response.BatchOperationResponse {
+	switch operation.Op {
+	case batchOpAdd:
+		if operation.Product == nil {
+			return batchError(http.StatusBadRequest, response.CodeInvalidRequest, "no product specified")
+		}
+		err := productService.Add(ctx, operation.Product.ToModel())
+		if err != nil {
+			return controller.serviceError(ctx, err)
+		}
+		return response.BatchOperationResponse{Status: http.StatusCreated}
+
+	case batchOpUpdatePrice:
+		if operation.Id == 0 && operation.ExternalId == nil {
+			return batchError(http.StatusBadRequest, response.CodeInvalidRequest, "no product id or external_id specified")
+		}
+		if operation.NewPrice == nil {
+			return batchError(http.StatusBadRequest, response.CodeInvalidRequest, "no new_price specified")
+		}
+		var err error
+		if operation.ExternalId != nil {
+			err = productService.UpdatePriceByExternalId(ctx, operation.ExternalId.ToModel(), *operation.NewPrice)
+		} else {
+			err = productService.UpdatePrice(ctx, operation.Id, *operation.NewPrice)
+		}
+		if err != nil {
+			return controller.serviceError(ctx, err)
+		}
+		return response.BatchOperationResponse{Status: http.StatusOK}
+
+	case batchOpDelete:
+		if operation.Id == 0 {
+			return batchError(http.StatusBadRequest, response.CodeInvalidRequest, "no product id specified")
+		}
+		err := productService.DeleteById(ctx, operation.Id)
+		if err != nil {
+			return controller.serviceError(ctx, err)
+		}
+		return response.BatchOperationResponse{Status: http.StatusOK}
+
+	case batchOpGet:
+		if operation.Id == 0 {
+			return batchError(http.StatusBadRequest, response.CodeInvalidRequest, "no product id specified")
+		}
+		product, err := productService.GetById(ctx, operation.Id)
+		if errors.Is(err, domain.ErrProductNotFound) {
+			return batchError(http.StatusNotFound, response.CodeNotFound, fmt.Sprintf("no product with ID %d", operation.Id))
+		}
+		if err != nil {
+			return controller.serviceError(ctx, err)
+		}
+		return response.BatchOperationResponse{Status: http.StatusOK, Body: response.ToProductResponse(product)}
+	}
+
+	return batchError(http.StatusBadRequest, response.CodeInvalidRequest, fmt.Sprintf("unknown operation %q", operation.Op))
+}
+
+// abortBatchResults marks every operation other than the failing one as not
+// applied, since the transaction they ran in was rolled back.
+func abortBatchResults(results []response.BatchOperationResponse, operationCount int) []response.BatchOperationResponse {
+	aborted := make([]response.BatchOperationResponse, operationCount)
+	for i := range aborted {
+		if i == len(results)-1 {
+			aborted[i] = results[i]
+			continue
+		}
+		aborted[i] = batchError(http.StatusFailedDependency, response.CodeOperationNotApplied, "batch was rolled back")
+	}
+	return aborted
+}
+
+func batchError(status int, code string, detail string) response.BatchOperationResponse {
+	return response.BatchOperationResponse{Status: status, Body: response.NewProblem(status, code, detail)}
+}
+
+// serviceError is the result of an operation the service rejected. Internal
+// errors are logged here, since their message is not reported.
+func (controller *BatchController) serviceError(ctx context.Context, err error) response.BatchOperationResponse {
+	problem := response.ServiceProblem(http.StatusInternalServerError, err)
+	if problem.Status == http.StatusInternalServerError {
+		controller.logger.ErrorContext(ctx, "batch operation failed", "error", err)
+	}
+	return response.BatchOperationResponse{Status: problem.Status, Body: problem}
+}
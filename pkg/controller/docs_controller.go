@@ -0,0 +1,36 @@
+package controller
+
+import (
+	_ "embed"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// OpenApiSpec is the OpenAPI 3.1 document of every route registered by the
+// controllers. It is maintained by hand and must be updated together with
+// the RegisterRoutes methods.
+//
+//go:embed static/openapi.json
+var OpenApiSpec []byte
+
+//go:embed static/docs.html
+var docsPage []byte
+
+type DocsController struct{}
+
+func NewDocsController() *DocsController {
+	return &DocsController{}
+}
+
+func (controller *DocsController) RegisterRoutes(e *echo.Echo) {
+	e.GET("/openapi.json", controller.GetOpenApiSpec)
+	e.GET("/docs", controller.GetDocs)
+}
+
+func (controller *DocsController) GetOpenApiSpec(c echo.Context) error {
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, OpenApiSpec)
+}
+
+func (controller *DocsController) GetDocs(c echo.Context) error {
+	return c.HTMLBlob(http.StatusOK, docsPage)
+}
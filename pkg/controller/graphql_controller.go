@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"github.com/erkindilekci/product-api/pkg/controller/middleware"
+	"github.com/erkindilekci/product-api/pkg/controller/request"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/graphqlapi"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type GraphqlController struct {
+	graphqlServer *graphqlapi.Server
+}
+
+func NewGraphqlController(graphqlServer *graphqlapi.Server) *GraphqlController {
+	return &GraphqlController{graphqlServer}
+}
+
+// RegisterRoutes requires products:read for every query; mutations check
+// their own scopes, like the operations of a batch.
+func (controller *GraphqlController) RegisterRoutes(e *echo.Echo) {
+	e.POST("/graphql", controller.Execute, middleware.RequireScope(domain.ScopeProductsRead))
+}
+
+// Execute answers with 200 whenever the query could be run, even if some
+// fields failed; their errors are listed in the errors of the result.
+func (controller *GraphqlController) Execute(c echo.Context) error {
+	var graphqlRequest request.GraphqlRequest
+	err := request.DecodeJson(c, &graphqlRequest)
+	if err != nil {
+		return writeDecodeError(c, err)
+	}
+	if graphqlRequest.Query == "" {
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "no query specified")
+	}
+
+	result := controller.graphqlServer.Execute(c.Request().Context(), graphqlRequest.Query, graphqlRequest.OperationName, graphqlRequest.Variables)
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"github.com/erkindilekci/product-api/pkg/controller/middleware"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type HealthController struct {
+	healthService service.IHealthService
+}
+
+func NewHealthController(healthService service.IHealthService) *HealthController {
+	return &HealthController{healthService}
+}
+
+func (controller *HealthController) RegisterRoutes(e *echo.Echo) {
+	e.GET("/healthz", controller.GetLiveness)
+	e.GET("/readyz", controller.GetReadiness)
+	e.POST("/api/v1/admin/drain", controller.Drain, middleware.RequireScope(domain.ScopeAdmin))
+}
+
+// GetLiveness reports that the process is running and able to serve
+// requests. It does not check dependencies, so a database outage does not get
+// the instance restarted.
+func (controller *HealthController) GetLiveness(c echo.Context) error {
+	return c.JSON(http.StatusOK, response.HealthResponse{Status: domain.HealthStatusUp})
+}
+
+func (controller *HealthController) GetReadiness(c echo.Context) error {
+	report := controller.healthService.Readiness(c.Request().Context())
+	if !report.Up() {
+		return c.JSON(http.StatusServiceUnavailable, response.ToHealthResponse(report))
+	}
+	return c.JSON(http.StatusOK, response.ToHealthResponse(report))
+}
+
+func (controller *HealthController) Drain(c echo.Context) error {
+	controller.healthService.Drain()
+	return c.NoContent(http.StatusAccepted)
+}
@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"github.com/erkindilekci/product-api/pkg/common/metrics"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+type MetricsController struct {
+	registry *metrics.Registry
+}
+
+func NewMetricsController(registry *metrics.Registry) *MetricsController {
+	return &MetricsController{registry}
+}
+
+func (controller *MetricsController) RegisterRoutes(e *echo.Echo) {
+	e.GET("/metrics", controller.GetMetrics)
+}
+
+func (controller *MetricsController) GetMetrics(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderContentType, metrics.ContentType)
+	c.Response().WriteHeader(http.StatusOK)
+	return controller.registry.Write(c.Response())
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"log/slog"
+	"time"
+)
+
+// AccessLog writes one line per request with its status and latency. It must
+// be registered after RequestMetadata so that the line carries the request id,
+// and before the authentication middlewares so that rejected requests are
+// logged too; the principal they store is read once the request completes.
+func AccessLog(logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			subject := ""
+			if principal, ok := GetPrincipal(c); ok {
+				subject = principal.Subject
+			}
+
+			status := c.Response().Status
+			level := slog.LevelInfo
+			if status >= 500 {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(c.Request().Context(), level, "request completed",
+				slog.String("method", c.Request().Method),
+				slog.String("path", c.Request().URL.Path),
+				slog.String("route", c.Path()),
+				slog.Int("status", status),
+				slog.Int64("bytes", c.Response().Size),
+				slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("client_ip", clientIp(c)),
+				slog.String("subject", subject),
+			)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+const (
+	HeaderApiKey = "X-API-Key"
+
+	bearerPrefix = "Bearer "
+)
+
+// ApiKeyAuth authenticates requests carrying an X-API-Key header and stores
+// the resulting principal in the context. Requests without a key pass through
+// unauthenticated; RequireScope decides whether a route needs one.
+func ApiKeyAuth(apiKeyService service.IApiKeyService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			token := c.Request().Header.Get(HeaderApiKey)
+			if token == "" {
+				return next(c)
+			}
+
+			principal, err := apiKeyService.Authenticate(token)
+			if err != nil {
+				return response.WriteNewProblem(c, http.StatusUnauthorized, response.CodeUnauthorized, "invalid api key")
+			}
+
+			setPrincipal(c, principal)
+			return next(c)
+		}
+	}
+}
+
+// JwtAuth authenticates requests carrying an "Authorization: Bearer" token.
+// Like ApiKeyAuth it lets requests without credentials through.
+func JwtAuth(jwtService service.IJwtService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			authorization := c.Request().Header.Get(echo.HeaderAuthorization)
+			if !strings.HasPrefix(authorization, bearerPrefix) {
+				return next(c)
+			}
+
+			principal, err := jwtService.Authenticate(strings.TrimPrefix(authorization, bearerPrefix))
+			if err != nil {
+				return response.WriteNewProblem(c, http.StatusUnauthorized, response.CodeUnauthorized, "invalid bearer token")
+			}
+
+			setPrincipal(c, principal)
+			return next(c)
+		}
+	}
+}
+
+// RequireScope rejects requests that are not authenticated with 401 and
+// requests whose principal lacks scope with 403.
+func RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, ok := GetPrincipal(c)
+			if !ok {
+				return response.WriteNewProblem(c, http.StatusUnauthorized, response.CodeUnauthorized, "authentication required")
+			}
+			if !principal.HasScope(scope) {
+				return response.WriteNewProblem(c, http.StatusForbidden, response.CodeForbidden, "missing scope "+scope)
+			}
+			return next(c)
+		}
+	}
+}
+
+func GetPrincipal(c echo.Context) (domain.Principal, bool) {
+	return domain.PrincipalFromContext(c.Request().Context())
+}
+
+// setPrincipal stores the principal in the request context so that it reaches
+// the service layer along with the context passed by the handlers.
+func setPrincipal(c echo.Context, principal domain.Principal) {
+	c.SetRequest(c.Request().WithContext(domain.ContextWithPrincipal(c.Request().Context(), principal)))
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+)
+
+// BodyLimit rejects request bodies larger than maxBytes with 413. The body is
+// buffered, so the middlewares and handlers after it can read it without
+// running into the limit halfway through.
+func BodyLimit(maxBytes int64) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tooLarge := func() error {
+				return response.WriteNewProblem(c, http.StatusRequestEntityTooLarge, response.CodePayloadTooLarge, fmt.Sprintf("request body must not be larger than %d bytes", maxBytes))
+			}
+			if c.Request().ContentLength > maxBytes {
+				return tooLarge()
+			}
+			if c.Request().Body == nil || c.Request().Body == http.NoBody {
+				return next(c)
+			}
+
+			requestBody, err := io.ReadAll(io.LimitReader(c.Request().Body, maxBytes+1))
+			if err != nil {
+				return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "unable to read request body")
+			}
+			if int64(len(requestBody)) > maxBytes {
+				return tooLarge()
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(requestBody))
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"net"
+)
+
+// ClientIpExtractor determines the client address that c.RealIP returns, on
+// which rate limits, access logs and audit events rely. Without trusted
+// proxies it is the address of the connection, since any client can send
+// X-Forwarded-For or X-Real-IP. Otherwise X-Forwarded-For is followed back
+// through the trusted proxies only.
+func ClientIpExtractor(trustedProxies []*net.IPNet) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	trustOptions := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, trustedProxy := range trustedProxies {
+		trustOptions = append(trustOptions, echo.TrustIPRange(trustedProxy))
+	}
+	return echo.ExtractIPFromXFFHeader(trustOptions...)
+}
+
+// clientIp returns the client address as determined by the IPExtractor of
+// the server, or the address of the connection if none is configured, so
+// that a client can never choose the address it is recorded or limited
+// under.
+func clientIp(c echo.Context) string {
+	if c.Echo().IPExtractor == nil {
+		return echo.ExtractIPDirect()(c.Request())
+	}
+	return c.RealIP()
+}
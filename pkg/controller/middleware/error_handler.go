@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/labstack/echo/v4"
+	"log/slog"
+	"net/http"
+)
+
+// ProblemErrorHandler replaces echo's default error handler, so that errors
+// raised by echo itself, such as unknown routes, and errors returned by
+// handlers are also reported as problem details.
+func ProblemErrorHandler(logger *slog.Logger) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+
+		var problem *response.Problem
+		var httpError *echo.HTTPError
+		switch {
+		case errors.Is(err, echo.ErrNotFound):
+			problem = response.NewProblem(http.StatusNotFound, response.CodeRouteNotFound, "no route matches "+c.Request().URL.Path)
+		case errors.Is(err, echo.ErrMethodNotAllowed):
+			problem = response.NewProblem(http.StatusMethodNotAllowed, response.CodeMethodNotAllowed, c.Request().Method+" is not allowed on "+c.Request().URL.Path)
+		case errors.As(err, &httpError) && httpError.Code < http.StatusInternalServerError:
+			problem = response.NewProblem(httpError.Code, response.CodeInvalidRequest, http.StatusText(httpError.Code))
+		default:
+			logger.ErrorContext(c.Request().Context(), "unhandled error", "error", err)
+			problem = response.NewProblem(http.StatusInternalServerError, response.CodeInternalError, "")
+		}
+
+		if c.Request().Method == http.MethodHead {
+			err = c.NoContent(problem.Status)
+		} else {
+			err = response.WriteProblem(c, problem)
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request().Context(), "unable to write error response", "error", err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/labstack/echo/v4"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe to
+// retry. The first response for a key is stored and replayed for later
+// requests of the same caller with the same key and body; reusing the key with
+// a different body is rejected with 422.
+func Idempotency(idempotencyService service.IIdempotencyService, logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(HeaderIdempotencyKey)
+			if c.Request().Method != http.MethodPost || key == "" {
+				return next(c)
+			}
+
+			requestBody, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "unable to read request body")
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(requestBody))
+
+			principal, _ := domain.PrincipalFromContext(c.Request().Context())
+			record, replay, err := idempotencyService.Begin(principal.Subject, key, hashRequest(c.Request().Method, c.Request().URL.Path, requestBody))
+			switch {
+			case errors.Is(err, service.ErrInvalidIdempotencyKey):
+				return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeIdempotencyKeyInvalid, err.Error())
+			case errors.Is(err, service.ErrIdempotencyKeyReused):
+				return response.WriteNewProblem(c, http.StatusUnprocessableEntity, response.CodeIdempotencyKeyReused, err.Error())
+			case errors.Is(err, service.ErrIdempotencyKeyInProgress):
+				return response.WriteNewProblem(c, http.StatusConflict, response.CodeIdempotencyKeyInProgress, err.Error())
+			case err != nil:
+				return response.WriteNewProblem(c, http.StatusInternalServerError, response.CodeInternalError, "unable to process idempotency key")
+			}
+
+			if replay {
+				c.Response().Header().Set(HeaderIdempotentReplayed, "true")
+				if record.ContentType != "" {
+					c.Response().Header().Set(echo.HeaderContentType, record.ContentType)
+				}
+				c.Response().WriteHeader(record.StatusCode)
+				_, err = c.Response().Write(record.ResponseBody)
+				return err
+			}
+
+			recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+
+			err = next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			status := c.Response().Status
+			if !isReplayableStatus(status) {
+				if releaseErr := idempotencyService.Release(principal.Subject, key); releaseErr != nil {
+					logger.ErrorContext(c.Request().Context(), "error while releasing idempotency key", "error", releaseErr)
+				}
+				return nil
+			}
+
+			contentType := c.Response().Header().Get(echo.HeaderContentType)
+			if completeErr := idempotencyService.Complete(principal.Subject, key, status, contentType, recorder.body.Bytes()); completeErr != nil {
+				logger.ErrorContext(c.Request().Context(), "error while storing idempotent response", "error", completeErr)
+			}
+			return nil
+		}
+	}
+}
+
+// isReplayableStatus reports whether a response reflects the outcome of the
+// request itself. Server errors and authentication failures are not stored so
+// that the client can retry with the same key.
+func isReplayableStatus(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return false
+	}
+	return status < http.StatusInternalServerError
+}
+
+func hashRequest(method string, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder keeps a copy of everything written to the client so the
+// response can be stored for replay.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (recorder *responseRecorder) Write(b []byte) (int, error) {
+	recorder.body.Write(b)
+	return recorder.ResponseWriter.Write(b)
+}
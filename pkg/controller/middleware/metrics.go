@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/erkindilekci/product-api/pkg/common/metrics"
+	"github.com/labstack/echo/v4"
+	"strconv"
+	"time"
+)
+
+// Metrics counts requests and records their latency per route and status. It
+// should be the first middleware so that rejected requests are counted too.
+func Metrics(registry *metrics.Registry) echo.MiddlewareFunc {
+	requestCount := registry.Counter("http_requests_total", "Number of HTTP requests.", "method", "route", "status")
+	requestDuration := registry.Histogram("http_request_duration_seconds", "Duration of HTTP requests.", metrics.DefaultBuckets, "method", "route", "status")
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			status := strconv.Itoa(c.Response().Status)
+			requestCount.Inc(c.Request().Method, route, status)
+			requestDuration.Observe(time.Since(start).Seconds(), c.Request().Method, route, status)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+)
+
+// IpRateLimit limits requests per IP address across all routes. It must be
+// registered before the authentication middlewares, so that it also holds
+// back clients sending invalid credentials.
+func IpRateLimit(rateLimitService service.IRateLimitService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			decision := rateLimitService.AllowIp(c.Request().Method+" "+c.Path(), clientIp(c))
+			if !decision.Allowed {
+				return writeRateLimited(c, decision)
+			}
+			return next(c)
+		}
+	}
+}
+
+// RateLimit limits requests per client and route. Authenticated clients are
+// identified by their principal, anonymous ones by their IP address. It must
+// be registered after the authentication middlewares.
+func RateLimit(rateLimitService service.IRateLimitService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			decision := rateLimitService.Allow(c.Request().Method+" "+c.Path(), rateLimitClient(c))
+			if decision.Limit == 0 {
+				// unlimited route, e.g. health probes
+				return next(c)
+			}
+			if !decision.Allowed {
+				return writeRateLimited(c, decision)
+			}
+
+			setRateLimitHeaders(c, decision)
+			return next(c)
+		}
+	}
+}
+
+func writeRateLimited(c echo.Context, decision domain.RateLimitDecision) error {
+	setRateLimitHeaders(c, decision)
+	c.Response().Header().Set(HeaderRetryAfter, formatSeconds(decision.RetryAfter))
+	return response.WriteNewProblem(c, http.StatusTooManyRequests, response.CodeRateLimited, "rate limit exceeded")
+}
+
+func setRateLimitHeaders(c echo.Context, decision domain.RateLimitDecision) {
+	header := c.Response().Header()
+	header.Set(HeaderRateLimitLimit, strconv.Itoa(decision.Limit))
+	header.Set(HeaderRateLimitRemaining, strconv.Itoa(decision.Remaining))
+	header.Set(HeaderRateLimitReset, formatSeconds(decision.ResetAfter))
+}
+
+func rateLimitClient(c echo.Context) string {
+	if principal, ok := GetPrincipal(c); ok {
+		return principal.Subject
+	}
+	return "ip:" + clientIp(c)
+}
+
+func formatSeconds(duration time.Duration) string {
+	return strconv.Itoa(int(duration / time.Second))
+}
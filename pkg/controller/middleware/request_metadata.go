@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/labstack/echo/v4"
+)
+
+const maxRequestIdLength = 128
+
+// RequestMetadata exposes the request id and client IP of the request to the
+// service layer through the request context. The id is taken from the
+// X-Request-ID header or generated when the header is missing or malformed,
+// and is echoed back in the response. The client IP is that of the server's
+// IPExtractor, so a client cannot forge it with X-Forwarded-For.
+func RequestMetadata() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			requestId := c.Request().Header.Get(echo.HeaderXRequestID)
+			if !isValidRequestId(requestId) {
+				requestId = generateRequestId()
+			}
+			c.Response().Header().Set(echo.HeaderXRequestID, requestId)
+
+			requestMetadata := domain.RequestMetadata{
+				RequestId: requestId,
+				ClientIp:  clientIp(c),
+			}
+			c.SetRequest(c.Request().WithContext(domain.ContextWithRequestMetadata(c.Request().Context(), requestMetadata)))
+			return next(c)
+		}
+	}
+}
+
+// isValidRequestId accepts ids of printable ASCII characters only, so that a
+// client cannot inject line breaks or control characters into the logs.
+func isValidRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > maxRequestIdLength {
+		return false
+	}
+	for i := 0; i < len(requestId); i++ {
+		if requestId[i] < 0x21 || requestId[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func generateRequestId() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
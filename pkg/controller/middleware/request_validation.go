@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"bytes"
+	"github.com/erkindilekci/product-api/pkg/common/openapi"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/labstack/echo/v4"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// RequestValidation rejects requests whose parameters or JSON body do not
+// match the OpenAPI document with 400 and the list of offending fields.
+// Routes missing from the document are passed through unchecked.
+func RequestValidation(document *openapi.Document) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			operation, found := document.FindOperation(c.Request().Method, c.Path())
+			if !found {
+				return next(c)
+			}
+
+			var fieldErrors []openapi.FieldError
+			for _, parameter := range operation.Parameters {
+				value, present := parameterValue(c, parameter)
+				fieldErrors = append(fieldErrors, document.ValidateParameter(parameter, value, present)...)
+			}
+
+			if operation.RequestBody != nil {
+				requestBody, err := io.ReadAll(c.Request().Body)
+				if err != nil {
+					return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "unable to read request body")
+				}
+				c.Request().Body = io.NopCloser(bytes.NewReader(requestBody))
+
+				mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+				if len(requestBody) > 0 && !operation.AcceptsContentType(mediaType) {
+					return response.WriteNewProblem(c, http.StatusUnsupportedMediaType, response.CodeUnsupportedMediaType, "content type must be application/json")
+				}
+				fieldErrors = append(fieldErrors, document.ValidateBody(operation, requestBody)...)
+			}
+
+			if len(fieldErrors) > 0 {
+				return response.WriteProblem(c, response.NewSpecificationProblem(fieldErrors))
+			}
+			return next(c)
+		}
+	}
+}
+
+func parameterValue(c echo.Context, parameter openapi.Parameter) (string, bool) {
+	switch parameter.In {
+	case openapi.InPath:
+		value := c.Param(parameter.Name)
+		return value, value != ""
+	case openapi.InQuery:
+		values, present := c.QueryParams()[parameter.Name]
+		if !present || len(values) == 0 {
+			return "", false
+		}
+		return values[0], true
+	case openapi.InHeader:
+		values := c.Request().Header.Values(parameter.Name)
+		if len(values) == 0 {
+			return "", false
+		}
+		return values[0], true
+	}
+	return "", false
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+var tracer = otel.Tracer("github.com/erkindilekci/product-api/pkg/controller")
+
+// Tracing starts a server span for every request, continuing the trace given
+// in the W3C traceparent header if there is one. The span is stored in the
+// request context so that service and repository spans become its children.
+func Tracing() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			request := c.Request()
+			ctx := otel.GetTextMapPropagator().Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			ctx, span := tracer.Start(ctx, request.Method+" "+route, trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attribute.String("http.request.method", request.Method), attribute.String("http.route", route)))
+			defer span.End()
+
+			c.SetRequest(request.WithContext(ctx))
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			status := c.Response().Status
+			span.SetAttributes(attribute.Int("http.response.status_code", status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+			return nil
+		}
+	}
+}
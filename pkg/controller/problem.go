@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"errors"
+	"github.com/erkindilekci/product-api/pkg/common/openapi"
+	"github.com/erkindilekci/product-api/pkg/controller/request"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/labstack/echo/v4"
+	"net/http"
+)
+
+// writeDecodeError responds to a request body that request.DecodeJson
+// rejected.
+func writeDecodeError(c echo.Context, err error) error {
+	var fieldError *request.FieldError
+	switch {
+	case errors.Is(err, request.ErrUnsupportedMediaType):
+		return response.WriteNewProblem(c, http.StatusUnsupportedMediaType, response.CodeUnsupportedMediaType, err.Error())
+	case errors.As(err, &fieldError):
+		problem := response.NewProblem(http.StatusBadRequest, response.CodeValidationFailed, fieldError.Error())
+		problem.Errors = []response.ProblemFieldError{{In: openapi.InBody, Pointer: fieldError.Pointer, Detail: fieldError.Message}}
+		return response.WriteProblem(c, problem)
+	}
+	return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, err.Error())
+}
+
+// writeServiceProblem responds to an error of a service. Errors without a
+// known cause are handed to the error handler, which logs them and answers
+// 500 without their message.
+func writeServiceProblem(c echo.Context, err error) error {
+	problem := response.ServiceProblem(http.StatusInternalServerError, err)
+	if problem.Status == http.StatusInternalServerError {
+		return err
+	}
+	return response.WriteProblem(c, problem)
+}
@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"github.com/erkindilekci/product-api/pkg/controller/middleware"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/labstack/echo/v4"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+type ProductChangeController struct {
+	productChangeService service.IProductChangeService
+	logger               *slog.Logger
+}
+
+func NewProductChangeController(productChangeService service.IProductChangeService, logger *slog.Logger) *ProductChangeController {
+	return &ProductChangeController{productChangeService, logger}
+}
+
+func (controller *ProductChangeController) RegisterRoutes(e *echo.Echo) {
+	e.GET("/api/v1/changes", controller.GetChanges, middleware.RequireScope(domain.ScopeProductsRead))
+}
+
+// GetChanges returns the product changes after the sequence given as since,
+// so that clients can keep a copy of the catalog without downloading it
+// again.
+func (controller *ProductChangeController) GetChanges(c echo.Context) error {
+	var since int64
+	limit := service.DefaultProductChangeLimit
+	var err error
+
+	if sinceParam := c.QueryParam("since"); sinceParam != "" {
+		since, err = strconv.ParseInt(sinceParam, 10, 64)
+		if err != nil {
+			return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "since must be an integer")
+		}
+	}
+	if limitParam := c.QueryParam("limit"); limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil {
+			return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "limit must be an integer")
+		}
+	}
+
+	page, err := controller.productChangeService.GetChanges(c.Request().Context(), since, limit)
+	if err != nil {
+		problem := response.ServiceProblem(http.StatusInternalServerError, err)
+		if problem.Status == http.StatusInternalServerError {
+			controller.logger.ErrorContext(c.Request().Context(), "failed to get product changes", "error", err)
+		}
+		return response.WriteProblem(c, problem)
+	}
+
+	return c.JSON(http.StatusOK, response.ToProductChangePageResponse(page))
+}
@@ -1,40 +1,55 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
+	"github.com/erkindilekci/product-api/pkg/controller/middleware"
 	"github.com/erkindilekci/product-api/pkg/controller/request"
 	"github.com/erkindilekci/product-api/pkg/controller/response"
 	"github.com/erkindilekci/product-api/pkg/domain"
 	"github.com/erkindilekci/product-api/pkg/service"
 	"github.com/labstack/echo/v4"
+	"log/slog"
 	"net/http"
 	"strconv"
 )
 
 type ProductController struct {
 	productService service.IProductService
+	logger         *slog.Logger
 }
 
-func NewProductController(productService service.IProductService) *ProductController {
-	return &ProductController{productService}
+func NewProductController(productService service.IProductService, logger *slog.Logger) *ProductController {
+	return &ProductController{productService, logger}
 }
 
 func (controller *ProductController) RegisterRoutes(e *echo.Echo) {
-	e.GET("/api/v1/products", controller.GetAllProducts)
-	e.GET("/api/v1/products/:id", controller.GetProductById)
-	e.POST("/api/v1/products", controller.AddNewProduct)
-	e.PUT("/api/v1/products/:id", controller.UpdatePriceById)
-	e.DELETE("/api/v1/products/:id", controller.DeleteProductById)
+	read := middleware.RequireScope(domain.ScopeProductsRead)
+	write := middleware.RequireScope(domain.ScopeProductsWrite)
+	remove := middleware.RequireScope(domain.ScopeProductsDelete)
+
+	e.GET("/api/v1/products", controller.GetAllProducts, read)
+	e.GET("/api/v1/products/:id", controller.GetProductById, read)
+	e.GET("/api/v1/products/by-external/:system/:externalId", controller.GetProductByExternalId, read)
+	e.POST("/api/v1/products", controller.AddNewProduct, write)
+	e.PUT("/api/v1/products/:id", controller.UpdatePriceById, write)
+	e.PUT("/api/v1/products/by-external/:system/:externalId", controller.UpdatePriceByExternalId, write)
+	e.DELETE("/api/v1/products/:id", controller.DeleteProductById, remove)
+	e.PUT("/api/v1/stores/:store/products/:sku", controller.UpsertProductBySku, write)
 }
 
 func (controller *ProductController) GetAllProducts(c echo.Context) error {
 	store := c.QueryParam("store")
 	var products []domain.Product
+	var err error
 
 	if len(store) == 0 {
-		products = controller.productService.GetAllProducts()
+		products, err = controller.productService.GetAllProducts(c.Request().Context())
 	} else {
-		products = controller.productService.GetProductsByStore(store)
+		products, err = controller.productService.GetProductsByStore(c.Request().Context(), store)
+	}
+	if err != nil {
+		return controller.writeServiceError(c, "list", err)
 	}
 
 	return c.JSON(http.StatusOK, response.ToProductResponseList(products))
@@ -43,17 +58,37 @@ func (controller *ProductController) GetAllProducts(c echo.Context) error {
 func (controller *ProductController) GetProductById(c echo.Context) error {
 	param := c.Param("id")
 	if param == "" {
-		return c.JSON(http.StatusBadRequest, response.NewErrorResponse("Invalid request: no product id specified"))
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "no product id specified")
 	}
 
 	productId, err := strconv.Atoi(param)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, response.NewErrorResponse("Invalid request: product id must be an integer"))
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "product id must be an integer")
+	}
+
+	product, err := controller.productService.GetById(c.Request().Context(), int64(productId))
+	if errors.Is(err, domain.ErrProductNotFound) {
+		return response.WriteNewProblem(c, http.StatusNotFound, response.CodeNotFound, fmt.Sprintf("no product with ID %d", productId))
+	}
+	if err != nil {
+		return controller.writeServiceError(c, "get", err)
+	}
+
+	return c.JSON(http.StatusOK, response.ToProductResponse(product))
+}
+
+func (controller *ProductController) GetProductByExternalId(c echo.Context) error {
+	externalId := domain.ExternalId{System: c.Param("system"), Id: c.Param("externalId")}
+	if externalId.System == "" || externalId.Id == "" {
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "external system and id must be specified")
 	}
 
-	product, err := controller.productService.GetById(int64(productId))
+	product, err := controller.productService.GetByExternalId(c.Request().Context(), externalId)
+	if errors.Is(err, domain.ErrProductNotFound) {
+		return response.WriteNewProblem(c, http.StatusNotFound, response.CodeNotFound, fmt.Sprintf("no product with external ID %s/%s", externalId.System, externalId.Id))
+	}
 	if err != nil {
-		return c.JSON(http.StatusNotFound, response.NewErrorResponse(fmt.Sprintf("Product not found: no product with ID %d", productId)))
+		return controller.writeServiceError(c, "get by external id", err)
 	}
 
 	return c.JSON(http.StatusOK, response.ToProductResponse(product))
@@ -61,14 +96,14 @@ func (controller *ProductController) GetProductById(c echo.Context) error {
 
 func (controller *ProductController) AddNewProduct(c echo.Context) error {
 	var addProductRequest request.AddProductRequest
-	err := c.Bind(&addProductRequest)
+	err := request.DecodeJson(c, &addProductRequest)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, response.NewErrorResponse("Invalid request: unable to bind the provided data to the product structure"))
+		return writeDecodeError(c, err)
 	}
 
-	err = controller.productService.Add(addProductRequest.ToModel())
+	err = controller.productService.Add(c.Request().Context(), addProductRequest.ToModel())
 	if err != nil {
-		return c.JSON(http.StatusUnprocessableEntity, response.NewErrorResponse(err.Error()))
+		return controller.writeServiceError(c, "add", err)
 	}
 
 	return c.NoContent(http.StatusCreated)
@@ -77,27 +112,51 @@ func (controller *ProductController) AddNewProduct(c echo.Context) error {
 func (controller *ProductController) UpdatePriceById(c echo.Context) error {
 	param := c.Param("id")
 	if param == "" {
-		return c.JSON(http.StatusBadRequest, response.NewErrorResponse("Invalid request: no product id specified"))
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "no product id specified")
 	}
 
 	productId, err := strconv.Atoi(param)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, response.NewErrorResponse("Invalid request: product id must be an integer"))
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "product id must be an integer")
 	}
 
 	newPrice := c.QueryParam("newPrice")
 	if len(newPrice) == 0 {
-		return c.JSON(http.StatusBadRequest, response.NewErrorResponse("Invalid request: no newPrice query parameter found"))
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "no newPrice query parameter found")
 	}
 
 	priceFloat, err := strconv.ParseFloat(newPrice, 64)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, response.NewErrorResponse("Invalid request: newPrice must be a float"))
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "newPrice must be a float")
 	}
 
-	err = controller.productService.UpdatePrice(int64(productId), float32(priceFloat))
+	err = controller.productService.UpdatePrice(c.Request().Context(), int64(productId), float32(priceFloat))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(err.Error()))
+		return controller.writeServiceError(c, "update price", err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (controller *ProductController) UpdatePriceByExternalId(c echo.Context) error {
+	externalId := domain.ExternalId{System: c.Param("system"), Id: c.Param("externalId")}
+	if externalId.System == "" || externalId.Id == "" {
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "external system and id must be specified")
+	}
+
+	newPrice := c.QueryParam("newPrice")
+	if len(newPrice) == 0 {
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "no newPrice query parameter found")
+	}
+
+	priceFloat, err := strconv.ParseFloat(newPrice, 64)
+	if err != nil {
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "newPrice must be a float")
+	}
+
+	err = controller.productService.UpdatePriceByExternalId(c.Request().Context(), externalId, float32(priceFloat))
+	if err != nil {
+		return controller.writeServiceError(c, "update price by external id", err)
 	}
 
 	return c.NoContent(http.StatusOK)
@@ -106,18 +165,57 @@ func (controller *ProductController) UpdatePriceById(c echo.Context) error {
 func (controller *ProductController) DeleteProductById(c echo.Context) error {
 	param := c.Param("id")
 	if param == "" {
-		return c.JSON(http.StatusBadRequest, response.NewErrorResponse("Invalid request: no product id specified"))
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "no product id specified")
 	}
 
 	productId, err := strconv.Atoi(param)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, response.NewErrorResponse("Invalid request: product id must be an integer"))
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "product id must be an integer")
 	}
 
-	err = controller.productService.DeleteById(int64(productId))
+	err = controller.productService.DeleteById(c.Request().Context(), int64(productId))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, response.NewErrorResponse(err.Error()))
+		return controller.writeServiceError(c, "delete", err)
 	}
 
 	return c.NoContent(http.StatusOK)
 }
+
+func (controller *ProductController) UpsertProductBySku(c echo.Context) error {
+	store := c.Param("store")
+	sku := c.Param("sku")
+	if store == "" || sku == "" {
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "store and sku must be specified")
+	}
+
+	var upsertProductRequest request.UpsertProductRequest
+	err := request.DecodeJson(c, &upsertProductRequest)
+	if err != nil {
+		return writeDecodeError(c, err)
+	}
+
+	product, created, err := controller.productService.UpsertBySku(c.Request().Context(), upsertProductRequest.ToModel(store, sku))
+	if err != nil {
+		return controller.writeServiceError(c, "upsert", err)
+	}
+
+	if created {
+		return c.JSON(http.StatusCreated, response.ToProductResponse(product))
+	}
+	return c.JSON(http.StatusOK, response.ToProductResponse(product))
+}
+
+// writeServiceError responds with the problem matching err and logs failures
+// other than authorization failures, conflicts and missing products, which
+// are expected outcomes.
+func (controller *ProductController) writeServiceError(c echo.Context, operation string, err error) error {
+	problem := response.ServiceProblem(http.StatusInternalServerError, err)
+	switch problem.Status {
+	case http.StatusForbidden, http.StatusConflict, http.StatusNotFound:
+	case http.StatusInternalServerError:
+		controller.logger.ErrorContext(c.Request().Context(), "product operation failed", "operation", operation, "error", err)
+	default:
+		controller.logger.WarnContext(c.Request().Context(), "product change failed", "operation", operation, "error", err)
+	}
+	return response.WriteProblem(c, problem)
+}
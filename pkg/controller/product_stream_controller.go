@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/controller/middleware"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	headerLastEventId = "Last-Event-ID"
+	mimeEventStream   = "text/event-stream"
+	// streamRetry is the reconnection delay, in milliseconds, that SSE
+	// clients are asked to use.
+	streamRetry        = 3000
+	streamWriteTimeout = 10 * time.Second
+)
+
+type ProductStreamController struct {
+	streamService service.IProductStreamService
+	keepAlive     time.Duration
+	upgrader      websocket.Upgrader
+	logger        *slog.Logger
+}
+
+// NewProductStreamController sends a keep-alive every keepAlive on idle
+// streams, so that proxies do not time them out.
+func NewProductStreamController(streamService service.IProductStreamService, keepAlive time.Duration, logger *slog.Logger) *ProductStreamController {
+	return &ProductStreamController{streamService, keepAlive, websocket.Upgrader{}, logger}
+}
+
+func (controller *ProductStreamController) RegisterRoutes(e *echo.Echo) {
+	read := middleware.RequireScope(domain.ScopeProductsRead)
+
+	e.GET("/api/v1/products/stream", controller.StreamProducts, read)
+	e.GET("/api/v1/products/stream/ws", controller.StreamProductsWebSocket, read)
+}
+
+// StreamProducts streams product events as Server-Sent Events until the
+// client disconnects or the server shuts down. The event id is the event
+// sequence, which EventSource sends back as Last-Event-ID when it reconnects.
+func (controller *ProductStreamController) StreamProducts(c echo.Context) error {
+	subscription, problem := controller.subscribe(c)
+	if problem != nil {
+		return response.WriteProblem(c, problem)
+	}
+	defer subscription.Close()
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, mimeEventStream)
+	header.Set(echo.HeaderCacheControl, "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	c.Response().WriteHeader(http.StatusOK)
+	if !controller.writeEventStream(c, fmt.Sprintf("retry: %d\n\n", streamRetry)) {
+		return nil
+	}
+
+	keepAlive := time.NewTicker(controller.keepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-keepAlive.C:
+			if !controller.writeEventStream(c, ": keep-alive\n\n") {
+				return nil
+			}
+		case outboxEvent, ok := <-subscription.Events():
+			if !ok {
+				controller.logStreamEnd(c.Request().Context(), subscription.Err())
+				return nil
+			}
+			var data bytes.Buffer
+			if err := json.Compact(&data, outboxEvent.Payload); err != nil {
+				return err
+			}
+			frame := fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", outboxEvent.Id, outboxEvent.EventType, data.Bytes())
+			if !controller.writeEventStream(c, frame) {
+				return nil
+			}
+		}
+	}
+}
+
+// StreamProductsWebSocket streams the same events over a WebSocket, one JSON
+// message per event. Clients resume with the last_event_id query parameter.
+// The server closes the socket with 1001 when it shuts down and with 1013
+// when the client fell behind; either way the client should reconnect.
+func (controller *ProductStreamController) StreamProductsWebSocket(c echo.Context) error {
+	subscription, problem := controller.subscribe(c)
+	if problem != nil {
+		return response.WriteProblem(c, problem)
+	}
+	defer subscription.Close()
+
+	connection, err := controller.upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		// The upgrader already answered the request.
+		return nil
+	}
+	defer connection.Close()
+
+	// Incoming messages are discarded; reading is needed to handle control
+	// frames and notice when the client goes away.
+	ctx, cancel := context.WithCancel(c.Request().Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := connection.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	keepAlive := time.NewTicker(controller.keepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-keepAlive.C:
+			if connection.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout)) != nil {
+				return nil
+			}
+		case outboxEvent, ok := <-subscription.Events():
+			if !ok {
+				err := subscription.Err()
+				controller.logStreamEnd(ctx, err)
+				closeMessage := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+				if errors.Is(err, service.ErrProductStreamClosed) {
+					closeMessage = websocket.FormatCloseMessage(websocket.CloseGoingAway, err.Error())
+				} else if errors.Is(err, service.ErrProductStreamTooSlow) {
+					closeMessage = websocket.FormatCloseMessage(websocket.CloseTryAgainLater, err.Error())
+				}
+				_ = connection.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(streamWriteTimeout))
+				return nil
+			}
+			_ = connection.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if connection.WriteJSON(response.ToProductStreamEventResponse(outboxEvent)) != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// subscribe reads the store and product_id filters and where to resume from:
+// the Last-Event-ID header or the last_event_id query parameter. A client
+// whose missed events were purged is answered with 410 and has to load the
+// products again before streaming live events.
+func (controller *ProductStreamController) subscribe(c echo.Context) (*service.ProductSubscription, *response.Problem) {
+	filter := domain.ProductStreamFilter{Store: c.QueryParam("store")}
+	var err error
+	if productId := c.QueryParam("product_id"); productId != "" {
+		filter.ProductId, err = strconv.ParseInt(productId, 10, 64)
+		if err != nil {
+			return nil, response.NewProblem(http.StatusBadRequest, response.CodeInvalidRequest, "product_id must be an integer")
+		}
+	}
+
+	var lastEventId int64
+	lastEventIdValue := c.Request().Header.Get(headerLastEventId)
+	if lastEventIdValue == "" {
+		lastEventIdValue = c.QueryParam("last_event_id")
+	}
+	if lastEventIdValue != "" {
+		lastEventId, err = strconv.ParseInt(lastEventIdValue, 10, 64)
+		if err != nil || lastEventId < 0 {
+			return nil, response.NewProblem(http.StatusBadRequest, response.CodeInvalidRequest, "last event id must be a non-negative integer")
+		}
+	}
+
+	subscription, err := controller.streamService.Subscribe(c.Request().Context(), filter, lastEventId)
+	switch {
+	case err == nil:
+		return subscription, nil
+	case errors.Is(err, service.ErrProductStreamClosed):
+		return nil, response.ServiceProblem(http.StatusServiceUnavailable, err)
+	case errors.Is(err, service.ErrProductStreamExpired):
+		return nil, response.NewProblem(http.StatusGone, response.CodeEventsExpired, err.Error())
+	}
+	problem := response.ServiceProblem(http.StatusInternalServerError, err)
+	if problem.Status == http.StatusInternalServerError {
+		controller.logger.ErrorContext(c.Request().Context(), "failed to subscribe to the product stream", "error", err)
+	}
+	return nil, problem
+}
+
+// writeEventStream writes and flushes chunk and reports whether the client
+// is still there.
+func (controller *ProductStreamController) writeEventStream(c echo.Context, chunk string) bool {
+	if _, err := c.Response().Write([]byte(chunk)); err != nil {
+		return false
+	}
+	c.Response().Flush()
+	return true
+}
+
+func (controller *ProductStreamController) logStreamEnd(ctx context.Context, err error) {
+	if err != nil && !errors.Is(err, service.ErrProductStreamClosed) {
+		controller.logger.WarnContext(ctx, "product stream ended", "error", err)
+	}
+}
@@ -0,0 +1,98 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"io"
+	"mime"
+	"strings"
+)
+
+var ErrUnsupportedMediaType = errors.New("content type must be application/json")
+
+// FieldError reports a field of the request body that could not be decoded.
+// Pointer is a JSON pointer to the field.
+type FieldError struct {
+	Pointer string
+	Message string
+}
+
+func (err *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Pointer, err.Message)
+}
+
+type normalizer interface {
+	Normalize()
+}
+
+// DecodeJson decodes the JSON body of c into target. Unlike echo's Bind it
+// only accepts application/json, rejects unknown fields and trailing data and
+// never falls back to form or query parameters. Strings are normalized by
+// target afterwards.
+func DecodeJson(c echo.Context, target normalizer) error {
+	mediaType, _, err := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if err != nil || mediaType != echo.MIMEApplicationJSON {
+		return ErrUnsupportedMediaType
+	}
+
+	requestBody, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return err
+	}
+	c.Request().Body = io.NopCloser(bytes.NewReader(requestBody))
+
+	decoder := json.NewDecoder(bytes.NewReader(requestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return decodeError(err)
+	}
+	// More accepts a closing delimiter after the value, so a second Decode
+	// has to find the end of the body instead.
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return errors.New("request body must contain a single JSON value")
+	}
+
+	target.Normalize()
+	return nil
+}
+
+func decodeError(err error) error {
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		return &FieldError{fieldPointer(typeError.Field), "must be " + describeJsonType(typeError.Type.Kind().String())}
+	}
+	// encoding/json has no typed error for unknown fields.
+	if field, found := strings.CutPrefix(err.Error(), "json: unknown field "); found {
+		return &FieldError{"/" + strings.Trim(field, `"`), "is not a known field"}
+	}
+	if errors.Is(err, io.EOF) {
+		return errors.New("request body can't be empty")
+	}
+	return fmt.Errorf("request body is not valid JSON: %w", err)
+}
+
+// fieldPointer converts the dotted path reported by encoding/json, e.g.
+// "operations.0.product.name", to a JSON pointer.
+func fieldPointer(field string) string {
+	if field == "" {
+		return ""
+	}
+	return "/" + strings.ReplaceAll(field, ".", "/")
+}
+
+func describeJsonType(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"):
+		return "an integer"
+	case strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "slice":
+		return "an array"
+	case kind == "struct", kind == "map", kind == "ptr":
+		return "an object"
+	}
+	return "a " + kind
+}
@@ -0,0 +1,72 @@
+package request
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"strings"
+)
+
+// normalizeString trims surrounding whitespace and converts value to Unicode
+// normalization form C, so that visually identical names are stored and
+// compared the same way.
+func normalizeString(value string) string {
+	return norm.NFC.String(strings.TrimSpace(value))
+}
+
+func (request *ExternalIdRequest) Normalize() {
+	request.System = normalizeString(request.System)
+	request.Id = normalizeString(request.Id)
+}
+
+func (request *AddProductRequest) Normalize() {
+	request.Name = normalizeString(request.Name)
+	request.Store = normalizeString(request.Store)
+	request.Sku = normalizeString(request.Sku)
+	for i := range request.ExternalIds {
+		request.ExternalIds[i].Normalize()
+	}
+}
+
+func (request *UpsertProductRequest) Normalize() {
+	request.Name = normalizeString(request.Name)
+	for i := range request.ExternalIds {
+		request.ExternalIds[i].Normalize()
+	}
+}
+
+func (request *BatchRequest) Normalize() {
+	for _, operation := range request.Operations {
+		if operation.ExternalId != nil {
+			operation.ExternalId.Normalize()
+		}
+		if operation.Product != nil {
+			operation.Product.Normalize()
+		}
+	}
+}
+
+func (request *CreateApiKeyRequest) Normalize() {
+	request.Name = normalizeString(request.Name)
+	for i, scope := range request.Scopes {
+		request.Scopes[i] = strings.TrimSpace(scope)
+	}
+}
+
+func (request *CreateRoleBindingRequest) Normalize() {
+	request.Subject = normalizeString(request.Subject)
+	request.Role = strings.TrimSpace(request.Role)
+	request.Store = normalizeString(request.Store)
+}
+
+// Normalize keeps the secret as sent, since receivers sign with it verbatim.
+func (request *CreateWebhookSubscriptionRequest) Normalize() {
+	request.Url = strings.TrimSpace(request.Url)
+	for i, eventType := range request.EventTypes {
+		request.EventTypes[i] = strings.TrimSpace(eventType)
+	}
+}
+
+// Normalize leaves the query alone: string literals in it are normalized by
+// the mutations that take them, like the fields of every other request.
+func (request *GraphqlRequest) Normalize() {
+	request.OperationName = strings.TrimSpace(request.OperationName)
+}
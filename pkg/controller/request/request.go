@@ -1,19 +1,126 @@
 package request
 
-import "github.com/erkindilekci/product-api/pkg/service/dto"
+import (
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service/dto"
+)
+
+type ExternalIdRequest struct {
+	System string `json:"system"`
+	Id     string `json:"id"`
+}
+
+func (request *ExternalIdRequest) ToModel() domain.ExternalId {
+	return domain.ExternalId{
+		System: request.System,
+		Id:     request.Id,
+	}
+}
 
 type AddProductRequest struct {
-	Name     string  `json:"name"`
-	Price    float32 `json:"price"`
-	Discount float32 `json:"discount"`
-	Store    string  `json:"store"`
+	Name        string              `json:"name"`
+	Price       float32             `json:"price"`
+	Discount    float32             `json:"discount"`
+	Store       string              `json:"store"`
+	Sku         string              `json:"sku"`
+	ExternalIds []ExternalIdRequest `json:"external_ids"`
 }
 
 func (request *AddProductRequest) ToModel() dto.ProductCreate {
+	var externalIds []domain.ExternalId
+	for _, externalId := range request.ExternalIds {
+		externalIds = append(externalIds, externalId.ToModel())
+	}
+
+	return dto.ProductCreate{
+		Name:        request.Name,
+		Price:       request.Price,
+		Discount:    request.Discount,
+		Store:       request.Store,
+		Sku:         request.Sku,
+		ExternalIds: externalIds,
+	}
+}
+
+type UpsertProductRequest struct {
+	Name        string              `json:"name"`
+	Price       float32             `json:"price"`
+	Discount    float32             `json:"discount"`
+	ExternalIds []ExternalIdRequest `json:"external_ids"`
+}
+
+func (request *UpsertProductRequest) ToModel(store string, sku string) dto.ProductCreate {
+	var externalIds []domain.ExternalId
+	for _, externalId := range request.ExternalIds {
+		externalIds = append(externalIds, externalId.ToModel())
+	}
+
 	return dto.ProductCreate{
-		Name:     request.Name,
-		Price:    request.Price,
-		Discount: request.Discount,
-		Store:    request.Store,
+		Name:        request.Name,
+		Price:       request.Price,
+		Discount:    request.Discount,
+		Store:       normalizeString(store),
+		Sku:         normalizeString(sku),
+		ExternalIds: externalIds,
+	}
+}
+
+type BatchOperationRequest struct {
+	Op         string             `json:"op"`
+	Id         int64              `json:"id"`
+	ExternalId *ExternalIdRequest `json:"external_id"`
+	NewPrice   *float32           `json:"new_price"`
+	Product    *AddProductRequest `json:"product"`
+}
+
+type BatchRequest struct {
+	Atomic     bool                    `json:"atomic"`
+	Operations []BatchOperationRequest `json:"operations"`
+}
+
+type CreateApiKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+func (request *CreateApiKeyRequest) ToModel() dto.ApiKeyCreate {
+	return dto.ApiKeyCreate{
+		Name:   request.Name,
+		Scopes: request.Scopes,
+	}
+}
+
+type CreateRoleBindingRequest struct {
+	Subject string `json:"subject"`
+	Role    string `json:"role"`
+	Store   string `json:"store"`
+}
+
+func (request *CreateRoleBindingRequest) ToModel() dto.RoleBindingCreate {
+	return dto.RoleBindingCreate{
+		Subject: request.Subject,
+		Role:    request.Role,
+		Store:   request.Store,
 	}
 }
+
+type CreateWebhookSubscriptionRequest struct {
+	Url        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Secret     string   `json:"secret"`
+}
+
+func (request *CreateWebhookSubscriptionRequest) ToModel() dto.WebhookSubscriptionCreate {
+	return dto.WebhookSubscriptionCreate{
+		Url:        request.Url,
+		EventTypes: request.EventTypes,
+		Secret:     request.Secret,
+	}
+}
+
+// GraphqlRequest is a GraphQL query as sent over HTTP POST.
+type GraphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
@@ -0,0 +1,171 @@
+package response
+
+import (
+	"errors"
+	"github.com/erkindilekci/product-api/pkg/common/openapi"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strings"
+)
+
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// Problem codes are part of the API contract: clients may branch on them, so
+// existing codes must never be renamed or reused for a different meaning.
+const (
+	CodeInvalidRequest           = "invalid_request"
+	CodeValidationFailed         = "validation_failed"
+	CodeUnauthorized             = "unauthorized"
+	CodeForbidden                = "forbidden"
+	CodeNotFound                 = "not_found"
+	CodeRouteNotFound            = "route_not_found"
+	CodeMethodNotAllowed         = "method_not_allowed"
+	CodeProductAlreadyExists     = "product_already_exists"
+	CodeExternalIdAlreadyExists  = "external_id_already_exists"
+	CodeRoleBindingAlreadyExists = "role_binding_already_exists"
+	CodeDeliveryNotRetryable     = "delivery_not_retryable"
+	CodeRateLimited              = "rate_limited"
+	CodePayloadTooLarge          = "payload_too_large"
+	CodeUnsupportedMediaType     = "unsupported_media_type"
+	CodeIdempotencyKeyInvalid    = "idempotency_key_invalid"
+	CodeIdempotencyKeyReused     = "idempotency_key_reused"
+	CodeIdempotencyKeyInProgress = "idempotency_key_in_progress"
+	CodeOperationNotApplied      = "operation_not_applied"
+	CodeQueryTooComplex          = "query_too_complex"
+	CodeServiceUnavailable       = "service_unavailable"
+	CodeEventsExpired            = "events_expired"
+	CodeInternalError            = "internal_error"
+)
+
+var problemTitles = map[string]string{
+	CodeInvalidRequest:           "Invalid request",
+	CodeValidationFailed:         "Validation failed",
+	CodeUnauthorized:             "Unauthorized",
+	CodeForbidden:                "Forbidden",
+	CodeNotFound:                 "Not found",
+	CodeRouteNotFound:            "Route not found",
+	CodeMethodNotAllowed:         "Method not allowed",
+	CodeProductAlreadyExists:     "Product already exists",
+	CodeExternalIdAlreadyExists:  "External id already exists",
+	CodeRoleBindingAlreadyExists: "Role binding already exists",
+	CodeDeliveryNotRetryable:     "Delivery not retryable",
+	CodeRateLimited:              "Too many requests",
+	CodePayloadTooLarge:          "Payload too large",
+	CodeUnsupportedMediaType:     "Unsupported media type",
+	CodeIdempotencyKeyInvalid:    "Invalid idempotency key",
+	CodeIdempotencyKeyReused:     "Idempotency key reused",
+	CodeIdempotencyKeyInProgress: "Idempotency key in progress",
+	CodeOperationNotApplied:      "Operation not applied",
+	CodeQueryTooComplex:          "Query too complex",
+	CodeServiceUnavailable:       "Service unavailable",
+	CodeEventsExpired:            "Events expired",
+	CodeInternalError:            "Internal server error",
+}
+
+// ProblemTypeBase prefixes the code of a problem to form its type URI.
+const ProblemTypeBase = "urn:product-api:problem:"
+
+// ProblemFieldError is one invalid field. Pointer is a JSON pointer into the
+// request body, or the name of the offending parameter when In is not
+// "body".
+type ProblemFieldError struct {
+	In      string `json:"in"`
+	Pointer string `json:"pointer"`
+	Detail  string `json:"detail"`
+}
+
+// Problem is an RFC 7807 problem details object, extended with a stable code
+// and the list of invalid fields.
+type Problem struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	Code     string              `json:"code"`
+	Errors   []ProblemFieldError `json:"errors,omitempty"`
+}
+
+func NewProblem(status int, code string, detail string) *Problem {
+	return &Problem{
+		Type:   ProblemTypeBase + code,
+		Title:  problemTitles[code],
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+func NewValidationProblem(status int, validationError *domain.ValidationError) *Problem {
+	problem := NewProblem(status, CodeValidationFailed, validationError.Error())
+	for _, violation := range validationError.Violations {
+		in := openapi.InBody
+		if !strings.HasPrefix(violation.Pointer, "/") {
+			in = openapi.InQuery
+		}
+		problem.Errors = append(problem.Errors, ProblemFieldError{in, violation.Pointer, violation.Message})
+	}
+	return problem
+}
+
+func NewSpecificationProblem(fieldErrors []openapi.FieldError) *Problem {
+	problem := NewProblem(http.StatusBadRequest, CodeValidationFailed, "the request does not match the API specification")
+	for _, fieldError := range fieldErrors {
+		problem.Errors = append(problem.Errors, ProblemFieldError{fieldError.In, fieldError.Field, fieldError.Message})
+	}
+	return problem
+}
+
+// ServiceProblem maps an error returned by a service to a problem. Errors
+// without a more specific meaning are reported with status, which callers
+// set to 500 unless the failure has a known cause such as a shutdown. Errors
+// of the client are typed by the services, so an unknown error is never
+// blamed on the client. Internal errors are reported without their message,
+// which may reveal details of the database; callers log them instead. The
+// gRPC API derives its status codes from the same mapping.
+func ServiceProblem(status int, err error) *Problem {
+	var validationError *domain.ValidationError
+	switch {
+	case errors.Is(err, domain.ErrForbidden):
+		return NewProblem(http.StatusForbidden, CodeForbidden, err.Error())
+	case errors.Is(err, domain.ErrProductNotFound), errors.Is(err, domain.ErrApiKeyNotFound), errors.Is(err, domain.ErrRoleBindingNotFound),
+		errors.Is(err, domain.ErrWebhookSubscriptionNotFound), errors.Is(err, domain.ErrWebhookDeliveryNotFound):
+		return NewProblem(http.StatusNotFound, CodeNotFound, err.Error())
+	case errors.Is(err, domain.ErrProductAlreadyExists):
+		return NewProblem(http.StatusConflict, CodeProductAlreadyExists, err.Error())
+	case errors.Is(err, domain.ErrExternalIdAlreadyExists):
+		return NewProblem(http.StatusConflict, CodeExternalIdAlreadyExists, err.Error())
+	case errors.Is(err, domain.ErrRoleBindingAlreadyExists):
+		return NewProblem(http.StatusConflict, CodeRoleBindingAlreadyExists, err.Error())
+	case errors.Is(err, domain.ErrWebhookDeliveryNotRetryable):
+		return NewProblem(http.StatusConflict, CodeDeliveryNotRetryable, err.Error())
+	case errors.As(err, &validationError):
+		return NewValidationProblem(http.StatusUnprocessableEntity, validationError)
+	}
+
+	switch status {
+	case http.StatusNotFound:
+		return NewProblem(status, CodeNotFound, err.Error())
+	case http.StatusInternalServerError:
+		return NewProblem(status, CodeInternalError, "")
+	case http.StatusServiceUnavailable:
+		return NewProblem(status, CodeServiceUnavailable, err.Error())
+	}
+	return NewProblem(status, CodeInvalidRequest, err.Error())
+}
+
+// WriteProblem sends problem as application/problem+json, using the request
+// path as the instance if none is set.
+func WriteProblem(c echo.Context, problem *Problem) error {
+	if problem.Instance == "" {
+		problem.Instance = c.Request().URL.Path
+	}
+	c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+	return c.JSON(problem.Status, problem)
+}
+
+// WriteNewProblem is a shorthand for WriteProblem(c, NewProblem(...)).
+func WriteNewProblem(c echo.Context, status int, code string, detail string) error {
+	return WriteProblem(c, NewProblem(status, code, detail))
+}
@@ -1,28 +1,38 @@
 package response
 
-import "github.com/erkindilekci/product-api/pkg/domain"
+import (
+	"encoding/json"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"time"
+)
 
-type ErrorResponse struct {
-	ErrorMessage string `json:"error_message"`
-}
-
-func NewErrorResponse(errorMessage string) *ErrorResponse {
-	return &ErrorResponse{errorMessage}
+type ExternalIdResponse struct {
+	System string `json:"system"`
+	Id     string `json:"id"`
 }
 
 type ProductResponse struct {
-	Name     string  `json:"name"`
-	Price    float32 `json:"price"`
-	Discount float32 `json:"discount"`
-	Store    string  `json:"store"`
+	Name        string               `json:"name"`
+	Price       float32              `json:"price"`
+	Discount    float32              `json:"discount"`
+	Store       string               `json:"store"`
+	Sku         string               `json:"sku,omitempty"`
+	ExternalIds []ExternalIdResponse `json:"external_ids,omitempty"`
 }
 
 func ToProductResponse(product domain.Product) ProductResponse {
+	var externalIds []ExternalIdResponse
+	for _, externalId := range product.ExternalIds {
+		externalIds = append(externalIds, ExternalIdResponse{externalId.System, externalId.Id})
+	}
+
 	return ProductResponse{
-		Name:     product.Name,
-		Price:    product.Price,
-		Discount: product.Price,
-		Store:    product.Store,
+		Name:        product.Name,
+		Price:       product.Price,
+		Discount:    product.Discount,
+		Store:       product.Store,
+		Sku:         product.Sku,
+		ExternalIds: externalIds,
 	}
 }
 
@@ -33,3 +43,244 @@ func ToProductResponseList(products []domain.Product) []ProductResponse {
 	}
 	return responses
 }
+
+type BatchOperationResponse struct {
+	Status int         `json:"status"`
+	Body   interface{} `json:"body,omitempty"`
+}
+
+type BatchResponse struct {
+	Results []BatchOperationResponse `json:"results"`
+}
+
+type ApiKeyResponse struct {
+	Id        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Key       string     `json:"key,omitempty"`
+}
+
+func ToApiKeyResponse(apiKey domain.ApiKey) ApiKeyResponse {
+	return ApiKeyResponse{
+		Id:        apiKey.Id,
+		Name:      apiKey.Name,
+		Prefix:    apiKey.Prefix,
+		Scopes:    apiKey.Scopes,
+		CreatedAt: apiKey.CreatedAt,
+		RevokedAt: apiKey.RevokedAt,
+	}
+}
+
+func ToApiKeyResponseList(apiKeys []domain.ApiKey) []ApiKeyResponse {
+	var responses []ApiKeyResponse
+	for _, apiKey := range apiKeys {
+		responses = append(responses, ToApiKeyResponse(apiKey))
+	}
+	return responses
+}
+
+type RoleBindingResponse struct {
+	Id        int64     `json:"id"`
+	Subject   string    `json:"subject"`
+	Role      string    `json:"role"`
+	Store     string    `json:"store"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func ToRoleBindingResponse(roleBinding domain.RoleBinding) RoleBindingResponse {
+	return RoleBindingResponse{
+		Id:        roleBinding.Id,
+		Subject:   roleBinding.Subject,
+		Role:      roleBinding.Role,
+		Store:     roleBinding.Store,
+		CreatedAt: roleBinding.CreatedAt,
+	}
+}
+
+func ToRoleBindingResponseList(roleBindings []domain.RoleBinding) []RoleBindingResponse {
+	var responses []RoleBindingResponse
+	for _, roleBinding := range roleBindings {
+		responses = append(responses, ToRoleBindingResponse(roleBinding))
+	}
+	return responses
+}
+
+type AuditEventResponse struct {
+	Id        int64           `json:"id"`
+	Action    string          `json:"action"`
+	ProductId int64           `json:"product_id"`
+	Actor     string          `json:"actor"`
+	RequestId string          `json:"request_id,omitempty"`
+	ClientIp  string          `json:"client_ip,omitempty"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func ToAuditEventResponse(auditEvent domain.AuditEvent) AuditEventResponse {
+	return AuditEventResponse{
+		Id:        auditEvent.Id,
+		Action:    auditEvent.Action,
+		ProductId: auditEvent.ProductId,
+		Actor:     auditEvent.Actor,
+		RequestId: auditEvent.RequestId,
+		ClientIp:  auditEvent.ClientIp,
+		Before:    auditEvent.Before,
+		After:     auditEvent.After,
+		CreatedAt: auditEvent.CreatedAt,
+	}
+}
+
+func ToAuditEventResponseList(auditEvents []domain.AuditEvent) []AuditEventResponse {
+	var responses []AuditEventResponse
+	for _, auditEvent := range auditEvents {
+		responses = append(responses, ToAuditEventResponse(auditEvent))
+	}
+	return responses
+}
+
+// WebhookSubscriptionResponse never contains the secret; only the client that
+// created the subscription knows it.
+type WebhookSubscriptionResponse struct {
+	Id         int64     `json:"id"`
+	Url        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func ToWebhookSubscriptionResponse(subscription domain.WebhookSubscription) WebhookSubscriptionResponse {
+	return WebhookSubscriptionResponse{
+		Id:         subscription.Id,
+		Url:        subscription.Url,
+		EventTypes: subscription.EventTypes,
+		CreatedAt:  subscription.CreatedAt,
+	}
+}
+
+func ToWebhookSubscriptionResponseList(subscriptions []domain.WebhookSubscription) []WebhookSubscriptionResponse {
+	var responses []WebhookSubscriptionResponse
+	for _, subscription := range subscriptions {
+		responses = append(responses, ToWebhookSubscriptionResponse(subscription))
+	}
+	return responses
+}
+
+type WebhookDeliveryResponse struct {
+	Id             int64           `json:"id"`
+	SubscriptionId int64           `json:"subscription_id"`
+	EventId        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+func ToWebhookDeliveryResponse(delivery domain.WebhookDelivery) WebhookDeliveryResponse {
+	deliveryResponse := WebhookDeliveryResponse{
+		Id:             delivery.Id,
+		SubscriptionId: delivery.SubscriptionId,
+		EventId:        delivery.EventId,
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		Payload:        delivery.Payload,
+		CreatedAt:      delivery.CreatedAt,
+		DeliveredAt:    delivery.DeliveredAt,
+	}
+	if delivery.Status == domain.WebhookDeliveryPending {
+		deliveryResponse.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	return deliveryResponse
+}
+
+func ToWebhookDeliveryResponseList(deliveries []domain.WebhookDelivery) []WebhookDeliveryResponse {
+	var responses []WebhookDeliveryResponse
+	for _, delivery := range deliveries {
+		responses = append(responses, ToWebhookDeliveryResponse(delivery))
+	}
+	return responses
+}
+
+// ProductStreamEventResponse is a product event as sent over the WebSocket
+// stream. Id is the event sequence to resume from and Data the event itself.
+type ProductStreamEventResponse struct {
+	Id    int64           `json:"id"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+func ToProductStreamEventResponse(outboxEvent domain.OutboxEvent) ProductStreamEventResponse {
+	return ProductStreamEventResponse{
+		Id:    outboxEvent.Id,
+		Event: outboxEvent.EventType,
+		Data:  outboxEvent.Payload,
+	}
+}
+
+// ProductChangeResponse is the current state of a product, or a tombstone
+// without one if it was deleted.
+type ProductChangeResponse struct {
+	Sequence  int64            `json:"sequence"`
+	ProductId int64            `json:"product_id"`
+	Deleted   bool             `json:"deleted"`
+	Product   *ProductResponse `json:"product,omitempty"`
+	ChangedAt time.Time        `json:"changed_at"`
+}
+
+type ProductChangePageResponse struct {
+	Changes      []ProductChangeResponse `json:"changes"`
+	NextSequence int64                   `json:"next_sequence"`
+	HasMore      bool                    `json:"has_more"`
+}
+
+func ToProductChangePageResponse(page domain.ProductChangePage) ProductChangePageResponse {
+	changes := []ProductChangeResponse{}
+	for _, change := range page.Changes {
+		changeResponse := ProductChangeResponse{
+			Sequence:  change.Sequence,
+			ProductId: change.ProductId,
+			Deleted:   change.Deleted,
+			ChangedAt: change.ChangedAt,
+		}
+		if change.Product != nil {
+			productResponse := ToProductResponse(*change.Product)
+			changeResponse.Product = &productResponse
+		}
+		changes = append(changes, changeResponse)
+	}
+	return ProductChangePageResponse{Changes: changes, NextSequence: page.NextSequence, HasMore: page.HasMore}
+}
+
+type ComponentHealthResponse struct {
+	Status  string                 `json:"status"`
+	Error   string                 `json:"error,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+type HealthResponse struct {
+	Status     string                             `json:"status"`
+	Draining   bool                               `json:"draining,omitempty"`
+	Components map[string]ComponentHealthResponse `json:"components,omitempty"`
+}
+
+func ToHealthResponse(report domain.HealthReport) HealthResponse {
+	components := map[string]ComponentHealthResponse{}
+	for _, component := range report.Components {
+		components[component.Name] = ComponentHealthResponse{
+			Status:  component.Status,
+			Error:   component.Error,
+			Details: component.Details,
+		}
+	}
+	return HealthResponse{Status: report.Status, Draining: report.Draining, Components: components}
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"github.com/erkindilekci/product-api/pkg/controller/middleware"
+	"github.com/erkindilekci/product-api/pkg/controller/request"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+type RoleBindingController struct {
+	roleBindingService service.IRoleBindingService
+}
+
+func NewRoleBindingController(roleBindingService service.IRoleBindingService) *RoleBindingController {
+	return &RoleBindingController{roleBindingService}
+}
+
+func (controller *RoleBindingController) RegisterRoutes(e *echo.Echo) {
+	admin := middleware.RequireScope(domain.ScopeAdmin)
+
+	e.GET("/api/v1/admin/role-bindings", controller.GetAllRoleBindings, admin)
+	e.POST("/api/v1/admin/role-bindings", controller.CreateRoleBinding, admin)
+	e.DELETE("/api/v1/admin/role-bindings/:id", controller.DeleteRoleBinding, admin)
+}
+
+func (controller *RoleBindingController) GetAllRoleBindings(c echo.Context) error {
+	subject := c.QueryParam("subject")
+	roleBindings, err := controller.roleBindingService.GetAll(subject)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, response.ToRoleBindingResponseList(roleBindings))
+}
+
+func (controller *RoleBindingController) CreateRoleBinding(c echo.Context) error {
+	var createRoleBindingRequest request.CreateRoleBindingRequest
+	err := request.DecodeJson(c, &createRoleBindingRequest)
+	if err != nil {
+		return writeDecodeError(c, err)
+	}
+
+	roleBinding, err := controller.roleBindingService.Create(createRoleBindingRequest.ToModel())
+	if err != nil {
+		return writeServiceProblem(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, response.ToRoleBindingResponse(roleBinding))
+}
+
+func (controller *RoleBindingController) DeleteRoleBinding(c echo.Context) error {
+	roleBindingId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "role binding id must be an integer")
+	}
+
+	err = controller.roleBindingService.Delete(int64(roleBindingId))
+	if err != nil {
+		return writeServiceProblem(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
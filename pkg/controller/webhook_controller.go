@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"github.com/erkindilekci/product-api/pkg/controller/middleware"
+	"github.com/erkindilekci/product-api/pkg/controller/request"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+)
+
+type WebhookController struct {
+	webhookService service.IWebhookService
+}
+
+func NewWebhookController(webhookService service.IWebhookService) *WebhookController {
+	return &WebhookController{webhookService}
+}
+
+func (controller *WebhookController) RegisterRoutes(e *echo.Echo) {
+	admin := middleware.RequireScope(domain.ScopeAdmin)
+
+	e.GET("/api/v1/admin/webhooks", controller.GetAllSubscriptions, admin)
+	e.POST("/api/v1/admin/webhooks", controller.CreateSubscription, admin)
+	e.DELETE("/api/v1/admin/webhooks/:id", controller.DeleteSubscription, admin)
+	e.GET("/api/v1/admin/webhooks/deliveries", controller.GetDeliveries, admin)
+	e.POST("/api/v1/admin/webhooks/deliveries/:id/retry", controller.RetryDelivery, admin)
+}
+
+func (controller *WebhookController) GetAllSubscriptions(c echo.Context) error {
+	subscriptions, err := controller.webhookService.GetSubscriptions(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, response.ToWebhookSubscriptionResponseList(subscriptions))
+}
+
+func (controller *WebhookController) CreateSubscription(c echo.Context) error {
+	var createSubscriptionRequest request.CreateWebhookSubscriptionRequest
+	err := request.DecodeJson(c, &createSubscriptionRequest)
+	if err != nil {
+		return writeDecodeError(c, err)
+	}
+
+	subscription, err := controller.webhookService.CreateSubscription(c.Request().Context(), createSubscriptionRequest.ToModel())
+	if err != nil {
+		return writeServiceProblem(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, response.ToWebhookSubscriptionResponse(subscription))
+}
+
+func (controller *WebhookController) DeleteSubscription(c echo.Context) error {
+	subscriptionId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "webhook subscription id must be an integer")
+	}
+
+	err = controller.webhookService.DeleteSubscription(c.Request().Context(), int64(subscriptionId))
+	if err != nil {
+		return writeServiceProblem(c, err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// GetDeliveries is the delivery log, newest first, filtered by subscription_id
+// and status (pending, delivered or dead).
+func (controller *WebhookController) GetDeliveries(c echo.Context) error {
+	filter := domain.WebhookDeliveryFilter{Status: c.QueryParam("status")}
+	var err error
+
+	if subscriptionId := c.QueryParam("subscription_id"); subscriptionId != "" {
+		filter.SubscriptionId, err = strconv.ParseInt(subscriptionId, 10, 64)
+		if err != nil {
+			return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "subscription_id must be an integer")
+		}
+	}
+	if limit := c.QueryParam("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "limit must be an integer")
+		}
+	}
+
+	deliveries, err := controller.webhookService.GetDeliveries(c.Request().Context(), filter)
+	if err != nil {
+		return writeServiceProblem(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response.ToWebhookDeliveryResponseList(deliveries))
+}
+
+func (controller *WebhookController) RetryDelivery(c echo.Context) error {
+	deliveryId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return response.WriteNewProblem(c, http.StatusBadRequest, response.CodeInvalidRequest, "webhook delivery id must be an integer")
+	}
+
+	delivery, err := controller.webhookService.RetryDelivery(c.Request().Context(), int64(deliveryId))
+	if err != nil {
+		return writeServiceProblem(c, err)
+	}
+
+	return c.JSON(http.StatusOK, response.ToWebhookDeliveryResponse(delivery))
+}
@@ -0,0 +1,28 @@
+package domain
+
+import "time"
+
+const (
+	ScopeProductsRead   = "products:read"
+	ScopeProductsWrite  = "products:write"
+	ScopeProductsDelete = "products:delete"
+	ScopeAdmin          = "admin"
+)
+
+var KnownScopes = []string{ScopeProductsRead, ScopeProductsWrite, ScopeProductsDelete, ScopeAdmin}
+
+// ApiKey is a client credential. Only the SHA-256 hash of the secret is kept;
+// Prefix is stored in clear text so a presented key can be looked up.
+type ApiKey struct {
+	Id        int64
+	Name      string
+	Prefix    string
+	KeyHash   string
+	Scopes    []string
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+func (apiKey ApiKey) Revoked() bool {
+	return apiKey.RevokedAt != nil
+}
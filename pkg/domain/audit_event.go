@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionProductCreated      = "product.created"
+	AuditActionProductUpserted     = "product.upserted"
+	AuditActionProductPriceChanged = "product.price_changed"
+	AuditActionProductDeleted      = "product.deleted"
+)
+
+// AuditEvent records a single product mutation. Before and After hold JSON
+// snapshots of the product and are nil for creations and deletions
+// respectively.
+type AuditEvent struct {
+	Id        int64
+	Action    string
+	ProductId int64
+	Actor     string
+	RequestId string
+	ClientIp  string
+	Before    json.RawMessage
+	After     json.RawMessage
+	CreatedAt time.Time
+}
+
+// AuditEventFilter selects audit events. They are returned oldest first
+// unless Descending is set. BeforeId, if set, only selects events with a
+// lower id and PriceChangesOnly only events that set or changed a price.
+type AuditEventFilter struct {
+	ProductId        int64
+	Actor            string
+	From             time.Time
+	To               time.Time
+	BeforeId         int64
+	PriceChangesOnly bool
+	Limit            int
+	Descending       bool
+}
+
+// PriceChange is a change of a product's price, derived from its audit
+// events. PreviousPrice is nil for the price a product was created with.
+type PriceChange struct {
+	EventId       int64
+	ProductId     int64
+	Price         float32
+	PreviousPrice *float32
+	Actor         string
+	ChangedAt     time.Time
+}
+
+// PriceChangePage is a page of price changes, newest first. TotalCount counts
+// every price change of the product.
+type PriceChangePage struct {
+	PriceChanges []PriceChange
+	TotalCount   int64
+	HasNextPage  bool
+}
@@ -0,0 +1,19 @@
+package domain
+
+import "errors"
+
+var (
+	ErrProductAlreadyExists     = errors.New("a product with the same name or sku already exists in this store")
+	ErrExternalIdAlreadyExists  = errors.New("external id is already assigned to a product")
+	ErrRoleBindingAlreadyExists = errors.New("the subject already has this role in this store")
+	ErrForbidden                = errors.New("not allowed to access products of this store")
+	// ErrProductNotFound is wrapped by the errors reporting that the product
+	// looked up does not exist.
+	ErrProductNotFound = errors.New("no product found")
+	// The errors below are wrapped likewise for the other resources.
+	ErrApiKeyNotFound              = errors.New("no api key found")
+	ErrRoleBindingNotFound         = errors.New("no role binding found")
+	ErrWebhookSubscriptionNotFound = errors.New("no webhook subscription found")
+	ErrWebhookDeliveryNotFound     = errors.New("no webhook delivery found")
+	ErrWebhookDeliveryNotRetryable = errors.New("only dead deliveries can be retried")
+)
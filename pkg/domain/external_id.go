@@ -0,0 +1,8 @@
+package domain
+
+// ExternalId identifies a product in a partner system, e.g. a store's own SKU
+// or catalog number.
+type ExternalId struct {
+	System string
+	Id     string
+}
@@ -0,0 +1,23 @@
+package domain
+
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+)
+
+type ComponentHealth struct {
+	Name    string
+	Status  string
+	Error   string
+	Details map[string]interface{}
+}
+
+type HealthReport struct {
+	Status     string
+	Draining   bool
+	Components []ComponentHealth
+}
+
+func (report HealthReport) Up() bool {
+	return report.Status == HealthStatusUp
+}
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+type IdempotencyRecord struct {
+	Key          string
+	RequestHash  string
+	StatusCode   int
+	ContentType  string
+	ResponseBody []byte
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+// Completed reports whether the original request finished and its response
+// was stored, as opposed to still being processed.
+func (record IdempotencyRecord) Completed() bool {
+	return record.StatusCode != 0
+}
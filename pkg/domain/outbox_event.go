@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEvent is a product event written to the outbox in the transaction of
+// the change it describes and published from there by the relay. Id orders the
+// events; EventId identifies the event to consumers, which may see it more than
+// once. EventType is the audit action of the change and Payload the JSON
+// document published for it.
+type OutboxEvent struct {
+	Id            int64
+	EventId       string
+	EventType     string
+	ProductId     int64
+	Payload       json.RawMessage
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+	PublishedAt   *time.Time
+}
@@ -0,0 +1,32 @@
+package domain
+
+import "context"
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject string
+	Roles   []string
+	Scopes  []string
+}
+
+type principalContextKey struct{}
+
+// HasScope reports whether the principal was granted scope, either directly
+// or through the admin scope.
+func (principal Principal) HasScope(scope string) bool {
+	for _, granted := range principal.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+func ContextWithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(Principal)
+	return principal, ok
+}
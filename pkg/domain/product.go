@@ -1,9 +1,29 @@
 package domain
 
 type Product struct {
-	Id       int64
-	Name     string
-	Price    float32
-	Discount float32
-	Store    string
+	Id          int64
+	Name        string
+	Price       float32
+	Discount    float32
+	Store       string
+	Sku         string
+	ExternalIds []ExternalId
+}
+
+// ProductFilter selects products. Empty fields match every product. Stores
+// nil matches the products of all stores, an empty Stores none.
+type ProductFilter struct {
+	Stores       []string
+	Sku          string
+	NameContains string
+	MinPrice     *float32
+	MaxPrice     *float32
+}
+
+// ProductPage is a page of products ordered by id. TotalCount counts every
+// product matching the filter, not only those on the page.
+type ProductPage struct {
+	Products    []Product
+	TotalCount  int64
+	HasNextPage bool
 }
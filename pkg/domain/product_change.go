@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// ProductChange is an entry of the change feed. Each product appears once,
+// with the sequence of its latest change: its current state, or a tombstone
+// if it was deleted, in which case Product is nil.
+type ProductChange struct {
+	Sequence  int64
+	ProductId int64
+	Deleted   bool
+	Product   *Product
+	ChangedAt time.Time
+}
+
+// ProductChangePage holds the changes after a sequence, in sequence order.
+// NextSequence is the sequence to ask for the following changes with.
+type ProductChangePage struct {
+	Changes      []ProductChange
+	NextSequence int64
+	HasMore      bool
+}
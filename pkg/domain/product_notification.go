@@ -0,0 +1,19 @@
+package domain
+
+const (
+	ProductNotificationInsert = "insert"
+	ProductNotificationUpdate = "update"
+	ProductNotificationDelete = "delete"
+	// ProductNotificationResync is sent after the listener reconnected.
+	// Changes made while it was disconnected were not notified, so
+	// subscribers that keep state derived from products should reload it.
+	ProductNotificationResync = "resync"
+)
+
+// ProductNotification tells that a product was changed through any instance
+// of the API. It carries no product data; subscribers read what they need.
+type ProductNotification struct {
+	Operation string `json:"operation"`
+	ProductId int64  `json:"product_id"`
+	Store     string `json:"store"`
+}
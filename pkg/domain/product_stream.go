@@ -0,0 +1,17 @@
+package domain
+
+import "slices"
+
+// ProductStreamFilter selects the product events a stream subscriber
+// receives. Zero fields match every event; Stores, when not nil, limits them
+// to the stores the subscriber may read.
+type ProductStreamFilter struct {
+	Store     string
+	ProductId int64
+	Stores    []string
+}
+
+func (filter ProductStreamFilter) Matches(productId int64, store string) bool {
+	return (filter.Store == "" || filter.Store == store) && (filter.ProductId == 0 || filter.ProductId == productId) &&
+		(filter.Stores == nil || slices.Contains(filter.Stores, store))
+}
@@ -0,0 +1,11 @@
+package domain
+
+import "time"
+
+type RateLimitDecision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration
+	RetryAfter time.Duration
+}
@@ -0,0 +1,20 @@
+package domain
+
+import "context"
+
+// RequestMetadata describes the HTTP request a service call originates from.
+type RequestMetadata struct {
+	RequestId string
+	ClientIp  string
+}
+
+type requestMetadataContextKey struct{}
+
+func ContextWithRequestMetadata(ctx context.Context, requestMetadata RequestMetadata) context.Context {
+	return context.WithValue(ctx, requestMetadataContextKey{}, requestMetadata)
+}
+
+func RequestMetadataFromContext(ctx context.Context) RequestMetadata {
+	requestMetadata, _ := ctx.Value(requestMetadataContextKey{}).(RequestMetadata)
+	return requestMetadata
+}
@@ -0,0 +1,42 @@
+package domain
+
+import "time"
+
+const (
+	RoleStoreViewer  = "store_viewer"
+	RoleStoreEditor  = "store_editor"
+	RoleStoreManager = "store_manager"
+)
+
+// AllStores as the store of a binding grants its role in every store.
+const AllStores = "*"
+
+// StoreRolePermissions lists the scopes a role grants within its store.
+var StoreRolePermissions = map[string][]string{
+	RoleStoreViewer:  {ScopeProductsRead},
+	RoleStoreEditor:  {ScopeProductsRead, ScopeProductsWrite},
+	RoleStoreManager: {ScopeProductsRead, ScopeProductsWrite, ScopeProductsDelete},
+}
+
+// RoleBinding grants a principal a role for the products of one store, or of
+// all stores if Store is AllStores.
+type RoleBinding struct {
+	Id        int64
+	Subject   string
+	Role      string
+	Store     string
+	CreatedAt time.Time
+}
+
+func (roleBinding RoleBinding) Grants(permission string) bool {
+	for _, granted := range StoreRolePermissions[roleBinding.Role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (roleBinding RoleBinding) Covers(store string) bool {
+	return roleBinding.Store == AllStores || roleBinding.Store == store
+}
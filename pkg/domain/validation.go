@@ -0,0 +1,41 @@
+package domain
+
+import "strings"
+
+// MaxTextLength is the length, in characters, of the VARCHAR(255) columns
+// that names, stores and identifiers are stored in.
+const MaxTextLength = 255
+
+// FieldViolation is one invalid field of a request. Pointer is a JSON
+// pointer to the field, e.g. "/external_ids/0/system", or the name of the
+// query parameter, e.g. "limit".
+type FieldViolation struct {
+	Pointer string
+	Message string
+}
+
+// ValidationError collects every invalid field of a request, so that clients
+// can report all of them at once.
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+func (err *ValidationError) Add(pointer string, message string) {
+	err.Violations = append(err.Violations, FieldViolation{pointer, message})
+}
+
+// OrNil returns err if any violation was added and nil otherwise.
+func (err *ValidationError) OrNil() error {
+	if len(err.Violations) == 0 {
+		return nil
+	}
+	return err
+}
+
+func (err *ValidationError) Error() string {
+	messages := make([]string, 0, len(err.Violations))
+	for _, violation := range err.Violations {
+		messages = append(messages, violation.Message)
+	}
+	return strings.Join(messages, "; ")
+}
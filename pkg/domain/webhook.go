@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook event types share their names with the audit actions they are
+// derived from.
+const (
+	WebhookEventProductCreated      = AuditActionProductCreated
+	WebhookEventProductPriceChanged = AuditActionProductPriceChanged
+	WebhookEventProductDeleted      = AuditActionProductDeleted
+)
+
+var WebhookEventTypes = []string{
+	WebhookEventProductCreated,
+	WebhookEventProductPriceChanged,
+	WebhookEventProductDeleted,
+}
+
+// WebhookSubscription asks for the events of EventTypes to be posted to Url,
+// signed with Secret.
+type WebhookSubscription struct {
+	Id         int64
+	Url        string
+	EventTypes []string
+	Secret     string
+	CreatedAt  time.Time
+}
+
+func (subscription WebhookSubscription) Accepts(eventType string) bool {
+	for _, subscribed := range subscription.EventTypes {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	// WebhookDeliveryDead marks deliveries that failed too often. They are
+	// kept for inspection and are only sent again when retried explicitly.
+	WebhookDeliveryDead = "dead"
+)
+
+// WebhookDelivery is one event to be posted to one subscription. EventId stays
+// the same across attempts, so receivers can discard duplicates.
+type WebhookDelivery struct {
+	Id             int64
+	SubscriptionId int64
+	EventId        string
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+type WebhookDeliveryFilter struct {
+	SubscriptionId int64
+	Status         string
+	Limit          int
+}
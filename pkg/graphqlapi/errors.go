@@ -0,0 +1,56 @@
+package graphqlapi
+
+import (
+	"context"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"net/http"
+)
+
+// problemError reports a problem as a GraphQL error. The stable problem code,
+// the HTTP status the REST API would have answered with and any invalid
+// fields are exposed as error extensions.
+type problemError struct {
+	problem *response.Problem
+}
+
+func (err *problemError) Error() string {
+	return err.problem.Detail
+}
+
+func (err *problemError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{
+		"code":   err.problem.Code,
+		"status": err.problem.Status,
+	}
+	if len(err.problem.Errors) > 0 {
+		extensions["errors"] = err.problem.Errors
+	}
+	return extensions
+}
+
+func newProblemError(status int, code string, detail string) error {
+	return &problemError{response.NewProblem(status, code, detail)}
+}
+
+// requestError reports a request that was rejected before execution, e.g.
+// for exceeding the query limits.
+func requestError(code string, detail string) gqlerrors.FormattedError {
+	formattedError := gqlerrors.NewFormattedError(detail)
+	formattedError.Extensions = (&problemError{response.NewProblem(http.StatusBadRequest, code, detail)}).Extensions()
+	return formattedError
+}
+
+// requireScope rejects resolvers the principal of ctx lacks scope for, with
+// the same problems as middleware.RequireScope.
+func requireScope(ctx context.Context, scope string) error {
+	principal, ok := domain.PrincipalFromContext(ctx)
+	if !ok {
+		return newProblemError(http.StatusUnauthorized, response.CodeUnauthorized, "authentication required")
+	}
+	if !principal.HasScope(scope) {
+		return newProblemError(http.StatusForbidden, response.CodeForbidden, "missing scope "+scope)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package graphqlapi
+
+import (
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"strconv"
+	"strings"
+)
+
+// Limits bound the cost of a query before it is executed. Depth counts the
+// levels of nested fields. Complexity counts the fields a query can resolve,
+// with the selection of a paginated field counted once per item of a page.
+type Limits struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// Introspection fields are measured against limits of their own, which admit
+// the query tools such as GraphiQL send to load the schema, so that the
+// schema stays loadable whatever the limits of regular queries are.
+const (
+	maxIntrospectionDepth      = 15
+	maxIntrospectionComplexity = 500
+)
+
+// queryCost is the depth and complexity of a selection set.
+type queryCost struct {
+	depth      int
+	complexity int
+}
+
+// costEstimator measures the operations of a validated document. The cost of
+// introspection fields is collected in introspection instead of the cost of
+// the selection set they are part of.
+type costEstimator struct {
+	fragments     map[string]*ast.FragmentDefinition
+	variables     map[string]interface{}
+	introspection queryCost
+}
+
+// checkLimits rejects documents with an operation exceeding limits. It must
+// only be called for documents that passed validation, which rules out
+// fragment cycles and unknown fragments.
+func checkLimits(document *ast.Document, variables map[string]interface{}, limits Limits) []gqlerrors.FormattedError {
+	estimator := costEstimator{fragments: map[string]*ast.FragmentDefinition{}, variables: variables}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			estimator.fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	var errors []gqlerrors.FormattedError
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		estimator.introspection = queryCost{}
+		cost := estimator.measure(operation.SelectionSet)
+		if estimator.introspection.depth > maxIntrospectionDepth {
+			errors = append(errors, requestError(response.CodeQueryTooComplex, fmt.Sprintf("introspection depth %d exceeds the limit of %d", estimator.introspection.depth, maxIntrospectionDepth)))
+		}
+		if estimator.introspection.complexity > maxIntrospectionComplexity {
+			errors = append(errors, requestError(response.CodeQueryTooComplex, fmt.Sprintf("introspection complexity %d exceeds the limit of %d", estimator.introspection.complexity, maxIntrospectionComplexity)))
+		}
+		if cost.depth > limits.MaxDepth {
+			errors = append(errors, requestError(response.CodeQueryTooComplex, fmt.Sprintf("query depth %d exceeds the limit of %d", cost.depth, limits.MaxDepth)))
+		}
+		if cost.complexity > limits.MaxComplexity {
+			errors = append(errors, requestError(response.CodeQueryTooComplex, fmt.Sprintf("query complexity %d exceeds the limit of %d", cost.complexity, limits.MaxComplexity)))
+		}
+	}
+	return errors
+}
+
+func (estimator *costEstimator) measure(selectionSet *ast.SelectionSet) queryCost {
+	var cost queryCost
+	if selectionSet == nil {
+		return cost
+	}
+
+	for _, selection := range selectionSet.Selections {
+		var selectionCost queryCost
+		switch selection := selection.(type) {
+		case *ast.Field:
+			childCost := estimator.measure(selection.SelectionSet)
+			selectionCost.depth = childCost.depth + 1
+			selectionCost.complexity = 1 + childCost.complexity*estimator.itemsPerPage(selection)
+			if strings.HasPrefix(selection.Name.Value, "__") && selection.Name.Value != "__typename" {
+				estimator.introspection.depth = max(estimator.introspection.depth, selectionCost.depth)
+				estimator.introspection.complexity += selectionCost.complexity
+				continue
+			}
+		case *ast.FragmentSpread:
+			if fragment, found := estimator.fragments[selection.Name.Value]; found {
+				selectionCost = estimator.measure(fragment.SelectionSet)
+			}
+		case *ast.InlineFragment:
+			selectionCost = estimator.measure(selection.SelectionSet)
+		}
+
+		cost.depth = max(cost.depth, selectionCost.depth)
+		cost.complexity += selectionCost.complexity
+	}
+	return cost
+}
+
+// itemsPerPage is how often the selection of field is resolved: the page
+// size for paginated fields and 1 for everything else.
+func (estimator *costEstimator) itemsPerPage(field *ast.Field) int {
+	if !paginatedFields[field.Name.Value] {
+		return 1
+	}
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != argumentFirst {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if first, err := strconv.Atoi(value.Value); err == nil && first > 0 {
+				return first
+			}
+		case *ast.Variable:
+			switch first := estimator.variables[value.Name.Value].(type) {
+			case int:
+				return max(first, 1)
+			case float64:
+				return max(int(first), 1)
+			}
+		}
+	}
+	return defaultPageSize
+}
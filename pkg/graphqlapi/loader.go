@@ -0,0 +1,101 @@
+package graphqlapi
+
+import (
+	"context"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"sync"
+)
+
+type storeLoaderKey struct{}
+
+// storeLoader batches the lookups of the store nodes of a request. Stores are
+// registered as they appear in results, and the first lookup for one of them
+// loads the data of every store registered so far in a single query, instead
+// of one query per store node.
+type storeLoader struct {
+	productService service.IProductService
+	mutex          sync.Mutex
+	stores         []string
+	registered     map[string]bool
+	counts         map[string]int64
+	// pages holds the product pages loaded so far, by page arguments.
+	pages map[string]*storeProductPages
+}
+
+type storeProductPages struct {
+	loaded map[string]bool
+	pages  map[string]domain.ProductPage
+}
+
+func contextWithStoreLoader(ctx context.Context, productService service.IProductService) context.Context {
+	return context.WithValue(ctx, storeLoaderKey{}, &storeLoader{
+		productService: productService,
+		registered:     map[string]bool{},
+		pages:          map[string]*storeProductPages{},
+	})
+}
+
+func storeLoaderFromContext(ctx context.Context) *storeLoader {
+	return ctx.Value(storeLoaderKey{}).(*storeLoader)
+}
+
+// register adds stores whose products may be looked up later.
+func (loader *storeLoader) register(stores ...string) {
+	loader.mutex.Lock()
+	defer loader.mutex.Unlock()
+	for _, store := range stores {
+		if !loader.registered[store] {
+			loader.registered[store] = true
+			loader.stores = append(loader.stores, store)
+		}
+	}
+}
+
+// productCounts counts the products of every store the principal may read,
+// once per request.
+func (loader *storeLoader) productCounts(ctx context.Context) (map[string]int64, error) {
+	loader.mutex.Lock()
+	defer loader.mutex.Unlock()
+	if loader.counts == nil {
+		counts, err := loader.productService.CountProductsByStore(ctx)
+		if err != nil {
+			return nil, err
+		}
+		loader.counts = counts
+	}
+	return loader.counts, nil
+}
+
+// productPage returns the page of products of store matching filter. key
+// identifies filter, afterId and limit, so that store nodes asking for the
+// same page share one query.
+func (loader *storeLoader) productPage(ctx context.Context, store string, key string, filter domain.ProductFilter, afterId int64, limit int) (domain.ProductPage, error) {
+	loader.register(store)
+	loader.mutex.Lock()
+	defer loader.mutex.Unlock()
+
+	storePages, found := loader.pages[key]
+	if !found {
+		storePages = &storeProductPages{loaded: map[string]bool{}, pages: map[string]domain.ProductPage{}}
+		loader.pages[key] = storePages
+	}
+	if !storePages.loaded[store] {
+		filter.Stores = []string{}
+		for _, registered := range loader.stores {
+			if !storePages.loaded[registered] {
+				filter.Stores = append(filter.Stores, registered)
+			}
+		}
+
+		pages, err := loader.productService.GetProductPagesByStore(ctx, filter, afterId, limit)
+		if err != nil {
+			return domain.ProductPage{}, err
+		}
+		for _, loaded := range filter.Stores {
+			storePages.loaded[loaded] = true
+			storePages.pages[loaded] = pages[loaded]
+		}
+	}
+	return storePages.pages[store], nil
+}
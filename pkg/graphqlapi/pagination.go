@@ -0,0 +1,119 @@
+package graphqlapi
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+
+	argumentFirst = "first"
+	argumentAfter = "after"
+)
+
+// connection is a page of a list, following the Relay cursor connection
+// specification.
+type connection struct {
+	Edges      []edge
+	PageInfo   pageInfo
+	TotalCount int
+}
+
+type edge struct {
+	Cursor string
+	Node   interface{}
+}
+
+type pageInfo struct {
+	HasNextPage bool
+	EndCursor   *string
+}
+
+// page is the first and after arguments of a paginated field. after is the
+// key of the last item the client has seen, or "" for the first page.
+type page struct {
+	kind  string
+	first int
+	after string
+}
+
+// pageArguments reads the arguments of a paginated field. Cursors are opaque
+// to clients; kind tells the cursors of different lists apart.
+func pageArguments(args map[string]interface{}, kind string) (page, error) {
+	requested := page{kind: kind, first: defaultPageSize}
+	if first, found := args[argumentFirst].(int); found {
+		requested.first = first
+	}
+	if requested.first < 0 || requested.first > maxPageSize {
+		return page{}, newProblemError(http.StatusBadRequest, response.CodeInvalidRequest, fmt.Sprintf("first must be between 0 and %d", maxPageSize))
+	}
+
+	after, _ := args[argumentAfter].(string)
+	if after == "" {
+		return requested, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(after)
+	key, found := strings.CutPrefix(string(decoded), kind+":")
+	if err != nil || !found || key == "" {
+		return page{}, newProblemError(http.StatusBadRequest, response.CodeInvalidRequest, "after is not a valid cursor")
+	}
+	requested.after = key
+	return requested, nil
+}
+
+// afterId reads the cursor of a page whose keys are ids, 0 for the first
+// page.
+func (requested page) afterId() (int64, error) {
+	if requested.after == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(requested.after, 10, 64)
+	if err != nil {
+		return 0, newProblemError(http.StatusBadRequest, response.CodeInvalidRequest, "after is not a valid cursor")
+	}
+	return id, nil
+}
+
+// paginate returns the requested page of items, which must be sorted by key.
+// The page starts after the first key greater than the cursor, so cursors stay
+// valid when the item they point at is deleted. It is meant for short lists;
+// long ones are paginated by the services.
+func paginate[T any](items []T, keyOf func(item T) string, requested page) connection {
+	start := 0
+	if requested.after != "" {
+		start = len(items)
+		for i, item := range items {
+			if keyOf(item) > requested.after {
+				start = i
+				break
+			}
+		}
+	}
+	end := min(start+requested.first, len(items))
+	return newConnection(items[start:end], keyOf, requested, len(items), end < len(items))
+}
+
+// newConnection wraps a page of items, giving each the cursor of its key.
+func newConnection[T any](items []T, keyOf func(item T) string, requested page, totalCount int, hasNextPage bool) connection {
+	result := connection{Edges: []edge{}, TotalCount: totalCount}
+	for _, item := range items {
+		cursor := base64.RawURLEncoding.EncodeToString([]byte(requested.kind + ":" + keyOf(item)))
+		result.Edges = append(result.Edges, edge{Cursor: cursor, Node: item})
+	}
+	result.PageInfo.HasNextPage = hasNextPage
+	if len(result.Edges) > 0 {
+		result.PageInfo.EndCursor = &result.Edges[len(result.Edges)-1].Cursor
+	}
+	return result
+}
+
+// idKey formats an id so that keys sort like the ids themselves.
+func idKey(id int64) string {
+	return fmt.Sprintf("%020d", id)
+}
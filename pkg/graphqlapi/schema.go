@@ -0,0 +1,592 @@
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/controller/request"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/graphql-go/graphql"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+const (
+	cursorKindProduct     = "product"
+	cursorKindStore       = "store"
+	cursorKindPriceChange = "price"
+)
+
+// paginatedFields are the fields whose selection is resolved once per item of
+// a page, see costEstimator.
+var paginatedFields = map[string]bool{
+	"products":     true,
+	"stores":       true,
+	"priceHistory": true,
+}
+
+// store is the source of the Store type. Stores have no table of their own;
+// they are the distinct stores of the products the caller may see.
+type store struct {
+	Name string
+}
+
+// resolvers implements the fields of the schema on top of the services.
+type resolvers struct {
+	productService service.IProductService
+	auditService   service.IAuditService
+	logger         *slog.Logger
+}
+
+func newSchema(resolvers *resolvers) (graphql.Schema, error) {
+	externalIdType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ExternalId",
+		Fields: graphql.Fields{
+			"system": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"id":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+	priceChangeType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "PriceChange",
+		Description: "A price a product had. previousPrice is null for the price it was created with.",
+		Fields: graphql.Fields{
+			"price":         &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"previousPrice": &graphql.Field{Type: graphql.Float},
+			"actor":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"changedAt":     &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		},
+	})
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"endCursor":   &graphql.Field{Type: graphql.String},
+		},
+	})
+	productFilterType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "ProductFilter",
+		Description: "Products match a filter if they match all of its fields.",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"store":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"sku":          &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"nameContains": &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Case-insensitive part of the name."},
+			"minPrice":     &graphql.InputObjectFieldConfig{Type: graphql.Float},
+			"maxPrice":     &graphql.InputObjectFieldConfig{Type: graphql.Float},
+		},
+	})
+	externalIdInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "ExternalIdInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"system": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"id":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+	addProductInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "AddProductInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name":        &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"price":       &graphql.InputObjectFieldConfig{Type: graphql.Float},
+			"discount":    &graphql.InputObjectFieldConfig{Type: graphql.Float},
+			"store":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"sku":         &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"externalIds": &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(externalIdInputType))},
+		},
+	})
+	upsertProductInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UpsertProductInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"price":    &graphql.InputObjectFieldConfig{Type: graphql.Float},
+			"discount": &graphql.InputObjectFieldConfig{Type: graphql.Float},
+		},
+	})
+
+	pageArgs := func(extra graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+		args := graphql.FieldConfigArgument{
+			argumentFirst: &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize, Description: fmt.Sprintf("Page size, at most %d.", maxPageSize)},
+			argumentAfter: &graphql.ArgumentConfig{Type: graphql.String, Description: "endCursor of the previous page."},
+		}
+		for name, argument := range extra {
+			args[name] = argument
+		}
+		return args
+	}
+	connectionType := func(name string, nodeType graphql.Output) *graphql.Object {
+		edgeType := graphql.NewObject(graphql.ObjectConfig{
+			Name: name + "Edge",
+			Fields: graphql.Fields{
+				"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"node":   &graphql.Field{Type: graphql.NewNonNull(nodeType)},
+			},
+		})
+		return graphql.NewObject(graphql.ObjectConfig{
+			Name: name + "Connection",
+			Fields: graphql.Fields{
+				"edges":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(edgeType)))},
+				"pageInfo":   &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+				"totalCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			},
+		})
+	}
+	priceChangeConnectionType := connectionType("PriceChange", priceChangeType)
+
+	// Product and Store refer to each other, so their fields are defined
+	// once both types exist.
+	productType := graphql.NewObject(graphql.ObjectConfig{Name: "Product", Fields: graphql.Fields{}})
+	storeType := graphql.NewObject(graphql.ObjectConfig{Name: "Store", Fields: graphql.Fields{}})
+	productConnectionType := connectionType("Product", productType)
+
+	productType.AddFieldConfig("id", &graphql.Field{Type: graphql.NewNonNull(graphql.ID)})
+	productType.AddFieldConfig("name", &graphql.Field{Type: graphql.NewNonNull(graphql.String)})
+	productType.AddFieldConfig("price", &graphql.Field{Type: graphql.NewNonNull(graphql.Float)})
+	productType.AddFieldConfig("discount", &graphql.Field{Type: graphql.NewNonNull(graphql.Float)})
+	productType.AddFieldConfig("sku", &graphql.Field{Type: graphql.String, Resolve: resolvers.productSku})
+	productType.AddFieldConfig("externalIds", &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(externalIdType))), Resolve: resolvers.productExternalIds})
+	productType.AddFieldConfig("store", &graphql.Field{Type: graphql.NewNonNull(storeType), Resolve: resolvers.productStore})
+	productType.AddFieldConfig("priceHistory", &graphql.Field{Type: graphql.NewNonNull(priceChangeConnectionType), Args: pageArgs(nil), Resolve: resolvers.productPriceHistory})
+
+	storeType.AddFieldConfig("name", &graphql.Field{Type: graphql.NewNonNull(graphql.String)})
+	storeType.AddFieldConfig("productCount", &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: resolvers.storeProductCount})
+	storeType.AddFieldConfig("products", &graphql.Field{
+		Type:    graphql.NewNonNull(productConnectionType),
+		Args:    pageArgs(graphql.FieldConfigArgument{"filter": &graphql.ArgumentConfig{Type: productFilterType}}),
+		Resolve: resolvers.storeProducts,
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"product": &graphql.Field{
+				Type:    productType,
+				Args:    graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: resolvers.product,
+			},
+			"productByExternalId": &graphql.Field{
+				Type: productType,
+				Args: graphql.FieldConfigArgument{
+					"system": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"id":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: resolvers.productByExternalId,
+			},
+			"products": &graphql.Field{
+				Type:    graphql.NewNonNull(productConnectionType),
+				Args:    pageArgs(graphql.FieldConfigArgument{"filter": &graphql.ArgumentConfig{Type: productFilterType}}),
+				Resolve: resolvers.products,
+			},
+			"store": &graphql.Field{
+				Type:    storeType,
+				Args:    graphql.FieldConfigArgument{"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: resolvers.store,
+			},
+			"stores": &graphql.Field{
+				Type:    graphql.NewNonNull(connectionType("Store", storeType)),
+				Args:    pageArgs(nil),
+				Resolve: resolvers.stores,
+			},
+			"priceHistory": &graphql.Field{
+				Type:    graphql.NewNonNull(priceChangeConnectionType),
+				Args:    pageArgs(graphql.FieldConfigArgument{"productId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}}),
+				Resolve: resolvers.priceHistory,
+			},
+		},
+	})
+
+	priceArgs := func(extra graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+		extra["newPrice"] = &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Float)}
+		return extra
+	}
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"addProduct": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.Boolean),
+				Args:    graphql.FieldConfigArgument{"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(addProductInputType)}},
+				Resolve: resolvers.addProduct,
+			},
+			"upsertProductBySku": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewObject(graphql.ObjectConfig{
+					Name: "UpsertProductPayload",
+					Fields: graphql.Fields{
+						"product": &graphql.Field{Type: graphql.NewNonNull(productType)},
+						"created": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+					},
+				})),
+				Args: graphql.FieldConfigArgument{
+					"store": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"sku":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(upsertProductInputType)},
+				},
+				Resolve: resolvers.upsertProductBySku,
+			},
+			"updatePrice": &graphql.Field{
+				Type:    graphql.NewNonNull(productType),
+				Args:    priceArgs(graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}}),
+				Resolve: resolvers.updatePrice,
+			},
+			"updatePriceByExternalId": &graphql.Field{
+				Type: graphql.NewNonNull(productType),
+				Args: priceArgs(graphql.FieldConfigArgument{
+					"system": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"id":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				}),
+				Resolve: resolvers.updatePriceByExternalId,
+			},
+			"deleteProduct": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.ID),
+				Args:    graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: resolvers.deleteProduct,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType, Mutation: mutationType})
+}
+
+func (resolvers *resolvers) product(p graphql.ResolveParams) (interface{}, error) {
+	productId, err := idArgument(p.Args, "id")
+	if err != nil {
+		return nil, err
+	}
+	product, err := resolvers.productService.GetById(p.Context, productId)
+	if errors.Is(err, domain.ErrProductNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, resolvers.serviceError(p.Context, "get", err)
+	}
+	return product, nil
+}
+
+func (resolvers *resolvers) productByExternalId(p graphql.ResolveParams) (interface{}, error) {
+	externalId, err := externalIdArgument(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	product, err := resolvers.productService.GetByExternalId(p.Context, externalId)
+	if errors.Is(err, domain.ErrProductNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, resolvers.serviceError(p.Context, "get by external id", err)
+	}
+	return product, nil
+}
+
+func (resolvers *resolvers) products(p graphql.ResolveParams) (interface{}, error) {
+	requested, err := pageArguments(p.Args, cursorKindProduct)
+	if err != nil {
+		return nil, err
+	}
+	afterId, err := requested.afterId()
+	if err != nil {
+		return nil, err
+	}
+
+	productPage, err := resolvers.productService.GetProductPage(p.Context, productFilterArgument(p.Args), afterId, requested.first)
+	if err != nil {
+		return nil, resolvers.serviceError(p.Context, "list", err)
+	}
+	for _, product := range productPage.Products {
+		storeLoaderFromContext(p.Context).register(product.Store)
+	}
+	return productConnection(productPage, requested), nil
+}
+
+func (resolvers *resolvers) store(p graphql.ResolveParams) (interface{}, error) {
+	storeName, _ := p.Args["name"].(string)
+	counts, err := storeLoaderFromContext(p.Context).productCounts(p.Context)
+	if err != nil {
+		return nil, resolvers.serviceError(p.Context, "count", err)
+	}
+	if counts[storeName] == 0 {
+		return nil, nil
+	}
+	storeLoaderFromContext(p.Context).register(storeName)
+	return store{storeName}, nil
+}
+
+// stores lists the stores with products the caller may see. There are few
+// stores, so they are paginated in memory.
+func (resolvers *resolvers) stores(p graphql.ResolveParams) (interface{}, error) {
+	requested, err := pageArguments(p.Args, cursorKindStore)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := storeLoaderFromContext(p.Context).productCounts(p.Context)
+	if err != nil {
+		return nil, resolvers.serviceError(p.Context, "count", err)
+	}
+
+	stores := []store{}
+	for storeName := range counts {
+		stores = append(stores, store{storeName})
+	}
+	sort.Slice(stores, func(i, j int) bool { return stores[i].Name < stores[j].Name })
+	storeConnection := paginate(stores, func(store store) string { return store.Name }, requested)
+	for _, storeEdge := range storeConnection.Edges {
+		storeLoaderFromContext(p.Context).register(storeEdge.Node.(store).Name)
+	}
+	return storeConnection, nil
+}
+
+// priceHistory loads the product first, so that its history is only listed to
+// principals who may read the product itself.
+func (resolvers *resolvers) priceHistory(p graphql.ResolveParams) (interface{}, error) {
+	productId, err := idArgument(p.Args, "productId")
+	if err != nil {
+		return nil, err
+	}
+	_, err = resolvers.productService.GetById(p.Context, productId)
+	if err != nil {
+		return nil, resolvers.serviceError(p.Context, "get", err)
+	}
+	return resolvers.paginatePriceHistory(p, productId)
+}
+
+func (resolvers *resolvers) productSku(p graphql.ResolveParams) (interface{}, error) {
+	product := p.Source.(domain.Product)
+	if product.Sku == "" {
+		return nil, nil
+	}
+	return product.Sku, nil
+}
+
+func (resolvers *resolvers) productExternalIds(p graphql.ResolveParams) (interface{}, error) {
+	product := p.Source.(domain.Product)
+	if product.ExternalIds == nil {
+		return []domain.ExternalId{}, nil
+	}
+	return product.ExternalIds, nil
+}
+
+func (resolvers *resolvers) productStore(p graphql.ResolveParams) (interface{}, error) {
+	storeName := p.Source.(domain.Product).Store
+	storeLoaderFromContext(p.Context).register(storeName)
+	return store{storeName}, nil
+}
+
+func (resolvers *resolvers) productPriceHistory(p graphql.ResolveParams) (interface{}, error) {
+	return resolvers.paginatePriceHistory(p, p.Source.(domain.Product).Id)
+}
+
+func (resolvers *resolvers) storeProductCount(p graphql.ResolveParams) (interface{}, error) {
+	counts, err := storeLoaderFromContext(p.Context).productCounts(p.Context)
+	if err != nil {
+		return nil, resolvers.serviceError(p.Context, "count", err)
+	}
+	return counts[p.Source.(store).Name], nil
+}
+
+func (resolvers *resolvers) storeProducts(p graphql.ResolveParams) (interface{}, error) {
+	requested, err := pageArguments(p.Args, cursorKindProduct)
+	if err != nil {
+		return nil, err
+	}
+	afterId, err := requested.afterId()
+	if err != nil {
+		return nil, err
+	}
+
+	storeName := p.Source.(store).Name
+	filter := productFilterArgument(p.Args)
+	if filter.Stores != nil && !slices.Contains(filter.Stores, storeName) {
+		return productConnection(domain.ProductPage{}, requested), nil
+	}
+
+	key := fmt.Sprintf("%v|%d|%d", p.Args["filter"], afterId, requested.first)
+	productPage, err := storeLoaderFromContext(p.Context).productPage(p.Context, storeName, key, filter, afterId, requested.first)
+	if err != nil {
+		return nil, resolvers.serviceError(p.Context, "list", err)
+	}
+	return productConnection(productPage, requested), nil
+}
+
+func (resolvers *resolvers) addProduct(p graphql.ResolveParams) (interface{}, error) {
+	if err := requireScope(p.Context, domain.ScopeProductsWrite); err != nil {
+		return nil, err
+	}
+
+	input, _ := p.Args["input"].(map[string]interface{})
+	productRequest := request.AddProductRequest{
+		Name:     stringArgument(input, "name"),
+		Price:    float32Argument(input, "price"),
+		Discount: float32Argument(input, "discount"),
+		Store:    stringArgument(input, "store"),
+		Sku:      stringArgument(input, "sku"),
+	}
+	externalIds, _ := input["externalIds"].([]interface{})
+	for _, externalId := range externalIds {
+		externalIdInput, _ := externalId.(map[string]interface{})
+		productRequest.ExternalIds = append(productRequest.ExternalIds, request.ExternalIdRequest{
+			System: stringArgument(externalIdInput, "system"),
+			Id:     stringArgument(externalIdInput, "id"),
+		})
+	}
+	productRequest.Normalize()
+
+	err := resolvers.productService.Add(p.Context, productRequest.ToModel())
+	if err != nil {
+		return nil, resolvers.serviceError(p.Context, "add", err)
+	}
+	return true, nil
+}
+
+func (resolvers *resolvers) upsertProductBySku(p graphql.ResolveParams) (interface{}, error) {
+	if err := requireScope(p.Context, domain.ScopeProductsWrite); err != nil {
+		return nil, err
+	}
+
+	input, _ := p.Args["input"].(map[string]interface{})
+	productRequest := request.UpsertProductRequest{
+		Name:     stringArgument(input, "name"),
+		Price:    float32Argument(input, "price"),
+		Discount: float32Argument(input, "discount"),
+	}
+	productRequest.Normalize()
+
+	product, created, err := resolvers.productService.UpsertBySku(p.Context, productRequest.ToModel(stringArgument(p.Args, "store"), stringArgument(p.Args, "sku")))
+	if err != nil {
+		return nil, resolvers.serviceError(p.Context, "upsert", err)
+	}
+	return map[string]interface{}{"product": product, "created": created}, nil
+}
+
+func (resolvers *resolvers) updatePrice(p graphql.ResolveParams) (interface{}, error) {
+	if err := requireScope(p.Context, domain.ScopeProductsWrite); err != nil {
+		return nil, err
+	}
+	productId, err := idArgument(p.Args, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	err = resolvers.productService.UpdatePrice(p.Context, productId, float32Argument(p.Args, "newPrice"))
+	if err != nil {
+		return nil, resolvers.serviceError(p.Context, "update price", err)
+	}
+	return resolvers.productService.GetById(p.Context, productId)
+}
+
+func (resolvers *resolvers) updatePriceByExternalId(p graphql.ResolveParams) (interface{}, error) {
+	if err := requireScope(p.Context, domain.ScopeProductsWrite); err != nil {
+		return nil, err
+	}
+	externalId, err := externalIdArgument(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	err = resolvers.productService.UpdatePriceByExternalId(p.Context, externalId, float32Argument(p.Args, "newPrice"))
+	if err != nil {
+		return nil, resolvers.serviceError(p.Context, "update price by external id", err)
+	}
+	return resolvers.productService.GetByExternalId(p.Context, externalId)
+}
+
+func (resolvers *resolvers) deleteProduct(p graphql.ResolveParams) (interface{}, error) {
+	if err := requireScope(p.Context, domain.ScopeProductsDelete); err != nil {
+		return nil, err
+	}
+	productId, err := idArgument(p.Args, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	err = resolvers.productService.DeleteById(p.Context, productId)
+	if err != nil {
+		return nil, resolvers.serviceError(p.Context, "delete", err)
+	}
+	return productId, nil
+}
+
+// paginatePriceHistory returns the price changes of a product, newest first.
+func (resolvers *resolvers) paginatePriceHistory(p graphql.ResolveParams, productId int64) (interface{}, error) {
+	requested, err := pageArguments(p.Args, cursorKindPriceChange)
+	if err != nil {
+		return nil, err
+	}
+	beforeEventId, err := requested.afterId()
+	if err != nil {
+		return nil, err
+	}
+
+	priceChangePage, err := resolvers.auditService.GetPriceHistory(p.Context, productId, beforeEventId, requested.first)
+	if err != nil {
+		resolvers.logger.ErrorContext(p.Context, "failed to load price history", "product_id", productId, "error", err)
+		return nil, newProblemError(http.StatusInternalServerError, response.CodeInternalError, "failed to load price history")
+	}
+	return newConnection(priceChangePage.PriceChanges, func(priceChange domain.PriceChange) string { return idKey(priceChange.EventId) },
+		requested, int(priceChangePage.TotalCount), priceChangePage.HasNextPage), nil
+}
+
+// serviceError maps err like the REST API does and logs failures other than
+// authorization failures, conflicts and missing products. Errors the service
+// does not classify become internal errors without their message.
+func (resolvers *resolvers) serviceError(ctx context.Context, operation string, err error) error {
+	problem := response.ServiceProblem(http.StatusInternalServerError, err)
+	switch problem.Status {
+	case http.StatusForbidden, http.StatusConflict, http.StatusNotFound:
+	case http.StatusInternalServerError:
+		resolvers.logger.ErrorContext(ctx, "product operation failed", "operation", operation, "error", err)
+	default:
+		resolvers.logger.WarnContext(ctx, "product change failed", "operation", operation, "error", err)
+	}
+	return &problemError{problem}
+}
+
+func productConnection(productPage domain.ProductPage, requested page) connection {
+	return newConnection(productPage.Products, func(product domain.Product) string { return idKey(product.Id) },
+		requested, int(productPage.TotalCount), productPage.HasNextPage)
+}
+
+// productFilterArgument reads the filter argument of a product list.
+func productFilterArgument(args map[string]interface{}) domain.ProductFilter {
+	filter, _ := args["filter"].(map[string]interface{})
+	productFilter := domain.ProductFilter{Sku: stringArgument(filter, "sku"), NameContains: stringArgument(filter, "nameContains")}
+	if storeName, found := filter["store"].(string); found {
+		productFilter.Stores = []string{storeName}
+	}
+	if minPrice, found := filter["minPrice"].(float64); found {
+		value := float32(minPrice)
+		productFilter.MinPrice = &value
+	}
+	if maxPrice, found := filter["maxPrice"].(float64); found {
+		value := float32(maxPrice)
+		productFilter.MaxPrice = &value
+	}
+	return productFilter
+}
+
+func idArgument(args map[string]interface{}, name string) (int64, error) {
+	id, err := strconv.ParseInt(stringArgument(args, name), 10, 64)
+	if err != nil {
+		return 0, newProblemError(http.StatusBadRequest, response.CodeInvalidRequest, name+" must be an integer")
+	}
+	return id, nil
+}
+
+func externalIdArgument(args map[string]interface{}) (domain.ExternalId, error) {
+	externalIdRequest := request.ExternalIdRequest{System: stringArgument(args, "system"), Id: stringArgument(args, "id")}
+	externalIdRequest.Normalize()
+	if externalIdRequest.System == "" || externalIdRequest.Id == "" {
+		return domain.ExternalId{}, newProblemError(http.StatusBadRequest, response.CodeInvalidRequest, "external system and id must be specified")
+	}
+	return externalIdRequest.ToModel(), nil
+}
+
+func stringArgument(args map[string]interface{}, name string) string {
+	value, _ := args[name].(string)
+	return value
+}
+
+func float32Argument(args map[string]interface{}, name string) float32 {
+	value, _ := args[name].(float64)
+	return float32(value)
+}
@@ -0,0 +1,54 @@
+package graphqlapi
+
+import (
+	"context"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"log/slog"
+)
+
+// Server executes GraphQL queries against the product schema. Mutations go
+// through IProductService and are normalized with the same request types as
+// the REST API, like those of the gRPC API.
+type Server struct {
+	schema         graphql.Schema
+	limits         Limits
+	productService service.IProductService
+}
+
+func NewServer(productService service.IProductService, auditService service.IAuditService, limits Limits, logger *slog.Logger) (*Server, error) {
+	schema, err := newSchema(&resolvers{productService, auditService, logger})
+	if err != nil {
+		return nil, err
+	}
+	return &Server{schema, limits, productService}, nil
+}
+
+// Execute parses and validates query and runs the operation named
+// operationName. Queries exceeding the limits are rejected before any field
+// is resolved.
+func (server *Server) Execute(ctx context.Context, query string, operationName string, variables map[string]interface{}) *graphql.Result {
+	document, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(query), Name: "GraphQL request"})})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	validation := graphql.ValidateDocument(&server.schema, document, nil)
+	if !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+	if limitErrors := checkLimits(document, variables, server.limits); len(limitErrors) > 0 {
+		return &graphql.Result{Errors: limitErrors}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        server.schema,
+		AST:           document,
+		OperationName: operationName,
+		Args:          variables,
+		Context:       contextWithStoreLoader(ctx, server.productService),
+	})
+}
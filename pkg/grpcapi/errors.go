@@ -0,0 +1,63 @@
+package grpcapi
+
+import (
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+// errorDomain identifies this API in the ErrorInfo details of a status.
+const errorDomain = "product-api"
+
+var httpStatusCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusConflict:              codes.AlreadyExists,
+	http.StatusUnprocessableEntity:   codes.InvalidArgument,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusInternalServerError:   codes.Internal,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+	http.StatusRequestEntityTooLarge: codes.ResourceExhausted,
+}
+
+// serviceStatus maps an error returned by a service to a gRPC status, using
+// the same mapping as the REST API. The stable problem code is attached as
+// ErrorInfo reason and invalid fields as BadRequest field violations.
+func serviceStatus(httpStatus int, err error) error {
+	return problemStatus(response.ServiceProblem(httpStatus, err))
+}
+
+func problemStatus(problem *response.Problem) error {
+	code, found := httpStatusCodes[problem.Status]
+	if !found {
+		code = codes.Unknown
+	}
+
+	grpcStatus := status.New(code, problem.Detail)
+	withDetails, err := grpcStatus.WithDetails(&errdetails.ErrorInfo{Reason: problem.Code, Domain: errorDomain})
+	if err != nil {
+		return grpcStatus.Err()
+	}
+
+	if len(problem.Errors) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, fieldError := range problem.Errors {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       fieldError.Pointer,
+				Description: fieldError.Detail,
+			})
+		}
+		if withFieldViolations, err := withDetails.WithDetails(badRequest); err == nil {
+			withDetails = withFieldViolations
+		}
+	}
+	return withDetails.Err()
+}
+
+func newStatus(httpStatus int, code string, detail string) error {
+	return problemStatus(response.NewProblem(httpStatus, code, detail))
+}
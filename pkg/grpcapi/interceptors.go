@@ -0,0 +1,217 @@
+package grpcapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/erkindilekci/product-api/pkg/common/metrics"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/grpcapi/productpb"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	MetadataApiKey        = "x-api-key"
+	MetadataAuthorization = "authorization"
+	MetadataRequestId     = "x-request-id"
+
+	bearerPrefix       = "Bearer "
+	maxRequestIdLength = 128
+)
+
+// methodScopes lists the scope each method needs, mirroring the REST routes.
+// Methods missing from the map are rejected.
+var methodScopes = map[string]string{
+	productpb.ProductService_AddProduct_FullMethodName:              domain.ScopeProductsWrite,
+	productpb.ProductService_UpsertProductBySku_FullMethodName:      domain.ScopeProductsWrite,
+	productpb.ProductService_GetProduct_FullMethodName:              domain.ScopeProductsRead,
+	productpb.ProductService_GetProductByExternalId_FullMethodName:  domain.ScopeProductsRead,
+	productpb.ProductService_ListProducts_FullMethodName:            domain.ScopeProductsRead,
+	productpb.ProductService_UpdatePrice_FullMethodName:             domain.ScopeProductsWrite,
+	productpb.ProductService_UpdatePriceByExternalId_FullMethodName: domain.ScopeProductsWrite,
+	productpb.ProductService_DeleteProduct_FullMethodName:           domain.ScopeProductsDelete,
+}
+
+// Authenticator resolves the credentials of a call to a principal. jwtService
+// may be nil when bearer tokens are not accepted.
+type Authenticator struct {
+	apiKeyService service.IApiKeyService
+	jwtService    service.IJwtService
+	logger        *slog.Logger
+}
+
+func NewAuthenticator(apiKeyService service.IApiKeyService, jwtService service.IJwtService, logger *slog.Logger) *Authenticator {
+	return &Authenticator{apiKeyService, jwtService, logger}
+}
+
+// ServerOptions installs the interceptors that trace, count, rate limit,
+// authenticate and log every call, in the order the REST middlewares run.
+// rateLimitService may be nil when rate limiting is disabled.
+func ServerOptions(authenticator *Authenticator, rateLimitService service.IRateLimitService, registry *metrics.Registry) []grpc.ServerOption {
+	callMetrics := newCallMetrics(registry)
+	unaryInterceptors := []grpc.UnaryServerInterceptor{tracingUnaryInterceptor, callMetrics.unaryInterceptor}
+	streamInterceptors := []grpc.StreamServerInterceptor{tracingStreamInterceptor, callMetrics.streamInterceptor}
+
+	if rateLimitService == nil {
+		unaryInterceptors = append(unaryInterceptors, authenticator.unaryInterceptor)
+		streamInterceptors = append(streamInterceptors, authenticator.streamInterceptor)
+	} else {
+		limiter := &rateLimiter{rateLimitService}
+		unaryInterceptors = append(unaryInterceptors, limiter.ipUnaryInterceptor, authenticator.unaryInterceptor, limiter.unaryInterceptor)
+		streamInterceptors = append(streamInterceptors, limiter.ipStreamInterceptor, authenticator.streamInterceptor, limiter.streamInterceptor)
+	}
+
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}
+}
+
+func (authenticator *Authenticator) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	ctx, err := authenticator.authorize(ctx, info.FullMethod)
+	var resp interface{}
+	if err == nil {
+		resp, err = handler(ctx, req)
+	}
+	authenticator.logCall(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+func (authenticator *Authenticator) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, err := authenticator.authorize(stream.Context(), info.FullMethod)
+	if err == nil {
+		err = handler(srv, &contextServerStream{stream, ctx})
+	}
+	authenticator.logCall(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// authorize stores the request metadata and principal of the call in ctx and
+// checks that the principal has the scope the method needs.
+func (authenticator *Authenticator) authorize(ctx context.Context, method string) (context.Context, error) {
+	incoming, _ := metadata.FromIncomingContext(ctx)
+	ctx = domain.ContextWithRequestMetadata(ctx, domain.RequestMetadata{
+		RequestId: requestId(incoming),
+		ClientIp:  clientIp(ctx),
+	})
+
+	principal, authenticated, err := authenticator.authenticate(incoming)
+	if err != nil {
+		return ctx, err
+	}
+	if authenticated {
+		ctx = domain.ContextWithPrincipal(ctx, principal)
+	}
+
+	scope, known := methodScopes[method]
+	switch {
+	case !known:
+		return ctx, newStatus(http.StatusNotFound, response.CodeRouteNotFound, "unknown method "+method)
+	case !authenticated:
+		return ctx, newStatus(http.StatusUnauthorized, response.CodeUnauthorized, "authentication required")
+	case !principal.HasScope(scope):
+		return ctx, newStatus(http.StatusForbidden, response.CodeForbidden, "missing scope "+scope)
+	}
+	return ctx, nil
+}
+
+func (authenticator *Authenticator) authenticate(incoming metadata.MD) (domain.Principal, bool, error) {
+	if token := firstValue(incoming, MetadataApiKey); token != "" {
+		principal, err := authenticator.apiKeyService.Authenticate(token)
+		if err != nil {
+			return domain.Principal{}, false, newStatus(http.StatusUnauthorized, response.CodeUnauthorized, "invalid api key")
+		}
+		return principal, true, nil
+	}
+
+	authorization := firstValue(incoming, MetadataAuthorization)
+	if authenticator.jwtService != nil && strings.HasPrefix(authorization, bearerPrefix) {
+		principal, err := authenticator.jwtService.Authenticate(strings.TrimPrefix(authorization, bearerPrefix))
+		if err != nil {
+			return domain.Principal{}, false, newStatus(http.StatusUnauthorized, response.CodeUnauthorized, "invalid bearer token")
+		}
+		return principal, true, nil
+	}
+	return domain.Principal{}, false, nil
+}
+
+func (authenticator *Authenticator) logCall(ctx context.Context, method string, start time.Time, err error) {
+	authenticator.logger.InfoContext(ctx, "rpc completed",
+		"method", method,
+		"code", status.Code(err).String(),
+		"duration_ms", time.Since(start).Milliseconds(),
+		"actor", actor(ctx),
+	)
+}
+
+func actor(ctx context.Context) string {
+	if principal, ok := domain.PrincipalFromContext(ctx); ok {
+		return principal.Subject
+	}
+	return "anonymous"
+}
+
+// requestId takes the id from the x-request-id metadata entry, like the REST
+// API does with the X-Request-ID header, and generates one otherwise.
+func requestId(incoming metadata.MD) string {
+	id := firstValue(incoming, MetadataRequestId)
+	if id != "" && len(id) <= maxRequestIdLength && isPrintableAscii(id) {
+		return id
+	}
+
+	generated := make([]byte, 16)
+	_, _ = rand.Read(generated)
+	return hex.EncodeToString(generated)
+}
+
+func isPrintableAscii(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] < 0x21 || value[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func clientIp(ctx context.Context) string {
+	callPeer, ok := peer.FromContext(ctx)
+	if !ok || callPeer.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(callPeer.Addr.String())
+	if err != nil {
+		return callPeer.Addr.String()
+	}
+	return host
+}
+
+func firstValue(incoming metadata.MD, key string) string {
+	values := incoming.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// contextServerStream replaces the context of a stream with the one carrying
+// the principal.
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *contextServerStream) Context() context.Context {
+	return stream.ctx
+}
@@ -0,0 +1,111 @@
+package grpcapi
+
+import (
+	"context"
+	"github.com/erkindilekci/product-api/pkg/common/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+	"time"
+)
+
+var tracer = otel.Tracer("github.com/erkindilekci/product-api/pkg/grpcapi")
+
+// tracingUnaryInterceptor starts a server span for every call, continuing the
+// trace given in the traceparent metadata entry if there is one, like the
+// Tracing middleware of the REST API.
+func tracingUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, span := startSpan(ctx, info.FullMethod)
+	resp, err := handler(ctx, req)
+	endSpan(span, err)
+	return resp, err
+}
+
+func tracingStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, span := startSpan(stream.Context(), info.FullMethod)
+	err := handler(srv, &contextServerStream{stream, ctx})
+	endSpan(span, err)
+	return err
+}
+
+func startSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	incoming, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(incoming))
+
+	serviceName, methodName, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	return tracer.Start(ctx, strings.TrimPrefix(method, "/"), trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("rpc.system", "grpc"), attribute.String("rpc.service", serviceName), attribute.String("rpc.method", methodName)))
+}
+
+func endSpan(span trace.Span, err error) {
+	code := status.Code(err)
+	span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(code)))
+	switch code {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss:
+		span.SetStatus(otelcodes.Error, code.String())
+	}
+	span.End()
+}
+
+// metadataCarrier lets the propagator read the trace context from the
+// metadata of a call.
+type metadataCarrier metadata.MD
+
+func (carrier metadataCarrier) Get(key string) string {
+	values := metadata.MD(carrier).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (carrier metadataCarrier) Set(key string, value string) {
+	metadata.MD(carrier).Set(key, value)
+}
+
+func (carrier metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(carrier))
+	for key := range carrier {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// callMetrics counts calls and records their latency per method and code.
+type callMetrics struct {
+	callCount    *metrics.CounterVec
+	callDuration *metrics.HistogramVec
+}
+
+func newCallMetrics(registry *metrics.Registry) *callMetrics {
+	return &callMetrics{
+		callCount:    registry.Counter("grpc_requests_total", "Number of gRPC calls.", "method", "code"),
+		callDuration: registry.Histogram("grpc_request_duration_seconds", "Duration of gRPC calls.", metrics.DefaultBuckets, "method", "code"),
+	}
+}
+
+func (callMetrics *callMetrics) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	callMetrics.observe(info.FullMethod, start, err)
+	return resp, err
+}
+
+func (callMetrics *callMetrics) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, stream)
+	callMetrics.observe(info.FullMethod, start, err)
+	return err
+}
+
+func (callMetrics *callMetrics) observe(method string, start time.Time, err error) {
+	code := status.Code(err).String()
+	callMetrics.callCount.Inc(method, code)
+	callMetrics.callDuration.Observe(time.Since(start).Seconds(), method, code)
+}
@@ -0,0 +1,194 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/controller/request"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/grpcapi/productpb"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"google.golang.org/grpc"
+	"log/slog"
+	"net/http"
+)
+
+// ProductServer implements the gRPC product API on top of IProductService.
+// Requests are normalized with the same request types as the REST API, so
+// both APIs store identical values for identical input.
+type ProductServer struct {
+	productpb.UnimplementedProductServiceServer
+	productService service.IProductService
+	logger         *slog.Logger
+}
+
+func NewProductServer(productService service.IProductService, logger *slog.Logger) *ProductServer {
+	return &ProductServer{productService: productService, logger: logger}
+}
+
+func (server *ProductServer) Register(grpcServer *grpc.Server) {
+	productpb.RegisterProductServiceServer(grpcServer, server)
+}
+
+func (server *ProductServer) AddProduct(ctx context.Context, addProductRequest *productpb.AddProductRequest) (*productpb.AddProductResponse, error) {
+	productRequest := request.AddProductRequest{
+		Name:        addProductRequest.GetName(),
+		Price:       addProductRequest.GetPrice(),
+		Discount:    addProductRequest.GetDiscount(),
+		Store:       addProductRequest.GetStore(),
+		Sku:         addProductRequest.GetSku(),
+		ExternalIds: toExternalIdRequests(addProductRequest.GetExternalIds()),
+	}
+	productRequest.Normalize()
+
+	err := server.productService.Add(ctx, productRequest.ToModel())
+	if err != nil {
+		return nil, server.serviceError(ctx, "add", err)
+	}
+	return &productpb.AddProductResponse{}, nil
+}
+
+func (server *ProductServer) UpsertProductBySku(ctx context.Context, upsertRequest *productpb.UpsertProductBySkuRequest) (*productpb.UpsertProductBySkuResponse, error) {
+	productRequest := request.UpsertProductRequest{
+		Name:     upsertRequest.GetName(),
+		Price:    upsertRequest.GetPrice(),
+		Discount: upsertRequest.GetDiscount(),
+	}
+	productRequest.Normalize()
+	productCreate := productRequest.ToModel(upsertRequest.GetStore(), upsertRequest.GetSku())
+	if productCreate.Store == "" || productCreate.Sku == "" {
+		return nil, newStatus(http.StatusBadRequest, response.CodeInvalidRequest, "store and sku must be specified")
+	}
+
+	product, created, err := server.productService.UpsertBySku(ctx, productCreate)
+	if err != nil {
+		return nil, server.serviceError(ctx, "upsert", err)
+	}
+	return &productpb.UpsertProductBySkuResponse{Product: toProductMessage(product), Created: created}, nil
+}
+
+func (server *ProductServer) GetProduct(ctx context.Context, getRequest *productpb.GetProductRequest) (*productpb.Product, error) {
+	product, err := server.productService.GetById(ctx, getRequest.GetId())
+	if errors.Is(err, domain.ErrProductNotFound) {
+		return nil, newStatus(http.StatusNotFound, response.CodeNotFound, fmt.Sprintf("no product with ID %d", getRequest.GetId()))
+	}
+	if err != nil {
+		return nil, server.serviceError(ctx, "get", err)
+	}
+	return toProductMessage(product), nil
+}
+
+func (server *ProductServer) GetProductByExternalId(ctx context.Context, getRequest *productpb.GetProductByExternalIdRequest) (*productpb.Product, error) {
+	externalId, err := toExternalId(getRequest.GetExternalId())
+	if err != nil {
+		return nil, err
+	}
+
+	product, err := server.productService.GetByExternalId(ctx, externalId)
+	if errors.Is(err, domain.ErrProductNotFound) {
+		return nil, newStatus(http.StatusNotFound, response.CodeNotFound, fmt.Sprintf("no product with external ID %s/%s", externalId.System, externalId.Id))
+	}
+	if err != nil {
+		return nil, server.serviceError(ctx, "get by external id", err)
+	}
+	return toProductMessage(product), nil
+}
+
+func (server *ProductServer) ListProducts(listRequest *productpb.ListProductsRequest, stream grpc.ServerStreamingServer[productpb.Product]) error {
+	ctx := stream.Context()
+	var products []domain.Product
+	var err error
+	if listRequest.GetStore() == "" {
+		products, err = server.productService.GetAllProducts(ctx)
+	} else {
+		products, err = server.productService.GetProductsByStore(ctx, listRequest.GetStore())
+	}
+	if err != nil {
+		return server.serviceError(ctx, "list", err)
+	}
+
+	for _, product := range products {
+		if err := stream.Send(toProductMessage(product)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (server *ProductServer) UpdatePrice(ctx context.Context, updateRequest *productpb.UpdatePriceRequest) (*productpb.UpdatePriceResponse, error) {
+	err := server.productService.UpdatePrice(ctx, updateRequest.GetId(), updateRequest.GetNewPrice())
+	if err != nil {
+		return nil, server.serviceError(ctx, "update price", err)
+	}
+	return &productpb.UpdatePriceResponse{}, nil
+}
+
+func (server *ProductServer) UpdatePriceByExternalId(ctx context.Context, updateRequest *productpb.UpdatePriceByExternalIdRequest) (*productpb.UpdatePriceResponse, error) {
+	externalId, err := toExternalId(updateRequest.GetExternalId())
+	if err != nil {
+		return nil, err
+	}
+
+	err = server.productService.UpdatePriceByExternalId(ctx, externalId, updateRequest.GetNewPrice())
+	if err != nil {
+		return nil, server.serviceError(ctx, "update price by external id", err)
+	}
+	return &productpb.UpdatePriceResponse{}, nil
+}
+
+func (server *ProductServer) DeleteProduct(ctx context.Context, deleteRequest *productpb.DeleteProductRequest) (*productpb.DeleteProductResponse, error) {
+	err := server.productService.DeleteById(ctx, deleteRequest.GetId())
+	if err != nil {
+		return nil, server.serviceError(ctx, "delete", err)
+	}
+	return &productpb.DeleteProductResponse{}, nil
+}
+
+// serviceError converts err to a status and, like the REST controller, logs
+// failures other than authorization errors, conflicts and missing products.
+// Errors the service does not classify become codes.Internal without their
+// message.
+func (server *ProductServer) serviceError(ctx context.Context, operation string, err error) error {
+	problem := response.ServiceProblem(http.StatusInternalServerError, err)
+	switch problem.Status {
+	case http.StatusForbidden, http.StatusConflict, http.StatusNotFound:
+	case http.StatusInternalServerError:
+		server.logger.ErrorContext(ctx, "product operation failed", "operation", operation, "error", err)
+	default:
+		server.logger.WarnContext(ctx, "product change failed", "operation", operation, "error", err)
+	}
+	return problemStatus(problem)
+}
+
+func toExternalId(externalId *productpb.ExternalId) (domain.ExternalId, error) {
+	externalIdRequest := request.ExternalIdRequest{System: externalId.GetSystem(), Id: externalId.GetId()}
+	externalIdRequest.Normalize()
+	if externalIdRequest.System == "" || externalIdRequest.Id == "" {
+		return domain.ExternalId{}, newStatus(http.StatusBadRequest, response.CodeInvalidRequest, "external system and id must be specified")
+	}
+	return externalIdRequest.ToModel(), nil
+}
+
+func toExternalIdRequests(externalIds []*productpb.ExternalId) []request.ExternalIdRequest {
+	var externalIdRequests []request.ExternalIdRequest
+	for _, externalId := range externalIds {
+		externalIdRequests = append(externalIdRequests, request.ExternalIdRequest{System: externalId.GetSystem(), Id: externalId.GetId()})
+	}
+	return externalIdRequests
+}
+
+func toProductMessage(product domain.Product) *productpb.Product {
+	message := &productpb.Product{
+		Id:       product.Id,
+		Name:     product.Name,
+		Price:    product.Price,
+		Discount: product.Discount,
+		Store:    product.Store,
+		Sku:      product.Sku,
+	}
+	for _, externalId := range product.ExternalIds {
+		message.ExternalIds = append(message.ExternalIds, &productpb.ExternalId{System: externalId.System, Id: externalId.Id})
+	}
+	return message
+}
@@ -0,0 +1,80 @@
+package grpcapi
+
+import (
+	"context"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"net/http"
+)
+
+// rateLimiter applies the limits of the REST API to gRPC calls. The full
+// method name, e.g. "/product.v1.ProductService/AddProduct", is used as the
+// route, so methods can be given their own rule in the route configuration.
+type rateLimiter struct {
+	rateLimitService service.IRateLimitService
+}
+
+// ipUnaryInterceptor limits calls per IP address. Like IpRateLimit it runs
+// before authentication, so that it also holds back clients sending invalid
+// credentials.
+func (limiter *rateLimiter) ipUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := limiter.allowIp(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (limiter *rateLimiter) ipStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := limiter.allowIp(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
+
+// unaryInterceptor limits calls per principal and method. It must run after
+// the authentication interceptor.
+func (limiter *rateLimiter) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := limiter.allow(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (limiter *rateLimiter) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := limiter.allow(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
+
+func (limiter *rateLimiter) allowIp(ctx context.Context, method string) error {
+	return rateLimitError(limiter.rateLimitService.AllowIp(method, clientIp(ctx)))
+}
+
+func (limiter *rateLimiter) allow(ctx context.Context, method string) error {
+	client := "ip:" + clientIp(ctx)
+	if principal, ok := domain.PrincipalFromContext(ctx); ok {
+		client = principal.Subject
+	}
+	return rateLimitError(limiter.rateLimitService.Allow(method, client))
+}
+
+// rateLimitError reports a rejected call as ResourceExhausted, telling the
+// client in RetryInfo when to try again.
+func rateLimitError(decision domain.RateLimitDecision) error {
+	if decision.Allowed {
+		return nil
+	}
+
+	rateLimited := newStatus(http.StatusTooManyRequests, response.CodeRateLimited, "rate limit exceeded")
+	withRetryInfo, err := status.Convert(rateLimited).WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(decision.RetryAfter)})
+	if err != nil {
+		return rateLimited
+	}
+	return withRetryInfo.Err()
+}
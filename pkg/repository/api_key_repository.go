@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"log/slog"
+)
+
+type IApiKeyRepository interface {
+	AddApiKey(apiKey domain.ApiKey) (domain.ApiKey, error)
+	GetAllApiKeys() []domain.ApiKey
+	GetApiKeyById(apiKeyId int64) (domain.ApiKey, error)
+	GetApiKeyByPrefix(prefix string) (domain.ApiKey, error)
+	UpdateApiKeySecret(apiKeyId int64, prefix string, keyHash string) error
+	RevokeApiKeyById(apiKeyId int64) error
+}
+
+const apiKeyColumns = "id, name, prefix, key_hash, scopes, created_at, revoked_at"
+
+type ApiKeyRepository struct {
+	dbPool *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewApiKeyRepository(dbPool *pgxpool.Pool, logger *slog.Logger) IApiKeyRepository {
+	return &ApiKeyRepository{dbPool, logger}
+}
+
+func (repository *ApiKeyRepository) AddApiKey(apiKey domain.ApiKey) (domain.ApiKey, error) {
+	ctx := context.Background()
+
+	insertStatement := "INSERT INTO api_keys (name, prefix, key_hash, scopes) VALUES ($1, $2, $3, $4) RETURNING " + apiKeyColumns
+
+	addedApiKey, err := scanApiKey(repository.dbPool.QueryRow(ctx, insertStatement, apiKey.Name, apiKey.Prefix, apiKey.KeyHash, apiKey.Scopes))
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while adding a new api key", "error", err)
+		return domain.ApiKey{}, err
+	}
+
+	repository.logger.InfoContext(ctx, "Api key added successfully", "id", addedApiKey.Id)
+	return addedApiKey, nil
+}
+
+func (repository *ApiKeyRepository) GetAllApiKeys() []domain.ApiKey {
+	ctx := context.Background()
+	apiKeyRows, err := repository.dbPool.Query(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY id")
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while getting all api keys", "error", err)
+		return []domain.ApiKey{}
+	}
+	defer apiKeyRows.Close()
+
+	var apiKeys []domain.ApiKey
+	for apiKeyRows.Next() {
+		apiKey, _ := scanApiKey(apiKeyRows)
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	return apiKeys
+}
+
+func (repository *ApiKeyRepository) GetApiKeyById(apiKeyId int64) (domain.ApiKey, error) {
+	ctx := context.Background()
+
+	apiKey, err := scanApiKey(repository.dbPool.QueryRow(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = $1", apiKeyId))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ApiKey{}, fmt.Errorf("%w with the id %d", domain.ErrApiKeyNotFound, apiKeyId)
+	}
+
+	return apiKey, err
+}
+
+func (repository *ApiKeyRepository) GetApiKeyByPrefix(prefix string) (domain.ApiKey, error) {
+	ctx := context.Background()
+
+	apiKey, err := scanApiKey(repository.dbPool.QueryRow(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE prefix = $1", prefix))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ApiKey{}, fmt.Errorf("%w with the prefix %s", domain.ErrApiKeyNotFound, prefix)
+	}
+
+	return apiKey, err
+}
+
+func (repository *ApiKeyRepository) UpdateApiKeySecret(apiKeyId int64, prefix string, keyHash string) error {
+	ctx := context.Background()
+
+	result, err := repository.dbPool.Exec(ctx, "UPDATE api_keys SET prefix = $1, key_hash = $2 WHERE id = $3 AND revoked_at IS NULL", prefix, keyHash, apiKeyId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%w with the id %d that is still active", domain.ErrApiKeyNotFound, apiKeyId)
+	}
+
+	repository.logger.InfoContext(ctx, "Api key rotated successfully", "id", apiKeyId)
+	return nil
+}
+
+func (repository *ApiKeyRepository) RevokeApiKeyById(apiKeyId int64) error {
+	ctx := context.Background()
+
+	result, err := repository.dbPool.Exec(ctx, "UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL", apiKeyId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%w with the id %d that is still active", domain.ErrApiKeyNotFound, apiKeyId)
+	}
+
+	repository.logger.InfoContext(ctx, "Api key revoked successfully", "id", apiKeyId)
+	return nil
+}
+
+func scanApiKey(row pgx.Row) (domain.ApiKey, error) {
+	var apiKey domain.ApiKey
+	err := row.Scan(&apiKey.Id, &apiKey.Name, &apiKey.Prefix, &apiKey.KeyHash, &apiKey.Scopes, &apiKey.CreatedAt, &apiKey.RevokedAt)
+	return apiKey, err
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"log/slog"
+	"strings"
+)
+
+type IAuditRepository interface {
+	AddAuditEvent(ctx context.Context, auditEvent domain.AuditEvent) error
+	GetAuditEvents(ctx context.Context, filter domain.AuditEventFilter) ([]domain.AuditEvent, error)
+	CountAuditEvents(ctx context.Context, filter domain.AuditEventFilter) (int64, error)
+}
+
+// AuditRepository writes to the append-only audit_events table. Obtained
+// through IProductRepository.AuditEvents it shares the product repository's
+// transaction, so an event is stored if and only if its mutation is.
+type AuditRepository struct {
+	db     dbExecutor
+	logger *slog.Logger
+}
+
+func NewAuditRepository(dbPool *pgxpool.Pool, logger *slog.Logger) IAuditRepository {
+	return &AuditRepository{newTracingExecutor(dbPool), logger}
+}
+
+func (repository *AuditRepository) AddAuditEvent(ctx context.Context, auditEvent domain.AuditEvent) error {
+	insertStatement := `INSERT INTO audit_events (action, product_id, actor, request_id, client_ip, before, after)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := repository.db.Exec(ctx, insertStatement, auditEvent.Action, auditEvent.ProductId, auditEvent.Actor,
+		nullableString(auditEvent.RequestId), nullableString(auditEvent.ClientIp), nullableJson(auditEvent.Before), nullableJson(auditEvent.After))
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while adding audit event", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (repository *AuditRepository) GetAuditEvents(ctx context.Context, filter domain.AuditEventFilter) ([]domain.AuditEvent, error) {
+	where, args := auditEventConditions(filter)
+	query := "SELECT id, action, product_id, actor, request_id, client_ip, before, after, created_at FROM audit_events" + where
+	query += " ORDER BY id"
+	if filter.Descending {
+		query += " DESC"
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" LIMIT $%d", len(args))
+
+	auditEventRows, err := repository.db.Query(ctx, query, args...)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while getting audit events", "error", err)
+		return nil, err
+	}
+	defer auditEventRows.Close()
+
+	var auditEvents []domain.AuditEvent
+	for auditEventRows.Next() {
+		var auditEvent domain.AuditEvent
+		var requestId, clientIp *string
+		var before, after []byte
+		err := auditEventRows.Scan(&auditEvent.Id, &auditEvent.Action, &auditEvent.ProductId, &auditEvent.Actor,
+			&requestId, &clientIp, &before, &after, &auditEvent.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		auditEvent.Before = before
+		auditEvent.After = after
+		if requestId != nil {
+			auditEvent.RequestId = *requestId
+		}
+		if clientIp != nil {
+			auditEvent.ClientIp = *clientIp
+		}
+		auditEvents = append(auditEvents, auditEvent)
+	}
+
+	return auditEvents, auditEventRows.Err()
+}
+
+func nullableJson(value []byte) interface{} {
+	if len(value) == 0 {
+		return nil
+	}
+	return string(value)
+}
+
+// CountAuditEvents counts the events matching filter, ignoring its limit.
+func (repository *AuditRepository) CountAuditEvents(ctx context.Context, filter domain.AuditEventFilter) (int64, error) {
+	where, args := auditEventConditions(filter)
+
+	var count int64
+	err := repository.db.QueryRow(ctx, "SELECT count(*) FROM audit_events"+where, args...).Scan(&count)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while counting audit events", "error", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// auditEventConditions returns the WHERE clause selecting the events matching
+// filter, or "" if it matches every event, together with its arguments.
+func auditEventConditions(filter domain.AuditEventFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ProductId != 0 {
+		addCondition("product_id = $%d", filter.ProductId)
+	}
+	if filter.Actor != "" {
+		addCondition("actor = $%d", filter.Actor)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $%d", filter.To)
+	}
+	if filter.BeforeId != 0 {
+		addCondition("id < $%d", filter.BeforeId)
+	}
+	if filter.PriceChangesOnly {
+		conditions = append(conditions, "after IS NOT NULL AND (before IS NULL OR before->'price' IS DISTINCT FROM after->'price')")
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
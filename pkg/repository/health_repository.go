@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type IHealthRepository interface {
+	Ping(ctx context.Context) error
+	GetMissingTables(ctx context.Context, tables []string) ([]string, error)
+	GetMissingColumns(ctx context.Context, columns []string) ([]string, error)
+}
+
+type HealthRepository struct {
+	dbPool *pgxpool.Pool
+}
+
+func NewHealthRepository(dbPool *pgxpool.Pool) IHealthRepository {
+	return &HealthRepository{dbPool}
+}
+
+func (repository *HealthRepository) Ping(ctx context.Context) error {
+	return repository.dbPool.Ping(ctx)
+}
+
+// GetMissingTables returns the given tables that do not exist in the
+// database's search path.
+func (repository *HealthRepository) GetMissingTables(ctx context.Context, tables []string) ([]string, error) {
+	return repository.queryNames(ctx, "SELECT name FROM unnest($1::text[]) AS name WHERE to_regclass(name) IS NULL ORDER BY name", tables)
+}
+
+// GetMissingColumns returns the given columns, written as table.column, that
+// do not exist although their table does. Columns of missing tables are left
+// to GetMissingTables.
+func (repository *HealthRepository) GetMissingColumns(ctx context.Context, columns []string) ([]string, error) {
+	query := `SELECT name FROM unnest($1::text[]) AS name
+WHERE to_regclass(split_part(name, '.', 1)) IS NOT NULL
+  AND NOT EXISTS (
+    SELECT 1 FROM pg_attribute
+    WHERE attrelid = to_regclass(split_part(name, '.', 1)) AND attname = split_part(name, '.', 2) AND NOT attisdropped
+  )
+ORDER BY name`
+	return repository.queryNames(ctx, query, columns)
+}
+
+func (repository *HealthRepository) queryNames(ctx context.Context, query string, names []string) ([]string, error) {
+	nameRows, err := repository.dbPool.Query(ctx, query, names)
+	if err != nil {
+		return nil, err
+	}
+	defer nameRows.Close()
+
+	var missingNames []string
+	for nameRows.Next() {
+		var name string
+		if err := nameRows.Scan(&name); err != nil {
+			return nil, err
+		}
+		missingNames = append(missingNames, name)
+	}
+
+	return missingNames, nameRows.Err()
+}
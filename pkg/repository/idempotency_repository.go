@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"log/slog"
+	"time"
+)
+
+type IIdempotencyRepository interface {
+	ReserveKey(key string, requestHash string, expiresAt time.Time) (bool, error)
+	GetByKey(key string) (domain.IdempotencyRecord, error)
+	SaveResponse(key string, statusCode int, contentType string, responseBody []byte) error
+	DeleteKey(key string) error
+	DeleteExpiredKeys() (int64, error)
+}
+
+type IdempotencyRepository struct {
+	dbPool *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewIdempotencyRepository(dbPool *pgxpool.Pool, logger *slog.Logger) IIdempotencyRepository {
+	return &IdempotencyRepository{dbPool, logger}
+}
+
+// ReserveKey claims the key for a new request. It returns false if the key is
+// already held by an unexpired record; expired records are taken over.
+func (repository *IdempotencyRepository) ReserveKey(key string, requestHash string, expiresAt time.Time) (bool, error) {
+	ctx := context.Background()
+
+	reserveStatement := `INSERT INTO idempotency_keys (key, request_hash, expires_at) VALUES ($1, $2, $3)
+ON CONFLICT (key) DO UPDATE
+	SET request_hash = EXCLUDED.request_hash, status_code = NULL, content_type = NULL, response_body = NULL,
+		created_at = now(), expires_at = EXCLUDED.expires_at
+	WHERE idempotency_keys.expires_at < now()`
+
+	result, err := repository.dbPool.Exec(ctx, reserveStatement, key, requestHash, expiresAt)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while reserving idempotency key", "error", err)
+		return false, err
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+func (repository *IdempotencyRepository) GetByKey(key string) (domain.IdempotencyRecord, error) {
+	ctx := context.Background()
+
+	var record domain.IdempotencyRecord
+	var statusCode *int32
+	var contentType *string
+	recordRow := repository.dbPool.QueryRow(ctx, "SELECT key, request_hash, status_code, content_type, response_body, created_at, expires_at FROM idempotency_keys WHERE key = $1", key)
+
+	err := recordRow.Scan(&record.Key, &record.RequestHash, &statusCode, &contentType, &record.ResponseBody, &record.CreatedAt, &record.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.IdempotencyRecord{}, fmt.Errorf("no idempotency record found with the key %s", key)
+	}
+	if err != nil {
+		return domain.IdempotencyRecord{}, err
+	}
+
+	if statusCode != nil {
+		record.StatusCode = int(*statusCode)
+	}
+	if contentType != nil {
+		record.ContentType = *contentType
+	}
+
+	return record, nil
+}
+
+func (repository *IdempotencyRepository) SaveResponse(key string, statusCode int, contentType string, responseBody []byte) error {
+	ctx := context.Background()
+
+	_, err := repository.dbPool.Exec(ctx, "UPDATE idempotency_keys SET status_code = $1, content_type = $2, response_body = $3 WHERE key = $4",
+		statusCode, contentType, responseBody, key)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while saving idempotent response", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (repository *IdempotencyRepository) DeleteKey(key string) error {
+	ctx := context.Background()
+
+	_, err := repository.dbPool.Exec(ctx, "DELETE FROM idempotency_keys WHERE key = $1", key)
+	return err
+}
+
+func (repository *IdempotencyRepository) DeleteExpiredKeys() (int64, error) {
+	ctx := context.Background()
+
+	result, err := repository.dbPool.Exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at < now()")
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"github.com/erkindilekci/product-api/pkg/common/metrics"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"time"
+)
+
+// InstrumentedProductRepository records the duration of every call to the
+// wrapped repository in a histogram labelled with the method name.
+type InstrumentedProductRepository struct {
+	repository    IProductRepository
+	queryDuration *metrics.HistogramVec
+}
+
+func NewInstrumentedProductRepository(repository IProductRepository, registry *metrics.Registry) IProductRepository {
+	queryDuration := registry.Histogram("repository_query_duration_seconds", "Duration of product repository calls.", metrics.DefaultBuckets, "method")
+	return &InstrumentedProductRepository{repository, queryDuration}
+}
+
+func (repository *InstrumentedProductRepository) GetAllProducts(ctx context.Context) []domain.Product {
+	defer repository.observe("GetAllProducts", time.Now())
+	return repository.repository.GetAllProducts(ctx)
+}
+
+func (repository *InstrumentedProductRepository) GetProductsByStore(ctx context.Context, store string) []domain.Product {
+	defer repository.observe("GetProductsByStore", time.Now())
+	return repository.repository.GetProductsByStore(ctx, store)
+}
+
+func (repository *InstrumentedProductRepository) GetProductPage(ctx context.Context, filter domain.ProductFilter, afterId int64, limit int) ([]domain.Product, error) {
+	defer repository.observe("GetProductPage", time.Now())
+	return repository.repository.GetProductPage(ctx, filter, afterId, limit)
+}
+
+func (repository *InstrumentedProductRepository) GetProductPagesByStore(ctx context.Context, filter domain.ProductFilter, afterId int64, limit int) (map[string][]domain.Product, error) {
+	defer repository.observe("GetProductPagesByStore", time.Now())
+	return repository.repository.GetProductPagesByStore(ctx, filter, afterId, limit)
+}
+
+func (repository *InstrumentedProductRepository) CountProductsByStore(ctx context.Context, filter domain.ProductFilter) (map[string]int64, error) {
+	defer repository.observe("CountProductsByStore", time.Now())
+	return repository.repository.CountProductsByStore(ctx, filter)
+}
+
+func (repository *InstrumentedProductRepository) AddProduct(ctx context.Context, product domain.Product) (int64, error) {
+	defer repository.observe("AddProduct", time.Now())
+	return repository.repository.AddProduct(ctx, product)
+}
+
+func (repository *InstrumentedProductRepository) AddExternalIds(ctx context.Context, productId int64, externalIds []domain.ExternalId) error {
+	defer repository.observe("AddExternalIds", time.Now())
+	return repository.repository.AddExternalIds(ctx, productId, externalIds)
+}
+
+func (repository *InstrumentedProductRepository) GetProductByExternalId(ctx context.Context, externalId domain.ExternalId) (domain.Product, error) {
+	defer repository.observe("GetProductByExternalId", time.Now())
+	return repository.repository.GetProductByExternalId(ctx, externalId)
+}
+
+func (repository *InstrumentedProductRepository) UpsertProductBySku(ctx context.Context, product domain.Product) (domain.Product, bool, error) {
+	defer repository.observe("UpsertProductBySku", time.Now())
+	return repository.repository.UpsertProductBySku(ctx, product)
+}
+
+func (repository *InstrumentedProductRepository) GetProductById(ctx context.Context, productId int64) (domain.Product, error) {
+	defer repository.observe("GetProductById", time.Now())
+	return repository.repository.GetProductById(ctx, productId)
+}
+
+func (repository *InstrumentedProductRepository) GetProductByIdForUpdate(ctx context.Context, productId int64) (domain.Product, error) {
+	defer repository.observe("GetProductByIdForUpdate", time.Now())
+	return repository.repository.GetProductByIdForUpdate(ctx, productId)
+}
+
+func (repository *InstrumentedProductRepository) GetProductBySkuForUpdate(ctx context.Context, store string, sku string) (domain.Product, error) {
+	defer repository.observe("GetProductBySkuForUpdate", time.Now())
+	return repository.repository.GetProductBySkuForUpdate(ctx, store, sku)
+}
+
+func (repository *InstrumentedProductRepository) DeleteProductById(ctx context.Context, productId int64) error {
+	defer repository.observe("DeleteProductById", time.Now())
+	return repository.repository.DeleteProductById(ctx, productId)
+}
+
+func (repository *InstrumentedProductRepository) UpdatePriceById(ctx context.Context, productId int64, newPrice float32) error {
+	defer repository.observe("UpdatePriceById", time.Now())
+	return repository.repository.UpdatePriceById(ctx, productId, newPrice)
+}
+
+// WithTx keeps the transactional repository instrumented, so calls made inside
+// a transaction are recorded as well.
+func (repository *InstrumentedProductRepository) WithTx(ctx context.Context, fn func(repository IProductRepository) error) error {
+	return repository.repository.WithTx(ctx, func(txRepository IProductRepository) error {
+		return fn(&InstrumentedProductRepository{txRepository, repository.queryDuration})
+	})
+}
+
+func (repository *InstrumentedProductRepository) AuditEvents() IAuditRepository {
+	return repository.repository.AuditEvents()
+}
+
+func (repository *InstrumentedProductRepository) Outbox() IOutboxRepository {
+	return repository.repository.Outbox()
+}
+
+func (repository *InstrumentedProductRepository) observe(method string, start time.Time) {
+	repository.queryDuration.Observe(time.Since(start).Seconds(), method)
+}
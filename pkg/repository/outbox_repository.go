@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+type IOutboxRepository interface {
+	AddOutboxEvent(ctx context.Context, outboxEvent domain.OutboxEvent) error
+	ClaimNextOutboxEvents(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]domain.OutboxEvent, error)
+	UpdateOutboxEvent(ctx context.Context, outboxEvent domain.OutboxEvent) error
+	DeletePublishedOutboxEvents(ctx context.Context, publishedBefore time.Time) (int64, error)
+	GetOutboxEventsAfter(ctx context.Context, afterId int64, limit int) ([]domain.OutboxEvent, error)
+	GetLastOutboxEventId(ctx context.Context) (int64, error)
+	GetFirstOutboxEventId(ctx context.Context) (int64, error)
+	GetTransactionBounds(ctx context.Context) (int64, int64, error)
+}
+
+const outboxColumns = "id, event_id, event_type, product_id, payload, attempts, next_attempt_at, last_error, created_at, published_at"
+
+// OutboxRepository stores product events until they are published. Obtained
+// through IProductRepository.Outbox it shares the product repository's
+// transaction, so an event is stored if and only if its change is.
+type OutboxRepository struct {
+	db     dbExecutor
+	logger *slog.Logger
+}
+
+func NewOutboxRepository(dbPool *pgxpool.Pool, logger *slog.Logger) IOutboxRepository {
+	return &OutboxRepository{newTracingExecutor(dbPool), logger}
+}
+
+func (repository *OutboxRepository) AddOutboxEvent(ctx context.Context, outboxEvent domain.OutboxEvent) error {
+	insertStatement := "INSERT INTO outbox (event_id, event_type, product_id, payload) VALUES ($1, $2, $3, $4)"
+
+	_, err := repository.db.Exec(ctx, insertStatement, outboxEvent.EventId, outboxEvent.EventType, outboxEvent.ProductId, string(outboxEvent.Payload))
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while adding outbox event", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// ClaimNextOutboxEvents returns, oldest first, up to limit unpublished
+// events that are the oldest unpublished event of their product and due, and
+// postpones them to leaseUntil. Only ever handing out the oldest event of a
+// product keeps each product's events in order, even with several relays
+// polling at once: the next event of a product is not claimed before the
+// previous one is published.
+func (repository *OutboxRepository) ClaimNextOutboxEvents(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]domain.OutboxEvent, error) {
+	claimStatement := `UPDATE outbox SET next_attempt_at = $2
+WHERE id IN (
+  SELECT id FROM outbox
+  WHERE id IN (
+    SELECT DISTINCT ON (product_id) id FROM outbox WHERE published_at IS NULL ORDER BY product_id, id
+  ) AND next_attempt_at <= $1
+  ORDER BY id LIMIT $3 FOR UPDATE SKIP LOCKED
+)
+RETURNING ` + outboxColumns
+
+	outboxRows, err := repository.db.Query(ctx, claimStatement, now, leaseUntil, limit)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while claiming outbox events", "error", err)
+		return nil, err
+	}
+	outboxEvents, err := extractOutboxEventsFromRows(outboxRows)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(outboxEvents, func(i, j int) bool { return outboxEvents[i].Id < outboxEvents[j].Id })
+	return outboxEvents, nil
+}
+
+// UpdateOutboxEvent stores the outcome of a publishing attempt.
+func (repository *OutboxRepository) UpdateOutboxEvent(ctx context.Context, outboxEvent domain.OutboxEvent) error {
+	updateStatement := "UPDATE outbox SET attempts = $2, next_attempt_at = $3, last_error = $4, published_at = $5 WHERE id = $1"
+
+	result, err := repository.db.Exec(ctx, updateStatement, outboxEvent.Id, outboxEvent.Attempts, outboxEvent.NextAttemptAt,
+		nullableString(outboxEvent.LastError), outboxEvent.PublishedAt)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while updating outbox event", "id", outboxEvent.Id, "error", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("no outbox event found with the id %d", outboxEvent.Id)
+	}
+	return nil
+}
+
+func (repository *OutboxRepository) DeletePublishedOutboxEvents(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	result, err := repository.db.Exec(ctx, "DELETE FROM outbox WHERE published_at < $1", publishedBefore)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while deleting published outbox events", "error", err)
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// GetOutboxEventsAfter lists up to limit events with an id greater than
+// afterId in id order, whether they were published or not.
+func (repository *OutboxRepository) GetOutboxEventsAfter(ctx context.Context, afterId int64, limit int) ([]domain.OutboxEvent, error) {
+	outboxRows, err := repository.db.Query(ctx, "SELECT "+outboxColumns+" FROM outbox WHERE id > $1 ORDER BY id LIMIT $2", afterId, limit)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while getting outbox events", "error", err)
+		return nil, err
+	}
+	return extractOutboxEventsFromRows(outboxRows)
+}
+
+// GetLastOutboxEventId returns the greatest id in the outbox, or 0 if it is
+// empty.
+func (repository *OutboxRepository) GetLastOutboxEventId(ctx context.Context) (int64, error) {
+	var lastId int64
+	err := repository.db.QueryRow(ctx, "SELECT COALESCE(MAX(id), 0) FROM outbox").Scan(&lastId)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while getting the last outbox event id", "error", err)
+		return 0, err
+	}
+	return lastId, nil
+}
+
+// GetFirstOutboxEventId returns the smallest id in the outbox, or 0 if it is
+// empty.
+func (repository *OutboxRepository) GetFirstOutboxEventId(ctx context.Context) (int64, error) {
+	var firstId int64
+	err := repository.db.QueryRow(ctx, "SELECT COALESCE(MIN(id), 0) FROM outbox").Scan(&firstId)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while getting the first outbox event id", "error", err)
+		return 0, err
+	}
+	return firstId, nil
+}
+
+// GetTransactionBounds returns the xmin and xmax of a current snapshot:
+// every transaction with an id below xmin has ended, and every transaction
+// running when the snapshot was taken has an id below xmax.
+func (repository *OutboxRepository) GetTransactionBounds(ctx context.Context) (int64, int64, error) {
+	query := `SELECT pg_snapshot_xmin(snapshot)::text::bigint, pg_snapshot_xmax(snapshot)::text::bigint
+FROM pg_current_snapshot() AS snapshot`
+
+	var xmin, xmax int64
+	err := repository.db.QueryRow(ctx, query).Scan(&xmin, &xmax)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while getting the transaction bounds", "error", err)
+		return 0, 0, err
+	}
+	return xmin, xmax, nil
+}
+
+func scanOutboxEvent(row pgx.Row) (domain.OutboxEvent, error) {
+	var outboxEvent domain.OutboxEvent
+	var payload []byte
+	var lastError *string
+	err := row.Scan(&outboxEvent.Id, &outboxEvent.EventId, &outboxEvent.EventType, &outboxEvent.ProductId, &payload,
+		&outboxEvent.Attempts, &outboxEvent.NextAttemptAt, &lastError, &outboxEvent.CreatedAt, &outboxEvent.PublishedAt)
+	if err != nil {
+		return domain.OutboxEvent{}, err
+	}
+	outboxEvent.Payload = payload
+	if lastError != nil {
+		outboxEvent.LastError = *lastError
+	}
+	return outboxEvent, nil
+}
+
+func extractOutboxEventsFromRows(outboxRows pgx.Rows) ([]domain.OutboxEvent, error) {
+	defer outboxRows.Close()
+
+	var outboxEvents []domain.OutboxEvent
+	for outboxRows.Next() {
+		outboxEvent, err := scanOutboxEvent(outboxRows)
+		if err != nil {
+			return nil, err
+		}
+		outboxEvents = append(outboxEvents, outboxEvent)
+	}
+	return outboxEvents, outboxRows.Err()
+}
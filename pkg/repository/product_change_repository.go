@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"log/slog"
+)
+
+type IProductChangeRepository interface {
+	GetProductChanges(ctx context.Context, since int64, limit int, stores []string) ([]domain.ProductChange, error)
+}
+
+// ProductChangeRepository reads the product_changes table the
+// products_track_change trigger created by scripts/init_db.sh keeps. It holds
+// the latest change of every product, including deleted ones.
+type ProductChangeRepository struct {
+	db     dbExecutor
+	logger *slog.Logger
+}
+
+func NewProductChangeRepository(dbPool *pgxpool.Pool, logger *slog.Logger) IProductChangeRepository {
+	return &ProductChangeRepository{newTracingExecutor(dbPool), logger}
+}
+
+// GetProductChanges returns up to limit changes with a sequence above since,
+// in sequence order, of the products in stores, or of every store if stores
+// is nil. A product that no longer exists is returned as deleted. Sequences
+// are taken as changes commit, so no change with a lower sequence can become
+// visible after the ones returned.
+func (repository *ProductChangeRepository) GetProductChanges(ctx context.Context, since int64, limit int, stores []string) ([]domain.ProductChange, error) {
+	query := `SELECT change.change_sequence, change.product_id, product.id IS NULL, change.changed_at,
+  product.name, product.price, product.discount, product.store, product.sku
+FROM product_changes AS change LEFT JOIN products AS product ON product.id = change.product_id
+WHERE change.change_sequence > $1`
+	args := []interface{}{since, limit}
+	if stores != nil {
+		args = append(args, stores)
+		query += " AND change.store = ANY($3)"
+	}
+	query += " ORDER BY change.change_sequence LIMIT $2"
+
+	changeRows, err := repository.db.Query(ctx, query, args...)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while getting product changes", "error", err)
+		return nil, err
+	}
+	defer changeRows.Close()
+
+	var changes []domain.ProductChange
+	for changeRows.Next() {
+		var change domain.ProductChange
+		var name, store, sku *string
+		var price, discount *float32
+		err := changeRows.Scan(&change.Sequence, &change.ProductId, &change.Deleted, &change.ChangedAt,
+			&name, &price, &discount, &store, &sku)
+		if err != nil {
+			return nil, err
+		}
+		if !change.Deleted {
+			change.Product = &domain.Product{Id: change.ProductId, Name: *name, Price: *price, Store: *store}
+			if discount != nil {
+				change.Product.Discount = *discount
+			}
+			if sku != nil {
+				change.Product.Sku = *sku
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, changeRows.Err()
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"log/slog"
+)
+
+// ProductNotificationChannel is the channel the products_notify trigger
+// created by scripts/init_db.sh notifies.
+const ProductNotificationChannel = "product_changes"
+
+type IProductNotificationRepository interface {
+	Listen(ctx context.Context) (IProductNotificationConnection, error)
+}
+
+type IProductNotificationConnection interface {
+	WaitForNotification(ctx context.Context) (domain.ProductNotification, error)
+	Ping(ctx context.Context) error
+	Close(ctx context.Context) error
+}
+
+type ProductNotificationRepository struct {
+	connConfig *pgx.ConnConfig
+	logger     *slog.Logger
+}
+
+// NewProductNotificationRepository listens on connections of its own, opened
+// with the settings of dbPool. A listening connection spends its life waiting
+// for notifications, so it must not be taken from the pool.
+func NewProductNotificationRepository(dbPool *pgxpool.Pool, logger *slog.Logger) IProductNotificationRepository {
+	return &ProductNotificationRepository{dbPool.Config().ConnConfig, logger}
+}
+
+// Listen opens a connection that receives the notifications sent from the
+// moment it returns.
+func (repository *ProductNotificationRepository) Listen(ctx context.Context) (IProductNotificationConnection, error) {
+	conn, err := pgx.ConnectConfig(ctx, repository.connConfig.Copy())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+ProductNotificationChannel); err != nil {
+		_ = conn.Close(ctx)
+		return nil, err
+	}
+	return &productNotificationConnection{conn, repository.logger}, nil
+}
+
+type productNotificationConnection struct {
+	conn   *pgx.Conn
+	logger *slog.Logger
+}
+
+// WaitForNotification blocks until a notification arrives, ctx is cancelled
+// or the connection fails. Notifications that cannot be decoded are logged
+// and skipped. The connection stays usable when ctx times out.
+func (connection *productNotificationConnection) WaitForNotification(ctx context.Context) (domain.ProductNotification, error) {
+	for {
+		notification, err := connection.conn.WaitForNotification(ctx)
+		if err != nil {
+			return domain.ProductNotification{}, err
+		}
+
+		var productNotification domain.ProductNotification
+		if err := json.Unmarshal([]byte(notification.Payload), &productNotification); err != nil {
+			connection.logger.ErrorContext(ctx, "error while decoding product notification", "payload", notification.Payload, "error", err)
+			continue
+		}
+		return productNotification, nil
+	}
+}
+
+// Ping makes a round trip to the database. Notifications arriving meanwhile
+// are kept for the next WaitForNotification.
+func (connection *productNotificationConnection) Ping(ctx context.Context) error {
+	return connection.conn.Ping(ctx)
+}
+
+func (connection *productNotificationConnection) Close(ctx context.Context) error {
+	return connection.conn.Close(ctx)
+}
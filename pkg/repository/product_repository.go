@@ -5,78 +5,274 @@ import (
 	"errors"
 	"fmt"
 	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"github.com/labstack/gommon/log"
+	"log/slog"
+	"strings"
 )
 
 type IProductRepository interface {
-	GetAllProducts() []domain.Product
-	GetProductsByStore(store string) []domain.Product
-	AddProduct(product domain.Product) error
-	GetProductById(productId int64) (domain.Product, error)
-	DeleteProductById(productId int64) error
-	UpdatePriceById(productId int64, newPrice float32) error
+	GetAllProducts(ctx context.Context) []domain.Product
+	GetProductsByStore(ctx context.Context, store string) []domain.Product
+	GetProductPage(ctx context.Context, filter domain.ProductFilter, afterId int64, limit int) ([]domain.Product, error)
+	GetProductPagesByStore(ctx context.Context, filter domain.ProductFilter, afterId int64, limit int) (map[string][]domain.Product, error)
+	CountProductsByStore(ctx context.Context, filter domain.ProductFilter) (map[string]int64, error)
+	AddProduct(ctx context.Context, product domain.Product) (int64, error)
+	AddExternalIds(ctx context.Context, productId int64, externalIds []domain.ExternalId) error
+	GetProductByExternalId(ctx context.Context, externalId domain.ExternalId) (domain.Product, error)
+	UpsertProductBySku(ctx context.Context, product domain.Product) (domain.Product, bool, error)
+	GetProductById(ctx context.Context, productId int64) (domain.Product, error)
+	GetProductByIdForUpdate(ctx context.Context, productId int64) (domain.Product, error)
+	GetProductBySkuForUpdate(ctx context.Context, store string, sku string) (domain.Product, error)
+	DeleteProductById(ctx context.Context, productId int64) error
+	UpdatePriceById(ctx context.Context, productId int64, newPrice float32) error
+	WithTx(ctx context.Context, fn func(repository IProductRepository) error) error
+	AuditEvents() IAuditRepository
+	Outbox() IOutboxRepository
 }
 
+// dbExecutor is satisfied by both *pgxpool.Pool and pgx.Tx, so the same
+// repository code runs either directly on the pool or inside a transaction.
+type dbExecutor interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	BeginFunc(ctx context.Context, f func(pgx.Tx) error) error
+}
+
+const productColumns = "id, name, price, discount, store, sku"
+
+// uniqueViolationCode is the SQLSTATE Postgres reports when a unique
+// constraint or index is violated.
+const uniqueViolationCode = "23505"
+
 type ProductRepository struct {
-	dbPool *pgxpool.Pool
+	db     dbExecutor
+	logger *slog.Logger
 }
 
-func NewProductRepository(dbPool *pgxpool.Pool) IProductRepository {
-	return &ProductRepository{dbPool}
+func NewProductRepository(dbPool *pgxpool.Pool, logger *slog.Logger) IProductRepository {
+	return &ProductRepository{newTracingExecutor(dbPool), logger}
 }
 
-func (repository *ProductRepository) GetAllProducts() []domain.Product {
-	ctx := context.Background()
-	productRows, err := repository.dbPool.Query(ctx, "SELECT * FROM products")
+func (repository *ProductRepository) GetAllProducts(ctx context.Context) []domain.Product {
+	productRows, err := repository.db.Query(ctx, "SELECT "+productColumns+" FROM products")
 	if err != nil {
-		log.Errorf("error while getting all products: %v", err)
+		repository.logger.ErrorContext(ctx, "error while getting all products", "error", err)
 		return []domain.Product{}
 	}
 
 	return extractProductsFromRows(productRows)
 }
 
-func (repository *ProductRepository) GetProductsByStore(store string) []domain.Product {
-	ctx := context.Background()
-	productRows, err := repository.dbPool.Query(ctx, "SELECT * FROM products WHERE store = $1", store)
+func (repository *ProductRepository) GetProductsByStore(ctx context.Context, store string) []domain.Product {
+	productRows, err := repository.db.Query(ctx, "SELECT "+productColumns+" FROM products WHERE store = $1", store)
 	if err != nil {
-		log.Errorf("error while getting all products by store: %v", err)
+		repository.logger.ErrorContext(ctx, "error while getting all products by store", "error", err)
 		return []domain.Product{}
 	}
 
 	return extractProductsFromRows(productRows)
 }
 
-func (repository *ProductRepository) AddProduct(product domain.Product) error {
-	ctx := context.Background()
+// GetProductPage returns up to limit products matching filter with an id
+// above afterId, ordered by id.
+func (repository *ProductRepository) GetProductPage(ctx context.Context, filter domain.ProductFilter, afterId int64, limit int) ([]domain.Product, error) {
+	conditions, args := productConditions(filter)
+	args = append(args, afterId, limit)
+	query := fmt.Sprintf("SELECT %s FROM products WHERE %s AND id > $%d ORDER BY id LIMIT $%d", productColumns, conditions, len(args)-1, len(args))
 
-	insertStatement := "INSERT INTO products (name, price, discount, store) VALUES ($1, $2, $3, $4)"
+	productRows, err := repository.db.Query(ctx, query, args...)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while getting product page", "error", err)
+		return nil, err
+	}
+	defer productRows.Close()
 
-	addNewProduct, err := repository.dbPool.Exec(ctx, insertStatement, product.Name, product.Price, product.Discount, product.Store)
+	products := []domain.Product{}
+	for productRows.Next() {
+		product, err := scanProduct(productRows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	return products, productRows.Err()
+}
+
+// GetProductPagesByStore is GetProductPage for every store at once: it
+// returns up to limit products per store, so that the pages of several
+// stores take a single query.
+func (repository *ProductRepository) GetProductPagesByStore(ctx context.Context, filter domain.ProductFilter, afterId int64, limit int) (map[string][]domain.Product, error) {
+	conditions, args := productConditions(filter)
+	args = append(args, afterId, limit)
+	query := fmt.Sprintf(`SELECT %s FROM (
+  SELECT %s, row_number() OVER (PARTITION BY store ORDER BY id) AS position
+  FROM products WHERE %s AND id > $%d
+) AS ranked
+WHERE position <= $%d ORDER BY store, id`, productColumns, productColumns, conditions, len(args)-1, len(args))
+
+	productRows, err := repository.db.Query(ctx, query, args...)
 	if err != nil {
-		log.Errorf("error while adding a new product: %v", err)
-		return err
+		repository.logger.ErrorContext(ctx, "error while getting product pages by store", "error", err)
+		return nil, err
+	}
+	defer productRows.Close()
+
+	pages := map[string][]domain.Product{}
+	for productRows.Next() {
+		product, err := scanProduct(productRows)
+		if err != nil {
+			return nil, err
+		}
+		pages[product.Store] = append(pages[product.Store], product)
+	}
+	return pages, productRows.Err()
+}
+
+func (repository *ProductRepository) CountProductsByStore(ctx context.Context, filter domain.ProductFilter) (map[string]int64, error) {
+	conditions, args := productConditions(filter)
+	countRows, err := repository.db.Query(ctx, "SELECT store, count(*) FROM products WHERE "+conditions+" GROUP BY store", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer countRows.Close()
+
+	counts := map[string]int64{}
+	for countRows.Next() {
+		var store string
+		var count int64
+		if err := countRows.Scan(&store, &count); err != nil {
+			return nil, err
+		}
+		counts[store] = count
+	}
+
+	return counts, countRows.Err()
+}
+
+func (repository *ProductRepository) AddProduct(ctx context.Context, product domain.Product) (int64, error) {
+	insertStatement := "INSERT INTO products (name, price, discount, store, sku) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+
+	var productId int64
+	err := repository.db.QueryRow(ctx, insertStatement, product.Name, product.Price, product.Discount, product.Store, nullableString(product.Sku)).Scan(&productId)
+	if isUniqueViolation(err) {
+		return 0, domain.ErrProductAlreadyExists
+	}
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while adding a new product", "error", err)
+		return 0, err
+	}
+
+	repository.logger.InfoContext(ctx, "Product added successfully", "id", productId)
+	return productId, nil
+}
+
+func (repository *ProductRepository) AddExternalIds(ctx context.Context, productId int64, externalIds []domain.ExternalId) error {
+	for _, externalId := range externalIds {
+		_, err := repository.db.Exec(ctx, "INSERT INTO product_external_ids (product_id, system, external_id) VALUES ($1, $2, $3)",
+			productId, externalId.System, externalId.Id)
+		if isUniqueViolation(err) {
+			return domain.ErrExternalIdAlreadyExists
+		}
+		if err != nil {
+			repository.logger.ErrorContext(ctx, "error while adding external id", "error", err)
+			return err
+		}
 	}
 
-	log.Info(fmt.Sprintf("Product added successfully: %v", addNewProduct))
 	return nil
 }
 
-func (repository *ProductRepository) GetProductById(productId int64) (domain.Product, error) {
-	ctx := context.Background()
+func (repository *ProductRepository) GetProductByExternalId(ctx context.Context, externalId domain.ExternalId) (domain.Product, error) {
+	var productId int64
+	err := repository.db.QueryRow(ctx, "SELECT product_id FROM product_external_ids WHERE system = $1 AND external_id = $2",
+		externalId.System, externalId.Id).Scan(&productId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Product{}, fmt.Errorf("%w with the external id %s/%s", domain.ErrProductNotFound, externalId.System, externalId.Id)
+	}
+	if err != nil {
+		return domain.Product{}, err
+	}
 
-	var product domain.Product
-	productRow := repository.dbPool.QueryRow(ctx, "SELECT * FROM products WHERE id = $1", productId)
+	return repository.GetProductById(ctx, productId)
+}
 
-	err := productRow.Scan(&product.Id, &product.Name, &product.Price, &product.Discount, &product.Store)
-	if err != nil && err.Error() == "no rows in result set" {
-		errStr := fmt.Sprintf("error while getting product by id: %d", productId)
-		log.Errorf(errStr)
-		return domain.Product{}, errors.New(errStr)
+// UpsertProductBySku inserts the product or, if the store already has a product
+// with the same sku, overwrites its name, price and discount. The returned flag
+// is true when a new row was created.
+func (repository *ProductRepository) UpsertProductBySku(ctx context.Context, product domain.Product) (domain.Product, bool, error) {
+	upsertStatement := `INSERT INTO products (name, price, discount, store, sku) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (store, sku) DO UPDATE SET name = EXCLUDED.name, price = EXCLUDED.price, discount = EXCLUDED.discount
+RETURNING ` + productColumns + `, (xmax = 0) AS inserted`
+
+	var inserted bool
+	var sku *string
+	upsertedRow := repository.db.QueryRow(ctx, upsertStatement, product.Name, product.Price, product.Discount, product.Store, product.Sku)
+	err := upsertedRow.Scan(&product.Id, &product.Name, &product.Price, &product.Discount, &product.Store, &sku, &inserted)
+	if isUniqueViolation(err) {
+		return domain.Product{}, false, domain.ErrProductAlreadyExists
+	}
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while upserting product", "error", err)
+		return domain.Product{}, false, err
 	}
 
+	if sku != nil {
+		product.Sku = *sku
+	}
+
+	repository.logger.InfoContext(ctx, "Product upserted successfully", "id", product.Id)
+	return product, inserted, nil
+}
+
+func (repository *ProductRepository) GetProductById(ctx context.Context, productId int64) (domain.Product, error) {
+	return repository.queryProductById(ctx, "SELECT "+productColumns+" FROM products WHERE id = $1", productId)
+}
+
+// GetProductByIdForUpdate reads the product and locks its row until the
+// surrounding transaction ends. Outside of WithTx the lock is released as soon
+// as the statement completes, so it should only be used inside a transaction.
+func (repository *ProductRepository) GetProductByIdForUpdate(ctx context.Context, productId int64) (domain.Product, error) {
+	return repository.queryProductById(ctx, "SELECT "+productColumns+" FROM products WHERE id = $1 FOR UPDATE", productId)
+}
+
+// GetProductBySkuForUpdate reads the product with its external ids and, like
+// GetProductByIdForUpdate, locks its row until the surrounding transaction
+// ends.
+func (repository *ProductRepository) GetProductBySkuForUpdate(ctx context.Context, store string, sku string) (domain.Product, error) {
+	productRow := repository.db.QueryRow(ctx, "SELECT "+productColumns+" FROM products WHERE store = $1 AND sku = $2 FOR UPDATE", store, sku)
+
+	product, err := scanProduct(productRow)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Product{}, fmt.Errorf("%w with the sku %s in store %s", domain.ErrProductNotFound, sku, store)
+	}
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	product.ExternalIds, err = repository.getExternalIds(ctx, product.Id)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	return product, nil
+}
+
+func (repository *ProductRepository) queryProductById(ctx context.Context, query string, productId int64) (domain.Product, error) {
+	productRow := repository.db.QueryRow(ctx, query, productId)
+
+	product, err := scanProduct(productRow)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Product{}, fmt.Errorf("%w with the id %d", domain.ErrProductNotFound, productId)
+	}
+
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	product.ExternalIds, err = repository.getExternalIds(ctx, productId)
 	if err != nil {
 		return domain.Product{}, err
 	}
@@ -84,40 +280,128 @@ func (repository *ProductRepository) GetProductById(productId int64) (domain.Pro
 	return product, nil
 }
 
-func (repository *ProductRepository) DeleteProductById(productId int64) error {
-	ctx := context.Background()
+func (repository *ProductRepository) getExternalIds(ctx context.Context, productId int64) ([]domain.ExternalId, error) {
+	externalIdRows, err := repository.db.Query(ctx, "SELECT system, external_id FROM product_external_ids WHERE product_id = $1 ORDER BY system, external_id", productId)
+	if err != nil {
+		return nil, err
+	}
+	defer externalIdRows.Close()
 
-	_, err := repository.dbPool.Exec(ctx, "DELETE FROM products WHERE id = $1", productId)
+	var externalIds []domain.ExternalId
+	for externalIdRows.Next() {
+		var externalId domain.ExternalId
+		if err := externalIdRows.Scan(&externalId.System, &externalId.Id); err != nil {
+			return nil, err
+		}
+		externalIds = append(externalIds, externalId)
+	}
+
+	return externalIds, externalIdRows.Err()
+}
+
+func (repository *ProductRepository) DeleteProductById(ctx context.Context, productId int64) error {
+	_, err := repository.db.Exec(ctx, "DELETE FROM products WHERE id = $1", productId)
 	if err != nil {
 		return err
 	}
 
-	log.Info("Product deleted successfully")
+	repository.logger.InfoContext(ctx, "Product deleted successfully", "id", productId)
 
 	return nil
 }
 
-func (repository *ProductRepository) UpdatePriceById(productId int64, newPrice float32) error {
-	ctx := context.Background()
-
-	_, err := repository.dbPool.Exec(ctx, "UPDATE products SET price = $1 WHERE id = $2", newPrice, productId)
+func (repository *ProductRepository) UpdatePriceById(ctx context.Context, productId int64, newPrice float32) error {
+	_, err := repository.db.Exec(ctx, "UPDATE products SET price = $1 WHERE id = $2", newPrice, productId)
 	if err != nil {
 		return err
 	}
 
-	log.Info("Price updated successfully")
+	repository.logger.InfoContext(ctx, "Price updated successfully", "id", productId)
 
 	return nil
 }
 
+// WithTx runs fn against a repository bound to a single transaction. The
+// transaction is committed if fn returns nil and rolled back otherwise. When
+// called on a repository that is already inside a transaction, a savepoint is
+// used instead.
+func (repository *ProductRepository) WithTx(ctx context.Context, fn func(repository IProductRepository) error) error {
+	return repository.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		return fn(&ProductRepository{tx, repository.logger})
+	})
+}
+
+// AuditEvents returns an audit repository bound to the same connection or
+// transaction as this repository.
+func (repository *ProductRepository) AuditEvents() IAuditRepository {
+	return &AuditRepository{repository.db, repository.logger}
+}
+
+// Outbox returns an outbox repository bound to the same connection or
+// transaction as this repository.
+func (repository *ProductRepository) Outbox() IOutboxRepository {
+	return &OutboxRepository{repository.db, repository.logger}
+}
+
 func extractProductsFromRows(productRows pgx.Rows) []domain.Product {
 	var products []domain.Product
 
 	for productRows.Next() {
-		var product domain.Product
-		productRows.Scan(&product.Id, &product.Name, &product.Price, &product.Discount, &product.Store)
+		product, _ := scanProduct(productRows)
 		products = append(products, product)
 	}
 
 	return products
 }
+
+func scanProduct(row pgx.Row) (domain.Product, error) {
+	var product domain.Product
+	var sku *string
+
+	err := row.Scan(&product.Id, &product.Name, &product.Price, &product.Discount, &product.Store, &sku)
+	if sku != nil {
+		product.Sku = *sku
+	}
+
+	return product, err
+}
+
+// productConditions returns the condition selecting the products matching
+// filter, together with its arguments.
+func productConditions(filter domain.ProductFilter) (string, []interface{}) {
+	conditions := []string{"true"}
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Stores != nil {
+		addCondition("store = ANY($%d)", filter.Stores)
+	}
+	if filter.Sku != "" {
+		addCondition("sku = $%d", filter.Sku)
+	}
+	if filter.NameContains != "" {
+		addCondition("strpos(lower(name), lower($%d)) > 0", filter.NameContains)
+	}
+	if filter.MinPrice != nil {
+		addCondition("price >= $%d", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		addCondition("price <= $%d", *filter.MaxPrice)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode
+}
+
+func nullableString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
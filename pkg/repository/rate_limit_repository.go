@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"math"
+	"sync"
+	"time"
+)
+
+// IRateLimitRepository stores token buckets. TakeToken refills the bucket for
+// the elapsed time, removes one token if available and returns the tokens left
+// afterwards together with whether a token was taken.
+type IRateLimitRepository interface {
+	TakeToken(key string, capacity int, refillPerSecond float64) (float64, bool, error)
+	DeleteIdleBuckets(idleFor time.Duration) (int64, error)
+}
+
+type PostgresRateLimitRepository struct {
+	dbPool *pgxpool.Pool
+}
+
+// NewPostgresRateLimitRepository keeps buckets in Postgres so that limits are
+// shared by all instances of the API.
+func NewPostgresRateLimitRepository(dbPool *pgxpool.Pool) IRateLimitRepository {
+	return &PostgresRateLimitRepository{dbPool}
+}
+
+func (repository *PostgresRateLimitRepository) TakeToken(key string, capacity int, refillPerSecond float64) (float64, bool, error) {
+	ctx := context.Background()
+
+	_, err := repository.dbPool.Exec(ctx, "INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES ($1, $2, now()) ON CONFLICT (key) DO NOTHING", key, float64(capacity))
+	if err != nil {
+		return 0, false, err
+	}
+
+	takeStatement := `UPDATE rate_limit_buckets AS bucket
+SET tokens = CASE WHEN refilled.tokens >= 1 THEN refilled.tokens - 1 ELSE refilled.tokens END, updated_at = now()
+FROM (
+	SELECT key, LEAST($2::float8, tokens + EXTRACT(EPOCH FROM (now() - updated_at)) * $3::float8) AS tokens
+	FROM rate_limit_buckets WHERE key = $1 FOR UPDATE
+) AS refilled
+WHERE bucket.key = refilled.key
+RETURNING bucket.tokens, refilled.tokens >= 1`
+
+	var tokens float64
+	var allowed bool
+	err = repository.dbPool.QueryRow(ctx, takeStatement, key, float64(capacity), refillPerSecond).Scan(&tokens, &allowed)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return tokens, allowed, nil
+}
+
+func (repository *PostgresRateLimitRepository) DeleteIdleBuckets(idleFor time.Duration) (int64, error) {
+	ctx := context.Background()
+
+	result, err := repository.dbPool.Exec(ctx, "DELETE FROM rate_limit_buckets WHERE updated_at < now() - $1::interval", idleFor.String())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+type MemoryRateLimitRepository struct {
+	mutex   sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+// NewMemoryRateLimitRepository keeps buckets in process memory. Limits apply
+// per instance.
+func NewMemoryRateLimitRepository() IRateLimitRepository {
+	return &MemoryRateLimitRepository{buckets: map[string]*memoryBucket{}}
+}
+
+func (repository *MemoryRateLimitRepository) TakeToken(key string, capacity int, refillPerSecond float64) (float64, bool, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	now := time.Now()
+	bucket, found := repository.buckets[key]
+	if !found {
+		bucket = &memoryBucket{tokens: float64(capacity), updatedAt: now}
+		repository.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(float64(capacity), bucket.tokens+now.Sub(bucket.updatedAt).Seconds()*refillPerSecond)
+	bucket.updatedAt = now
+
+	if bucket.tokens < 1 {
+		return bucket.tokens, false, nil
+	}
+	bucket.tokens--
+	return bucket.tokens, true, nil
+}
+
+func (repository *MemoryRateLimitRepository) DeleteIdleBuckets(idleFor time.Duration) (int64, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	var deleted int64
+	for key, bucket := range repository.buckets {
+		if time.Since(bucket.updatedAt) > idleFor {
+			delete(repository.buckets, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
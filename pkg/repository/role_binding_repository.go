@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"log/slog"
+)
+
+type IRoleBindingRepository interface {
+	AddRoleBinding(roleBinding domain.RoleBinding) (domain.RoleBinding, error)
+	GetAllRoleBindings() []domain.RoleBinding
+	GetRoleBindingsBySubject(subject string) ([]domain.RoleBinding, error)
+	DeleteRoleBindingById(roleBindingId int64) error
+}
+
+const roleBindingColumns = "id, subject, role, store, created_at"
+
+type RoleBindingRepository struct {
+	dbPool *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewRoleBindingRepository(dbPool *pgxpool.Pool, logger *slog.Logger) IRoleBindingRepository {
+	return &RoleBindingRepository{dbPool, logger}
+}
+
+func (repository *RoleBindingRepository) AddRoleBinding(roleBinding domain.RoleBinding) (domain.RoleBinding, error) {
+	ctx := context.Background()
+
+	insertStatement := "INSERT INTO role_bindings (subject, role, store) VALUES ($1, $2, $3) RETURNING " + roleBindingColumns
+
+	var addedRoleBinding domain.RoleBinding
+	err := repository.dbPool.QueryRow(ctx, insertStatement, roleBinding.Subject, roleBinding.Role, roleBinding.Store).
+		Scan(&addedRoleBinding.Id, &addedRoleBinding.Subject, &addedRoleBinding.Role, &addedRoleBinding.Store, &addedRoleBinding.CreatedAt)
+	if isUniqueViolation(err) {
+		return domain.RoleBinding{}, domain.ErrRoleBindingAlreadyExists
+	}
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while adding a new role binding", "error", err)
+		return domain.RoleBinding{}, err
+	}
+
+	repository.logger.InfoContext(ctx, "Role binding added successfully", "id", addedRoleBinding.Id)
+	return addedRoleBinding, nil
+}
+
+func (repository *RoleBindingRepository) GetAllRoleBindings() []domain.RoleBinding {
+	ctx := context.Background()
+	roleBindingRows, err := repository.dbPool.Query(ctx, "SELECT "+roleBindingColumns+" FROM role_bindings ORDER BY id")
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while getting all role bindings", "error", err)
+		return []domain.RoleBinding{}
+	}
+
+	return extractRoleBindingsFromRows(roleBindingRows)
+}
+
+// GetRoleBindingsBySubject returns an error rather than no bindings when
+// the query fails, so that authorization cannot mistake a failure for a
+// principal without access.
+func (repository *RoleBindingRepository) GetRoleBindingsBySubject(subject string) ([]domain.RoleBinding, error) {
+	ctx := context.Background()
+	roleBindingRows, err := repository.dbPool.Query(ctx, "SELECT "+roleBindingColumns+" FROM role_bindings WHERE subject = $1 ORDER BY id", subject)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while getting role bindings by subject", "error", err)
+		return nil, err
+	}
+	defer roleBindingRows.Close()
+
+	var roleBindings []domain.RoleBinding
+	for roleBindingRows.Next() {
+		var roleBinding domain.RoleBinding
+		err := roleBindingRows.Scan(&roleBinding.Id, &roleBinding.Subject, &roleBinding.Role, &roleBinding.Store, &roleBinding.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		roleBindings = append(roleBindings, roleBinding)
+	}
+
+	return roleBindings, roleBindingRows.Err()
+}
+
+func (repository *RoleBindingRepository) DeleteRoleBindingById(roleBindingId int64) error {
+	ctx := context.Background()
+
+	result, err := repository.dbPool.Exec(ctx, "DELETE FROM role_bindings WHERE id = $1", roleBindingId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%w with the id %d", domain.ErrRoleBindingNotFound, roleBindingId)
+	}
+
+	repository.logger.InfoContext(ctx, "Role binding deleted successfully", "id", roleBindingId)
+	return nil
+}
+
+func extractRoleBindingsFromRows(roleBindingRows pgx.Rows) []domain.RoleBinding {
+	defer roleBindingRows.Close()
+
+	var roleBindings []domain.RoleBinding
+	for roleBindingRows.Next() {
+		var roleBinding domain.RoleBinding
+		roleBindingRows.Scan(&roleBinding.Id, &roleBinding.Subject, &roleBinding.Role, &roleBinding.Store, &roleBinding.CreatedAt)
+		roleBindings = append(roleBindings, roleBinding)
+	}
+
+	return roleBindings
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/erkindilekci/product-api/pkg/repository")
+
+// tracingExecutor starts a client span for every statement it runs. pgx v4
+// has no tracer hooks, so the spans are created around the dbExecutor calls
+// instead. Queries keep their span open until the rows are closed.
+type tracingExecutor struct {
+	db dbExecutor
+}
+
+func newTracingExecutor(db dbExecutor) dbExecutor {
+	return &tracingExecutor{db}
+}
+
+func (executor *tracingExecutor) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	ctx, span := startQuerySpan(ctx, sql)
+	defer span.End()
+
+	commandTag, err := executor.db.Exec(ctx, sql, arguments...)
+	recordQueryError(span, err)
+	return commandTag, err
+}
+
+func (executor *tracingExecutor) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	ctx, span := startQuerySpan(ctx, sql)
+
+	rows, err := executor.db.Query(ctx, sql, args...)
+	if err != nil {
+		recordQueryError(span, err)
+		span.End()
+		return nil, err
+	}
+	return &tracingRows{Rows: rows, span: span}, nil
+}
+
+func (executor *tracingExecutor) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	ctx, span := startQuerySpan(ctx, sql)
+	return &tracingRow{executor.db.QueryRow(ctx, sql, args...), span}
+}
+
+func (executor *tracingExecutor) BeginFunc(ctx context.Context, f func(pgx.Tx) error) error {
+	ctx, span := tracer.Start(ctx, "db.transaction", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "postgresql")))
+	defer span.End()
+
+	err := executor.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		return f(&tracingTx{tx, &tracingExecutor{tx}})
+	})
+	recordQueryError(span, err)
+	return err
+}
+
+// tracingTx traces the statements run inside a transaction; all other
+// methods go straight to the wrapped transaction.
+type tracingTx struct {
+	pgx.Tx
+	executor *tracingExecutor
+}
+
+func (tx *tracingTx) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	return tx.executor.Exec(ctx, sql, arguments...)
+}
+
+func (tx *tracingTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return tx.executor.Query(ctx, sql, args...)
+}
+
+func (tx *tracingTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return tx.executor.QueryRow(ctx, sql, args...)
+}
+
+func (tx *tracingTx) BeginFunc(ctx context.Context, f func(pgx.Tx) error) error {
+	return tx.executor.BeginFunc(ctx, f)
+}
+
+type tracingRows struct {
+	pgx.Rows
+	span  trace.Span
+	ended bool
+}
+
+func (rows *tracingRows) Close() {
+	rows.Rows.Close()
+	if rows.ended {
+		return
+	}
+	rows.ended = true
+	recordQueryError(rows.span, rows.Rows.Err())
+	rows.span.End()
+}
+
+func (rows *tracingRows) Next() bool {
+	if rows.Rows.Next() {
+		return true
+	}
+	// pgx closes the rows once they are exhausted without calling Close on
+	// this wrapper, so the span is ended here as well.
+	rows.Close()
+	return false
+}
+
+type tracingRow struct {
+	row  pgx.Row
+	span trace.Span
+}
+
+func (row *tracingRow) Scan(dest ...interface{}) error {
+	defer row.span.End()
+
+	err := row.row.Scan(dest...)
+	if err != pgx.ErrNoRows {
+		recordQueryError(row.span, err)
+	}
+	return err
+}
+
+func startQuerySpan(ctx context.Context, sql string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "db.query", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "postgresql"), attribute.String("db.statement", sql)))
+}
+
+func recordQueryError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
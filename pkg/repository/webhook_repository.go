@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type IWebhookRepository interface {
+	AddSubscription(ctx context.Context, subscription domain.WebhookSubscription) (domain.WebhookSubscription, error)
+	GetAllSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error)
+	GetSubscriptionById(ctx context.Context, subscriptionId int64) (domain.WebhookSubscription, error)
+	DeleteSubscriptionById(ctx context.Context, subscriptionId int64) error
+	AddDeliveries(ctx context.Context, deliveries []domain.WebhookDelivery) error
+	ClaimDueDeliveries(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]domain.WebhookDelivery, error)
+	GetDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter) ([]domain.WebhookDelivery, error)
+	GetDeliveryById(ctx context.Context, deliveryId int64) (domain.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery domain.WebhookDelivery) error
+}
+
+const (
+	webhookSubscriptionColumns = "id, url, event_types, secret, created_at"
+	webhookDeliveryColumns     = "id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at"
+)
+
+type WebhookRepository struct {
+	db     dbExecutor
+	logger *slog.Logger
+}
+
+func NewWebhookRepository(dbPool *pgxpool.Pool, logger *slog.Logger) IWebhookRepository {
+	return &WebhookRepository{newTracingExecutor(dbPool), logger}
+}
+
+func (repository *WebhookRepository) AddSubscription(ctx context.Context, subscription domain.WebhookSubscription) (domain.WebhookSubscription, error) {
+	insertStatement := "INSERT INTO webhook_subscriptions (url, event_types, secret) VALUES ($1, $2, $3) RETURNING " + webhookSubscriptionColumns
+
+	addedSubscription, err := scanWebhookSubscription(repository.db.QueryRow(ctx, insertStatement, subscription.Url, subscription.EventTypes, subscription.Secret))
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while adding a webhook subscription", "error", err)
+		return domain.WebhookSubscription{}, err
+	}
+
+	repository.logger.InfoContext(ctx, "Webhook subscription added successfully", "id", addedSubscription.Id)
+	return addedSubscription, nil
+}
+
+func (repository *WebhookRepository) GetAllSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	subscriptionRows, err := repository.db.Query(ctx, "SELECT "+webhookSubscriptionColumns+" FROM webhook_subscriptions ORDER BY id")
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while getting webhook subscriptions", "error", err)
+		return nil, err
+	}
+	defer subscriptionRows.Close()
+
+	var subscriptions []domain.WebhookSubscription
+	for subscriptionRows.Next() {
+		subscription, err := scanWebhookSubscription(subscriptionRows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, subscriptionRows.Err()
+}
+
+func (repository *WebhookRepository) GetSubscriptionById(ctx context.Context, subscriptionId int64) (domain.WebhookSubscription, error) {
+	subscription, err := scanWebhookSubscription(repository.db.QueryRow(ctx, "SELECT "+webhookSubscriptionColumns+" FROM webhook_subscriptions WHERE id = $1", subscriptionId))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.WebhookSubscription{}, fmt.Errorf("%w with the id %d", domain.ErrWebhookSubscriptionNotFound, subscriptionId)
+	}
+	return subscription, err
+}
+
+// DeleteSubscriptionById also deletes the deliveries of the subscription.
+func (repository *WebhookRepository) DeleteSubscriptionById(ctx context.Context, subscriptionId int64) error {
+	result, err := repository.db.Exec(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", subscriptionId)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%w with the id %d", domain.ErrWebhookSubscriptionNotFound, subscriptionId)
+	}
+
+	repository.logger.InfoContext(ctx, "Webhook subscription deleted successfully", "id", subscriptionId)
+	return nil
+}
+
+// AddDeliveries skips deliveries of an event that its subscription already
+// has, since the relay may publish an event more than once.
+func (repository *WebhookRepository) AddDeliveries(ctx context.Context, deliveries []domain.WebhookDelivery) error {
+	insertStatement := `INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, status, next_attempt_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (subscription_id, event_id) DO NOTHING`
+
+	return repository.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		for _, delivery := range deliveries {
+			_, err := tx.Exec(ctx, insertStatement, delivery.SubscriptionId, delivery.EventId, delivery.EventType,
+				string(delivery.Payload), delivery.Status, delivery.NextAttemptAt)
+			if err != nil {
+				repository.logger.ErrorContext(ctx, "error while adding webhook delivery", "error", err)
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ClaimDueDeliveries returns up to limit pending deliveries whose next attempt
+// is due and postpones them to leaseUntil. Another instance polling at the same
+// time skips the claimed rows, and a delivery whose sender died is picked up
+// again once the lease ends.
+func (repository *WebhookRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]domain.WebhookDelivery, error) {
+	claimStatement := `UPDATE webhook_deliveries SET next_attempt_at = $3
+WHERE id IN (
+  SELECT id FROM webhook_deliveries WHERE status = $1 AND next_attempt_at <= $2
+  ORDER BY next_attempt_at, id LIMIT $4 FOR UPDATE SKIP LOCKED
+)
+RETURNING ` + webhookDeliveryColumns
+
+	deliveryRows, err := repository.db.Query(ctx, claimStatement, domain.WebhookDeliveryPending, now, leaseUntil, limit)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while claiming webhook deliveries", "error", err)
+		return nil, err
+	}
+	return extractWebhookDeliveriesFromRows(deliveryRows)
+}
+
+// GetDeliveries lists the newest deliveries first.
+func (repository *WebhookRepository) GetDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter) ([]domain.WebhookDelivery, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.SubscriptionId != 0 {
+		addCondition("subscription_id = $%d", filter.SubscriptionId)
+	}
+	if filter.Status != "" {
+		addCondition("status = $%d", filter.Status)
+	}
+
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	deliveryRows, err := repository.db.Query(ctx, query, args...)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while getting webhook deliveries", "error", err)
+		return nil, err
+	}
+	return extractWebhookDeliveriesFromRows(deliveryRows)
+}
+
+func (repository *WebhookRepository) GetDeliveryById(ctx context.Context, deliveryId int64) (domain.WebhookDelivery, error) {
+	delivery, err := scanWebhookDelivery(repository.db.QueryRow(ctx, "SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE id = $1", deliveryId))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.WebhookDelivery{}, fmt.Errorf("%w with the id %d", domain.ErrWebhookDeliveryNotFound, deliveryId)
+	}
+	return delivery, err
+}
+
+// UpdateDelivery stores the outcome of an attempt.
+func (repository *WebhookRepository) UpdateDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	updateStatement := `UPDATE webhook_deliveries
+SET status = $2, attempts = $3, next_attempt_at = $4, last_status_code = $5, last_error = $6, delivered_at = $7
+WHERE id = $1`
+
+	var lastStatusCode *int
+	if delivery.LastStatusCode != 0 {
+		lastStatusCode = &delivery.LastStatusCode
+	}
+	result, err := repository.db.Exec(ctx, updateStatement, delivery.Id, delivery.Status, delivery.Attempts, delivery.NextAttemptAt,
+		lastStatusCode, nullableString(delivery.LastError), delivery.DeliveredAt)
+	if err != nil {
+		repository.logger.ErrorContext(ctx, "error while updating webhook delivery", "id", delivery.Id, "error", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%w with the id %d", domain.ErrWebhookDeliveryNotFound, delivery.Id)
+	}
+	return nil
+}
+
+func scanWebhookSubscription(row pgx.Row) (domain.WebhookSubscription, error) {
+	var subscription domain.WebhookSubscription
+	err := row.Scan(&subscription.Id, &subscription.Url, &subscription.EventTypes, &subscription.Secret, &subscription.CreatedAt)
+	return subscription, err
+}
+
+func scanWebhookDelivery(row pgx.Row) (domain.WebhookDelivery, error) {
+	var delivery domain.WebhookDelivery
+	var payload []byte
+	var lastStatusCode *int
+	var lastError *string
+	err := row.Scan(&delivery.Id, &delivery.SubscriptionId, &delivery.EventId, &delivery.EventType, &payload, &delivery.Status,
+		&delivery.Attempts, &delivery.NextAttemptAt, &lastStatusCode, &lastError, &delivery.CreatedAt, &delivery.DeliveredAt)
+	if err != nil {
+		return domain.WebhookDelivery{}, err
+	}
+	delivery.Payload = payload
+	if lastStatusCode != nil {
+		delivery.LastStatusCode = *lastStatusCode
+	}
+	if lastError != nil {
+		delivery.LastError = *lastError
+	}
+	return delivery, nil
+}
+
+func extractWebhookDeliveriesFromRows(deliveryRows pgx.Rows) ([]domain.WebhookDelivery, error) {
+	defer deliveryRows.Close()
+
+	var deliveries []domain.WebhookDelivery
+	for deliveryRows.Next() {
+		delivery, err := scanWebhookDelivery(deliveryRows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, deliveryRows.Err()
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"github.com/erkindilekci/product-api/pkg/service/dto"
+	"strings"
+)
+
+// Api keys have the form pak_<prefix>_<secret>. The prefix is used to find the
+// stored key and the hash of the whole token is compared against it.
+const (
+	apiKeyTokenPrefix = "pak"
+	apiKeyPrefixBytes = 4
+	apiKeySecretBytes = 24
+)
+
+var ErrInvalidApiKey = errors.New("invalid api key")
+
+type IApiKeyService interface {
+	Create(apiKeyCreate dto.ApiKeyCreate) (domain.ApiKey, string, error)
+	GetAll() []domain.ApiKey
+	Rotate(apiKeyId int64) (domain.ApiKey, string, error)
+	Revoke(apiKeyId int64) error
+	Authenticate(token string) (domain.Principal, error)
+	EnsureBootstrapKey(token string) error
+}
+
+type ApiKeyService struct {
+	apiKeyRepository repository.IApiKeyRepository
+}
+
+func NewApiKeyService(apiKeyRepository repository.IApiKeyRepository) IApiKeyService {
+	return &ApiKeyService{apiKeyRepository}
+}
+
+// Create stores a new key and returns it together with the plaintext token,
+// which is not recoverable afterwards.
+func (service *ApiKeyService) Create(apiKeyCreate dto.ApiKeyCreate) (domain.ApiKey, string, error) {
+	err := validateApiKeyCreate(apiKeyCreate)
+	if err != nil {
+		return domain.ApiKey{}, "", err
+	}
+
+	prefix, token, err := generateApiKeyToken()
+	if err != nil {
+		return domain.ApiKey{}, "", err
+	}
+
+	apiKey, err := service.apiKeyRepository.AddApiKey(domain.ApiKey{
+		Name:    apiKeyCreate.Name,
+		Prefix:  prefix,
+		KeyHash: hashApiKeyToken(token),
+		Scopes:  apiKeyCreate.Scopes,
+	})
+	if err != nil {
+		return domain.ApiKey{}, "", err
+	}
+
+	return apiKey, token, nil
+}
+
+func (service *ApiKeyService) GetAll() []domain.ApiKey {
+	return service.apiKeyRepository.GetAllApiKeys()
+}
+
+// Rotate replaces the secret of an active key. The old token stops working
+// immediately; name and scopes are kept.
+func (service *ApiKeyService) Rotate(apiKeyId int64) (domain.ApiKey, string, error) {
+	prefix, token, err := generateApiKeyToken()
+	if err != nil {
+		return domain.ApiKey{}, "", err
+	}
+
+	err = service.apiKeyRepository.UpdateApiKeySecret(apiKeyId, prefix, hashApiKeyToken(token))
+	if err != nil {
+		return domain.ApiKey{}, "", err
+	}
+
+	apiKey, err := service.apiKeyRepository.GetApiKeyById(apiKeyId)
+	if err != nil {
+		return domain.ApiKey{}, "", err
+	}
+
+	return apiKey, token, nil
+}
+
+func (service *ApiKeyService) Revoke(apiKeyId int64) error {
+	return service.apiKeyRepository.RevokeApiKeyById(apiKeyId)
+}
+
+func (service *ApiKeyService) Authenticate(token string) (domain.Principal, error) {
+	prefix, ok := parseApiKeyPrefix(token)
+	if !ok {
+		return domain.Principal{}, ErrInvalidApiKey
+	}
+
+	apiKey, err := service.apiKeyRepository.GetApiKeyByPrefix(prefix)
+	if err != nil || apiKey.Revoked() {
+		return domain.Principal{}, ErrInvalidApiKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(hashApiKeyToken(token))) != 1 {
+		return domain.Principal{}, ErrInvalidApiKey
+	}
+
+	return domain.Principal{Subject: fmt.Sprintf("api-key:%d", apiKey.Id), Scopes: apiKey.Scopes}, nil
+}
+
+// EnsureBootstrapKey stores token as an admin key unless a key with the same
+// prefix already exists. It lets a fresh deployment create its first keys.
+func (service *ApiKeyService) EnsureBootstrapKey(token string) error {
+	prefix, ok := parseApiKeyPrefix(token)
+	if !ok {
+		return fmt.Errorf("bootstrap api key must have the form %s_<prefix>_<secret>", apiKeyTokenPrefix)
+	}
+
+	if _, err := service.apiKeyRepository.GetApiKeyByPrefix(prefix); err == nil {
+		return nil
+	}
+
+	_, err := service.apiKeyRepository.AddApiKey(domain.ApiKey{
+		Name:    "bootstrap-admin",
+		Prefix:  prefix,
+		KeyHash: hashApiKeyToken(token),
+		Scopes:  []string{domain.ScopeAdmin},
+	})
+	return err
+}
+
+func validateApiKeyCreate(apiKeyCreate dto.ApiKeyCreate) error {
+	validationError := &domain.ValidationError{}
+	if apiKeyCreate.Name == "" {
+		validationError.Add("/name", "name can't be empty")
+	}
+	checkMaxLength(validationError, "/name", "name", apiKeyCreate.Name)
+	if len(apiKeyCreate.Scopes) == 0 {
+		validationError.Add("/scopes", "scopes can't be empty")
+	}
+	for i, scope := range apiKeyCreate.Scopes {
+		if !isKnownScope(scope) {
+			validationError.Add(fmt.Sprintf("/scopes/%d", i), fmt.Sprintf("unknown scope %q", scope))
+		}
+	}
+	return validationError.OrNil()
+}
+
+func isKnownScope(scope string) bool {
+	for _, knownScope := range domain.KnownScopes {
+		if scope == knownScope {
+			return true
+		}
+	}
+	return false
+}
+
+func generateApiKeyToken() (string, string, error) {
+	prefixBytes := make([]byte, apiKeyPrefixBytes)
+	secretBytes := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", err
+	}
+
+	prefix := hex.EncodeToString(prefixBytes)
+	return prefix, fmt.Sprintf("%s_%s_%s", apiKeyTokenPrefix, prefix, hex.EncodeToString(secretBytes)), nil
+}
+
+func parseApiKeyPrefix(token string) (string, bool) {
+	parts := strings.Split(token, "_")
+	if len(parts) != 3 || parts[0] != apiKeyTokenPrefix || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+func hashApiKeyToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+)
+
+const (
+	defaultAuditEventLimit = 100
+	maxAuditEventLimit     = 10000
+)
+
+type IAuditService interface {
+	GetEvents(ctx context.Context, filter domain.AuditEventFilter) ([]domain.AuditEvent, error)
+	GetPriceHistory(ctx context.Context, productId int64, beforeEventId int64, limit int) (domain.PriceChangePage, error)
+}
+
+type AuditService struct {
+	auditRepository repository.IAuditRepository
+}
+
+func NewAuditService(auditRepository repository.IAuditRepository) IAuditService {
+	return &AuditService{auditRepository}
+}
+
+func (service *AuditService) GetEvents(ctx context.Context, filter domain.AuditEventFilter) ([]domain.AuditEvent, error) {
+	validationError := &domain.ValidationError{}
+	if filter.Limit < 0 || filter.Limit > maxAuditEventLimit {
+		validationError.Add("limit", fmt.Sprintf("limit must be between 1 and %d", maxAuditEventLimit))
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		validationError.Add("from", "from must be before to")
+	}
+	if err := validationError.OrNil(); err != nil {
+		return nil, err
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditEventLimit
+	}
+
+	return service.auditRepository.GetAuditEvents(ctx, filter)
+}
+
+// GetPriceHistory lists up to limit prices a product had, newest first,
+// starting below the audit event beforeEventId, 0 for the latest price. Audit
+// events that left the price unchanged, such as upserts of other fields, are
+// skipped.
+func (service *AuditService) GetPriceHistory(ctx context.Context, productId int64, beforeEventId int64, limit int) (domain.PriceChangePage, error) {
+	filter := domain.AuditEventFilter{ProductId: productId, PriceChangesOnly: true}
+	totalCount, err := service.auditRepository.CountAuditEvents(ctx, filter)
+	if err != nil {
+		return domain.PriceChangePage{}, err
+	}
+
+	filter.BeforeId = beforeEventId
+	filter.Limit = limit + 1
+	filter.Descending = true
+	auditEvents, err := service.auditRepository.GetAuditEvents(ctx, filter)
+	if err != nil {
+		return domain.PriceChangePage{}, err
+	}
+
+	page := domain.PriceChangePage{PriceChanges: []domain.PriceChange{}, TotalCount: totalCount}
+	if len(auditEvents) > limit {
+		auditEvents = auditEvents[:limit]
+		page.HasNextPage = true
+	}
+	for _, auditEvent := range auditEvents {
+		var before, after productSnapshot
+		if err := json.Unmarshal(auditEvent.After, &after); err != nil {
+			return domain.PriceChangePage{}, err
+		}
+
+		priceChange := domain.PriceChange{
+			EventId:   auditEvent.Id,
+			ProductId: auditEvent.ProductId,
+			Price:     after.Price,
+			Actor:     auditEvent.Actor,
+			ChangedAt: auditEvent.CreatedAt,
+		}
+		if auditEvent.Before != nil {
+			if err := json.Unmarshal(auditEvent.Before, &before); err != nil {
+				return domain.PriceChangePage{}, err
+			}
+			priceChange.PreviousPrice = &before.Price
+		}
+		page.PriceChanges = append(page.PriceChanges, priceChange)
+	}
+	return page, nil
+}
+
+// productSnapshot is the JSON form of a product stored in audit events. It is
+// kept separate from the API response so that the audit format stays stable.
+type productSnapshot struct {
+	Id       int64   `json:"id"`
+	Name     string  `json:"name"`
+	Price    float32 `json:"price"`
+	Discount float32 `json:"discount"`
+	Store    string  `json:"store"`
+	Sku      string  `json:"sku,omitempty"`
+}
+
+// recordProductChange stores a mutation of the product as an audit event,
+// together with the actor and request it originates from, and as an outbox
+// event to be published. Both are written through productRepository, so they
+// commit or roll back with the mutation. before or after is nil when the
+// product did not exist on that side of the mutation.
+func recordProductChange(ctx context.Context, productRepository repository.IProductRepository, action string, productId int64, before *domain.Product, after *domain.Product) error {
+	beforeSnapshot, err := snapshotProduct(before)
+	if err != nil {
+		return err
+	}
+	afterSnapshot, err := snapshotProduct(after)
+	if err != nil {
+		return err
+	}
+
+	requestMetadata := domain.RequestMetadataFromContext(ctx)
+	err = productRepository.AuditEvents().AddAuditEvent(ctx, domain.AuditEvent{
+		Action:    action,
+		ProductId: productId,
+		Actor:     actorFromContext(ctx),
+		RequestId: requestMetadata.RequestId,
+		ClientIp:  requestMetadata.ClientIp,
+		Before:    beforeSnapshot,
+		After:     afterSnapshot,
+	})
+	if err != nil {
+		return err
+	}
+
+	return addOutboxEvent(ctx, productRepository.Outbox(), action, productId, beforeSnapshot, afterSnapshot)
+}
+
+func snapshotProduct(product *domain.Product) (json.RawMessage, error) {
+	if product == nil {
+		return nil, nil
+	}
+	return json.Marshal(productSnapshot{
+		Id:       product.Id,
+		Name:     product.Name,
+		Price:    product.Price,
+		Discount: product.Discount,
+		Store:    product.Store,
+		Sku:      product.Sku,
+	})
+}
@@ -1,8 +1,29 @@
 package dto
 
+import "github.com/erkindilekci/product-api/pkg/domain"
+
 type ProductCreate struct {
-	Name     string
-	Price    float32
-	Discount float32
-	Store    string
+	Name        string
+	Price       float32
+	Discount    float32
+	Store       string
+	Sku         string
+	ExternalIds []domain.ExternalId
+}
+
+type ApiKeyCreate struct {
+	Name   string
+	Scopes []string
+}
+
+type RoleBindingCreate struct {
+	Subject string
+	Role    string
+	Store   string
+}
+
+type WebhookSubscriptionCreate struct {
+	Url        string
+	EventTypes []string
+	Secret     string
 }
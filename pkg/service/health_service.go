@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+// requiredTables are the tables created by scripts/init_db.sh. A table missing
+// from the database means the script has not been run up to date.
+var requiredTables = []string{
+	"products",
+	"idempotency_keys",
+	"product_external_ids",
+	"api_keys",
+	"role_bindings",
+	"audit_events",
+	"rate_limit_buckets",
+	"webhook_subscriptions",
+	"webhook_deliveries",
+	"outbox",
+	"product_changes",
+}
+
+// requiredColumns are the columns scripts/init_db.sh adds to tables that
+// existed before, written as table.column. CREATE TABLE IF NOT EXISTS leaves
+// an existing table alone, so these are missing if only the newer part of the
+// script has not been run.
+var requiredColumns = []string{
+	"products.sku",
+}
+
+type IHealthService interface {
+	Readiness(ctx context.Context) domain.HealthReport
+	Drain()
+	Draining() bool
+}
+
+type HealthService struct {
+	healthRepository repository.IHealthRepository
+	logger           *slog.Logger
+	draining         atomic.Bool
+}
+
+func NewHealthService(healthRepository repository.IHealthRepository, logger *slog.Logger) IHealthService {
+	return &HealthService{healthRepository: healthRepository, logger: logger}
+}
+
+// Readiness checks the database connection and schema. The instance is
+// reported as not ready while draining, even if all components are up.
+// /readyz is served without authentication, so failed checks are reported
+// with a generic error and only logged in detail.
+func (service *HealthService) Readiness(ctx context.Context) domain.HealthReport {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	report := domain.HealthReport{Status: domain.HealthStatusUp, Draining: service.Draining()}
+	report.Components = append(report.Components, service.checkDatabase(ctx))
+	report.Components = append(report.Components, service.checkSchema(ctx))
+
+	if report.Draining {
+		report.Status = domain.HealthStatusDown
+	}
+	for _, component := range report.Components {
+		if component.Status != domain.HealthStatusUp {
+			report.Status = domain.HealthStatusDown
+		}
+	}
+	return report
+}
+
+// Drain makes the instance report itself as not ready so that load balancers
+// stop routing new requests to it before it shuts down.
+func (service *HealthService) Drain() {
+	service.draining.Store(true)
+}
+
+func (service *HealthService) Draining() bool {
+	return service.draining.Load()
+}
+
+func (service *HealthService) checkDatabase(ctx context.Context) domain.ComponentHealth {
+	start := time.Now()
+	err := service.healthRepository.Ping(ctx)
+	component := domain.ComponentHealth{
+		Name:    "database",
+		Status:  domain.HealthStatusUp,
+		Details: map[string]interface{}{"latency_ms": time.Since(start).Milliseconds()},
+	}
+	if err != nil {
+		service.logger.ErrorContext(ctx, "database health check failed", "error", err)
+		component.Status = domain.HealthStatusDown
+		component.Error = "database unreachable"
+	}
+	return component
+}
+
+func (service *HealthService) checkSchema(ctx context.Context) domain.ComponentHealth {
+	component := domain.ComponentHealth{Name: "schema", Status: domain.HealthStatusUp}
+
+	missingTables, err := service.healthRepository.GetMissingTables(ctx, requiredTables)
+	if err != nil {
+		return service.schemaCheckFailed(ctx, component, err)
+	}
+	missingColumns, err := service.healthRepository.GetMissingColumns(ctx, requiredColumns)
+	if err != nil {
+		return service.schemaCheckFailed(ctx, component, err)
+	}
+
+	if len(missingTables) > 0 || len(missingColumns) > 0 {
+		component.Status = domain.HealthStatusDown
+		component.Error = "pending migrations"
+		component.Details = map[string]interface{}{}
+		if len(missingTables) > 0 {
+			component.Details["missing_tables"] = missingTables
+		}
+		if len(missingColumns) > 0 {
+			component.Details["missing_columns"] = missingColumns
+		}
+	}
+	return component
+}
+
+func (service *HealthService) schemaCheckFailed(ctx context.Context, component domain.ComponentHealth, err error) domain.ComponentHealth {
+	service.logger.ErrorContext(ctx, "schema health check failed", "error", err)
+	component.Status = domain.HealthStatusDown
+	component.Error = "schema check failed"
+	return component
+}
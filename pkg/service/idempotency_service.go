@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"log/slog"
+	"time"
+)
+
+const maxIdempotencyKeyLength = 255
+
+var (
+	ErrInvalidIdempotencyKey    = errors.New("idempotency key must be between 1 and 255 characters")
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+type IIdempotencyService interface {
+	Begin(subject string, key string, requestHash string) (domain.IdempotencyRecord, bool, error)
+	Complete(subject string, key string, statusCode int, contentType string, responseBody []byte) error
+	Release(subject string, key string) error
+	PurgeExpiredPeriodically(ctx context.Context, interval time.Duration)
+}
+
+type IdempotencyService struct {
+	idempotencyRepository repository.IIdempotencyRepository
+	ttl                   time.Duration
+	logger                *slog.Logger
+}
+
+func NewIdempotencyService(idempotencyRepository repository.IIdempotencyRepository, ttl time.Duration, logger *slog.Logger) IIdempotencyService {
+	return &IdempotencyService{idempotencyRepository, ttl, logger}
+}
+
+// Begin claims the key of subject for the request identified by requestHash.
+// If the key was already used by subject for the same request and a response
+// is stored, the stored record is returned with replay set to true. Keys are
+// scoped to the subject, so callers cannot replay each other's responses.
+func (service *IdempotencyService) Begin(subject string, key string, requestHash string) (domain.IdempotencyRecord, bool, error) {
+	if len(key) == 0 || len(key) > maxIdempotencyKeyLength {
+		return domain.IdempotencyRecord{}, false, ErrInvalidIdempotencyKey
+	}
+	key = scopeIdempotencyKey(subject, key)
+
+	reserved, err := service.idempotencyRepository.ReserveKey(key, requestHash, time.Now().Add(service.ttl))
+	if err != nil {
+		return domain.IdempotencyRecord{}, false, err
+	}
+	if reserved {
+		return domain.IdempotencyRecord{}, false, nil
+	}
+
+	record, err := service.idempotencyRepository.GetByKey(key)
+	if err != nil {
+		return domain.IdempotencyRecord{}, false, err
+	}
+
+	if record.RequestHash != requestHash {
+		return domain.IdempotencyRecord{}, false, ErrIdempotencyKeyReused
+	}
+	if !record.Completed() {
+		return domain.IdempotencyRecord{}, false, ErrIdempotencyKeyInProgress
+	}
+
+	return record, true, nil
+}
+
+func (service *IdempotencyService) Complete(subject string, key string, statusCode int, contentType string, responseBody []byte) error {
+	return service.idempotencyRepository.SaveResponse(scopeIdempotencyKey(subject, key), statusCode, contentType, responseBody)
+}
+
+// Release drops a reserved key so that the client can retry the request, e.g.
+// after a server error that should not be replayed.
+func (service *IdempotencyService) Release(subject string, key string) error {
+	return service.idempotencyRepository.DeleteKey(scopeIdempotencyKey(subject, key))
+}
+
+func (service *IdempotencyService) PurgeExpiredPeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := service.idempotencyRepository.DeleteExpiredKeys()
+			if err != nil {
+				service.logger.ErrorContext(ctx, "error while purging expired idempotency keys", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				service.logger.InfoContext(ctx, "Purged expired idempotency keys", "count", deleted)
+			}
+		}
+	}
+}
+
+// scopeIdempotencyKey derives the stored key from the subject and the key the
+// client sent. Hashing keeps it within the key column however long both are.
+func scopeIdempotencyKey(subject string, key string) string {
+	hash := sha256.Sum256([]byte(subject + "\x00" + key))
+	return hex.EncodeToString(hash[:])
+}
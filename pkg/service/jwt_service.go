@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/common/auth"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/golang-jwt/jwt/v5"
+	"strings"
+	"time"
+)
+
+const jwtLeeway = 30 * time.Second
+
+var ErrInvalidBearerToken = errors.New("invalid bearer token")
+
+type IJwtService interface {
+	Authenticate(token string) (domain.Principal, error)
+}
+
+type JwtService struct {
+	keySet *auth.JsonWebKeySet
+	config auth.JwtConfig
+	parser *jwt.Parser
+}
+
+func NewJwtService(keySet *auth.JsonWebKeySet, config auth.JwtConfig) IJwtService {
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "ES256", "HS256"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(jwtLeeway),
+	}
+	if config.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(config.Audience))
+	}
+
+	return &JwtService{keySet, config, jwt.NewParser(parserOptions...)}
+}
+
+// Authenticate verifies the token signature against the configured key set,
+// checks exp, nbf, iss and aud, and maps the role claim to scopes.
+func (service *JwtService) Authenticate(token string) (domain.Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := service.parser.ParseWithClaims(token, claims, service.lookupKey)
+	if err != nil {
+		return domain.Principal{}, fmt.Errorf("%w: %v", ErrInvalidBearerToken, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return domain.Principal{}, fmt.Errorf("%w: missing sub claim", ErrInvalidBearerToken)
+	}
+
+	roles := rolesFromClaim(claims[service.config.RoleClaim])
+	return domain.Principal{Subject: subject, Roles: roles, Scopes: service.scopesForRoles(roles)}, nil
+}
+
+func (service *JwtService) lookupKey(token *jwt.Token) (interface{}, error) {
+	keyId, _ := token.Header["kid"].(string)
+	key, found := service.keySet.Lookup(keyId)
+	if !found {
+		return nil, fmt.Errorf("unknown key id %q", keyId)
+	}
+	if key.Algorithm != "" && key.Algorithm != token.Method.Alg() {
+		return nil, fmt.Errorf("key %q is not valid for %s", keyId, token.Method.Alg())
+	}
+	return key.Key, nil
+}
+
+func (service *JwtService) scopesForRoles(roles []string) []string {
+	var scopes []string
+	seen := map[string]bool{}
+	for _, role := range roles {
+		for _, scope := range service.config.RoleScopes[role] {
+			if !seen[scope] {
+				seen[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// rolesFromClaim accepts either a JSON array of strings or a space separated
+// string, the two forms commonly used by identity providers.
+func rolesFromClaim(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		var roles []string
+		for _, role := range value {
+			if roleString, ok := role.(string); ok {
+				roles = append(roles, roleString)
+			}
+		}
+		return roles
+	}
+	return nil
+}
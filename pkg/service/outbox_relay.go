@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/erkindilekci/product-api/pkg/common/events"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"log/slog"
+	"time"
+)
+
+type IOutboxRelay interface {
+	RelayDue(ctx context.Context) int
+	RelayPeriodically(ctx context.Context, interval time.Duration)
+	PurgePublishedPeriodically(ctx context.Context, interval time.Duration)
+}
+
+// OutboxRelay publishes the events product changes left in the outbox. An
+// event is marked as published only after the publisher accepted it, so it is
+// delivered at least once, and a product's events are published in the order
+// they were written.
+type OutboxRelay struct {
+	outboxRepository repository.IOutboxRepository
+	publisher        events.EventPublisher
+	config           events.Config
+	logger           *slog.Logger
+}
+
+func NewOutboxRelay(outboxRepository repository.IOutboxRepository, publisher events.EventPublisher, config events.Config, logger *slog.Logger) IOutboxRelay {
+	return &OutboxRelay{outboxRepository, publisher, config, logger}
+}
+
+// productEventPayload is the document published for a product event. Product
+// holds the product after the change and is null for deletions; Previous
+// holds it before the change and is omitted for creations.
+type productEventPayload struct {
+	Id         string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	ProductId  int64           `json:"product_id"`
+	Product    json.RawMessage `json:"product"`
+	Previous   json.RawMessage `json:"previous,omitempty"`
+}
+
+// RelayDue makes one attempt at publishing the next due event of every
+// product and returns how many were attempted.
+func (relay *OutboxRelay) RelayDue(ctx context.Context) int {
+	now := time.Now()
+	leaseUntil := now.Add(time.Duration(relay.config.BatchSize) * relay.config.Timeout)
+	outboxEvents, err := relay.outboxRepository.ClaimNextOutboxEvents(ctx, now, leaseUntil, relay.config.BatchSize)
+	if err != nil {
+		relay.logger.ErrorContext(ctx, "failed to claim outbox events", "error", err)
+		return 0
+	}
+
+	for i, outboxEvent := range outboxEvents {
+		// Publishing after the lease ended could overtake a relay that
+		// claimed the product's events in the meantime.
+		if time.Now().Add(relay.config.Timeout).After(leaseUntil) {
+			return i
+		}
+		relay.publish(ctx, outboxEvent)
+	}
+	return len(outboxEvents)
+}
+
+// RelayPeriodically polls the outbox until ctx is cancelled. Each poll keeps
+// relaying as long as there are due events, since every round publishes only
+// one event per product.
+func (relay *OutboxRelay) RelayPeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for ctx.Err() == nil && relay.RelayDue(ctx) > 0 {
+			}
+		}
+	}
+}
+
+func (relay *OutboxRelay) PurgePublishedPeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := relay.outboxRepository.DeletePublishedOutboxEvents(ctx, time.Now().Add(-relay.config.Retention))
+			if err != nil {
+				relay.logger.ErrorContext(ctx, "error while purging published outbox events", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				relay.logger.InfoContext(ctx, "Purged published outbox events", "count", deleted)
+			}
+		}
+	}
+}
+
+// publish hands outboxEvent to the publisher once and records the outcome:
+// published, or due again after an exponential backoff. Events are retried
+// until they are published; they are never given up, as that would break the
+// order of the product's later events.
+func (relay *OutboxRelay) publish(ctx context.Context, outboxEvent domain.OutboxEvent) {
+	publishCtx, cancel := context.WithTimeout(ctx, relay.config.Timeout)
+	err := relay.publisher.Publish(publishCtx, outboxEvent)
+	cancel()
+
+	now := time.Now()
+	outboxEvent.Attempts++
+	if err == nil {
+		outboxEvent.PublishedAt = &now
+		outboxEvent.LastError = ""
+	} else {
+		outboxEvent.LastError = err.Error()
+		outboxEvent.NextAttemptAt = now.Add(relay.config.Backoff(outboxEvent.Attempts))
+		relay.logger.WarnContext(ctx, "failed to publish outbox event", "event_id", outboxEvent.EventId, "product_id", outboxEvent.ProductId, "attempts", outboxEvent.Attempts, "error", err)
+	}
+
+	if err := relay.outboxRepository.UpdateOutboxEvent(ctx, outboxEvent); err != nil {
+		relay.logger.ErrorContext(ctx, "failed to record outbox publishing attempt", "event_id", outboxEvent.EventId, "error", err)
+	}
+}
+
+// addOutboxEvent writes the event of a product change to the outbox. before
+// and after are the snapshots of the product around the change.
+func addOutboxEvent(ctx context.Context, outboxRepository repository.IOutboxRepository, action string, productId int64, before json.RawMessage, after json.RawMessage) error {
+	eventId, err := generateEventId()
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(productEventPayload{
+		Id:         eventId,
+		Type:       action,
+		OccurredAt: time.Now(),
+		ProductId:  productId,
+		Product:    nullJsonIfEmpty(after),
+		Previous:   before,
+	})
+	if err != nil {
+		return err
+	}
+
+	return outboxRepository.AddOutboxEvent(ctx, domain.OutboxEvent{
+		EventId:   eventId,
+		EventType: action,
+		ProductId: productId,
+		Payload:   payload,
+	})
+}
+
+func generateEventId() (string, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", err
+	}
+	return "evt_" + hex.EncodeToString(idBytes), nil
+}
+
+func nullJsonIfEmpty(value json.RawMessage) json.RawMessage {
+	if len(value) == 0 {
+		return json.RawMessage("null")
+	}
+	return value
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+)
+
+const (
+	// DefaultProductChangeLimit is the page size of clients that do not ask
+	// for one.
+	DefaultProductChangeLimit = 100
+	maxProductChangeLimit     = 1000
+)
+
+type IProductChangeService interface {
+	GetChanges(ctx context.Context, since int64, limit int) (domain.ProductChangePage, error)
+}
+
+type ProductChangeService struct {
+	productChangeRepository repository.IProductChangeRepository
+	roleBindingService      IRoleBindingService
+}
+
+func NewProductChangeService(productChangeRepository repository.IProductChangeRepository, roleBindingService IRoleBindingService) IProductChangeService {
+	return &ProductChangeService{productChangeRepository, roleBindingService}
+}
+
+// GetChanges returns the changes after the sequence since, 0 for all
+// products, of the stores the principal may read. Applying the pages in
+// order, until HasMore is false, brings a copy of the catalog taken at since
+// up to date.
+func (service *ProductChangeService) GetChanges(ctx context.Context, since int64, limit int) (domain.ProductChangePage, error) {
+	validationError := &domain.ValidationError{}
+	if since < 0 {
+		validationError.Add("since", "since must not be negative")
+	}
+	if limit < 1 || limit > maxProductChangeLimit {
+		validationError.Add("limit", fmt.Sprintf("limit must be between 1 and %d", maxProductChangeLimit))
+	}
+	if err := validationError.OrNil(); err != nil {
+		return domain.ProductChangePage{}, err
+	}
+
+	stores, allStores, err := service.roleBindingService.AccessibleStores(ctx)
+	if err != nil {
+		return domain.ProductChangePage{}, err
+	}
+	if allStores {
+		stores = nil
+	}
+
+	changes, err := service.productChangeRepository.GetProductChanges(ctx, since, limit+1, stores)
+	if err != nil {
+		return domain.ProductChangePage{}, err
+	}
+
+	page := domain.ProductChangePage{Changes: changes, NextSequence: since}
+	if len(changes) > limit {
+		page.Changes = changes[:limit]
+		page.HasMore = true
+	}
+	if len(page.Changes) > 0 {
+		page.NextSequence = page.Changes[len(page.Changes)-1].Sequence
+	}
+	return page, nil
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"context"
+	"github.com/erkindilekci/product-api/pkg/common/metrics"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"log/slog"
+)
+
+// RegisterProductMetrics exposes the number of products per store, counted on
+// every scrape.
+func RegisterProductMetrics(registry *metrics.Registry, productRepository repository.IProductRepository, logger *slog.Logger) {
+	productCount := registry.Gauge("products", "Number of products per store.", "store")
+
+	registry.OnCollect(func() {
+		counts, err := productRepository.CountProductsByStore(context.Background(), domain.ProductFilter{})
+		if err != nil {
+			logger.Error("error while counting products by store", "error", err)
+			return
+		}
+
+		productCount.Reset()
+		for store, count := range counts {
+			productCount.Set(float64(count), store)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type IProductNotificationService interface {
+	Subscribe(handler func(domain.ProductNotification)) (unsubscribe func())
+	Run(ctx context.Context, reconnectDelay time.Duration)
+}
+
+// ProductNotificationService listens for the notifications the database sends
+// when a product changes, whichever instance changed it, and hands them to
+// the in-process subscribers.
+type ProductNotificationService struct {
+	notificationRepository repository.IProductNotificationRepository
+	pingInterval           time.Duration
+	logger                 *slog.Logger
+
+	mutex            sync.Mutex
+	nextSubscriberId int
+	subscribers      map[int]func(domain.ProductNotification)
+}
+
+// NewProductNotificationService pings the listening connection when no
+// notification arrived for pingInterval. A connection whose peer is gone
+// without closing it would otherwise wait silently; one that does not answer
+// within pingInterval is replaced.
+func NewProductNotificationService(notificationRepository repository.IProductNotificationRepository, pingInterval time.Duration, logger *slog.Logger) IProductNotificationService {
+	return &ProductNotificationService{
+		notificationRepository: notificationRepository,
+		pingInterval:           pingInterval,
+		logger:                 logger,
+		subscribers:            map[int]func(domain.ProductNotification){},
+	}
+}
+
+// Subscribe calls handler with every notification until unsubscribe is
+// called. Handlers are called one at a time from the listening goroutine and
+// must not block.
+func (service *ProductNotificationService) Subscribe(handler func(domain.ProductNotification)) func() {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	subscriberId := service.nextSubscriberId
+	service.nextSubscriberId++
+	service.subscribers[subscriberId] = handler
+
+	return func() {
+		service.mutex.Lock()
+		defer service.mutex.Unlock()
+		delete(service.subscribers, subscriberId)
+	}
+}
+
+// Run listens until ctx is cancelled. When the connection fails it reconnects
+// after reconnectDelay and then sends the subscribers a
+// domain.ProductNotificationResync, because the changes made in between were
+// not notified.
+func (service *ProductNotificationService) Run(ctx context.Context, reconnectDelay time.Duration) {
+	listened := false
+	for {
+		err := service.listen(ctx, &listened)
+		if ctx.Err() != nil {
+			return
+		}
+		service.logger.WarnContext(ctx, "Product notification listener disconnected, reconnecting", "reconnect_delay", reconnectDelay.String(), "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// listen serves one connection and returns why it ended. listened tells
+// whether a connection was listening before, in which case notifications may
+// have been missed.
+func (service *ProductNotificationService) listen(ctx context.Context, listened *bool) error {
+	connection, err := service.notificationRepository.Listen(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = connection.Close(context.WithoutCancel(ctx)) }()
+
+	if *listened {
+		service.notify(domain.ProductNotification{Operation: domain.ProductNotificationResync})
+	}
+	*listened = true
+
+	for {
+		waitCtx, cancel := context.WithTimeout(ctx, service.pingInterval)
+		notification, err := connection.WaitForNotification(waitCtx)
+		cancel()
+		if err == nil {
+			service.notify(notification)
+			continue
+		}
+		if ctx.Err() != nil || !errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+			return err
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, service.pingInterval)
+		err = connection.Ping(pingCtx)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (service *ProductNotificationService) notify(notification domain.ProductNotification) {
+	service.mutex.Lock()
+	handlers := make([]func(domain.ProductNotification), 0, len(service.subscribers))
+	for _, handler := range service.subscribers {
+		handlers = append(handlers, handler)
+	}
+	service.mutex.Unlock()
+
+	for _, handler := range handlers {
+		handler(notification)
+	}
+}
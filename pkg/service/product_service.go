@@ -1,94 +1,454 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/erkindilekci/product-api/pkg/domain"
 	"github.com/erkindilekci/product-api/pkg/repository"
 	"github.com/erkindilekci/product-api/pkg/service/dto"
+	"log/slog"
+	"slices"
+	"unicode/utf8"
 )
 
 type IProductService interface {
-	Add(productCreate dto.ProductCreate) error
-	GetAllProducts() []domain.Product
-	GetProductsByStore(store string) []domain.Product
-	GetById(productId int64) (domain.Product, error)
-	DeleteById(productId int64) error
-	UpdatePrice(productId int64, newPrice float32) error
+	Add(ctx context.Context, productCreate dto.ProductCreate) error
+	UpsertBySku(ctx context.Context, productCreate dto.ProductCreate) (domain.Product, bool, error)
+	GetAllProducts(ctx context.Context) ([]domain.Product, error)
+	GetProductsByStore(ctx context.Context, store string) ([]domain.Product, error)
+	GetProductPage(ctx context.Context, filter domain.ProductFilter, afterId int64, limit int) (domain.ProductPage, error)
+	GetProductPagesByStore(ctx context.Context, filter domain.ProductFilter, afterId int64, limit int) (map[string]domain.ProductPage, error)
+	CountProductsByStore(ctx context.Context) (map[string]int64, error)
+	GetById(ctx context.Context, productId int64) (domain.Product, error)
+	GetByExternalId(ctx context.Context, externalId domain.ExternalId) (domain.Product, error)
+	DeleteById(ctx context.Context, productId int64) error
+	UpdatePrice(ctx context.Context, productId int64, newPrice float32) error
+	UpdatePriceByExternalId(ctx context.Context, externalId domain.ExternalId, newPrice float32) error
+	WithTx(ctx context.Context, fn func(service IProductService) error) error
 }
 
 type ProductService struct {
-	productRepository repository.IProductRepository
+	productRepository  repository.IProductRepository
+	roleBindingService IRoleBindingService
+	logger             *slog.Logger
 }
 
-func NewProductService(productRepository repository.IProductRepository) IProductService {
-	return &ProductService{productRepository}
+func NewProductService(productRepository repository.IProductRepository, roleBindingService IRoleBindingService, logger *slog.Logger) IProductService {
+	return &ProductService{productRepository, roleBindingService, logger}
 }
 
-func (service *ProductService) Add(productCreate dto.ProductCreate) error {
+func (service *ProductService) Add(ctx context.Context, productCreate dto.ProductCreate) error {
 	err := validateProductCreate(productCreate)
 	if err != nil {
 		return err
 	}
+	err = service.authorize(ctx, productCreate.Store, domain.ScopeProductsWrite)
+	if err != nil {
+		return err
+	}
 
 	product := productCreateToProduct(productCreate)
-	return service.productRepository.AddProduct(product)
+	return service.productRepository.WithTx(ctx, func(repository repository.IProductRepository) error {
+		productId, err := repository.AddProduct(ctx, product)
+		if err != nil {
+			return err
+		}
+
+		if len(productCreate.ExternalIds) > 0 {
+			err = repository.AddExternalIds(ctx, productId, productCreate.ExternalIds)
+			if err != nil {
+				return err
+			}
+		}
+
+		product.Id = productId
+		return recordProductChange(ctx, repository, domain.AuditActionProductCreated, productId, nil, &product)
+	})
 }
 
-func (service *ProductService) GetAllProducts() []domain.Product {
-	return service.productRepository.GetAllProducts()
+// UpsertBySku creates or overwrites the product with the sku in the store.
+// The external ids of an existing product are kept; those in productCreate
+// that it does not have yet are added.
+func (service *ProductService) UpsertBySku(ctx context.Context, productCreate dto.ProductCreate) (domain.Product, bool, error) {
+	err := validateProductCreate(productCreate)
+	if err != nil {
+		return domain.Product{}, false, err
+	}
+	if productCreate.Sku == "" {
+		validationError := &domain.ValidationError{}
+		validationError.Add("/sku", "sku can't be empty")
+		return domain.Product{}, false, validationError
+	}
+	err = service.authorize(ctx, productCreate.Store, domain.ScopeProductsWrite)
+	if err != nil {
+		return domain.Product{}, false, err
+	}
+
+	var upsertedProduct domain.Product
+	var created bool
+	err = service.productRepository.WithTx(ctx, func(repository repository.IProductRepository) error {
+		var before *domain.Product
+		newExternalIds := productCreate.ExternalIds
+		existingProduct, err := repository.GetProductBySkuForUpdate(ctx, productCreate.Store, productCreate.Sku)
+		if err == nil {
+			before = &existingProduct
+			newExternalIds = missingExternalIds(existingProduct.ExternalIds, productCreate.ExternalIds)
+		} else if !errors.Is(err, domain.ErrProductNotFound) {
+			return err
+		}
+
+		upsertedProduct, created, err = repository.UpsertProductBySku(ctx, productCreateToProduct(productCreate))
+		if err != nil {
+			return err
+		}
+
+		if len(newExternalIds) > 0 {
+			err = repository.AddExternalIds(ctx, upsertedProduct.Id, newExternalIds)
+			if err != nil {
+				return err
+			}
+		}
+		upsertedProduct, err = repository.GetProductById(ctx, upsertedProduct.Id)
+		if err != nil {
+			return err
+		}
+
+		action := domain.AuditActionProductUpserted
+		if created {
+			action = domain.AuditActionProductCreated
+		}
+		return recordProductChange(ctx, repository, action, upsertedProduct.Id, before, &upsertedProduct)
+	})
+	if err != nil {
+		return domain.Product{}, false, err
+	}
+
+	return upsertedProduct, created, nil
+}
+
+// GetAllProducts returns every product, or only those of the principal's
+// stores if it is restricted to specific stores.
+func (service *ProductService) GetAllProducts(ctx context.Context) ([]domain.Product, error) {
+	stores, allStores, err := service.roleBindingService.AccessibleStores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if allStores {
+		return service.productRepository.GetAllProducts(ctx), nil
+	}
+
+	products := []domain.Product{}
+	for _, store := range stores {
+		products = append(products, service.productRepository.GetProductsByStore(ctx, store)...)
+	}
+	return products, nil
+}
+
+func (service *ProductService) GetProductsByStore(ctx context.Context, store string) ([]domain.Product, error) {
+	stores, allStores, err := service.roleBindingService.AccessibleStores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !allStores && !containsString(stores, store) {
+		return []domain.Product{}, nil
+	}
+
+	return service.productRepository.GetProductsByStore(ctx, store), nil
 }
 
-func (service *ProductService) GetProductsByStore(store string) []domain.Product {
-	return service.productRepository.GetProductsByStore(store)
+// GetProductPage returns up to limit products matching filter with an id
+// above afterId, out of those of the principal's stores.
+func (service *ProductService) GetProductPage(ctx context.Context, filter domain.ProductFilter, afterId int64, limit int) (domain.ProductPage, error) {
+	filter, err := service.restrictToAccessibleStores(ctx, filter)
+	if err != nil {
+		return domain.ProductPage{}, err
+	}
+
+	counts, err := service.productRepository.CountProductsByStore(ctx, filter)
+	if err != nil {
+		return domain.ProductPage{}, err
+	}
+	products, err := service.productRepository.GetProductPage(ctx, filter, afterId, limit+1)
+	if err != nil {
+		return domain.ProductPage{}, err
+	}
+
+	page := newProductPage(products, limit)
+	for _, count := range counts {
+		page.TotalCount += count
+	}
+	return page, nil
 }
 
-func (service *ProductService) GetById(productId int64) (domain.Product, error) {
-	return service.productRepository.GetProductById(productId)
+// GetProductPagesByStore returns a page as GetProductPage would for each of
+// the principal's stores in filter.Stores, loading all of them together.
+// Stores without matching products are left out of the result.
+func (service *ProductService) GetProductPagesByStore(ctx context.Context, filter domain.ProductFilter, afterId int64, limit int) (map[string]domain.ProductPage, error) {
+	filter, err := service.restrictToAccessibleStores(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	counts, err := service.productRepository.CountProductsByStore(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	productsByStore, err := service.productRepository.GetProductPagesByStore(ctx, filter, afterId, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := map[string]domain.ProductPage{}
+	for store, count := range counts {
+		page := newProductPage(productsByStore[store], limit)
+		page.TotalCount = count
+		pages[store] = page
+	}
+	return pages, nil
 }
 
-func (service *ProductService) DeleteById(productId int64) error {
-	_, err := service.GetById(productId)
+// CountProductsByStore counts the products of each of the principal's stores.
+func (service *ProductService) CountProductsByStore(ctx context.Context) (map[string]int64, error) {
+	filter, err := service.restrictToAccessibleStores(ctx, domain.ProductFilter{})
 	if err != nil {
+		return nil, err
+	}
+	return service.productRepository.CountProductsByStore(ctx, filter)
+}
+
+// restrictToAccessibleStores narrows the stores of filter to those the
+// principal may read.
+func (service *ProductService) restrictToAccessibleStores(ctx context.Context, filter domain.ProductFilter) (domain.ProductFilter, error) {
+	stores, allStores, err := service.roleBindingService.AccessibleStores(ctx)
+	if err != nil || allStores {
+		return filter, err
+	}
+	if filter.Stores == nil {
+		filter.Stores = stores
+		return filter, nil
+	}
+
+	accessibleStores := []string{}
+	for _, store := range filter.Stores {
+		if containsString(stores, store) {
+			accessibleStores = append(accessibleStores, store)
+		}
+	}
+	filter.Stores = accessibleStores
+	return filter, nil
+}
+
+// newProductPage takes the page from products, which were loaded with one
+// product beyond limit to tell whether there is a next page.
+func newProductPage(products []domain.Product, limit int) domain.ProductPage {
+	page := domain.ProductPage{Products: products}
+	if len(products) > limit {
+		page.Products = products[:limit]
+		page.HasNextPage = true
+	}
+	return page
+}
+
+func (service *ProductService) GetById(ctx context.Context, productId int64) (domain.Product, error) {
+	product, err := service.productRepository.GetProductById(ctx, productId)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	err = service.authorize(ctx, product.Store, domain.ScopeProductsRead)
+	if err != nil {
+		return domain.Product{}, err
+	}
+	return product, nil
+}
+
+func (service *ProductService) GetByExternalId(ctx context.Context, externalId domain.ExternalId) (domain.Product, error) {
+	product, err := service.productRepository.GetProductByExternalId(ctx, externalId)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	err = service.authorize(ctx, product.Store, domain.ScopeProductsRead)
+	if err != nil {
+		return domain.Product{}, err
+	}
+	return product, nil
+}
+
+func (service *ProductService) DeleteById(ctx context.Context, productId int64) error {
+	return service.productRepository.WithTx(ctx, func(repository repository.IProductRepository) error {
+		product, err := repository.GetProductByIdForUpdate(ctx, productId)
+		if err != nil {
+			return err
+		}
+
+		err = service.authorize(ctx, product.Store, domain.ScopeProductsDelete)
+		if err != nil {
+			return err
+		}
+
+		err = repository.DeleteProductById(ctx, productId)
+		if err != nil {
+			return err
+		}
+
+		return recordProductChange(ctx, repository, domain.AuditActionProductDeleted, productId, &product, nil)
+	})
+}
+
+func (service *ProductService) UpdatePrice(ctx context.Context, productId int64, newPrice float32) error {
+	if err := validateNewPrice(newPrice); err != nil {
 		return err
 	}
 
-	return service.productRepository.DeleteProductById(productId)
+	return service.productRepository.WithTx(ctx, func(repository repository.IProductRepository) error {
+		return service.updatePrice(ctx, repository, productId, newPrice)
+	})
 }
 
-func (service *ProductService) UpdatePrice(productId int64, newPrice float32) error {
-	_, err := service.GetById(productId)
+func (service *ProductService) UpdatePriceByExternalId(ctx context.Context, externalId domain.ExternalId, newPrice float32) error {
+	if err := validateNewPrice(newPrice); err != nil {
+		return err
+	}
+
+	return service.productRepository.WithTx(ctx, func(repository repository.IProductRepository) error {
+		product, err := repository.GetProductByExternalId(ctx, externalId)
+		if err != nil {
+			return err
+		}
+
+		return service.updatePrice(ctx, repository, product.Id, newPrice)
+	})
+}
+
+// updatePrice locks the product, checks that the caller may modify its store
+// and records the change. It must run inside a transaction.
+func (service *ProductService) updatePrice(ctx context.Context, repository repository.IProductRepository, productId int64, newPrice float32) error {
+	product, err := repository.GetProductByIdForUpdate(ctx, productId)
 	if err != nil {
 		return err
 	}
 
-	if newPrice < 0 {
-		return errors.New("price can't be less than zero")
+	err = service.authorize(ctx, product.Store, domain.ScopeProductsWrite)
+	if err != nil {
+		return err
+	}
+
+	err = repository.UpdatePriceById(ctx, productId, newPrice)
+	if err != nil {
+		return err
+	}
+
+	updatedProduct := product
+	updatedProduct.Price = newPrice
+	return recordProductChange(ctx, repository, domain.AuditActionProductPriceChanged, productId, &product, &updatedProduct)
+}
+
+// WithTx runs fn against a service whose repository calls all share one
+// transaction, so several operations either all succeed or none are applied.
+func (service *ProductService) WithTx(ctx context.Context, fn func(service IProductService) error) error {
+	return service.productRepository.WithTx(ctx, func(repository repository.IProductRepository) error {
+		return fn(NewProductService(repository, service.roleBindingService, service.logger))
+	})
+}
+
+// authorize checks that the caller may access products of store and logs
+// denied attempts.
+func (service *ProductService) authorize(ctx context.Context, store string, permission string) error {
+	err := service.roleBindingService.Authorize(ctx, store, permission)
+	if errors.Is(err, domain.ErrForbidden) {
+		service.logger.WarnContext(ctx, "product access denied", "actor", actorFromContext(ctx), "store", store, "permission", permission)
+	}
+	return err
+}
+
+// actorFromContext names the authenticated principal of the request for
+// audit purposes.
+func actorFromContext(ctx context.Context) string {
+	principal, ok := domain.PrincipalFromContext(ctx)
+	if !ok {
+		return "anonymous"
 	}
+	return principal.Subject
+}
 
-	return service.productRepository.UpdatePriceById(productId, newPrice)
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
 }
 
+// validateProductCreate reports every invalid field instead of stopping at
+// the first one.
 func validateProductCreate(productCreate dto.ProductCreate) error {
+	validationError := &domain.ValidationError{}
 	if productCreate.Name == "" {
-		return errors.New("name can't be empty")
+		validationError.Add("/name", "name can't be empty")
 	}
+	checkMaxLength(validationError, "/name", "name", productCreate.Name)
 	if productCreate.Price < 0 {
-		return errors.New("price can't be less than zero")
+		validationError.Add("/price", "price can't be less than zero")
 	}
 	if productCreate.Discount < 0 {
-		return errors.New("discount can't be less than zero")
+		validationError.Add("/discount", "discount can't be less than zero")
 	}
 	if productCreate.Store == "" {
-		return errors.New("store can't be empty")
+		validationError.Add("/store", "store can't be empty")
+	}
+	checkMaxLength(validationError, "/store", "store", productCreate.Store)
+	checkMaxLength(validationError, "/sku", "sku", productCreate.Sku)
+	for i, externalId := range productCreate.ExternalIds {
+		if externalId.System == "" {
+			validationError.Add(fmt.Sprintf("/external_ids/%d/system", i), "external id system can't be empty")
+		}
+		if externalId.Id == "" {
+			validationError.Add(fmt.Sprintf("/external_ids/%d/id", i), "external id can't be empty")
+		}
+		checkMaxLength(validationError, fmt.Sprintf("/external_ids/%d/system", i), "external id system", externalId.System)
+		checkMaxLength(validationError, fmt.Sprintf("/external_ids/%d/id", i), "external id", externalId.Id)
+	}
+	return validationError.OrNil()
+}
+
+func validateNewPrice(newPrice float32) error {
+	validationError := &domain.ValidationError{}
+	if newPrice < 0 {
+		validationError.Add("newPrice", "price can't be less than zero")
+	}
+	return validationError.OrNil()
+}
+
+// checkMaxLength reports value if it does not fit into a VARCHAR(255)
+// column.
+func checkMaxLength(validationError *domain.ValidationError, pointer string, name string, value string) {
+	if utf8.RuneCountInString(value) > domain.MaxTextLength {
+		validationError.Add(pointer, fmt.Sprintf("%s can't be longer than %d characters", name, domain.MaxTextLength))
+	}
+}
+
+// missingExternalIds returns the external ids out of externalIds that are not
+// among existingExternalIds.
+func missingExternalIds(existingExternalIds []domain.ExternalId, externalIds []domain.ExternalId) []domain.ExternalId {
+	var missing []domain.ExternalId
+	for _, externalId := range externalIds {
+		if !slices.Contains(existingExternalIds, externalId) && !slices.Contains(missing, externalId) {
+			missing = append(missing, externalId)
+		}
 	}
-	return nil
+	return missing
 }
 
 func productCreateToProduct(productCreate dto.ProductCreate) domain.Product {
 	return domain.Product{
-		Name:     productCreate.Name,
-		Price:    productCreate.Price,
-		Discount: productCreate.Discount,
-		Store:    productCreate.Store,
+		Name:        productCreate.Name,
+		Price:       productCreate.Price,
+		Discount:    productCreate.Discount,
+		Store:       productCreate.Store,
+		Sku:         productCreate.Sku,
+		ExternalIds: productCreate.ExternalIds,
 	}
 }
@@ -0,0 +1,417 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// productStreamBuffer is how many live events a subscriber may fall
+	// behind before it is disconnected.
+	productStreamBuffer = 256
+	productStreamPage   = 500
+)
+
+var (
+	ErrProductStreamClosed  = errors.New("the product stream is shutting down")
+	ErrProductStreamTooSlow = errors.New("the subscriber fell too far behind the product stream")
+	ErrProductStreamExpired = errors.New("the events after the last event id are no longer retained")
+)
+
+type IProductStreamService interface {
+	Subscribe(ctx context.Context, filter domain.ProductStreamFilter, lastEventId int64) (*ProductSubscription, error)
+	Poll(ctx context.Context) int
+	Wake()
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// ProductStreamService streams the product events in the outbox to in-process
+// subscribers. The outbox id is the event sequence: a subscriber that passes
+// the id of the last event it saw first receives the events after it that
+// are still in the outbox, then the live ones. Every instance polls the
+// shared outbox, so subscribers see the changes made through any instance.
+//
+// Ids are handed out when an event is written but become visible when its
+// transaction commits, so a missing id holds back the events after it until
+// every transaction that was running when the gap was seen has ended. The
+// event is then either visible or was rolled back. This relies on the
+// transaction writing an event having an id already, which it has since the
+// audit event is written first.
+type ProductStreamService struct {
+	outboxRepository   repository.IOutboxRepository
+	roleBindingService IRoleBindingService
+	logger             *slog.Logger
+	wake               chan struct{}
+
+	// pollMutex serialises polls and guards the gap fields; mutex guards
+	// the fields below it.
+	pollMutex   sync.Mutex
+	gapPending  bool
+	gapHorizon  int64
+	mutex       sync.Mutex
+	running     bool
+	lastEventId int64
+	subscribers map[*ProductSubscription]struct{}
+}
+
+func NewProductStreamService(outboxRepository repository.IOutboxRepository, roleBindingService IRoleBindingService, logger *slog.Logger) IProductStreamService {
+	return &ProductStreamService{
+		outboxRepository:   outboxRepository,
+		roleBindingService: roleBindingService,
+		logger:             logger,
+		wake:               make(chan struct{}, 1),
+		subscribers:        map[*ProductSubscription]struct{}{},
+	}
+}
+
+// ProductSubscription delivers the events of a subscriber in id order on
+// Events. The channel is closed when the subscription ends; Err then tells
+// why, and is nil if it was closed by the subscriber or its context.
+type ProductSubscription struct {
+	service     *ProductStreamService
+	filter      domain.ProductStreamFilter
+	lastEventId int64
+	replayUntil int64
+	live        chan domain.OutboxEvent
+	events      chan domain.OutboxEvent
+	// done is closed and err set, under the service mutex, when the
+	// subscription ends.
+	done chan struct{}
+	err  error
+}
+
+// Subscribe starts a subscription that ends with ctx. lastEventId is 0 for
+// live events only. The events are limited to the stores the principal may
+// read, and filtering on another store is forbidden. It fails with
+// ErrProductStreamExpired if events after lastEventId were already purged
+// from the outbox; the subscriber has to load the products again then.
+func (service *ProductStreamService) Subscribe(ctx context.Context, filter domain.ProductStreamFilter, lastEventId int64) (*ProductSubscription, error) {
+	stores, allStores, err := service.roleBindingService.AccessibleStores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !allStores {
+		if filter.Store != "" && !containsString(stores, filter.Store) {
+			return nil, domain.ErrForbidden
+		}
+		filter.Stores = stores
+	}
+
+	var firstEventId int64
+	if lastEventId != 0 {
+		firstEventId, err = service.outboxRepository.GetFirstOutboxEventId(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	if !service.running {
+		return nil, ErrProductStreamClosed
+	}
+	// Ids between lastEventId and the first retained one could also have
+	// been rolled back; the subscriber then loads the products needlessly.
+	if lastEventId != 0 && lastEventId < service.lastEventId && (firstEventId == 0 || firstEventId > lastEventId+1) {
+		return nil, ErrProductStreamExpired
+	}
+
+	subscription := &ProductSubscription{
+		service:     service,
+		filter:      filter,
+		lastEventId: lastEventId,
+		replayUntil: service.lastEventId,
+		live:        make(chan domain.OutboxEvent, productStreamBuffer),
+		events:      make(chan domain.OutboxEvent),
+		done:        make(chan struct{}),
+	}
+	if lastEventId == 0 {
+		subscription.lastEventId = service.lastEventId
+	}
+	service.subscribers[subscription] = struct{}{}
+	go subscription.run(ctx)
+	return subscription, nil
+}
+
+// Poll hands the events written since the last poll to the subscribers and
+// returns how many it handed out.
+func (service *ProductStreamService) Poll(ctx context.Context) int {
+	service.pollMutex.Lock()
+	defer service.pollMutex.Unlock()
+
+	polled := 0
+	for {
+		service.mutex.Lock()
+		running, lastEventId := service.running, service.lastEventId
+		service.mutex.Unlock()
+		if !running {
+			return polled
+		}
+
+		// The bounds are read before the events, so that the events of the
+		// transactions that ended by then are visible.
+		skipGap := false
+		if service.gapPending {
+			xmin, _, err := service.outboxRepository.GetTransactionBounds(ctx)
+			if err != nil {
+				service.logger.ErrorContext(ctx, "failed to poll the product stream", "error", err)
+				return polled
+			}
+			skipGap = xmin >= service.gapHorizon
+		}
+
+		outboxEvents, err := service.outboxRepository.GetOutboxEventsAfter(ctx, lastEventId, productStreamPage)
+		if err != nil {
+			service.logger.ErrorContext(ctx, "failed to poll the product stream", "error", err)
+			return polled
+		}
+		broadcast, blocked := service.broadcast(outboxEvents, skipGap)
+		polled += broadcast
+		if broadcast > 0 {
+			service.gapPending = false
+		}
+		if blocked {
+			if !service.gapPending {
+				_, xmax, err := service.outboxRepository.GetTransactionBounds(ctx)
+				if err != nil {
+					service.logger.ErrorContext(ctx, "failed to poll the product stream", "error", err)
+					return polled
+				}
+				service.gapPending, service.gapHorizon = true, xmax
+			}
+			return polled
+		}
+		if broadcast < productStreamPage {
+			return polled
+		}
+	}
+}
+
+// Wake makes Run poll now instead of at its next tick. It does not block.
+func (service *ProductStreamService) Wake() {
+	select {
+	case service.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run polls the outbox every interval, and whenever it is woken, until ctx is
+// cancelled and then ends every subscription with ErrProductStreamClosed, so
+// that the requests streaming them finish before the server shuts down.
+func (service *ProductStreamService) Run(ctx context.Context, interval time.Duration) {
+	if !service.start(ctx, interval) {
+		return
+	}
+	defer service.stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			service.Poll(ctx)
+		case <-service.wake:
+			service.Poll(ctx)
+		}
+	}
+}
+
+// start looks up where the outbox currently ends and waits until the
+// transactions running at that time have ended, so that no event before it
+// can still appear, checking every interval. It returns false if ctx is
+// cancelled first.
+func (service *ProductStreamService) start(ctx context.Context, interval time.Duration) bool {
+	var lastEventId, horizon int64
+	found := false
+	for {
+		var err error
+		if !found {
+			lastEventId, err = service.outboxRepository.GetLastOutboxEventId(ctx)
+			if err == nil {
+				_, horizon, err = service.outboxRepository.GetTransactionBounds(ctx)
+				found = err == nil
+			}
+		}
+		if found {
+			var xmin int64
+			xmin, _, err = service.outboxRepository.GetTransactionBounds(ctx)
+			if err == nil && xmin >= horizon {
+				service.mutex.Lock()
+				service.lastEventId = lastEventId
+				service.running = true
+				service.mutex.Unlock()
+				return true
+			}
+		}
+		if err != nil {
+			service.logger.ErrorContext(ctx, "failed to start the product stream", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (service *ProductStreamService) stop() {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	service.running = false
+	for subscription := range service.subscribers {
+		service.end(subscription, ErrProductStreamClosed)
+	}
+}
+
+// broadcast hands outboxEvents, which follow the last event handed out, to
+// the subscribers and returns how many it handed out and whether it stopped
+// at a gap in the ids. The gap right after the last event handed out is
+// skipped if skipGap is set.
+func (service *ProductStreamService) broadcast(outboxEvents []domain.OutboxEvent, skipGap bool) (int, bool) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+
+	for i, outboxEvent := range outboxEvents {
+		if outboxEvent.Id != service.lastEventId+1 && (i > 0 || !skipGap) {
+			return i, true
+		}
+		service.lastEventId = outboxEvent.Id
+
+		productId, store := streamedProduct(outboxEvent)
+		for subscription := range service.subscribers {
+			if !subscription.filter.Matches(productId, store) {
+				continue
+			}
+			select {
+			case subscription.live <- outboxEvent:
+			default:
+				service.end(subscription, ErrProductStreamTooSlow)
+			}
+		}
+	}
+	return len(outboxEvents), false
+}
+
+// end must be called with the mutex held.
+func (service *ProductStreamService) end(subscription *ProductSubscription, err error) {
+	if _, found := service.subscribers[subscription]; !found {
+		return
+	}
+	delete(service.subscribers, subscription)
+	subscription.err = err
+	close(subscription.done)
+}
+
+func (subscription *ProductSubscription) Events() <-chan domain.OutboxEvent {
+	return subscription.events
+}
+
+// Err tells why the subscription ended. It is only meaningful once Events is
+// closed.
+func (subscription *ProductSubscription) Err() error {
+	subscription.service.mutex.Lock()
+	defer subscription.service.mutex.Unlock()
+	return subscription.err
+}
+
+// Close ends the subscription.
+func (subscription *ProductSubscription) Close() {
+	subscription.service.mutex.Lock()
+	defer subscription.service.mutex.Unlock()
+	subscription.service.end(subscription, nil)
+}
+
+// run replays the events the subscriber missed, then forwards the live ones.
+// Live events arriving during the replay wait in the buffer. Live events the
+// subscriber already saw, which happens when it resumes from an id this
+// instance has not polled yet, are skipped.
+func (subscription *ProductSubscription) run(ctx context.Context) {
+	defer close(subscription.events)
+	defer subscription.Close()
+
+replay:
+	for subscription.lastEventId < subscription.replayUntil {
+		outboxEvents, err := subscription.service.outboxRepository.GetOutboxEventsAfter(ctx, subscription.lastEventId, productStreamPage)
+		if err != nil {
+			subscription.fail(err)
+			return
+		}
+		if len(outboxEvents) == 0 {
+			break
+		}
+		for _, outboxEvent := range outboxEvents {
+			if outboxEvent.Id > subscription.replayUntil {
+				break replay
+			}
+			if !subscription.send(ctx, outboxEvent) {
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-subscription.done:
+			return
+		case outboxEvent := <-subscription.live:
+			if outboxEvent.Id <= subscription.lastEventId {
+				continue
+			}
+			if !subscription.send(ctx, outboxEvent) {
+				return
+			}
+		}
+	}
+}
+
+// send delivers outboxEvent if it matches the filter and returns false if
+// ctx or the subscription ended first.
+func (subscription *ProductSubscription) send(ctx context.Context, outboxEvent domain.OutboxEvent) bool {
+	subscription.lastEventId = max(subscription.lastEventId, outboxEvent.Id)
+	productId, store := streamedProduct(outboxEvent)
+	if !subscription.filter.Matches(productId, store) {
+		return true
+	}
+	select {
+	case subscription.events <- outboxEvent:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-subscription.done:
+		return false
+	}
+}
+
+func (subscription *ProductSubscription) fail(err error) {
+	subscription.service.mutex.Lock()
+	defer subscription.service.mutex.Unlock()
+	subscription.service.end(subscription, err)
+}
+
+// streamedProduct returns the id and store of the product an event is about.
+// The store is taken from the product after the change, or before it for
+// deletions.
+func streamedProduct(outboxEvent domain.OutboxEvent) (int64, string) {
+	var payload productEventPayload
+	var product productSnapshot
+	if json.Unmarshal(outboxEvent.Payload, &payload) == nil {
+		snapshot := payload.Product
+		if string(snapshot) == "null" {
+			snapshot = payload.Previous
+		}
+		_ = json.Unmarshal(snapshot, &product)
+	}
+	return outboxEvent.ProductId, product.Store
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"github.com/erkindilekci/product-api/pkg/common/ratelimit"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"log/slog"
+	"math"
+	"time"
+)
+
+type IRateLimitService interface {
+	Allow(route string, client string) domain.RateLimitDecision
+	AllowIp(route string, ip string) domain.RateLimitDecision
+	PurgeIdlePeriodically(ctx context.Context, interval time.Duration)
+}
+
+type RateLimitService struct {
+	rateLimitRepository repository.IRateLimitRepository
+	config              ratelimit.Config
+	logger              *slog.Logger
+}
+
+func NewRateLimitService(rateLimitRepository repository.IRateLimitRepository, config ratelimit.Config, logger *slog.Logger) IRateLimitService {
+	return &RateLimitService{rateLimitRepository, config, logger}
+}
+
+// Allow takes a token from the bucket of client on route. Every route has its
+// own bucket per client, sized by the rule configured for the route.
+func (service *RateLimitService) Allow(route string, client string) domain.RateLimitDecision {
+	return service.takeToken(route+"|"+client, service.config.RuleFor(route))
+}
+
+// AllowIp takes a token from the bucket shared by all requests of ip, sized
+// by the per-IP rule. Routes configured as unlimited, such as health probes,
+// are not counted.
+func (service *RateLimitService) AllowIp(route string, ip string) domain.RateLimitDecision {
+	if service.config.RuleFor(route).Unlimited() {
+		return domain.RateLimitDecision{Allowed: true}
+	}
+	return service.takeToken("ip|"+ip, service.config.PerIp)
+}
+
+// takeToken fails open: requests are allowed when the bucket store fails.
+// The limits protect the API from overload rather than guarding access, and
+// the only store that can fail is the database the requests need anyway, so
+// rejecting them would turn a database hiccup into a full outage.
+func (service *RateLimitService) takeToken(key string, rule ratelimit.Rule) domain.RateLimitDecision {
+	if rule.Unlimited() {
+		return domain.RateLimitDecision{Allowed: true}
+	}
+	refillPerSecond := rule.RefillPerSecond()
+
+	tokens, allowed, err := service.rateLimitRepository.TakeToken(key, rule.Requests, refillPerSecond)
+	if err != nil {
+		service.logger.Error("error while taking rate limit token, allowing the request", "error", err)
+		return domain.RateLimitDecision{Allowed: true, Limit: rule.Requests, Remaining: rule.Requests}
+	}
+
+	decision := domain.RateLimitDecision{
+		Allowed:    allowed,
+		Limit:      rule.Requests,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: secondsToDuration((float64(rule.Requests) - tokens) / refillPerSecond),
+	}
+	if !allowed {
+		decision.RetryAfter = secondsToDuration((1 - tokens) / refillPerSecond)
+	}
+	return decision
+}
+
+// PurgeIdlePeriodically drops buckets that have not been used for a full
+// period of the slowest rule; such buckets would be full again anyway.
+func (service *RateLimitService) PurgeIdlePeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := service.rateLimitRepository.DeleteIdleBuckets(service.longestPeriod())
+			if err != nil {
+				service.logger.ErrorContext(ctx, "error while purging idle rate limit buckets", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				service.logger.InfoContext(ctx, "Purged idle rate limit buckets", "count", deleted)
+			}
+		}
+	}
+}
+
+func (service *RateLimitService) longestPeriod() time.Duration {
+	longest := max(service.config.Default.Period, service.config.PerIp.Period)
+	for _, rule := range service.config.Routes {
+		if rule.Period > longest {
+			longest = rule.Period
+		}
+	}
+	return longest
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(seconds)) * time.Second
+}
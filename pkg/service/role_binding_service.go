@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"github.com/erkindilekci/product-api/pkg/service/dto"
+)
+
+// IRoleBindingService manages store role bindings and decides whether the
+// principal of a request may access products of a store.
+//
+// Access is denied unless a binding grants it: a principal can only read and
+// modify products of the stores it is bound to, or of every store through a
+// binding to AllStores. The admin scope is never restricted.
+type IRoleBindingService interface {
+	Create(roleBindingCreate dto.RoleBindingCreate) (domain.RoleBinding, error)
+	GetAll(subject string) ([]domain.RoleBinding, error)
+	Delete(roleBindingId int64) error
+	Authorize(ctx context.Context, store string, permission string) error
+	AccessibleStores(ctx context.Context) ([]string, bool, error)
+}
+
+type RoleBindingService struct {
+	roleBindingRepository repository.IRoleBindingRepository
+}
+
+func NewRoleBindingService(roleBindingRepository repository.IRoleBindingRepository) IRoleBindingService {
+	return &RoleBindingService{roleBindingRepository}
+}
+
+func (service *RoleBindingService) Create(roleBindingCreate dto.RoleBindingCreate) (domain.RoleBinding, error) {
+	err := validateRoleBindingCreate(roleBindingCreate)
+	if err != nil {
+		return domain.RoleBinding{}, err
+	}
+
+	return service.roleBindingRepository.AddRoleBinding(domain.RoleBinding{
+		Subject: roleBindingCreate.Subject,
+		Role:    roleBindingCreate.Role,
+		Store:   roleBindingCreate.Store,
+	})
+}
+
+func (service *RoleBindingService) GetAll(subject string) ([]domain.RoleBinding, error) {
+	if subject == "" {
+		return service.roleBindingRepository.GetAllRoleBindings(), nil
+	}
+	return service.roleBindingRepository.GetRoleBindingsBySubject(subject)
+}
+
+func (service *RoleBindingService) Delete(roleBindingId int64) error {
+	return service.roleBindingRepository.DeleteRoleBindingById(roleBindingId)
+}
+
+func (service *RoleBindingService) Authorize(ctx context.Context, store string, permission string) error {
+	roleBindings, admin, err := service.roleBindingsOf(ctx)
+	if err != nil || admin {
+		return err
+	}
+
+	for _, roleBinding := range roleBindings {
+		if roleBinding.Covers(store) && roleBinding.Grants(permission) {
+			return nil
+		}
+	}
+
+	return domain.ErrForbidden
+}
+
+// AccessibleStores returns the stores whose products the principal may read.
+// The second result is true when it may read the products of every store, in
+// which case the stores are not listed.
+func (service *RoleBindingService) AccessibleStores(ctx context.Context) ([]string, bool, error) {
+	roleBindings, admin, err := service.roleBindingsOf(ctx)
+	if err != nil || admin {
+		return nil, admin, err
+	}
+
+	stores := []string{}
+	seen := map[string]bool{}
+	for _, roleBinding := range roleBindings {
+		if !roleBinding.Grants(domain.ScopeProductsRead) {
+			continue
+		}
+		if roleBinding.Store == domain.AllStores {
+			return nil, true, nil
+		}
+		if !seen[roleBinding.Store] {
+			seen[roleBinding.Store] = true
+			stores = append(stores, roleBinding.Store)
+		}
+	}
+
+	return stores, false, nil
+}
+
+// roleBindingsOf returns the bindings of the principal of ctx. Requests
+// without a principal have none; the second result is true for admins.
+func (service *RoleBindingService) roleBindingsOf(ctx context.Context) ([]domain.RoleBinding, bool, error) {
+	principal, ok := domain.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, false, nil
+	}
+	if principal.HasScope(domain.ScopeAdmin) {
+		return nil, true, nil
+	}
+
+	roleBindings, err := service.roleBindingRepository.GetRoleBindingsBySubject(principal.Subject)
+	if err != nil {
+		return nil, false, fmt.Errorf("getting role bindings of %s: %w", principal.Subject, err)
+	}
+	return roleBindings, false, nil
+}
+
+func validateRoleBindingCreate(roleBindingCreate dto.RoleBindingCreate) error {
+	validationError := &domain.ValidationError{}
+	if roleBindingCreate.Subject == "" {
+		validationError.Add("/subject", "subject can't be empty")
+	}
+	checkMaxLength(validationError, "/subject", "subject", roleBindingCreate.Subject)
+	if roleBindingCreate.Store == "" {
+		validationError.Add("/store", "store can't be empty")
+	}
+	checkMaxLength(validationError, "/store", "store", roleBindingCreate.Store)
+	if _, known := domain.StoreRolePermissions[roleBindingCreate.Role]; !known {
+		validationError.Add("/role", fmt.Sprintf("unknown role %q", roleBindingCreate.Role))
+	}
+	return validationError.OrNil()
+}
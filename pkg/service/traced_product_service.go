@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service/dto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/erkindilekci/product-api/pkg/service")
+
+// TracedProductService wraps every IProductService call in a span named after
+// the method.
+type TracedProductService struct {
+	productService IProductService
+	// txSpan is set on the service handed out by WithTx. Its calls become
+	// children of the transaction span even though callers pass their own
+	// context.
+	txSpan trace.Span
+}
+
+func NewTracedProductService(productService IProductService) IProductService {
+	return &TracedProductService{productService: productService}
+}
+
+func (service *TracedProductService) Add(ctx context.Context, productCreate dto.ProductCreate) error {
+	ctx, span := service.startSpan(ctx, "Add", attribute.String("product.store", productCreate.Store))
+	defer span.End()
+
+	err := service.productService.Add(ctx, productCreate)
+	recordServiceError(span, err)
+	return err
+}
+
+func (service *TracedProductService) UpsertBySku(ctx context.Context, productCreate dto.ProductCreate) (domain.Product, bool, error) {
+	ctx, span := service.startSpan(ctx, "UpsertBySku", attribute.String("product.store", productCreate.Store), attribute.String("product.sku", productCreate.Sku))
+	defer span.End()
+
+	product, created, err := service.productService.UpsertBySku(ctx, productCreate)
+	recordServiceError(span, err)
+	return product, created, err
+}
+
+func (service *TracedProductService) GetAllProducts(ctx context.Context) ([]domain.Product, error) {
+	ctx, span := service.startSpan(ctx, "GetAllProducts")
+	defer span.End()
+
+	products, err := service.productService.GetAllProducts(ctx)
+	recordServiceError(span, err)
+	return products, err
+}
+
+func (service *TracedProductService) GetProductsByStore(ctx context.Context, store string) ([]domain.Product, error) {
+	ctx, span := service.startSpan(ctx, "GetProductsByStore", attribute.String("product.store", store))
+	defer span.End()
+
+	products, err := service.productService.GetProductsByStore(ctx, store)
+	recordServiceError(span, err)
+	return products, err
+}
+
+func (service *TracedProductService) GetProductPage(ctx context.Context, filter domain.ProductFilter, afterId int64, limit int) (domain.ProductPage, error) {
+	ctx, span := service.startSpan(ctx, "GetProductPage", attribute.Int64("page.after_id", afterId), attribute.Int("page.limit", limit))
+	defer span.End()
+
+	page, err := service.productService.GetProductPage(ctx, filter, afterId, limit)
+	recordServiceError(span, err)
+	return page, err
+}
+
+func (service *TracedProductService) GetProductPagesByStore(ctx context.Context, filter domain.ProductFilter, afterId int64, limit int) (map[string]domain.ProductPage, error) {
+	ctx, span := service.startSpan(ctx, "GetProductPagesByStore", attribute.StringSlice("product.stores", filter.Stores), attribute.Int("page.limit", limit))
+	defer span.End()
+
+	pages, err := service.productService.GetProductPagesByStore(ctx, filter, afterId, limit)
+	recordServiceError(span, err)
+	return pages, err
+}
+
+func (service *TracedProductService) CountProductsByStore(ctx context.Context) (map[string]int64, error) {
+	ctx, span := service.startSpan(ctx, "CountProductsByStore")
+	defer span.End()
+
+	counts, err := service.productService.CountProductsByStore(ctx)
+	recordServiceError(span, err)
+	return counts, err
+}
+
+func (service *TracedProductService) GetById(ctx context.Context, productId int64) (domain.Product, error) {
+	ctx, span := service.startSpan(ctx, "GetById", attribute.Int64("product.id", productId))
+	defer span.End()
+
+	product, err := service.productService.GetById(ctx, productId)
+	recordServiceError(span, err)
+	return product, err
+}
+
+func (service *TracedProductService) GetByExternalId(ctx context.Context, externalId domain.ExternalId) (domain.Product, error) {
+	ctx, span := service.startSpan(ctx, "GetByExternalId", attribute.String("product.external_id.system", externalId.System))
+	defer span.End()
+
+	product, err := service.productService.GetByExternalId(ctx, externalId)
+	recordServiceError(span, err)
+	return product, err
+}
+
+func (service *TracedProductService) DeleteById(ctx context.Context, productId int64) error {
+	ctx, span := service.startSpan(ctx, "DeleteById", attribute.Int64("product.id", productId))
+	defer span.End()
+
+	err := service.productService.DeleteById(ctx, productId)
+	recordServiceError(span, err)
+	return err
+}
+
+func (service *TracedProductService) UpdatePrice(ctx context.Context, productId int64, newPrice float32) error {
+	ctx, span := service.startSpan(ctx, "UpdatePrice", attribute.Int64("product.id", productId))
+	defer span.End()
+
+	err := service.productService.UpdatePrice(ctx, productId, newPrice)
+	recordServiceError(span, err)
+	return err
+}
+
+func (service *TracedProductService) UpdatePriceByExternalId(ctx context.Context, externalId domain.ExternalId, newPrice float32) error {
+	ctx, span := service.startSpan(ctx, "UpdatePriceByExternalId", attribute.String("product.external_id.system", externalId.System))
+	defer span.End()
+
+	err := service.productService.UpdatePriceByExternalId(ctx, externalId, newPrice)
+	recordServiceError(span, err)
+	return err
+}
+
+// WithTx traces the transaction as a whole and keeps the service handed to fn
+// traced, so each operation inside it gets its own span.
+func (service *TracedProductService) WithTx(ctx context.Context, fn func(service IProductService) error) error {
+	ctx, span := service.startSpan(ctx, "WithTx")
+	defer span.End()
+
+	err := service.productService.WithTx(ctx, func(txService IProductService) error {
+		return fn(&TracedProductService{txService, span})
+	})
+	recordServiceError(span, err)
+	return err
+}
+
+func (service *TracedProductService) startSpan(ctx context.Context, method string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	if service.txSpan != nil {
+		ctx = trace.ContextWithSpan(ctx, service.txSpan)
+	}
+	return tracer.Start(ctx, "ProductService."+method, trace.WithAttributes(attributes...))
+}
+
+func recordServiceError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/common/webhook"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"github.com/erkindilekci/product-api/pkg/service/dto"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultWebhookDeliveryLimit = 100
+	maxWebhookDeliveryLimit     = 1000
+	minWebhookSecretLength      = 16
+	// maxWebhookErrorLength bounds the part of a failed response body that is
+	// kept in the delivery log.
+	maxWebhookErrorLength = 512
+)
+
+type IWebhookService interface {
+	CreateSubscription(ctx context.Context, subscriptionCreate dto.WebhookSubscriptionCreate) (domain.WebhookSubscription, error)
+	GetSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, subscriptionId int64) error
+	GetDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter) ([]domain.WebhookDelivery, error)
+	RetryDelivery(ctx context.Context, deliveryId int64) (domain.WebhookDelivery, error)
+	Publish(ctx context.Context, outboxEvent domain.OutboxEvent) error
+	DeliverDue(ctx context.Context) int
+	DeliverPeriodically(ctx context.Context, interval time.Duration)
+}
+
+type WebhookService struct {
+	webhookRepository repository.IWebhookRepository
+	config            webhook.Config
+	httpClient        *http.Client
+	logger            *slog.Logger
+}
+
+func NewWebhookService(webhookRepository repository.IWebhookRepository, config webhook.Config, logger *slog.Logger) IWebhookService {
+	return &WebhookService{webhookRepository, config, webhook.NewHttpClient(config), logger}
+}
+
+func (service *WebhookService) CreateSubscription(ctx context.Context, subscriptionCreate dto.WebhookSubscriptionCreate) (domain.WebhookSubscription, error) {
+	err := validateWebhookSubscriptionCreate(subscriptionCreate, service.config.AllowPrivateNetworks)
+	if err != nil {
+		return domain.WebhookSubscription{}, err
+	}
+
+	return service.webhookRepository.AddSubscription(ctx, domain.WebhookSubscription{
+		Url:        subscriptionCreate.Url,
+		EventTypes: subscriptionCreate.EventTypes,
+		Secret:     subscriptionCreate.Secret,
+	})
+}
+
+func (service *WebhookService) GetSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	return service.webhookRepository.GetAllSubscriptions(ctx)
+}
+
+func (service *WebhookService) DeleteSubscription(ctx context.Context, subscriptionId int64) error {
+	return service.webhookRepository.DeleteSubscriptionById(ctx, subscriptionId)
+}
+
+func (service *WebhookService) GetDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter) ([]domain.WebhookDelivery, error) {
+	validationError := &domain.ValidationError{}
+	if filter.Limit < 0 || filter.Limit > maxWebhookDeliveryLimit {
+		validationError.Add("limit", fmt.Sprintf("limit must be between 1 and %d", maxWebhookDeliveryLimit))
+	}
+	if filter.Status != "" && filter.Status != domain.WebhookDeliveryPending && filter.Status != domain.WebhookDeliveryDelivered && filter.Status != domain.WebhookDeliveryDead {
+		validationError.Add("status", fmt.Sprintf("unknown delivery status %q", filter.Status))
+	}
+	if err := validationError.OrNil(); err != nil {
+		return nil, err
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultWebhookDeliveryLimit
+	}
+
+	return service.webhookRepository.GetDeliveries(ctx, filter)
+}
+
+// RetryDelivery sends a dead-lettered delivery again, with a fresh budget of
+// attempts.
+func (service *WebhookService) RetryDelivery(ctx context.Context, deliveryId int64) (domain.WebhookDelivery, error) {
+	delivery, err := service.webhookRepository.GetDeliveryById(ctx, deliveryId)
+	if err != nil {
+		return domain.WebhookDelivery{}, err
+	}
+	if delivery.Status != domain.WebhookDeliveryDead {
+		return domain.WebhookDelivery{}, domain.ErrWebhookDeliveryNotRetryable
+	}
+
+	delivery.Status = domain.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	err = service.webhookRepository.UpdateDelivery(ctx, delivery)
+	if err != nil {
+		return domain.WebhookDelivery{}, err
+	}
+	return delivery, nil
+}
+
+// Publish queues a delivery of outboxEvent for each subscription of its
+// webhook event type. It is the webhook publisher of the outbox relay: the
+// relay publishes the event again if queueing fails.
+func (service *WebhookService) Publish(ctx context.Context, outboxEvent domain.OutboxEvent) error {
+	var payload productEventPayload
+	if err := json.Unmarshal(outboxEvent.Payload, &payload); err != nil {
+		return err
+	}
+	eventType, ok := webhookEventType(outboxEvent.EventType, payload)
+	if !ok {
+		return nil
+	}
+
+	subscriptions, err := service.webhookRepository.GetAllSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	var deliveries []domain.WebhookDelivery
+	now := time.Now()
+	for _, subscription := range subscriptions {
+		if !subscription.Accepts(eventType) {
+			continue
+		}
+		deliveries = append(deliveries, domain.WebhookDelivery{
+			SubscriptionId: subscription.Id,
+			EventId:        outboxEvent.EventId,
+			EventType:      eventType,
+			Status:         domain.WebhookDeliveryPending,
+			NextAttemptAt:  now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	payload.Type = eventType
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	for i := range deliveries {
+		deliveries[i].Payload = body
+	}
+	return service.webhookRepository.AddDeliveries(ctx, deliveries)
+}
+
+// DeliverDue makes one attempt at every due delivery and returns how many were
+// attempted. Claimed deliveries are leased for as long as attempting all of
+// them may take, after which another poll may pick them up again.
+func (service *WebhookService) DeliverDue(ctx context.Context) int {
+	now := time.Now()
+	leaseUntil := now.Add(time.Duration(service.config.BatchSize) * service.config.Timeout)
+	deliveries, err := service.webhookRepository.ClaimDueDeliveries(ctx, now, leaseUntil, service.config.BatchSize)
+	if err != nil {
+		service.logger.ErrorContext(ctx, "failed to claim webhook deliveries", "error", err)
+		return 0
+	}
+
+	subscriptions := map[int64]domain.WebhookSubscription{}
+	for i, delivery := range deliveries {
+		// An attempt after the lease ended could send the delivery a second
+		// time alongside a poll that claimed it in the meantime.
+		if time.Now().Add(service.config.Timeout).After(leaseUntil) {
+			return i
+		}
+		subscription, found := subscriptions[delivery.SubscriptionId]
+		if !found {
+			subscription, err = service.webhookRepository.GetSubscriptionById(ctx, delivery.SubscriptionId)
+			if err != nil {
+				service.logger.ErrorContext(ctx, "failed to load webhook subscription", "subscription_id", delivery.SubscriptionId, "error", err)
+				continue
+			}
+			subscriptions[delivery.SubscriptionId] = subscription
+		}
+		service.attempt(ctx, subscription, delivery)
+	}
+	return len(deliveries)
+}
+
+// DeliverPeriodically polls for due deliveries until ctx is cancelled.
+func (service *WebhookService) DeliverPeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			service.DeliverDue(ctx)
+		}
+	}
+}
+
+// attempt posts delivery once and records the outcome: delivered on a 2xx
+// response, otherwise pending with an exponential backoff, or dead once the
+// attempts are used up.
+func (service *WebhookService) attempt(ctx context.Context, subscription domain.WebhookSubscription, delivery domain.WebhookDelivery) {
+	statusCode, err := service.post(ctx, subscription, delivery)
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = ""
+	switch {
+	case err == nil:
+		delivery.Status = domain.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= service.config.MaxAttempts:
+		delivery.Status = domain.WebhookDeliveryDead
+		delivery.LastError = err.Error()
+		service.logger.WarnContext(ctx, "webhook delivery dead-lettered", "delivery_id", delivery.Id, "subscription_id", subscription.Id, "attempts", delivery.Attempts, "error", err)
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(service.config.Backoff(delivery.Attempts))
+	}
+
+	if err := service.webhookRepository.UpdateDelivery(ctx, delivery); err != nil {
+		service.logger.ErrorContext(ctx, "failed to record webhook delivery attempt", "delivery_id", delivery.Id, "error", err)
+	}
+}
+
+// post sends the signed payload and returns the response status, if any, and
+// an error unless the receiver answered with a 2xx status.
+func (service *WebhookService) post(ctx context.Context, subscription domain.WebhookSubscription, delivery domain.WebhookDelivery) (int, error) {
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("User-Agent", "product-api-webhooks")
+	httpRequest.Header.Set(webhook.HeaderEventId, delivery.EventId)
+	httpRequest.Header.Set(webhook.HeaderEvent, delivery.EventType)
+	httpRequest.Header.Set(webhook.HeaderTimestamp, timestamp)
+	httpRequest.Header.Set(webhook.HeaderSignature, webhook.Sign(subscription.Secret, timestamp, delivery.Payload))
+
+	httpResponse, err := service.httpClient.Do(httpRequest)
+	if err != nil {
+		return 0, err
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(httpResponse.Body, maxWebhookErrorLength))
+		return httpResponse.StatusCode, fmt.Errorf("receiver answered with status %d: %s", httpResponse.StatusCode, body)
+	}
+	_, _ = io.Copy(io.Discard, httpResponse.Body)
+	return httpResponse.StatusCode, nil
+}
+
+// webhookEventType maps a product event to the webhook event it is published
+// as. Upserts are published as price changes if they changed the price and
+// are not published otherwise.
+func webhookEventType(eventType string, payload productEventPayload) (string, bool) {
+	switch eventType {
+	case domain.AuditActionProductCreated, domain.AuditActionProductPriceChanged, domain.AuditActionProductDeleted:
+		return eventType, true
+	case domain.AuditActionProductUpserted:
+		var before, after productSnapshot
+		if json.Unmarshal(payload.Previous, &before) != nil || json.Unmarshal(payload.Product, &after) != nil {
+			return "", false
+		}
+		return domain.WebhookEventProductPriceChanged, before.Price != after.Price
+	}
+	return "", false
+}
+
+// validateWebhookSubscriptionCreate refuses urls of hosts that are internal
+// by their name or address. Host names are only resolved when delivering, by
+// the client webhook.NewHttpClient returns.
+func validateWebhookSubscriptionCreate(subscriptionCreate dto.WebhookSubscriptionCreate, allowPrivateNetworks bool) error {
+	validationError := &domain.ValidationError{}
+	subscriptionUrl, err := url.Parse(subscriptionCreate.Url)
+	if err != nil || (subscriptionUrl.Scheme != "http" && subscriptionUrl.Scheme != "https") || subscriptionUrl.Host == "" {
+		validationError.Add("/url", "url must be an absolute http or https url")
+	} else if !allowPrivateNetworks && isPrivateHost(subscriptionUrl.Hostname()) {
+		validationError.Add("/url", "url must not point to a loopback, private or link-local address")
+	}
+	checkMaxLength(validationError, "/url", "url", subscriptionCreate.Url)
+	if len(subscriptionCreate.EventTypes) == 0 {
+		validationError.Add("/event_types", "event types can't be empty")
+	}
+	for i, eventType := range subscriptionCreate.EventTypes {
+		if !containsString(domain.WebhookEventTypes, eventType) {
+			validationError.Add(fmt.Sprintf("/event_types/%d", i), fmt.Sprintf("unknown event type %q", eventType))
+		}
+	}
+	if len(subscriptionCreate.Secret) < minWebhookSecretLength {
+		validationError.Add("/secret", fmt.Sprintf("secret must be at least %d characters long", minWebhookSecretLength))
+	}
+	checkMaxLength(validationError, "/secret", "secret", subscriptionCreate.Secret)
+	return validationError.OrNil()
+}
+
+func isPrivateHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && !webhook.IsPublicAddress(addr)
+}
@@ -2,24 +2,30 @@ package repo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/erkindilekci/product-api/pkg/common/postgresql"
 	"github.com/erkindilekci/product-api/pkg/domain"
 	"github.com/erkindilekci/product-api/pkg/repository"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"log/slog"
 	"os"
 	"testing"
 )
 
 var productRepo repository.IProductRepository
+var productChangeRepo repository.IProductChangeRepository
 var databasePool *pgxpool.Pool
 var testContext context.Context
 
 func TestMain(m *testing.M) {
 	testContext = context.Background()
+	testLogger := slog.New(slog.NewTextHandler(io.Discard, nil))
 
-	databasePool = postgresql.GetConnectionPool(testContext, postgresql.Config{
+	var err error
+	databasePool, err = postgresql.GetConnectionPool(testContext, postgresql.Config{
 		Host:                  "localhost",
 		Port:                  "5433",
 		UserName:              "postgres",
@@ -27,9 +33,14 @@ func TestMain(m *testing.M) {
 		DbName:                "productapp",
 		MaxConnections:        "10",
 		MaxConnectionIdleTime: "30s",
-	})
+	}, testLogger)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-	productRepo = repository.NewProductRepository(databasePool)
+	productRepo = repository.NewProductRepository(databasePool, testLogger)
+	productChangeRepo = repository.NewProductChangeRepository(databasePool, testLogger)
 
 	fmt.Println("Before / Setup")
 	exitCode := m.Run()
@@ -49,7 +60,7 @@ func teardownTestData(ctx context.Context, dbPool *pgxpool.Pool) {
 func TestGetAllProducts(t *testing.T) {
 	setupTestData(testContext, databasePool)
 
-	actualProducts := productRepo.GetAllProducts()
+	actualProducts := productRepo.GetAllProducts(testContext)
 
 	t.Run("TestGetAllProductsLength", func(t *testing.T) {
 		actualLength := len(actualProducts)
@@ -60,10 +71,10 @@ func TestGetAllProducts(t *testing.T) {
 
 	t.Run("TestGetAllProductsContent", func(t *testing.T) {
 		expectedProducts := []domain.Product{
-			{1, "XBOX Series X", 1000.0, 10.0, "Microsoft"},
-			{2, "Steelseries Rival 500", 100.0, 20.0, "Amazon"},
-			{3, "Asus Vivobook", 600.0, 15.0, "Asus Store"},
-			{4, "Macbook Pro M3 Pro", 3000.0, 0.0, "Apple"},
+			{Id: 1, Name: "XBOX Series X", Price: 1000.0, Discount: 10.0, Store: "Microsoft"},
+			{Id: 2, Name: "Steelseries Rival 500", Price: 100.0, Discount: 20.0, Store: "Amazon"},
+			{Id: 3, Name: "Asus Vivobook", Price: 600.0, Discount: 15.0, Store: "Asus Store"},
+			{Id: 4, Name: "Macbook Pro M3 Pro", Price: 3000.0, Discount: 0.0, Store: "Apple"},
 		}
 		assert.Equal(t, expectedProducts, actualProducts)
 	})
@@ -74,9 +85,9 @@ func TestGetAllProducts(t *testing.T) {
 func TestGetAllProductsByStore(t *testing.T) {
 	setupTestData(testContext, databasePool)
 
-	actualProducts := productRepo.GetProductsByStore("Apple")
+	actualProducts := productRepo.GetProductsByStore(testContext, "Apple")
 	expectedProducts := []domain.Product{
-		{4, "Macbook Pro M3 Pro", 3000.0, 0.0, "Apple"},
+		{Id: 4, Name: "Macbook Pro M3 Pro", Price: 3000.0, Discount: 0.0, Store: "Apple"},
 	}
 
 	t.Run("TestGetAllProductsByStoreLength", func(t *testing.T) {
@@ -93,10 +104,76 @@ func TestGetAllProductsByStore(t *testing.T) {
 	teardownTestData(testContext, databasePool)
 }
 
+func TestCountProductsByStore(t *testing.T) {
+	setupTestData(testContext, databasePool)
+
+	counts, err := productRepo.CountProductsByStore(testContext, domain.ProductFilter{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int64{"Microsoft": 1, "Amazon": 1, "Asus Store": 1, "Apple": 1}, counts)
+
+	teardownTestData(testContext, databasePool)
+}
+
+func TestGetProductPage(t *testing.T) {
+	setupTestData(testContext, databasePool)
+
+	t.Run("FirstPage", func(t *testing.T) {
+		products, err := productRepo.GetProductPage(testContext, domain.ProductFilter{}, 0, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, []domain.Product{
+			{Id: 1, Name: "XBOX Series X", Price: 1000.0, Discount: 10.0, Store: "Microsoft"},
+			{Id: 2, Name: "Steelseries Rival 500", Price: 100.0, Discount: 20.0, Store: "Amazon"},
+		}, products)
+	})
+
+	t.Run("AfterCursor", func(t *testing.T) {
+		products, err := productRepo.GetProductPage(testContext, domain.ProductFilter{}, 2, 10)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(products))
+		assert.Equal(t, int64(3), products[0].Id)
+	})
+
+	t.Run("Filtered", func(t *testing.T) {
+		maxPrice := float32(1000.0)
+		filter := domain.ProductFilter{Stores: []string{"Microsoft", "Apple"}, NameContains: "xbox", MaxPrice: &maxPrice}
+		products, err := productRepo.GetProductPage(testContext, filter, 0, 10)
+		assert.Nil(t, err)
+		assert.Equal(t, []domain.Product{{Id: 1, Name: "XBOX Series X", Price: 1000.0, Discount: 10.0, Store: "Microsoft"}}, products)
+	})
+
+	t.Run("NoStores", func(t *testing.T) {
+		products, err := productRepo.GetProductPage(testContext, domain.ProductFilter{Stores: []string{}}, 0, 10)
+		assert.Nil(t, err)
+		assert.Empty(t, products)
+	})
+
+	teardownTestData(testContext, databasePool)
+}
+
+func TestGetProductPagesByStore(t *testing.T) {
+	setupTestData(testContext, databasePool)
+	_, err := productRepo.AddProduct(testContext, domain.Product{Name: "Surface Pro", Price: 1200.0, Store: "Microsoft"})
+	assert.Nil(t, err)
+
+	pages, err := productRepo.GetProductPagesByStore(testContext, domain.ProductFilter{Stores: []string{"Microsoft", "Apple"}}, 0, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]domain.Product{
+		"Microsoft": {{Id: 1, Name: "XBOX Series X", Price: 1000.0, Discount: 10.0, Store: "Microsoft"}},
+		"Apple":     {{Id: 4, Name: "Macbook Pro M3 Pro", Price: 3000.0, Discount: 0.0, Store: "Apple"}},
+	}, pages)
+
+	counts, err := productRepo.CountProductsByStore(testContext, domain.ProductFilter{Stores: []string{"Microsoft", "Apple"}})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int64{"Microsoft": 2, "Apple": 1}, counts)
+
+	teardownTestData(testContext, databasePool)
+}
+
 func TestAddProduct(t *testing.T) {
 	newProduct := domain.Product{Name: "Product 1", Price: 100.0, Discount: 20.0, Store: "Store 1"}
-	err := productRepo.AddProduct(newProduct)
-	allProducts := productRepo.GetAllProducts()
+	_, err := productRepo.AddProduct(testContext, newProduct)
+	allProducts := productRepo.GetAllProducts(testContext)
 
 	t.Run("TestAddProductNoError", func(t *testing.T) {
 		assert.NoError(t, err)
@@ -115,11 +192,55 @@ func TestAddProduct(t *testing.T) {
 	teardownTestData(testContext, databasePool)
 }
 
+func TestAddProductDuplicate(t *testing.T) {
+	setupTestData(testContext, databasePool)
+
+	t.Run("TestAddProductDuplicateName", func(t *testing.T) {
+		duplicateProduct := domain.Product{Name: "xbox series x", Price: 900.0, Discount: 0.0, Store: "Microsoft"}
+		_, err := productRepo.AddProduct(testContext, duplicateProduct)
+		assert.ErrorIs(t, err, domain.ErrProductAlreadyExists)
+	})
+
+	t.Run("TestAddProductSameNameOtherStore", func(t *testing.T) {
+		otherStoreProduct := domain.Product{Name: "XBOX Series X", Price: 950.0, Discount: 0.0, Store: "Amazon"}
+		_, err := productRepo.AddProduct(testContext, otherStoreProduct)
+		assert.NoError(t, err)
+	})
+
+	teardownTestData(testContext, databasePool)
+}
+
+func TestUpsertProductBySku(t *testing.T) {
+	product := domain.Product{Name: "Logitech Mx Keys", Price: 120.0, Discount: 15.0, Store: "Amazon", Sku: "B07S92QBCJ"}
+
+	var insertedId int64
+
+	t.Run("TestUpsertProductBySkuInsert", func(t *testing.T) {
+		upserted, created, err := productRepo.UpsertProductBySku(testContext, product)
+		assert.NoError(t, err)
+		assert.True(t, created)
+		assert.NotZero(t, upserted.Id)
+		insertedId = upserted.Id
+	})
+
+	t.Run("TestUpsertProductBySkuUpdate", func(t *testing.T) {
+		product.Price = 110.0
+		upserted, created, err := productRepo.UpsertProductBySku(testContext, product)
+		assert.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, insertedId, upserted.Id)
+		assert.Equal(t, product.Price, upserted.Price)
+		assert.Equal(t, 1, len(productRepo.GetAllProducts(testContext)))
+	})
+
+	teardownTestData(testContext, databasePool)
+}
+
 func TestGetProductById(t *testing.T) {
 	setupTestData(testContext, databasePool)
 
 	t.Run("TestGetProductByIdValid", func(t *testing.T) {
-		product, err := productRepo.GetProductById(1)
+		product, err := productRepo.GetProductById(testContext, 1)
 		expectedProduct := domain.Product{Id: 1, Name: "XBOX Series X", Price: 1000.0, Discount: 10.0, Store: "Microsoft"}
 
 		assert.NoError(t, err)
@@ -129,17 +250,42 @@ func TestGetProductById(t *testing.T) {
 	teardownTestData(testContext, databasePool)
 }
 
+func TestExternalIds(t *testing.T) {
+	setupTestData(testContext, databasePool)
+
+	externalId := domain.ExternalId{System: "amazon", Id: "B07W6JN8V8"}
+
+	t.Run("TestAddExternalIds", func(t *testing.T) {
+		err := productRepo.AddExternalIds(testContext, 2, []domain.ExternalId{externalId})
+		assert.NoError(t, err)
+	})
+
+	t.Run("TestAddExternalIdsDuplicate", func(t *testing.T) {
+		err := productRepo.AddExternalIds(testContext, 3, []domain.ExternalId{externalId})
+		assert.ErrorIs(t, err, domain.ErrExternalIdAlreadyExists)
+	})
+
+	t.Run("TestGetProductByExternalId", func(t *testing.T) {
+		product, err := productRepo.GetProductByExternalId(testContext, externalId)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), product.Id)
+		assert.Equal(t, []domain.ExternalId{externalId}, product.ExternalIds)
+	})
+
+	teardownTestData(testContext, databasePool)
+}
+
 func TestDeleteProductById(t *testing.T) {
 	setupTestData(testContext, databasePool)
 
 	t.Run("TestDeleteProductByIdValid", func(t *testing.T) {
-		err := productRepo.DeleteProductById(4)
+		err := productRepo.DeleteProductById(testContext, 4)
 		assert.NoError(t, err)
 	})
 
 	t.Run("TestDeleteProductByIdContent", func(t *testing.T) {
 		deletedProduct := domain.Product{Id: 4, Name: "Macbook Pro M3 Pro", Price: 3000.0, Store: "Apple"}
-		assert.NotContains(t, productRepo.GetAllProducts(), deletedProduct)
+		assert.NotContains(t, productRepo.GetAllProducts(testContext), deletedProduct)
 	})
 
 	teardownTestData(testContext, databasePool)
@@ -149,13 +295,115 @@ func TestUpdatePriceById(t *testing.T) {
 	setupTestData(testContext, databasePool)
 
 	t.Run("TestUpdatePriceByIdValid", func(t *testing.T) {
-		err := productRepo.UpdatePriceById(4, 3200.0)
+		err := productRepo.UpdatePriceById(testContext, 4, 3200.0)
 		assert.NoError(t, err)
 	})
 
 	t.Run("TestUpdatePriceByIdContent", func(t *testing.T) {
 		updatedProduct := domain.Product{Id: 4, Name: "Macbook Pro M3 Pro", Price: 3200.0, Store: "Apple"}
-		assert.Contains(t, productRepo.GetAllProducts(), updatedProduct)
+		assert.Contains(t, productRepo.GetAllProducts(testContext), updatedProduct)
+	})
+
+	teardownTestData(testContext, databasePool)
+}
+
+func TestWithTx(t *testing.T) {
+	setupTestData(testContext, databasePool)
+
+	t.Run("TestWithTxCommit", func(t *testing.T) {
+		err := productRepo.WithTx(testContext, func(txRepo repository.IProductRepository) error {
+			product, err := txRepo.GetProductByIdForUpdate(testContext, 1)
+			if err != nil {
+				return err
+			}
+			return txRepo.UpdatePriceById(testContext, product.Id, 900.0)
+		})
+		assert.NoError(t, err)
+
+		product, _ := productRepo.GetProductById(testContext, 1)
+		assert.Equal(t, float32(900.0), product.Price)
+	})
+
+	t.Run("TestWithTxRollback", func(t *testing.T) {
+		err := productRepo.WithTx(testContext, func(txRepo repository.IProductRepository) error {
+			if err := txRepo.DeleteProductById(testContext, 2); err != nil {
+				return err
+			}
+			return errors.New("abort")
+		})
+		assert.Error(t, err)
+
+		_, err = productRepo.GetProductById(testContext, 2)
+		assert.NoError(t, err)
+	})
+
+	teardownTestData(testContext, databasePool)
+}
+
+func TestGetProductChanges(t *testing.T) {
+	setupTestData(testContext, databasePool)
+
+	changes, err := productChangeRepo.GetProductChanges(testContext, 0, 10, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(changes))
+	since := changes[len(changes)-1].Sequence
+
+	t.Run("TestGetProductChangesInsertUpdateAndDelete", func(t *testing.T) {
+		productId, err := productRepo.AddProduct(testContext, domain.Product{Name: "PlayStation 5", Price: 500.0, Discount: 5.0, Store: "Sony"})
+		assert.NoError(t, err)
+		assert.NoError(t, productRepo.UpdatePriceById(testContext, 1, 900.0))
+		assert.NoError(t, productRepo.DeleteProductById(testContext, 4))
+
+		changes, err := productChangeRepo.GetProductChanges(testContext, since, 10, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(changes))
+		assert.Equal(t, productId, changes[0].ProductId)
+		assert.Equal(t, float32(5.0), changes[0].Product.Discount)
+		assert.Equal(t, int64(1), changes[1].ProductId)
+		assert.Equal(t, float32(900.0), changes[1].Product.Price)
+		assert.Equal(t, int64(4), changes[2].ProductId)
+		assert.True(t, changes[2].Deleted)
+		assert.Nil(t, changes[2].Product)
+		assert.Less(t, changes[1].Sequence, changes[2].Sequence)
+	})
+
+	t.Run("TestGetProductChangesLatestOnly", func(t *testing.T) {
+		assert.NoError(t, productRepo.UpdatePriceById(testContext, 1, 950.0))
+
+		changes, err := productChangeRepo.GetProductChanges(testContext, since, 10, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(changes))
+		assert.Equal(t, int64(4), changes[1].ProductId)
+		assert.Equal(t, int64(1), changes[2].ProductId)
+		assert.Equal(t, float32(950.0), changes[2].Product.Price)
+	})
+
+	t.Run("TestGetProductChangesByStore", func(t *testing.T) {
+		changes, err := productChangeRepo.GetProductChanges(testContext, since, 10, []string{"Apple"})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(changes))
+		assert.True(t, changes[0].Deleted)
+
+		changes, err = productChangeRepo.GetProductChanges(testContext, 0, 10, []string{})
+		assert.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("TestGetProductChangesRolledBack", func(t *testing.T) {
+		last, err := productChangeRepo.GetProductChanges(testContext, since, 10, nil)
+		assert.NoError(t, err)
+
+		err = productRepo.WithTx(testContext, func(txRepo repository.IProductRepository) error {
+			if err := txRepo.DeleteProductById(testContext, 2); err != nil {
+				return err
+			}
+			return errors.New("abort")
+		})
+		assert.Error(t, err)
+
+		changes, err := productChangeRepo.GetProductChanges(testContext, last[len(last)-1].Sequence, 10, nil)
+		assert.NoError(t, err)
+		assert.Empty(t, changes)
 	})
 
 	teardownTestData(testContext, databasePool)
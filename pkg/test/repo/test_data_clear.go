@@ -7,7 +7,7 @@ import (
 )
 
 func TruncateTestData(ctx context.Context, dbPool *pgxpool.Pool) {
-	_, err := dbPool.Exec(ctx, "TRUNCATE products RESTART IDENTITY")
+	_, err := dbPool.Exec(ctx, "TRUNCATE products, audit_events, product_changes RESTART IDENTITY CASCADE")
 	if err != nil {
 		log.Error(err)
 	} else {
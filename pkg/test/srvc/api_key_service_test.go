@@ -0,0 +1,86 @@
+package srvc
+
+import (
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/erkindilekci/product-api/pkg/service/dto"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestApiKeyCreate(t *testing.T) {
+	apiKeyService := service.NewApiKeyService(NewFakeApiKeyRepository())
+
+	t.Run("ValidApiKey", func(t *testing.T) {
+		apiKey, token, err := apiKeyService.Create(dto.ApiKeyCreate{Name: "sync-client", Scopes: []string{domain.ScopeProductsRead}})
+		assert.Nil(t, err)
+		assert.NotEmpty(t, token)
+		assert.NotContains(t, apiKey.KeyHash, token)
+	})
+
+	t.Run("UnknownScope", func(t *testing.T) {
+		_, _, err := apiKeyService.Create(dto.ApiKeyCreate{Name: "sync-client", Scopes: []string{"products:everything"}})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("NoScopes", func(t *testing.T) {
+		_, _, err := apiKeyService.Create(dto.ApiKeyCreate{Name: "sync-client"})
+		assert.NotNil(t, err)
+	})
+}
+
+func TestApiKeyAuthenticate(t *testing.T) {
+	apiKeyService := service.NewApiKeyService(NewFakeApiKeyRepository())
+	apiKey, token, _ := apiKeyService.Create(dto.ApiKeyCreate{Name: "sync-client", Scopes: []string{domain.ScopeProductsWrite}})
+
+	t.Run("ValidToken", func(t *testing.T) {
+		principal, err := apiKeyService.Authenticate(token)
+		assert.Nil(t, err)
+		assert.True(t, principal.HasScope(domain.ScopeProductsWrite))
+		assert.False(t, principal.HasScope(domain.ScopeProductsDelete))
+	})
+
+	t.Run("WrongSecret", func(t *testing.T) {
+		_, err := apiKeyService.Authenticate(token[:len(token)-1] + "x")
+		assert.ErrorIs(t, err, service.ErrInvalidApiKey)
+	})
+
+	t.Run("MalformedToken", func(t *testing.T) {
+		_, err := apiKeyService.Authenticate("not-an-api-key")
+		assert.ErrorIs(t, err, service.ErrInvalidApiKey)
+	})
+
+	t.Run("RotatedToken", func(t *testing.T) {
+		_, newToken, err := apiKeyService.Rotate(apiKey.Id)
+		assert.Nil(t, err)
+
+		_, err = apiKeyService.Authenticate(token)
+		assert.ErrorIs(t, err, service.ErrInvalidApiKey)
+
+		_, err = apiKeyService.Authenticate(newToken)
+		assert.Nil(t, err)
+		token = newToken
+	})
+
+	t.Run("RevokedToken", func(t *testing.T) {
+		assert.Nil(t, apiKeyService.Revoke(apiKey.Id))
+
+		_, err := apiKeyService.Authenticate(token)
+		assert.ErrorIs(t, err, service.ErrInvalidApiKey)
+	})
+}
+
+func TestApiKeyEnsureBootstrapKey(t *testing.T) {
+	apiKeyService := service.NewApiKeyService(NewFakeApiKeyRepository())
+	token := "pak_b00757a9_6c3f0e9d2a7b4c18"
+
+	assert.Nil(t, apiKeyService.EnsureBootstrapKey(token))
+	assert.Nil(t, apiKeyService.EnsureBootstrapKey(token))
+	assert.Equal(t, 1, len(apiKeyService.GetAll()))
+
+	principal, err := apiKeyService.Authenticate(token)
+	assert.Nil(t, err)
+	assert.True(t, principal.HasScope(domain.ScopeProductsDelete))
+
+	assert.NotNil(t, apiKeyService.EnsureBootstrapKey("admin"))
+}
@@ -0,0 +1,110 @@
+package srvc
+
+import (
+	"encoding/json"
+	"github.com/erkindilekci/product-api/pkg/controller/middleware"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/erkindilekci/product-api/pkg/service/dto"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuditEvents(t *testing.T) {
+	initialData := []domain.Product{
+		{Id: 1, Name: "Pixel 8", Price: 700.0, Discount: 0.0, Store: "Google"},
+	}
+	productRepository := NewFakeProductRepository(initialData)
+	productService := service.NewProductService(productRepository, service.NewRoleBindingService(NewFakeRoleBindingRepository(globalRoleBindings("jane.doe"))), testLogger)
+	auditService := service.NewAuditService(productRepository.AuditEvents())
+
+	ctx := principalContext("jane.doe", domain.ScopeAdmin)
+	ctx = domain.ContextWithRequestMetadata(ctx, domain.RequestMetadata{RequestId: "req-1", ClientIp: "10.0.0.1"})
+
+	assert.Nil(t, productService.UpdatePrice(ctx, 1, 650.0))
+	assert.Nil(t, productService.Add(ctx, dto.ProductCreate{Name: "Pixel Watch", Price: 350.0, Store: "Google"}))
+	assert.Nil(t, productService.DeleteById(ctx, 1))
+	assert.NotNil(t, productService.UpdatePrice(ctx, 1, 600.0))
+
+	t.Run("AllMutationsRecorded", func(t *testing.T) {
+		auditEvents, err := auditService.GetEvents(testContext, domain.AuditEventFilter{})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(auditEvents))
+		assert.Equal(t, domain.AuditActionProductPriceChanged, auditEvents[0].Action)
+		assert.Equal(t, domain.AuditActionProductCreated, auditEvents[1].Action)
+		assert.Equal(t, domain.AuditActionProductDeleted, auditEvents[2].Action)
+	})
+
+	t.Run("NewestFirst", func(t *testing.T) {
+		auditEvents, err := auditService.GetEvents(testContext, domain.AuditEventFilter{Limit: 2, Descending: true})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(auditEvents))
+		assert.Equal(t, domain.AuditActionProductDeleted, auditEvents[0].Action)
+		assert.Equal(t, domain.AuditActionProductCreated, auditEvents[1].Action)
+	})
+
+	t.Run("EventContent", func(t *testing.T) {
+		auditEvents, _ := auditService.GetEvents(testContext, domain.AuditEventFilter{ProductId: 1, Limit: 1})
+		assert.Equal(t, 1, len(auditEvents))
+
+		priceChange := auditEvents[0]
+		assert.Equal(t, "jane.doe", priceChange.Actor)
+		assert.Equal(t, "req-1", priceChange.RequestId)
+		assert.Equal(t, "10.0.0.1", priceChange.ClientIp)
+
+		var before, after map[string]interface{}
+		assert.Nil(t, json.Unmarshal(priceChange.Before, &before))
+		assert.Nil(t, json.Unmarshal(priceChange.After, &after))
+		assert.Equal(t, 700.0, before["price"])
+		assert.Equal(t, 650.0, after["price"])
+	})
+
+	t.Run("FilterByActor", func(t *testing.T) {
+		auditEvents, _ := auditService.GetEvents(testContext, domain.AuditEventFilter{Actor: "john.doe"})
+		assert.Equal(t, 0, len(auditEvents))
+	})
+
+	t.Run("InvalidTimeRange", func(t *testing.T) {
+		now := time.Now()
+		_, err := auditService.GetEvents(testContext, domain.AuditEventFilter{From: now, To: now.Add(-time.Hour)})
+		assert.NotNil(t, err)
+	})
+}
+
+func TestAuditClientIp(t *testing.T) {
+	clientIpOf := func(e *echo.Echo, remoteAddr string, forwardedFor string) string {
+		var clientIp string
+		e.Use(middleware.RequestMetadata())
+		e.GET("/api/v1/products", func(c echo.Context) error {
+			clientIp = domain.RequestMetadataFromContext(c.Request().Context()).ClientIp
+			return c.NoContent(http.StatusOK)
+		})
+
+		httpRequest := httptest.NewRequest(http.MethodGet, "/api/v1/products", nil)
+		httpRequest.RemoteAddr = remoteAddr
+		httpRequest.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+		httpRequest.Header.Set(echo.HeaderXRealIP, forwardedFor)
+		e.ServeHTTP(httptest.NewRecorder(), httpRequest)
+		return clientIp
+	}
+
+	t.Run("WithoutIpExtractor", func(t *testing.T) {
+		assert.Equal(t, "203.0.113.7", clientIpOf(echo.New(), "203.0.113.7:40000", "198.51.100.1"))
+	})
+
+	t.Run("TrustedProxy", func(t *testing.T) {
+		_, proxyNetwork, _ := net.ParseCIDR("10.0.0.0/8")
+		e := echo.New()
+		e.IPExtractor = middleware.ClientIpExtractor([]*net.IPNet{proxyNetwork})
+		assert.Equal(t, "198.51.100.1", clientIpOf(e, "10.0.0.5:40000", "198.51.100.1"))
+
+		e = echo.New()
+		e.IPExtractor = middleware.ClientIpExtractor([]*net.IPNet{proxyNetwork})
+		assert.Equal(t, "203.0.113.7", clientIpOf(e, "203.0.113.7:40000", "198.51.100.1"))
+	})
+}
@@ -0,0 +1,42 @@
+package srvc
+
+import (
+	"context"
+	"github.com/erkindilekci/product-api/pkg/common/postgresql"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func unreachableDatabaseConfig(maxAttempts int) postgresql.Config {
+	return postgresql.Config{
+		Host:                  "127.0.0.1",
+		Port:                  "1",
+		UserName:              "postgres",
+		Password:              "password",
+		DbName:                "productapp",
+		MaxConnections:        "1",
+		MaxConnectionIdleTime: "30s",
+		ConnectRetry: postgresql.RetryConfig{
+			MaxAttempts:    maxAttempts,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     2 * time.Millisecond,
+		},
+	}
+}
+
+func TestGetConnectionPoolRetry(t *testing.T) {
+	t.Run("GivesUpAfterMaxAttempts", func(t *testing.T) {
+		dbPool, err := postgresql.GetConnectionPool(testContext, unreachableDatabaseConfig(3), testLogger)
+		assert.Nil(t, dbPool)
+		assert.ErrorContains(t, err, "after 3 attempts")
+	})
+
+	t.Run("StopsWhenCancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(testContext)
+		cancel()
+
+		_, err := postgresql.GetConnectionPool(ctx, unreachableDatabaseConfig(100), testLogger)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,67 @@
+package srvc
+
+import (
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"time"
+)
+
+type FakeApiKeyRepository struct {
+	apiKeys []domain.ApiKey
+}
+
+func NewFakeApiKeyRepository() repository.IApiKeyRepository {
+	return &FakeApiKeyRepository{}
+}
+
+func (repository *FakeApiKeyRepository) AddApiKey(apiKey domain.ApiKey) (domain.ApiKey, error) {
+	apiKey.Id = int64(len(repository.apiKeys) + 1)
+	apiKey.CreatedAt = time.Now()
+	repository.apiKeys = append(repository.apiKeys, apiKey)
+	return apiKey, nil
+}
+
+func (repository *FakeApiKeyRepository) GetAllApiKeys() []domain.ApiKey {
+	return repository.apiKeys
+}
+
+func (repository *FakeApiKeyRepository) GetApiKeyById(apiKeyId int64) (domain.ApiKey, error) {
+	for _, apiKey := range repository.apiKeys {
+		if apiKey.Id == apiKeyId {
+			return apiKey, nil
+		}
+	}
+	return domain.ApiKey{}, fmt.Errorf("%w with the id %d", domain.ErrApiKeyNotFound, apiKeyId)
+}
+
+func (repository *FakeApiKeyRepository) GetApiKeyByPrefix(prefix string) (domain.ApiKey, error) {
+	for _, apiKey := range repository.apiKeys {
+		if apiKey.Prefix == prefix {
+			return apiKey, nil
+		}
+	}
+	return domain.ApiKey{}, fmt.Errorf("%w with the prefix %s", domain.ErrApiKeyNotFound, prefix)
+}
+
+func (repository *FakeApiKeyRepository) UpdateApiKeySecret(apiKeyId int64, prefix string, keyHash string) error {
+	for i, apiKey := range repository.apiKeys {
+		if apiKey.Id == apiKeyId && !apiKey.Revoked() {
+			repository.apiKeys[i].Prefix = prefix
+			repository.apiKeys[i].KeyHash = keyHash
+			return nil
+		}
+	}
+	return fmt.Errorf("%w with the id %d that is still active", domain.ErrApiKeyNotFound, apiKeyId)
+}
+
+func (repository *FakeApiKeyRepository) RevokeApiKeyById(apiKeyId int64) error {
+	for i, apiKey := range repository.apiKeys {
+		if apiKey.Id == apiKeyId && !apiKey.Revoked() {
+			revokedAt := time.Now()
+			repository.apiKeys[i].RevokedAt = &revokedAt
+			return nil
+		}
+	}
+	return fmt.Errorf("%w with the id %d that is still active", domain.ErrApiKeyNotFound, apiKeyId)
+}
@@ -0,0 +1,86 @@
+package srvc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"time"
+)
+
+type FakeAuditRepository struct {
+	auditEvents []domain.AuditEvent
+}
+
+func (repository *FakeAuditRepository) AddAuditEvent(ctx context.Context, auditEvent domain.AuditEvent) error {
+	auditEvent.Id = int64(len(repository.auditEvents) + 1)
+	auditEvent.CreatedAt = time.Now()
+	repository.auditEvents = append(repository.auditEvents, auditEvent)
+	return nil
+}
+
+func (repository *FakeAuditRepository) GetAuditEvents(ctx context.Context, filter domain.AuditEventFilter) ([]domain.AuditEvent, error) {
+	candidates := repository.auditEvents
+	if filter.Descending {
+		candidates = make([]domain.AuditEvent, len(repository.auditEvents))
+		for i, auditEvent := range repository.auditEvents {
+			candidates[len(candidates)-1-i] = auditEvent
+		}
+	}
+
+	var auditEvents []domain.AuditEvent
+	for _, auditEvent := range candidates {
+		if !matchesAuditEventFilter(auditEvent, filter) {
+			continue
+		}
+		if len(auditEvents) == filter.Limit {
+			break
+		}
+		auditEvents = append(auditEvents, auditEvent)
+	}
+	return auditEvents, nil
+}
+
+func (repository *FakeAuditRepository) CountAuditEvents(ctx context.Context, filter domain.AuditEventFilter) (int64, error) {
+	var count int64
+	for _, auditEvent := range repository.auditEvents {
+		if matchesAuditEventFilter(auditEvent, filter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func matchesAuditEventFilter(auditEvent domain.AuditEvent, filter domain.AuditEventFilter) bool {
+	if filter.ProductId != 0 && auditEvent.ProductId != filter.ProductId {
+		return false
+	}
+	if filter.Actor != "" && auditEvent.Actor != filter.Actor {
+		return false
+	}
+	if !filter.From.IsZero() && auditEvent.CreatedAt.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && !auditEvent.CreatedAt.Before(filter.To) {
+		return false
+	}
+	if filter.BeforeId != 0 && auditEvent.Id >= filter.BeforeId {
+		return false
+	}
+	if filter.PriceChangesOnly {
+		if auditEvent.After == nil {
+			return false
+		}
+		if auditEvent.Before != nil && snapshotPrice(auditEvent.Before) == snapshotPrice(auditEvent.After) {
+			return false
+		}
+	}
+	return true
+}
+
+func snapshotPrice(snapshot json.RawMessage) float32 {
+	var product struct {
+		Price float32 `json:"price"`
+	}
+	_ = json.Unmarshal(snapshot, &product)
+	return product.Price
+}
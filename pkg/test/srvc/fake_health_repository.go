@@ -0,0 +1,57 @@
+package srvc
+
+import (
+	"context"
+	"github.com/erkindilekci/product-api/pkg/repository"
+)
+
+type FakeHealthRepository struct {
+	pingErr        error
+	existingTables []string
+	missingColumns []string
+}
+
+func NewFakeHealthRepository(pingErr error, existingTables []string, missingColumns ...string) repository.IHealthRepository {
+	return &FakeHealthRepository{pingErr, existingTables, missingColumns}
+}
+
+func (repository *FakeHealthRepository) Ping(ctx context.Context) error {
+	return repository.pingErr
+}
+
+func (repository *FakeHealthRepository) GetMissingTables(ctx context.Context, tables []string) ([]string, error) {
+	if repository.pingErr != nil {
+		return nil, repository.pingErr
+	}
+
+	var missingTables []string
+	for _, table := range tables {
+		if !containsTable(repository.existingTables, table) {
+			missingTables = append(missingTables, table)
+		}
+	}
+	return missingTables, nil
+}
+
+func (repository *FakeHealthRepository) GetMissingColumns(ctx context.Context, columns []string) ([]string, error) {
+	if repository.pingErr != nil {
+		return nil, repository.pingErr
+	}
+
+	var missingColumns []string
+	for _, column := range columns {
+		if containsTable(repository.missingColumns, column) {
+			missingColumns = append(missingColumns, column)
+		}
+	}
+	return missingColumns, nil
+}
+
+func containsTable(tables []string, table string) bool {
+	for _, existing := range tables {
+		if existing == table {
+			return true
+		}
+	}
+	return false
+}
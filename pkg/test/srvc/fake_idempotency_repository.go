@@ -0,0 +1,61 @@
+package srvc
+
+import (
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"time"
+)
+
+type FakeIdempotencyRepository struct {
+	records map[string]domain.IdempotencyRecord
+}
+
+func NewFakeIdempotencyRepository() repository.IIdempotencyRepository {
+	return &FakeIdempotencyRepository{map[string]domain.IdempotencyRecord{}}
+}
+
+func (repository *FakeIdempotencyRepository) ReserveKey(key string, requestHash string, expiresAt time.Time) (bool, error) {
+	record, found := repository.records[key]
+	if found && record.ExpiresAt.After(time.Now()) {
+		return false, nil
+	}
+	repository.records[key] = domain.IdempotencyRecord{Key: key, RequestHash: requestHash, CreatedAt: time.Now(), ExpiresAt: expiresAt}
+	return true, nil
+}
+
+func (repository *FakeIdempotencyRepository) GetByKey(key string) (domain.IdempotencyRecord, error) {
+	record, found := repository.records[key]
+	if !found {
+		return domain.IdempotencyRecord{}, fmt.Errorf("no idempotency record found with the key %s", key)
+	}
+	return record, nil
+}
+
+func (repository *FakeIdempotencyRepository) SaveResponse(key string, statusCode int, contentType string, responseBody []byte) error {
+	record, found := repository.records[key]
+	if !found {
+		return fmt.Errorf("no idempotency record found with the key %s", key)
+	}
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.ResponseBody = responseBody
+	repository.records[key] = record
+	return nil
+}
+
+func (repository *FakeIdempotencyRepository) DeleteKey(key string) error {
+	delete(repository.records, key)
+	return nil
+}
+
+func (repository *FakeIdempotencyRepository) DeleteExpiredKeys() (int64, error) {
+	var deleted int64
+	for key, record := range repository.records {
+		if record.ExpiresAt.Before(time.Now()) {
+			delete(repository.records, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,145 @@
+package srvc
+
+import (
+	"context"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FakeOutboxRepository is safe for concurrent use, since the product stream
+// polls it in the background.
+type FakeOutboxRepository struct {
+	mutex        sync.Mutex
+	outboxEvents []domain.OutboxEvent
+	lastId       int64
+	// xmin and xmax are the transaction bounds; tests set them to hold
+	// back the events after a skipped id.
+	xmin int64
+	xmax int64
+}
+
+func (repository *FakeOutboxRepository) count() int {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	return len(repository.outboxEvents)
+}
+
+// truncate drops the events after the first count, as a rollback would.
+func (repository *FakeOutboxRepository) truncate(count int) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	repository.outboxEvents = repository.outboxEvents[:count]
+}
+
+// skipId hands out the next id without storing an event, as a transaction
+// that is still running or rolled back would.
+func (repository *FakeOutboxRepository) skipId() int64 {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	repository.lastId++
+	return repository.lastId
+}
+
+func (repository *FakeOutboxRepository) setTransactionBounds(xmin int64, xmax int64) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	repository.xmin, repository.xmax = xmin, xmax
+}
+
+func (repository *FakeOutboxRepository) AddOutboxEvent(ctx context.Context, outboxEvent domain.OutboxEvent) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	if outboxEvent.Id == 0 {
+		repository.lastId++
+		outboxEvent.Id = repository.lastId
+	}
+	outboxEvent.CreatedAt = time.Now()
+	outboxEvent.NextAttemptAt = outboxEvent.CreatedAt
+	i := sort.Search(len(repository.outboxEvents), func(i int) bool { return repository.outboxEvents[i].Id > outboxEvent.Id })
+	repository.outboxEvents = slices.Insert(repository.outboxEvents, i, outboxEvent)
+	return nil
+}
+
+func (repository *FakeOutboxRepository) ClaimNextOutboxEvents(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]domain.OutboxEvent, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	var claimed []domain.OutboxEvent
+	seenProducts := map[int64]bool{}
+	for i, outboxEvent := range repository.outboxEvents {
+		if outboxEvent.PublishedAt != nil || seenProducts[outboxEvent.ProductId] {
+			continue
+		}
+		seenProducts[outboxEvent.ProductId] = true
+		if len(claimed) < limit && !outboxEvent.NextAttemptAt.After(now) {
+			repository.outboxEvents[i].NextAttemptAt = leaseUntil
+			claimed = append(claimed, repository.outboxEvents[i])
+		}
+	}
+	return claimed, nil
+}
+
+func (repository *FakeOutboxRepository) UpdateOutboxEvent(ctx context.Context, outboxEvent domain.OutboxEvent) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	for i, existing := range repository.outboxEvents {
+		if existing.Id == outboxEvent.Id {
+			repository.outboxEvents[i] = outboxEvent
+			return nil
+		}
+	}
+	return fmt.Errorf("no outbox event found with the id %d", outboxEvent.Id)
+}
+
+func (repository *FakeOutboxRepository) DeletePublishedOutboxEvents(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	var kept []domain.OutboxEvent
+	for _, outboxEvent := range repository.outboxEvents {
+		if outboxEvent.PublishedAt == nil || !outboxEvent.PublishedAt.Before(publishedBefore) {
+			kept = append(kept, outboxEvent)
+		}
+	}
+	deleted := int64(len(repository.outboxEvents) - len(kept))
+	repository.outboxEvents = kept
+	return deleted, nil
+}
+
+func (repository *FakeOutboxRepository) GetOutboxEventsAfter(ctx context.Context, afterId int64, limit int) ([]domain.OutboxEvent, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	var outboxEvents []domain.OutboxEvent
+	for _, outboxEvent := range repository.outboxEvents {
+		if outboxEvent.Id > afterId && len(outboxEvents) < limit {
+			outboxEvents = append(outboxEvents, outboxEvent)
+		}
+	}
+	return outboxEvents, nil
+}
+
+func (repository *FakeOutboxRepository) GetLastOutboxEventId(ctx context.Context) (int64, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	if len(repository.outboxEvents) == 0 {
+		return 0, nil
+	}
+	return repository.outboxEvents[len(repository.outboxEvents)-1].Id, nil
+}
+
+func (repository *FakeOutboxRepository) GetFirstOutboxEventId(ctx context.Context) (int64, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	if len(repository.outboxEvents) == 0 {
+		return 0, nil
+	}
+	return repository.outboxEvents[0].Id, nil
+}
+
+func (repository *FakeOutboxRepository) GetTransactionBounds(ctx context.Context) (int64, int64, error) {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+	return repository.xmin, repository.xmax, nil
+}
@@ -0,0 +1,33 @@
+package srvc
+
+import (
+	"context"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"slices"
+)
+
+// fakeProductChange is a change with the store of its product, which a
+// tombstone no longer carries.
+type fakeProductChange struct {
+	store string
+	domain.ProductChange
+}
+
+// FakeProductChangeRepository serves changes, which must be in sequence
+// order, regardless of how long ago they were made.
+type FakeProductChangeRepository struct {
+	changes []fakeProductChange
+}
+
+func (repository *FakeProductChangeRepository) GetProductChanges(ctx context.Context, since int64, limit int, stores []string) ([]domain.ProductChange, error) {
+	var changes []domain.ProductChange
+	for _, change := range repository.changes {
+		if stores != nil && !slices.Contains(stores, change.store) {
+			continue
+		}
+		if change.Sequence > since && len(changes) < limit {
+			changes = append(changes, change.ProductChange)
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,64 @@
+package srvc
+
+import (
+	"context"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+)
+
+// FakeProductNotificationRepository hands every connection it opens to the
+// test through connections.
+type FakeProductNotificationRepository struct {
+	connections chan *FakeProductNotificationConnection
+}
+
+func NewFakeProductNotificationRepository() *FakeProductNotificationRepository {
+	return &FakeProductNotificationRepository{make(chan *FakeProductNotificationConnection)}
+}
+
+func (repository *FakeProductNotificationRepository) Listen(ctx context.Context) (repository.IProductNotificationConnection, error) {
+	connection := &FakeProductNotificationConnection{
+		notifications: make(chan domain.ProductNotification),
+		broken:        make(chan error),
+		pings:         make(chan error),
+	}
+	select {
+	case repository.connections <- connection:
+		return connection, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// FakeProductNotificationConnection delivers what is sent on notifications
+// and fails with what is sent on broken. Pings wait for their result on
+// pings.
+type FakeProductNotificationConnection struct {
+	notifications chan domain.ProductNotification
+	broken        chan error
+	pings         chan error
+}
+
+func (connection *FakeProductNotificationConnection) WaitForNotification(ctx context.Context) (domain.ProductNotification, error) {
+	select {
+	case notification := <-connection.notifications:
+		return notification, nil
+	case err := <-connection.broken:
+		return domain.ProductNotification{}, err
+	case <-ctx.Done():
+		return domain.ProductNotification{}, ctx.Err()
+	}
+}
+
+func (connection *FakeProductNotificationConnection) Ping(ctx context.Context) error {
+	select {
+	case err := <-connection.pings:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (connection *FakeProductNotificationConnection) Close(ctx context.Context) error {
+	return nil
+}
@@ -1,24 +1,30 @@
 package srvc
 
 import (
+	"context"
 	"fmt"
 	"github.com/erkindilekci/product-api/pkg/domain"
 	"github.com/erkindilekci/product-api/pkg/repository"
+	"slices"
+	"sort"
+	"strings"
 )
 
 type FakeProductRepository struct {
-	products []domain.Product
+	products    []domain.Product
+	auditEvents *FakeAuditRepository
+	outbox      *FakeOutboxRepository
 }
 
 func NewFakeProductRepository(initialProducts []domain.Product) repository.IProductRepository {
-	return &FakeProductRepository{initialProducts}
+	return &FakeProductRepository{initialProducts, &FakeAuditRepository{}, &FakeOutboxRepository{}}
 }
 
-func (repository *FakeProductRepository) GetAllProducts() []domain.Product {
+func (repository *FakeProductRepository) GetAllProducts(ctx context.Context) []domain.Product {
 	return repository.products
 }
 
-func (repository *FakeProductRepository) GetProductsByStore(store string) []domain.Product {
+func (repository *FakeProductRepository) GetProductsByStore(ctx context.Context, store string) []domain.Product {
 	var products []domain.Product
 	for _, product := range repository.products {
 		if product.Store == store {
@@ -28,37 +34,192 @@ func (repository *FakeProductRepository) GetProductsByStore(store string) []doma
 	return products
 }
 
-func (repository *FakeProductRepository) AddProduct(product domain.Product) error {
-	product.Id = int64(len(repository.products))
+func (repository *FakeProductRepository) GetProductPage(ctx context.Context, filter domain.ProductFilter, afterId int64, limit int) ([]domain.Product, error) {
+	products := []domain.Product{}
+	for _, product := range repository.matchingProducts(filter, afterId) {
+		if len(products) == limit {
+			break
+		}
+		products = append(products, product)
+	}
+	return products, nil
+}
+
+func (repository *FakeProductRepository) GetProductPagesByStore(ctx context.Context, filter domain.ProductFilter, afterId int64, limit int) (map[string][]domain.Product, error) {
+	pages := map[string][]domain.Product{}
+	for _, product := range repository.matchingProducts(filter, afterId) {
+		if len(pages[product.Store]) < limit {
+			pages[product.Store] = append(pages[product.Store], product)
+		}
+	}
+	return pages, nil
+}
+
+func (repository *FakeProductRepository) CountProductsByStore(ctx context.Context, filter domain.ProductFilter) (map[string]int64, error) {
+	counts := map[string]int64{}
+	for _, product := range repository.matchingProducts(filter, 0) {
+		counts[product.Store]++
+	}
+	return counts, nil
+}
+
+// matchingProducts returns the products matching filter with an id above
+// afterId, ordered by id.
+func (repository *FakeProductRepository) matchingProducts(filter domain.ProductFilter, afterId int64) []domain.Product {
+	var products []domain.Product
+	for _, product := range repository.products {
+		if product.Id <= afterId {
+			continue
+		}
+		if filter.Stores != nil && !slices.Contains(filter.Stores, product.Store) {
+			continue
+		}
+		if filter.Sku != "" && product.Sku != filter.Sku {
+			continue
+		}
+		if filter.NameContains != "" && !strings.Contains(strings.ToLower(product.Name), strings.ToLower(filter.NameContains)) {
+			continue
+		}
+		if filter.MinPrice != nil && product.Price < *filter.MinPrice {
+			continue
+		}
+		if filter.MaxPrice != nil && product.Price > *filter.MaxPrice {
+			continue
+		}
+		products = append(products, product)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].Id < products[j].Id })
+	return products
+}
+
+func (repository *FakeProductRepository) AddProduct(ctx context.Context, product domain.Product) (int64, error) {
+	var maxId int64
+	for _, existing := range repository.products {
+		if isSameStoreProduct(existing, product) {
+			return 0, domain.ErrProductAlreadyExists
+		}
+		if existing.Id > maxId {
+			maxId = existing.Id
+		}
+	}
+	product.Id = maxId + 1
+	product.ExternalIds = nil
 	repository.products = append(repository.products, product)
-	return nil
+	return product.Id, nil
 }
 
-func (repository *FakeProductRepository) GetProductById(productId int64) (domain.Product, error) {
+func (repository *FakeProductRepository) AddExternalIds(ctx context.Context, productId int64, externalIds []domain.ExternalId) error {
+	for _, externalId := range externalIds {
+		if _, err := repository.GetProductByExternalId(ctx, externalId); err == nil {
+			return domain.ErrExternalIdAlreadyExists
+		}
+	}
+	for i, product := range repository.products {
+		if product.Id == productId {
+			repository.products[i].ExternalIds = append(product.ExternalIds, externalIds...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w with the id %d", domain.ErrProductNotFound, productId)
+}
+
+func (repository *FakeProductRepository) GetProductByExternalId(ctx context.Context, externalId domain.ExternalId) (domain.Product, error) {
+	for _, product := range repository.products {
+		for _, productExternalId := range product.ExternalIds {
+			if productExternalId == externalId {
+				return product, nil
+			}
+		}
+	}
+	return domain.Product{}, fmt.Errorf("%w with the external id %s/%s", domain.ErrProductNotFound, externalId.System, externalId.Id)
+}
+
+func (repository *FakeProductRepository) UpsertProductBySku(ctx context.Context, product domain.Product) (domain.Product, bool, error) {
+	for i, existing := range repository.products {
+		if existing.Store == product.Store && existing.Sku == product.Sku {
+			product.Id = existing.Id
+			product.ExternalIds = existing.ExternalIds
+			repository.products[i] = product
+			return product, false, nil
+		}
+	}
+	if _, err := repository.AddProduct(ctx, product); err != nil {
+		return domain.Product{}, false, err
+	}
+	return repository.products[len(repository.products)-1], true, nil
+}
+
+func isSameStoreProduct(existing domain.Product, product domain.Product) bool {
+	if existing.Store != product.Store {
+		return false
+	}
+	if product.Sku != "" && existing.Sku == product.Sku {
+		return true
+	}
+	return strings.EqualFold(existing.Name, product.Name)
+}
+
+func (repository *FakeProductRepository) GetProductById(ctx context.Context, productId int64) (domain.Product, error) {
 	for _, product := range repository.products {
 		if product.Id == productId {
 			return product, nil
 		}
 	}
-	return domain.Product{}, fmt.Errorf("no product found with the id %d", productId)
+	return domain.Product{}, fmt.Errorf("%w with the id %d", domain.ErrProductNotFound, productId)
 }
 
-func (repository *FakeProductRepository) DeleteProductById(productId int64) error {
+func (repository *FakeProductRepository) GetProductBySkuForUpdate(ctx context.Context, store string, sku string) (domain.Product, error) {
+	for _, product := range repository.products {
+		if product.Store == store && product.Sku == sku {
+			return product, nil
+		}
+	}
+	return domain.Product{}, fmt.Errorf("%w with the sku %s in store %s", domain.ErrProductNotFound, sku, store)
+}
+
+func (repository *FakeProductRepository) GetProductByIdForUpdate(ctx context.Context, productId int64) (domain.Product, error) {
+	return repository.GetProductById(ctx, productId)
+}
+
+func (repository *FakeProductRepository) DeleteProductById(ctx context.Context, productId int64) error {
 	for i, product := range repository.products {
 		if product.Id == productId {
 			repository.products = append(repository.products[:i], repository.products[i+1:]...)
 			return nil
 		}
 	}
-	return fmt.Errorf("product with id %d not found in repository", productId)
+	return fmt.Errorf("%w with the id %d", domain.ErrProductNotFound, productId)
 }
 
-func (repository *FakeProductRepository) UpdatePriceById(productId int64, newPrice float32) error {
+func (repository *FakeProductRepository) UpdatePriceById(ctx context.Context, productId int64, newPrice float32) error {
 	for i, product := range repository.products {
 		if product.Id == productId {
 			repository.products[i].Price = newPrice
 			return nil
 		}
 	}
-	return fmt.Errorf("no product found with the id %d", productId)
+	return fmt.Errorf("%w with the id %d", domain.ErrProductNotFound, productId)
+}
+
+func (repository *FakeProductRepository) WithTx(ctx context.Context, fn func(repository repository.IProductRepository) error) error {
+	snapshot := make([]domain.Product, len(repository.products))
+	copy(snapshot, repository.products)
+	auditEventCount := len(repository.auditEvents.auditEvents)
+	outboxEventCount := repository.outbox.count()
+
+	if err := fn(repository); err != nil {
+		repository.products = snapshot
+		repository.auditEvents.auditEvents = repository.auditEvents.auditEvents[:auditEventCount]
+		repository.outbox.truncate(outboxEventCount)
+		return err
+	}
+	return nil
+}
+
+func (repository *FakeProductRepository) AuditEvents() repository.IAuditRepository {
+	return repository.auditEvents
+}
+
+func (repository *FakeProductRepository) Outbox() repository.IOutboxRepository {
+	return repository.outbox
 }
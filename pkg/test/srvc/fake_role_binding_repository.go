@@ -0,0 +1,52 @@
+package srvc
+
+import (
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"time"
+)
+
+type FakeRoleBindingRepository struct {
+	roleBindings []domain.RoleBinding
+}
+
+func NewFakeRoleBindingRepository(initialRoleBindings []domain.RoleBinding) repository.IRoleBindingRepository {
+	return &FakeRoleBindingRepository{initialRoleBindings}
+}
+
+func (repository *FakeRoleBindingRepository) AddRoleBinding(roleBinding domain.RoleBinding) (domain.RoleBinding, error) {
+	for _, existing := range repository.roleBindings {
+		if existing.Subject == roleBinding.Subject && existing.Role == roleBinding.Role && existing.Store == roleBinding.Store {
+			return domain.RoleBinding{}, domain.ErrRoleBindingAlreadyExists
+		}
+	}
+	roleBinding.Id = int64(len(repository.roleBindings) + 1)
+	roleBinding.CreatedAt = time.Now()
+	repository.roleBindings = append(repository.roleBindings, roleBinding)
+	return roleBinding, nil
+}
+
+func (repository *FakeRoleBindingRepository) GetAllRoleBindings() []domain.RoleBinding {
+	return repository.roleBindings
+}
+
+func (repository *FakeRoleBindingRepository) GetRoleBindingsBySubject(subject string) ([]domain.RoleBinding, error) {
+	var roleBindings []domain.RoleBinding
+	for _, roleBinding := range repository.roleBindings {
+		if roleBinding.Subject == subject {
+			roleBindings = append(roleBindings, roleBinding)
+		}
+	}
+	return roleBindings, nil
+}
+
+func (repository *FakeRoleBindingRepository) DeleteRoleBindingById(roleBindingId int64) error {
+	for i, roleBinding := range repository.roleBindings {
+		if roleBinding.Id == roleBindingId {
+			repository.roleBindings = append(repository.roleBindings[:i], repository.roleBindings[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w with the id %d", domain.ErrRoleBindingNotFound, roleBindingId)
+}
@@ -0,0 +1,121 @@
+package srvc
+
+import (
+	"context"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"sort"
+	"time"
+)
+
+type FakeWebhookRepository struct {
+	subscriptions []domain.WebhookSubscription
+	deliveries    []domain.WebhookDelivery
+}
+
+func NewFakeWebhookRepository() repository.IWebhookRepository {
+	return &FakeWebhookRepository{}
+}
+
+func (repository *FakeWebhookRepository) AddSubscription(ctx context.Context, subscription domain.WebhookSubscription) (domain.WebhookSubscription, error) {
+	subscription.Id = int64(len(repository.subscriptions) + 1)
+	subscription.CreatedAt = time.Now()
+	repository.subscriptions = append(repository.subscriptions, subscription)
+	return subscription, nil
+}
+
+func (repository *FakeWebhookRepository) GetAllSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	return repository.subscriptions, nil
+}
+
+func (repository *FakeWebhookRepository) GetSubscriptionById(ctx context.Context, subscriptionId int64) (domain.WebhookSubscription, error) {
+	for _, subscription := range repository.subscriptions {
+		if subscription.Id == subscriptionId {
+			return subscription, nil
+		}
+	}
+	return domain.WebhookSubscription{}, fmt.Errorf("%w with the id %d", domain.ErrWebhookSubscriptionNotFound, subscriptionId)
+}
+
+func (repository *FakeWebhookRepository) DeleteSubscriptionById(ctx context.Context, subscriptionId int64) error {
+	for i, subscription := range repository.subscriptions {
+		if subscription.Id == subscriptionId {
+			repository.subscriptions = append(repository.subscriptions[:i], repository.subscriptions[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w with the id %d", domain.ErrWebhookSubscriptionNotFound, subscriptionId)
+}
+
+func (repository *FakeWebhookRepository) AddDeliveries(ctx context.Context, deliveries []domain.WebhookDelivery) error {
+	for _, delivery := range deliveries {
+		if repository.hasDelivery(delivery.SubscriptionId, delivery.EventId) {
+			continue
+		}
+		delivery.Id = int64(len(repository.deliveries) + 1)
+		delivery.CreatedAt = time.Now()
+		repository.deliveries = append(repository.deliveries, delivery)
+	}
+	return nil
+}
+
+func (repository *FakeWebhookRepository) hasDelivery(subscriptionId int64, eventId string) bool {
+	for _, delivery := range repository.deliveries {
+		if delivery.SubscriptionId == subscriptionId && delivery.EventId == eventId {
+			return true
+		}
+	}
+	return false
+}
+
+func (repository *FakeWebhookRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]domain.WebhookDelivery, error) {
+	var claimed []domain.WebhookDelivery
+	for i, delivery := range repository.deliveries {
+		if len(claimed) == limit {
+			break
+		}
+		if delivery.Status == domain.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) {
+			repository.deliveries[i].NextAttemptAt = leaseUntil
+			claimed = append(claimed, repository.deliveries[i])
+		}
+	}
+	return claimed, nil
+}
+
+func (repository *FakeWebhookRepository) GetDeliveries(ctx context.Context, filter domain.WebhookDeliveryFilter) ([]domain.WebhookDelivery, error) {
+	var deliveries []domain.WebhookDelivery
+	for _, delivery := range repository.deliveries {
+		if filter.SubscriptionId != 0 && delivery.SubscriptionId != filter.SubscriptionId {
+			continue
+		}
+		if filter.Status != "" && delivery.Status != filter.Status {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].Id > deliveries[j].Id })
+	if len(deliveries) > filter.Limit {
+		deliveries = deliveries[:filter.Limit]
+	}
+	return deliveries, nil
+}
+
+func (repository *FakeWebhookRepository) GetDeliveryById(ctx context.Context, deliveryId int64) (domain.WebhookDelivery, error) {
+	for _, delivery := range repository.deliveries {
+		if delivery.Id == deliveryId {
+			return delivery, nil
+		}
+	}
+	return domain.WebhookDelivery{}, fmt.Errorf("%w with the id %d", domain.ErrWebhookDeliveryNotFound, deliveryId)
+}
+
+func (repository *FakeWebhookRepository) UpdateDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	for i, existing := range repository.deliveries {
+		if existing.Id == delivery.Id {
+			repository.deliveries[i] = delivery
+			return nil
+		}
+	}
+	return fmt.Errorf("%w with the id %d", domain.ErrWebhookDeliveryNotFound, delivery.Id)
+}
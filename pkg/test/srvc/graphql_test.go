@@ -0,0 +1,277 @@
+package srvc
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/erkindilekci/product-api/pkg/common/metrics"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/graphqlapi"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func newGraphqlTestServer(t *testing.T) *graphqlapi.Server {
+	initialData := []domain.Product{
+		{Id: 1, Name: "Kindle", Price: 100.0, Store: "Amazon"},
+		{Id: 2, Name: "Echo Dot", Price: 50.0, Store: "Amazon"},
+		{Id: 3, Name: "Surface Pro", Price: 1200.0, Store: "Microsoft"},
+		{Id: 4, Name: "Pixel 8", Price: 700.0, Store: "Google", Sku: "PX8"},
+	}
+	productRepository := NewFakeProductRepository(initialData)
+	productService := service.NewProductService(productRepository, service.NewRoleBindingService(NewFakeRoleBindingRepository(globalRoleBindings("jane.doe"))), testLogger)
+	auditService := service.NewAuditService(productRepository.AuditEvents())
+
+	server, err := graphqlapi.NewServer(productService, auditService, graphqlapi.Limits{MaxDepth: 6, MaxComplexity: 200}, testLogger)
+	assert.Nil(t, err)
+	return server
+}
+
+// graphqlData round-trips the data of result through JSON, so that it can be
+// compared like a client would see it.
+func graphqlData(t *testing.T, result *graphql.Result) map[string]interface{} {
+	assert.Empty(t, result.Errors)
+	encoded, err := json.Marshal(result.Data)
+	assert.Nil(t, err)
+	var data map[string]interface{}
+	assert.Nil(t, json.Unmarshal(encoded, &data))
+	return data
+}
+
+func TestGraphqlQueries(t *testing.T) {
+	server := newGraphqlTestServer(t)
+	ctx := principalContext("jane.doe", domain.ScopeProductsRead)
+
+	t.Run("ProductById", func(t *testing.T) {
+		data := graphqlData(t, server.Execute(ctx, `{ product(id: 4) { name price sku store { name productCount } } }`, "", nil))
+		assert.Equal(t, map[string]interface{}{
+			"name":  "Pixel 8",
+			"price": 700.0,
+			"sku":   "PX8",
+			"store": map[string]interface{}{"name": "Google", "productCount": 1.0},
+		}, data["product"])
+	})
+
+	t.Run("UnknownProduct", func(t *testing.T) {
+		data := graphqlData(t, server.Execute(ctx, `{ product(id: 99) { name } }`, "", nil))
+		assert.Nil(t, data["product"])
+	})
+
+	t.Run("FilteredProducts", func(t *testing.T) {
+		query := `query($filter: ProductFilter) { products(filter: $filter) { totalCount edges { node { name } } } }`
+		data := graphqlData(t, server.Execute(ctx, query, "", map[string]interface{}{"filter": map[string]interface{}{"store": "Amazon", "maxPrice": 75.0}}))
+		products := data["products"].(map[string]interface{})
+		assert.Equal(t, 1.0, products["totalCount"])
+		assert.Equal(t, []interface{}{map[string]interface{}{"node": map[string]interface{}{"name": "Echo Dot"}}}, products["edges"])
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		query := `query($after: String) { products(first: 3, after: $after) { edges { node { id } } pageInfo { hasNextPage endCursor } } }`
+
+		var ids []interface{}
+		var after interface{}
+		for pages := 0; pages < 5; pages++ {
+			data := graphqlData(t, server.Execute(ctx, query, "", map[string]interface{}{"after": after}))
+			products := data["products"].(map[string]interface{})
+			for _, edge := range products["edges"].([]interface{}) {
+				ids = append(ids, edge.(map[string]interface{})["node"].(map[string]interface{})["id"])
+			}
+			pageInfo := products["pageInfo"].(map[string]interface{})
+			if !pageInfo["hasNextPage"].(bool) {
+				break
+			}
+			after = pageInfo["endCursor"]
+		}
+		assert.Equal(t, []interface{}{"1", "2", "3", "4"}, ids)
+	})
+
+	t.Run("InvalidCursor", func(t *testing.T) {
+		result := server.Execute(ctx, `{ products(after: "not-a-cursor") { totalCount } }`, "", nil)
+		assert.Equal(t, 1, len(result.Errors))
+		assert.Equal(t, "invalid_request", result.Errors[0].Extensions["code"])
+	})
+
+	t.Run("StoresWithProducts", func(t *testing.T) {
+		data := graphqlData(t, server.Execute(ctx, `{ stores(first: 2) { totalCount edges { node { name products { totalCount } } } } }`, "", nil))
+		stores := data["stores"].(map[string]interface{})
+		assert.Equal(t, 3.0, stores["totalCount"])
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"node": map[string]interface{}{"name": "Amazon", "products": map[string]interface{}{"totalCount": 2.0}}},
+			map[string]interface{}{"node": map[string]interface{}{"name": "Google", "products": map[string]interface{}{"totalCount": 1.0}}},
+		}, stores["edges"])
+	})
+}
+
+func TestGraphqlMutations(t *testing.T) {
+	server := newGraphqlTestServer(t)
+	writer := principalContext("jane.doe", domain.ScopeProductsRead, domain.ScopeProductsWrite)
+
+	t.Run("PriceHistoryNewestFirst", func(t *testing.T) {
+		for _, newPrice := range []string{"1100", "1000", "900"} {
+			graphqlData(t, server.Execute(writer, `mutation { updatePrice(id: 3, newPrice: `+newPrice+`) { price } }`, "", nil))
+		}
+
+		query := `query($after: String) { product(id: 3) { priceHistory(first: 2, after: $after) { totalCount edges { node { price } } pageInfo { hasNextPage endCursor } } } }`
+		data := graphqlData(t, server.Execute(writer, query, "", nil))
+		history := data["product"].(map[string]interface{})["priceHistory"].(map[string]interface{})
+		assert.Equal(t, 3.0, history["totalCount"])
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"node": map[string]interface{}{"price": 900.0}},
+			map[string]interface{}{"node": map[string]interface{}{"price": 1000.0}},
+		}, history["edges"])
+		pageInfo := history["pageInfo"].(map[string]interface{})
+		assert.Equal(t, true, pageInfo["hasNextPage"])
+
+		data = graphqlData(t, server.Execute(writer, query, "", map[string]interface{}{"after": pageInfo["endCursor"]}))
+		history = data["product"].(map[string]interface{})["priceHistory"].(map[string]interface{})
+		assert.Equal(t, []interface{}{map[string]interface{}{"node": map[string]interface{}{"price": 1100.0}}}, history["edges"])
+		assert.Equal(t, false, history["pageInfo"].(map[string]interface{})["hasNextPage"])
+	})
+
+	t.Run("UpdatePriceAndHistory", func(t *testing.T) {
+		data := graphqlData(t, server.Execute(writer, `mutation { updatePrice(id: 1, newPrice: 90) { price } }`, "", nil))
+		assert.Equal(t, map[string]interface{}{"price": 90.0}, data["updatePrice"])
+
+		data = graphqlData(t, server.Execute(writer, `{ priceHistory(productId: 1) { edges { node { price previousPrice actor } } } }`, "", nil))
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"node": map[string]interface{}{"price": 90.0, "previousPrice": 100.0, "actor": "jane.doe"}},
+		}, data["priceHistory"].(map[string]interface{})["edges"])
+	})
+
+	t.Run("AddProduct", func(t *testing.T) {
+		data := graphqlData(t, server.Execute(writer, `mutation { addProduct(input: {name: " Fire TV ", price: 40, store: "Amazon"}) }`, "", nil))
+		assert.Equal(t, true, data["addProduct"])
+
+		data = graphqlData(t, server.Execute(writer, `{ products(filter: {nameContains: "fire"}) { edges { node { name } } } }`, "", nil))
+		assert.Equal(t, []interface{}{map[string]interface{}{"node": map[string]interface{}{"name": "Fire TV"}}}, data["products"].(map[string]interface{})["edges"])
+	})
+
+	t.Run("InvalidProduct", func(t *testing.T) {
+		result := server.Execute(writer, `mutation { addProduct(input: {name: "", price: -1, store: "Amazon"}) }`, "", nil)
+		assert.Equal(t, 1, len(result.Errors))
+		extensions := result.Errors[0].Extensions
+		assert.Equal(t, "validation_failed", extensions["code"])
+		assert.Equal(t, 422, extensions["status"])
+
+		encoded, _ := json.Marshal(extensions["errors"])
+		assert.JSONEq(t, `[{"in":"body","pointer":"/name","detail":"name can't be empty"},{"in":"body","pointer":"/price","detail":"price can't be less than zero"}]`, string(encoded))
+	})
+
+	t.Run("MissingScope", func(t *testing.T) {
+		result := server.Execute(writer, `mutation { deleteProduct(id: 2) }`, "", nil)
+		assert.Equal(t, 1, len(result.Errors))
+		assert.Equal(t, "forbidden", result.Errors[0].Extensions["code"])
+	})
+}
+
+func TestGraphqlLimits(t *testing.T) {
+	server := newGraphqlTestServer(t)
+	ctx := principalContext("jane.doe", domain.ScopeProductsRead)
+
+	t.Run("TooDeep", func(t *testing.T) {
+		query := `{ product(id: 1) { store { products { edges { node { store { name } } } } } } }`
+		result := server.Execute(ctx, query, "", nil)
+		assert.Equal(t, 1, len(result.Errors))
+		assert.Equal(t, "query_too_complex", result.Errors[0].Extensions["code"])
+		assert.Equal(t, "query depth 7 exceeds the limit of 6", result.Errors[0].Message)
+	})
+
+	t.Run("TooComplexThroughFragment", func(t *testing.T) {
+		query := `query($first: Int) { products(first: $first) { edges { ...history } } }
+			fragment history on ProductEdge { node { priceHistory(first: 50) { totalCount } } }`
+		result := server.Execute(ctx, query, "", map[string]interface{}{"first": 10})
+		assert.Equal(t, 1, len(result.Errors))
+		assert.Equal(t, "query_too_complex", result.Errors[0].Extensions["code"])
+	})
+
+	t.Run("SchemaIntrospection", func(t *testing.T) {
+		result := server.Execute(ctx, testutil.IntrospectionQuery, "", nil)
+		assert.Empty(t, result.Errors)
+	})
+
+	t.Run("IntrospectionTooDeep", func(t *testing.T) {
+		query := "{ __schema { types { " + strings.Repeat("fields { type { ", 7) + "name" + strings.Repeat(" } }", 7) + " } } }"
+		result := server.Execute(ctx, query, "", nil)
+		assert.Equal(t, 1, len(result.Errors))
+		assert.Equal(t, "query_too_complex", result.Errors[0].Extensions["code"])
+		assert.Equal(t, "introspection depth 17 exceeds the limit of 15", result.Errors[0].Message)
+	})
+}
+
+func TestGraphqlStoreLookupsAreBatched(t *testing.T) {
+	initialData := []domain.Product{
+		{Id: 1, Name: "Kindle", Price: 100.0, Store: "Amazon"},
+		{Id: 2, Name: "Surface Pro", Price: 1200.0, Store: "Microsoft"},
+		{Id: 3, Name: "Pixel 8", Price: 700.0, Store: "Google"},
+	}
+	registry := metrics.NewRegistry()
+	productRepository := repository.NewInstrumentedProductRepository(NewFakeProductRepository(initialData), registry)
+	productService := service.NewProductService(productRepository, service.NewRoleBindingService(NewFakeRoleBindingRepository(globalRoleBindings("jane.doe"))), testLogger)
+	server, err := graphqlapi.NewServer(productService, service.NewAuditService(productRepository.AuditEvents()), graphqlapi.Limits{MaxDepth: 10, MaxComplexity: 1000}, testLogger)
+	assert.Nil(t, err)
+
+	query := `{ stores { edges { node { name productCount products(first: 5) { edges { node { name } } } } } } }`
+	data := graphqlData(t, server.Execute(principalContext("jane.doe", domain.ScopeProductsRead), query, "", nil))
+	assert.Equal(t, 3, len(data["stores"].(map[string]interface{})["edges"].([]interface{})))
+
+	var output bytes.Buffer
+	assert.Nil(t, registry.Write(&output))
+	assert.Contains(t, output.String(), `repository_query_duration_seconds_count{method="GetProductPagesByStore"} 1`+"\n")
+	// once for the stores and their product counts, once for the product pages
+	assert.Contains(t, output.String(), `repository_query_duration_seconds_count{method="CountProductsByStore"} 2`+"\n")
+}
+
+func TestGraphqlProductLookupFailure(t *testing.T) {
+	productService := service.NewProductService(NewFakeProductRepository([]domain.Product{{Id: 1, Name: "Kindle", Price: 100.0, Store: "Amazon"}}), service.NewRoleBindingService(&failingRoleBindingRepository{}), testLogger)
+	server, err := graphqlapi.NewServer(productService, service.NewAuditService(&FakeAuditRepository{}), graphqlapi.Limits{MaxDepth: 6, MaxComplexity: 200}, testLogger)
+	assert.Nil(t, err)
+
+	result := server.Execute(principalContext("jane.doe", domain.ScopeProductsRead), `{ product(id: 1) { name } }`, "", nil)
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, "internal_error", result.Errors[0].Extensions["code"])
+	assert.Empty(t, result.Errors[0].Message)
+
+	result = server.Execute(principalContext("jane.doe", domain.ScopeProductsRead, domain.ScopeProductsWrite), `mutation { updatePrice(id: 1, newPrice: 90) { price } }`, "", nil)
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, "internal_error", result.Errors[0].Extensions["code"])
+	assert.Empty(t, result.Errors[0].Message)
+}
+
+func TestGraphqlPriceHistoryOfOtherStore(t *testing.T) {
+	initialData := []domain.Product{
+		{Id: 1, Name: "Kindle", Price: 100.0, Store: "Amazon"},
+		{Id: 2, Name: "Surface Pro", Price: 1200.0, Store: "Microsoft"},
+	}
+	roleBindings := append(globalRoleBindings("jane.doe"), domain.RoleBinding{Id: 2, Subject: "john.roe", Role: domain.RoleStoreViewer, Store: "Amazon"})
+	productRepository := NewFakeProductRepository(initialData)
+	productService := service.NewProductService(productRepository, service.NewRoleBindingService(NewFakeRoleBindingRepository(roleBindings)), testLogger)
+	server, err := graphqlapi.NewServer(productService, service.NewAuditService(productRepository.AuditEvents()), graphqlapi.Limits{MaxDepth: 6, MaxComplexity: 200}, testLogger)
+	assert.Nil(t, err)
+
+	writer := principalContext("jane.doe", domain.ScopeProductsRead, domain.ScopeProductsWrite, domain.ScopeProductsDelete)
+	graphqlData(t, server.Execute(writer, `mutation { updatePrice(id: 2, newPrice: 1100) { price } }`, "", nil))
+	viewer := principalContext("john.roe", domain.ScopeProductsRead)
+
+	t.Run("Forbidden", func(t *testing.T) {
+		result := server.Execute(viewer, `{ priceHistory(productId: 2) { totalCount edges { node { price actor } } } }`, "", nil)
+		assert.Equal(t, 1, len(result.Errors))
+		assert.Equal(t, "forbidden", result.Errors[0].Extensions["code"])
+		assert.Nil(t, result.Data)
+	})
+
+	t.Run("OwnStore", func(t *testing.T) {
+		data := graphqlData(t, server.Execute(viewer, `{ priceHistory(productId: 1) { totalCount } }`, "", nil))
+		assert.Equal(t, 0.0, data["priceHistory"].(map[string]interface{})["totalCount"])
+	})
+
+	t.Run("DeletedProduct", func(t *testing.T) {
+		graphqlData(t, server.Execute(writer, `mutation { deleteProduct(id: 2) }`, "", nil))
+		result := server.Execute(writer, `{ priceHistory(productId: 2) { totalCount } }`, "", nil)
+		assert.Equal(t, 1, len(result.Errors))
+		assert.Equal(t, "not_found", result.Errors[0].Extensions["code"])
+	})
+}
@@ -0,0 +1,71 @@
+package srvc
+
+import (
+	"errors"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var allTables = []string{"products", "idempotency_keys", "product_external_ids", "api_keys", "role_bindings", "audit_events", "rate_limit_buckets", "webhook_subscriptions", "webhook_deliveries", "outbox", "product_changes"}
+
+func findComponent(report domain.HealthReport, name string) domain.ComponentHealth {
+	for _, component := range report.Components {
+		if component.Name == name {
+			return component
+		}
+	}
+	return domain.ComponentHealth{}
+}
+
+func TestReadiness(t *testing.T) {
+	t.Run("Ready", func(t *testing.T) {
+		healthService := service.NewHealthService(NewFakeHealthRepository(nil, allTables), testLogger)
+
+		report := healthService.Readiness(testContext)
+		assert.True(t, report.Up())
+		assert.Equal(t, domain.HealthStatusUp, findComponent(report, "database").Status)
+		assert.Equal(t, domain.HealthStatusUp, findComponent(report, "schema").Status)
+	})
+
+	t.Run("DatabaseDown", func(t *testing.T) {
+		healthService := service.NewHealthService(NewFakeHealthRepository(errors.New("connection refused"), allTables), testLogger)
+
+		report := healthService.Readiness(testContext)
+		assert.False(t, report.Up())
+		assert.Equal(t, "database unreachable", findComponent(report, "database").Error)
+		assert.Equal(t, "schema check failed", findComponent(report, "schema").Error)
+	})
+
+	t.Run("PendingMigrations", func(t *testing.T) {
+		healthService := service.NewHealthService(NewFakeHealthRepository(nil, allTables[:5]), testLogger)
+
+		report := healthService.Readiness(testContext)
+		schema := findComponent(report, "schema")
+		assert.False(t, report.Up())
+		assert.Equal(t, domain.HealthStatusDown, schema.Status)
+		assert.Equal(t, []string{"audit_events", "rate_limit_buckets", "webhook_subscriptions", "webhook_deliveries", "outbox", "product_changes"}, schema.Details["missing_tables"])
+	})
+
+	t.Run("MissingColumns", func(t *testing.T) {
+		healthService := service.NewHealthService(NewFakeHealthRepository(nil, allTables, "products.sku"), testLogger)
+
+		report := healthService.Readiness(testContext)
+		schema := findComponent(report, "schema")
+		assert.False(t, report.Up())
+		assert.Equal(t, "pending migrations", schema.Error)
+		assert.Equal(t, []string{"products.sku"}, schema.Details["missing_columns"])
+		assert.Nil(t, schema.Details["missing_tables"])
+	})
+
+	t.Run("Draining", func(t *testing.T) {
+		healthService := service.NewHealthService(NewFakeHealthRepository(nil, allTables), testLogger)
+		healthService.Drain()
+
+		report := healthService.Readiness(testContext)
+		assert.False(t, report.Up())
+		assert.True(t, report.Draining)
+		assert.Equal(t, domain.HealthStatusUp, findComponent(report, "database").Status)
+	})
+}
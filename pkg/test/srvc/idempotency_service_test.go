@@ -0,0 +1,72 @@
+package srvc
+
+import (
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyBegin(t *testing.T) {
+	idempotencyService := service.NewIdempotencyService(NewFakeIdempotencyRepository(), time.Hour, testLogger)
+
+	t.Run("NewKey", func(t *testing.T) {
+		_, replay, err := idempotencyService.Begin("jane.doe", "key-1", "hash-1")
+		assert.Nil(t, err)
+		assert.False(t, replay)
+	})
+
+	t.Run("InProgress", func(t *testing.T) {
+		_, _, err := idempotencyService.Begin("jane.doe", "key-1", "hash-1")
+		assert.ErrorIs(t, err, service.ErrIdempotencyKeyInProgress)
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		err := idempotencyService.Complete("jane.doe", "key-1", http.StatusCreated, "application/json", []byte("{}"))
+		assert.Nil(t, err)
+
+		record, replay, err := idempotencyService.Begin("jane.doe", "key-1", "hash-1")
+		assert.Nil(t, err)
+		assert.True(t, replay)
+		assert.Equal(t, http.StatusCreated, record.StatusCode)
+		assert.Equal(t, []byte("{}"), record.ResponseBody)
+	})
+
+	t.Run("DifferentRequest", func(t *testing.T) {
+		_, _, err := idempotencyService.Begin("jane.doe", "key-1", "hash-2")
+		assert.ErrorIs(t, err, service.ErrIdempotencyKeyReused)
+	})
+
+	t.Run("Released", func(t *testing.T) {
+		_, _, err := idempotencyService.Begin("jane.doe", "key-2", "hash-1")
+		assert.Nil(t, err)
+		assert.Nil(t, idempotencyService.Release("jane.doe", "key-2"))
+
+		_, replay, err := idempotencyService.Begin("jane.doe", "key-2", "hash-1")
+		assert.Nil(t, err)
+		assert.False(t, replay)
+	})
+
+	t.Run("OtherSubject", func(t *testing.T) {
+		_, replay, err := idempotencyService.Begin("john.doe", "key-1", "hash-1")
+		assert.Nil(t, err)
+		assert.False(t, replay)
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		_, _, err := idempotencyService.Begin("jane.doe", "", "hash-1")
+		assert.ErrorIs(t, err, service.ErrInvalidIdempotencyKey)
+	})
+}
+
+func TestIdempotencyExpiredKey(t *testing.T) {
+	idempotencyService := service.NewIdempotencyService(NewFakeIdempotencyRepository(), -time.Minute, testLogger)
+
+	_, _, err := idempotencyService.Begin("jane.doe", "key-1", "hash-1")
+	assert.Nil(t, err)
+
+	_, replay, err := idempotencyService.Begin("jane.doe", "key-1", "hash-2")
+	assert.Nil(t, err)
+	assert.False(t, replay)
+}
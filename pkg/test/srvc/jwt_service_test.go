@@ -0,0 +1,115 @@
+package srvc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/common/auth"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var hmacSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func newTestJwtService(t *testing.T, rsaKey *rsa.PrivateKey) service.IJwtService {
+	jwksDocument := fmt.Sprintf(`{"keys": [
+		{"kty": "oct", "kid": "hmac", "alg": "HS256", "k": "%s"},
+		{"kty": "RSA", "kid": "rsa", "alg": "RS256", "n": "%s", "e": "%s"}
+	]}`,
+		base64.RawURLEncoding.EncodeToString(hmacSecret),
+		base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+	)
+
+	keySet, err := auth.ParseJsonWebKeySet([]byte(jwksDocument))
+	assert.Nil(t, err)
+
+	return service.NewJwtService(keySet, auth.JwtConfig{
+		Issuer:     "https://sso.example.com",
+		Audience:   "product-api",
+		RoleClaim:  "roles",
+		RoleScopes: map[string][]string{"catalog-editor": {domain.ScopeProductsRead, domain.ScopeProductsWrite}},
+	})
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "jane.doe",
+		"iss":   "https://sso.example.com",
+		"aud":   "product-api",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"catalog-editor"},
+	}
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, keyId string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = keyId
+	signed, err := token.SignedString(key)
+	assert.Nil(t, err)
+	return signed
+}
+
+func TestJwtAuthenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	jwtService := newTestJwtService(t, rsaKey)
+
+	t.Run("ValidHS256", func(t *testing.T) {
+		principal, err := jwtService.Authenticate(signToken(t, jwt.SigningMethodHS256, "hmac", hmacSecret, validClaims()))
+		assert.Nil(t, err)
+		assert.Equal(t, "jane.doe", principal.Subject)
+		assert.Equal(t, []string{"catalog-editor"}, principal.Roles)
+		assert.True(t, principal.HasScope(domain.ScopeProductsWrite))
+		assert.False(t, principal.HasScope(domain.ScopeProductsDelete))
+	})
+
+	t.Run("ValidRS256", func(t *testing.T) {
+		_, err := jwtService.Authenticate(signToken(t, jwt.SigningMethodRS256, "rsa", rsaKey, validClaims()))
+		assert.Nil(t, err)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		claims := validClaims()
+		claims["exp"] = time.Now().Add(-time.Hour).Unix()
+		_, err := jwtService.Authenticate(signToken(t, jwt.SigningMethodHS256, "hmac", hmacSecret, claims))
+		assert.ErrorIs(t, err, service.ErrInvalidBearerToken)
+	})
+
+	t.Run("NotYetValid", func(t *testing.T) {
+		claims := validClaims()
+		claims["nbf"] = time.Now().Add(time.Hour).Unix()
+		_, err := jwtService.Authenticate(signToken(t, jwt.SigningMethodHS256, "hmac", hmacSecret, claims))
+		assert.ErrorIs(t, err, service.ErrInvalidBearerToken)
+	})
+
+	t.Run("WrongAudience", func(t *testing.T) {
+		claims := validClaims()
+		claims["aud"] = "billing-api"
+		_, err := jwtService.Authenticate(signToken(t, jwt.SigningMethodHS256, "hmac", hmacSecret, claims))
+		assert.ErrorIs(t, err, service.ErrInvalidBearerToken)
+	})
+
+	t.Run("WrongIssuer", func(t *testing.T) {
+		claims := validClaims()
+		claims["iss"] = "https://evil.example.com"
+		_, err := jwtService.Authenticate(signToken(t, jwt.SigningMethodHS256, "hmac", hmacSecret, claims))
+		assert.ErrorIs(t, err, service.ErrInvalidBearerToken)
+	})
+
+	t.Run("AlgorithmMismatch", func(t *testing.T) {
+		_, err := jwtService.Authenticate(signToken(t, jwt.SigningMethodHS256, "rsa", hmacSecret, validClaims()))
+		assert.ErrorIs(t, err, service.ErrInvalidBearerToken)
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		_, err := jwtService.Authenticate(signToken(t, jwt.SigningMethodHS256, "other", hmacSecret, validClaims()))
+		assert.ErrorIs(t, err, service.ErrInvalidBearerToken)
+	})
+}
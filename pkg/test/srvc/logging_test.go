@@ -0,0 +1,61 @@
+package srvc
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/erkindilekci/product-api/pkg/common/logging"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/erkindilekci/product-api/pkg/service/dto"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	t.Run("RequestIdFromContext", func(t *testing.T) {
+		var output bytes.Buffer
+		logger, err := logging.NewLogger(logging.Config{Level: "info", Format: logging.FormatJson}, &output)
+		assert.Nil(t, err)
+
+		ctx := domain.ContextWithRequestMetadata(testContext, domain.RequestMetadata{RequestId: "req-1"})
+		logger.InfoContext(ctx, "Product added successfully", "id", 5)
+
+		var record map[string]interface{}
+		assert.Nil(t, json.Unmarshal(output.Bytes(), &record))
+		assert.Equal(t, "Product added successfully", record["msg"])
+		assert.Equal(t, "req-1", record["request_id"])
+		assert.Equal(t, float64(5), record["id"])
+	})
+
+	t.Run("LevelFilter", func(t *testing.T) {
+		var output bytes.Buffer
+		logger, err := logging.NewLogger(logging.Config{Level: "warn", Format: logging.FormatText}, &output)
+		assert.Nil(t, err)
+
+		logger.Info("ignored")
+		logger.Warn("kept")
+		assert.NotContains(t, output.String(), "ignored")
+		assert.Contains(t, output.String(), "msg=kept")
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		_, err := logging.NewLogger(logging.Config{Level: "verbose", Format: logging.FormatJson}, &bytes.Buffer{})
+		assert.NotNil(t, err)
+
+		_, err = logging.NewLogger(logging.Config{Level: "info", Format: "xml"}, &bytes.Buffer{})
+		assert.NotNil(t, err)
+	})
+}
+
+func TestProductServiceLogsDeniedChange(t *testing.T) {
+	var output bytes.Buffer
+	logger, _ := logging.NewLogger(logging.Config{Level: "info", Format: logging.FormatText}, &output)
+	roleBindings := []domain.RoleBinding{{Id: 1, Subject: "amazon.manager", Role: domain.RoleStoreManager, Store: "Amazon"}}
+	productService := service.NewProductService(NewFakeProductRepository(nil),
+		service.NewRoleBindingService(NewFakeRoleBindingRepository(roleBindings)), logger)
+
+	err := productService.Add(principalContext("amazon.manager", domain.ScopeProductsWrite), dto.ProductCreate{Name: "Surface Pro", Price: 1200.0, Store: "Microsoft"})
+
+	assert.ErrorIs(t, err, domain.ErrForbidden)
+	assert.Contains(t, output.String(), `msg="product access denied" actor=amazon.manager store=Microsoft`)
+}
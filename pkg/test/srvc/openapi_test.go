@@ -0,0 +1,121 @@
+package srvc
+
+import (
+	"github.com/erkindilekci/product-api/pkg/common/metrics"
+	"github.com/erkindilekci/product-api/pkg/common/openapi"
+	"github.com/erkindilekci/product-api/pkg/controller"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func loadOpenApiDocument(t *testing.T) *openapi.Document {
+	document, err := openapi.Load(controller.OpenApiSpec)
+	assert.Nil(t, err)
+	return document
+}
+
+func TestOpenApiDocumentCoversRoutes(t *testing.T) {
+	document := loadOpenApiDocument(t)
+
+	e := echo.New()
+	controller.NewProductController(nil, testLogger).RegisterRoutes(e)
+	controller.NewBatchController(nil, testLogger).RegisterRoutes(e)
+	controller.NewProductStreamController(nil, time.Minute, testLogger).RegisterRoutes(e)
+	controller.NewProductChangeController(nil, testLogger).RegisterRoutes(e)
+	controller.NewApiKeyController(nil).RegisterRoutes(e)
+	controller.NewRoleBindingController(nil).RegisterRoutes(e)
+	controller.NewAuditController(nil).RegisterRoutes(e)
+	controller.NewMetricsController(metrics.NewRegistry()).RegisterRoutes(e)
+	controller.NewHealthController(nil).RegisterRoutes(e)
+	controller.NewDocsController().RegisterRoutes(e)
+	controller.NewGraphqlController(nil).RegisterRoutes(e)
+	controller.NewWebhookController(nil).RegisterRoutes(e)
+
+	var routes []string
+	for _, route := range e.Routes() {
+		routes = append(routes, route.Method+" "+route.Path)
+	}
+	assert.ElementsMatch(t, routes, document.Routes())
+}
+
+func TestOpenApiBodyValidation(t *testing.T) {
+	document := loadOpenApiDocument(t)
+	addProduct, found := document.FindOperation("POST", "/api/v1/products")
+	assert.True(t, found)
+
+	t.Run("ValidProduct", func(t *testing.T) {
+		fieldErrors := document.ValidateBody(addProduct, []byte(`{"name":"Kindle","price":100,"store":"Amazon","external_ids":[{"system":"erp","id":"A-1"}]}`))
+		assert.Empty(t, fieldErrors)
+	})
+
+	t.Run("AllFieldErrors", func(t *testing.T) {
+		fieldErrors := document.ValidateBody(addProduct, []byte(`{"name":"","price":-1,"discount":"ten","external_ids":[{"system":"erp"}]}`))
+		assert.ElementsMatch(t, []openapi.FieldError{
+			{In: openapi.InBody, Field: "/store", Message: "is required"},
+			{In: openapi.InBody, Field: "/name", Message: "can't be empty"},
+			{In: openapi.InBody, Field: "/price", Message: "must be at least 0"},
+			{In: openapi.InBody, Field: "/discount", Message: "must be a number"},
+			{In: openapi.InBody, Field: "/external_ids/0/id", Message: "is required"},
+		}, fieldErrors)
+	})
+
+	t.Run("UnknownField", func(t *testing.T) {
+		fieldErrors := document.ValidateBody(addProduct, []byte(`{"name":"Kindle","store":"Amazon","prices":100}`))
+		assert.Equal(t, []openapi.FieldError{{In: openapi.InBody, Field: "/prices", Message: "is not a known field"}}, fieldErrors)
+	})
+
+	t.Run("MalformedJson", func(t *testing.T) {
+		fieldErrors := document.ValidateBody(addProduct, []byte(`{"name":`))
+		assert.Equal(t, []openapi.FieldError{{In: openapi.InBody, Field: "", Message: "must be valid JSON"}}, fieldErrors)
+	})
+
+	t.Run("MissingBody", func(t *testing.T) {
+		fieldErrors := document.ValidateBody(addProduct, nil)
+		assert.Equal(t, []openapi.FieldError{{In: openapi.InBody, Field: "", Message: "is required"}}, fieldErrors)
+	})
+
+	t.Run("BatchOperations", func(t *testing.T) {
+		batch, _ := document.FindOperation("POST", "/api/v1/batch")
+		fieldErrors := document.ValidateBody(batch, []byte(`{"operations":[{"op":"get","id":1},{"op":"rename","id":1.5}]}`))
+		assert.ElementsMatch(t, []openapi.FieldError{
+			{In: openapi.InBody, Field: "/operations/1/op", Message: "must be one of add, update_price, delete, get"},
+			{In: openapi.InBody, Field: "/operations/1/id", Message: "must be an integer"},
+		}, fieldErrors)
+	})
+}
+
+func TestOpenApiParameterValidation(t *testing.T) {
+	document := loadOpenApiDocument(t)
+	updatePrice, _ := document.FindOperation("PUT", "/api/v1/products/:id")
+	auditEvents, _ := document.FindOperation("GET", "/api/v1/audit")
+
+	parameter := func(operation *openapi.Operation, name string) openapi.Parameter {
+		for _, parameter := range operation.Parameters {
+			if parameter.Name == name {
+				return parameter
+			}
+		}
+		t.Fatalf("no parameter %s", name)
+		return openapi.Parameter{}
+	}
+
+	t.Run("ValidParameters", func(t *testing.T) {
+		assert.Empty(t, document.ValidateParameter(parameter(updatePrice, "id"), "7", true))
+		assert.Empty(t, document.ValidateParameter(parameter(updatePrice, "newPrice"), "19.99", true))
+		assert.Empty(t, document.ValidateParameter(parameter(auditEvents, "from"), "2024-01-01T00:00:00Z", true))
+	})
+
+	t.Run("MissingRequiredParameter", func(t *testing.T) {
+		fieldErrors := document.ValidateParameter(parameter(updatePrice, "newPrice"), "", false)
+		assert.Equal(t, []openapi.FieldError{{In: openapi.InQuery, Field: "newPrice", Message: "is required"}}, fieldErrors)
+	})
+
+	t.Run("InvalidParameters", func(t *testing.T) {
+		assert.Equal(t, "must be an integer", document.ValidateParameter(parameter(updatePrice, "id"), "abc", true)[0].Message)
+		assert.Equal(t, "must be at least 0", document.ValidateParameter(parameter(updatePrice, "newPrice"), "-5", true)[0].Message)
+		assert.Equal(t, "must be at most 10000", document.ValidateParameter(parameter(auditEvents, "limit"), "20000", true)[0].Message)
+		assert.Equal(t, "must be an RFC 3339 timestamp", document.ValidateParameter(parameter(auditEvents, "to"), "yesterday", true)[0].Message)
+	})
+}
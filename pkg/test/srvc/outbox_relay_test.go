@@ -0,0 +1,162 @@
+package srvc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/erkindilekci/product-api/pkg/common/events"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/erkindilekci/product-api/pkg/service/dto"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testOutboxConfig = events.Config{
+	BatchSize:      10,
+	Timeout:        time.Second,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     4 * time.Millisecond,
+	Retention:      time.Hour,
+}
+
+// recordingPublisher records the events it accepts and fails for the products
+// in failing.
+type recordingPublisher struct {
+	published []domain.OutboxEvent
+	attempts  int
+	failing   map[int64]bool
+}
+
+func (publisher *recordingPublisher) Publish(ctx context.Context, outboxEvent domain.OutboxEvent) error {
+	publisher.attempts++
+	if publisher.failing[outboxEvent.ProductId] {
+		return errors.New("broker unavailable")
+	}
+	publisher.published = append(publisher.published, outboxEvent)
+	return nil
+}
+
+func newOutboxTestSetup(publisher events.EventPublisher) (service.IProductService, service.IOutboxRelay, *FakeOutboxRepository) {
+	initialData := []domain.Product{
+		{Id: 1, Name: "Kindle", Price: 100.0, Store: "Amazon"},
+		{Id: 2, Name: "iPhone", Price: 1000.0, Store: "Apple"},
+	}
+	productRepository := NewFakeProductRepository(initialData)
+	productService := service.NewProductService(productRepository, service.NewRoleBindingService(NewFakeRoleBindingRepository(globalRoleBindings("jane.doe"))), testLogger)
+	outboxRepository := productRepository.Outbox().(*FakeOutboxRepository)
+	return productService, service.NewOutboxRelay(outboxRepository, publisher, testOutboxConfig, testLogger), outboxRepository
+}
+
+// relayAll relays until the outbox has nothing due, waiting out backoffs.
+func relayAll(relay service.IOutboxRelay) {
+	for attempt := 0; attempt < 10; attempt++ {
+		for relay.RelayDue(testContext) > 0 {
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func publishedTypes(outboxEvents []domain.OutboxEvent, productId int64) []string {
+	var eventTypes []string
+	for _, outboxEvent := range outboxEvents {
+		if outboxEvent.ProductId == productId {
+			eventTypes = append(eventTypes, outboxEvent.EventType)
+		}
+	}
+	return eventTypes
+}
+
+func TestOutboxRelay(t *testing.T) {
+	ctx := principalContext("jane.doe", domain.ScopeProductsWrite, domain.ScopeProductsDelete)
+
+	t.Run("PublishesEventsInOrderPerProduct", func(t *testing.T) {
+		publisher := &recordingPublisher{}
+		productService, relay, _ := newOutboxTestSetup(publisher)
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 90.0))
+		assert.Nil(t, productService.UpdatePrice(ctx, 2, 900.0))
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 80.0))
+		assert.Nil(t, productService.DeleteById(ctx, 1))
+
+		// Every round publishes the oldest unpublished event of each product.
+		assert.Equal(t, 2, relay.RelayDue(testContext))
+		assert.Equal(t, 1, relay.RelayDue(testContext))
+		assert.Equal(t, 1, relay.RelayDue(testContext))
+		assert.Equal(t, 0, relay.RelayDue(testContext))
+
+		assert.Equal(t, []string{domain.AuditActionProductPriceChanged, domain.AuditActionProductPriceChanged, domain.AuditActionProductDeleted}, publishedTypes(publisher.published, 1))
+		var payload map[string]interface{}
+		assert.Nil(t, json.Unmarshal(publisher.published[0].Payload, &payload))
+		assert.Equal(t, publisher.published[0].EventId, payload["id"])
+		assert.Equal(t, 90.0, payload["product"].(map[string]interface{})["price"])
+		assert.Equal(t, 100.0, payload["previous"].(map[string]interface{})["price"])
+	})
+
+	t.Run("RetriesFailedEventsWithoutOvertakingThem", func(t *testing.T) {
+		publisher := &recordingPublisher{failing: map[int64]bool{1: true}}
+		productService, relay, outboxRepository := newOutboxTestSetup(publisher)
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 90.0))
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 80.0))
+		assert.Nil(t, productService.UpdatePrice(ctx, 2, 900.0))
+
+		relayAll(relay)
+		assert.Nil(t, publishedTypes(publisher.published, 1))
+		assert.Equal(t, []string{domain.AuditActionProductPriceChanged}, publishedTypes(publisher.published, 2))
+		assert.Greater(t, outboxRepository.outboxEvents[0].Attempts, 1)
+		assert.Equal(t, "broker unavailable", outboxRepository.outboxEvents[0].LastError)
+		assert.Equal(t, 0, outboxRepository.outboxEvents[1].Attempts)
+
+		publisher.failing = nil
+		relayAll(relay)
+		assert.Equal(t, 3, len(publisher.published))
+		assert.Equal(t, outboxRepository.outboxEvents[0].EventId, publisher.published[1].EventId)
+		assert.Equal(t, outboxRepository.outboxEvents[1].EventId, publisher.published[2].EventId)
+	})
+
+	t.Run("RolledBackChangesLeaveNoEvents", func(t *testing.T) {
+		publisher := &recordingPublisher{}
+		productService, relay, outboxRepository := newOutboxTestSetup(publisher)
+		err := productService.WithTx(ctx, func(txService service.IProductService) error {
+			assert.Nil(t, txService.Add(ctx, dto.ProductCreate{Name: "Echo Dot", Price: 50.0, Store: "Amazon"}))
+			return errors.New("abort")
+		})
+		assert.NotNil(t, err)
+
+		assert.Equal(t, 0, relay.RelayDue(testContext))
+		assert.Equal(t, 0, len(outboxRepository.outboxEvents))
+	})
+}
+
+func TestEventPublishers(t *testing.T) {
+	outboxEvent := domain.OutboxEvent{
+		EventId:   "evt_1",
+		EventType: domain.AuditActionProductCreated,
+		ProductId: 1,
+		Payload:   json.RawMessage("{\n  \"id\": \"evt_1\",\n  \"type\": \"product.created\"\n}"),
+	}
+
+	t.Run("WriterPublisherWritesJsonLines", func(t *testing.T) {
+		var output bytes.Buffer
+		publisher := events.NewWriterPublisher(&output)
+		assert.Nil(t, publisher.Publish(testContext, outboxEvent))
+		assert.Nil(t, publisher.Publish(testContext, outboxEvent))
+
+		lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+		assert.Equal(t, []string{`{"id":"evt_1","type":"product.created"}`, `{"id":"evt_1","type":"product.created"}`}, lines)
+	})
+
+	t.Run("MultiPublisherStopsAtFirstFailure", func(t *testing.T) {
+		first := &recordingPublisher{}
+		failing := &recordingPublisher{failing: map[int64]bool{1: true}}
+		last := &recordingPublisher{}
+		publisher := events.NewMultiPublisher(first, failing, last)
+
+		assert.NotNil(t, publisher.Publish(testContext, outboxEvent))
+		assert.Equal(t, 1, len(first.published))
+		assert.Equal(t, 1, failing.attempts)
+		assert.Equal(t, 0, last.attempts)
+	})
+}
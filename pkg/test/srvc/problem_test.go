@@ -0,0 +1,48 @@
+package srvc
+
+import (
+	"errors"
+	"fmt"
+	"github.com/erkindilekci/product-api/pkg/common/openapi"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestServiceProblem(t *testing.T) {
+	t.Run("KnownErrors", func(t *testing.T) {
+		for err, code := range map[error]string{
+			domain.ErrForbidden: response.CodeForbidden,
+			fmt.Errorf("%w with the id 1", domain.ErrRoleBindingNotFound):         response.CodeNotFound,
+			fmt.Errorf("%w with the id 1", domain.ErrWebhookSubscriptionNotFound): response.CodeNotFound,
+			domain.ErrRoleBindingAlreadyExists:                                    response.CodeRoleBindingAlreadyExists,
+			domain.ErrWebhookDeliveryNotRetryable:                                 response.CodeDeliveryNotRetryable,
+		} {
+			problem := response.ServiceProblem(http.StatusInternalServerError, err)
+			assert.Equal(t, code, problem.Code)
+			assert.Equal(t, err.Error(), problem.Detail)
+		}
+	})
+
+	t.Run("ValidationError", func(t *testing.T) {
+		validationError := &domain.ValidationError{}
+		validationError.Add("/name", "name can't be empty")
+		validationError.Add("limit", "limit must be between 1 and 100")
+
+		problem := response.ServiceProblem(http.StatusInternalServerError, validationError)
+		assert.Equal(t, http.StatusUnprocessableEntity, problem.Status)
+		assert.Equal(t, []response.ProblemFieldError{
+			{In: openapi.InBody, Pointer: "/name", Detail: "name can't be empty"},
+			{In: openapi.InQuery, Pointer: "limit", Detail: "limit must be between 1 and 100"},
+		}, problem.Errors)
+	})
+
+	t.Run("UnknownErrorHidden", func(t *testing.T) {
+		problem := response.ServiceProblem(http.StatusInternalServerError, errors.New("connection refused"))
+		assert.Equal(t, http.StatusInternalServerError, problem.Status)
+		assert.Equal(t, response.CodeInternalError, problem.Code)
+		assert.Empty(t, problem.Detail)
+	})
+}
@@ -0,0 +1,78 @@
+package srvc
+
+import (
+	"context"
+	"errors"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestProductChanges(t *testing.T) {
+	roleBindings := append(globalRoleBindings("jane.doe"), domain.RoleBinding{Id: 2, Subject: "apple.viewer", Role: domain.RoleStoreViewer, Store: "Apple"})
+	roleBindingService := service.NewRoleBindingService(NewFakeRoleBindingRepository(roleBindings))
+	productChangeService := service.NewProductChangeService(&FakeProductChangeRepository{[]fakeProductChange{
+		{"Apple", domain.ProductChange{Sequence: 3, ProductId: 2, Deleted: true}},
+		{"Amazon", domain.ProductChange{Sequence: 5, ProductId: 1, Product: &domain.Product{Id: 1, Name: "Kindle", Price: 90.0, Store: "Amazon"}}},
+		{"Apple", domain.ProductChange{Sequence: 8, ProductId: 3, Product: &domain.Product{Id: 3, Name: "iPad", Price: 500.0, Store: "Apple"}}},
+	}}, roleBindingService)
+
+	t.Run("Pages", func(t *testing.T) {
+		page, err := productChangeService.GetChanges(testContext, 0, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(page.Changes))
+		assert.True(t, page.Changes[0].Deleted)
+		assert.Nil(t, page.Changes[0].Product)
+		assert.Equal(t, "Kindle", page.Changes[1].Product.Name)
+		assert.Equal(t, int64(5), page.NextSequence)
+		assert.True(t, page.HasMore)
+
+		page, err = productChangeService.GetChanges(testContext, page.NextSequence, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(page.Changes))
+		assert.Equal(t, int64(8), page.NextSequence)
+		assert.False(t, page.HasMore)
+	})
+
+	t.Run("UpToDate", func(t *testing.T) {
+		page, err := productChangeService.GetChanges(testContext, 8, 10)
+		assert.Nil(t, err)
+		assert.Empty(t, page.Changes)
+		assert.Equal(t, int64(8), page.NextSequence)
+		assert.False(t, page.HasMore)
+	})
+
+	t.Run("OnlyAccessibleStores", func(t *testing.T) {
+		page, err := productChangeService.GetChanges(principalContext("apple.viewer", domain.ScopeProductsRead), 0, 10)
+		assert.Nil(t, err)
+		var productIds []int64
+		for _, change := range page.Changes {
+			productIds = append(productIds, change.ProductId)
+		}
+		assert.Equal(t, []int64{2, 3}, productIds)
+		assert.Equal(t, int64(8), page.NextSequence)
+
+		page, err = productChangeService.GetChanges(principalContext("john.doe", domain.ScopeProductsRead), 0, 10)
+		assert.Nil(t, err)
+		assert.Empty(t, page.Changes)
+
+		page, err = productChangeService.GetChanges(context.Background(), 0, 10)
+		assert.Nil(t, err)
+		assert.Empty(t, page.Changes)
+	})
+
+	t.Run("InvalidArguments", func(t *testing.T) {
+		for _, limit := range []int{0, 1001} {
+			_, err := productChangeService.GetChanges(testContext, -1, limit)
+
+			var validationError *domain.ValidationError
+			assert.True(t, errors.As(err, &validationError))
+			var pointers []string
+			for _, violation := range validationError.Violations {
+				pointers = append(pointers, violation.Pointer)
+			}
+			assert.Equal(t, []string{"since", "limit"}, pointers)
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package srvc
+
+import (
+	"bytes"
+	"context"
+	"github.com/erkindilekci/product-api/pkg/common/metrics"
+	"github.com/erkindilekci/product-api/pkg/common/ratelimit"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/grpcapi"
+	"github.com/erkindilekci/product-api/pkg/grpcapi/productpb"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/erkindilekci/product-api/pkg/service/dto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// newGrpcTestClient serves the gRPC API over an in-memory connection and
+// returns a client together with api keys for a writer and a reader.
+func newGrpcTestClient(t *testing.T) (productpb.ProductServiceClient, string, string) {
+	initialData := []domain.Product{
+		{Id: 1, Name: "Kindle", Price: 100.0, Store: "Amazon"},
+		{Id: 2, Name: "Surface Pro", Price: 1200.0, Store: "Microsoft"},
+	}
+	productService := service.NewProductService(NewFakeProductRepository(initialData), service.NewRoleBindingService(NewFakeRoleBindingRepository(globalRoleBindings("api-key:1", "api-key:2"))), testLogger)
+	return newGrpcTestClientWith(t, productService, nil, metrics.NewRegistry())
+}
+
+// newGrpcTestClientWith serves productService with the given rate limits and
+// metrics registry. rateLimitService may be nil.
+func newGrpcTestClientWith(t *testing.T, productService service.IProductService, rateLimitService service.IRateLimitService, registry *metrics.Registry) (productpb.ProductServiceClient, string, string) {
+	apiKeyService := service.NewApiKeyService(NewFakeApiKeyRepository())
+	_, writerKey, _ := apiKeyService.Create(dto.ApiKeyCreate{Name: "writer", Scopes: []string{domain.ScopeProductsRead, domain.ScopeProductsWrite}})
+	_, readerKey, _ := apiKeyService.Create(dto.ApiKeyCreate{Name: "reader", Scopes: []string{domain.ScopeProductsRead}})
+
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer(grpcapi.ServerOptions(grpcapi.NewAuthenticator(apiKeyService, nil, testLogger), rateLimitService, registry)...)
+	grpcapi.NewProductServer(productService, testLogger).Register(grpcServer)
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+
+	connection, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = connection.Close() })
+	return productpb.NewProductServiceClient(connection), writerKey, readerKey
+}
+
+func withApiKey(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), grpcapi.MetadataApiKey, token)
+}
+
+func TestGrpcProductServer(t *testing.T) {
+	client, writerKey, readerKey := newGrpcTestClient(t)
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		_, err := client.GetProduct(context.Background(), &productpb.GetProductRequest{Id: 1})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("MissingScope", func(t *testing.T) {
+		_, err := client.AddProduct(withApiKey(readerKey), &productpb.AddProductRequest{Name: "Echo Dot", Price: 50.0, Store: "Amazon"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("AddAndGet", func(t *testing.T) {
+		_, err := client.AddProduct(withApiKey(writerKey), &productpb.AddProductRequest{Name: " Echo Dot ", Price: 50.0, Store: "Amazon"})
+		assert.Nil(t, err)
+
+		product, err := client.GetProduct(withApiKey(readerKey), &productpb.GetProductRequest{Id: 3})
+		assert.Nil(t, err)
+		assert.Equal(t, "Echo Dot", product.GetName())
+	})
+
+	t.Run("InvalidProduct", func(t *testing.T) {
+		_, err := client.AddProduct(withApiKey(writerKey), &productpb.AddProductRequest{Price: -1.0, Store: "Amazon"})
+		grpcStatus := status.Convert(err)
+		assert.Equal(t, codes.InvalidArgument, grpcStatus.Code())
+
+		var fields []string
+		var reason string
+		for _, detail := range grpcStatus.Details() {
+			switch detail := detail.(type) {
+			case *errdetails.BadRequest:
+				for _, violation := range detail.GetFieldViolations() {
+					fields = append(fields, violation.GetField())
+				}
+			case *errdetails.ErrorInfo:
+				reason = detail.GetReason()
+			}
+		}
+		assert.Equal(t, []string{"/name", "/price"}, fields)
+		assert.Equal(t, "validation_failed", reason)
+	})
+
+	t.Run("DuplicateProduct", func(t *testing.T) {
+		_, err := client.AddProduct(withApiKey(writerKey), &productpb.AddProductRequest{Name: "kindle", Price: 90.0, Store: "Amazon"})
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := client.GetProduct(withApiKey(readerKey), &productpb.GetProductRequest{Id: 999})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("ListProductsOfStore", func(t *testing.T) {
+		stream, err := client.ListProducts(withApiKey(readerKey), &productpb.ListProductsRequest{Store: "Amazon"})
+		assert.Nil(t, err)
+
+		var names []string
+		for {
+			product, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			assert.Nil(t, err)
+			names = append(names, product.GetName())
+		}
+		assert.Equal(t, []string{"Kindle", "Echo Dot"}, names)
+	})
+}
+
+func TestGrpcInterceptors(t *testing.T) {
+	initialData := []domain.Product{{Id: 1, Name: "Kindle", Price: 100.0, Store: "Amazon"}}
+	config := ratelimit.Config{
+		Enabled: true,
+		Default: ratelimit.Rule{Requests: 1, Period: time.Minute},
+		PerIp:   ratelimit.Rule{Requests: 10, Period: time.Minute},
+	}
+	rateLimitService := service.NewRateLimitService(repository.NewMemoryRateLimitRepository(), config, testLogger)
+	registry := metrics.NewRegistry()
+	productService := service.NewProductService(NewFakeProductRepository(initialData), service.NewRoleBindingService(NewFakeRoleBindingRepository(globalRoleBindings("api-key:1", "api-key:2"))), testLogger)
+	client, _, readerKey := newGrpcTestClientWith(t, productService, rateLimitService, registry)
+
+	t.Run("RateLimited", func(t *testing.T) {
+		_, err := client.GetProduct(withApiKey(readerKey), &productpb.GetProductRequest{Id: 1})
+		assert.Nil(t, err)
+
+		_, err = client.GetProduct(withApiKey(readerKey), &productpb.GetProductRequest{Id: 1})
+		grpcStatus := status.Convert(err)
+		assert.Equal(t, codes.ResourceExhausted, grpcStatus.Code())
+
+		var retryDelay time.Duration
+		for _, detail := range grpcStatus.Details() {
+			if retryInfo, ok := detail.(*errdetails.RetryInfo); ok {
+				retryDelay = retryInfo.GetRetryDelay().AsDuration()
+			}
+		}
+		assert.Greater(t, retryDelay, time.Duration(0))
+	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		var output bytes.Buffer
+		assert.Nil(t, registry.Write(&output))
+		assert.Contains(t, output.String(), `grpc_requests_total{method="/product.v1.ProductService/GetProduct",code="OK"} 1`)
+		assert.Contains(t, output.String(), `grpc_requests_total{method="/product.v1.ProductService/GetProduct",code="ResourceExhausted"} 1`)
+	})
+}
+
+func TestGrpcInternalError(t *testing.T) {
+	productService := service.NewProductService(NewFakeProductRepository([]domain.Product{{Id: 1, Name: "Kindle", Price: 100.0, Store: "Amazon"}}), service.NewRoleBindingService(&failingRoleBindingRepository{}), testLogger)
+	client, writerKey, readerKey := newGrpcTestClientWith(t, productService, nil, metrics.NewRegistry())
+
+	_, err := client.GetProduct(withApiKey(readerKey), &productpb.GetProductRequest{Id: 1})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Empty(t, status.Convert(err).Message())
+
+	t.Run("Writes", func(t *testing.T) {
+		_, err := client.AddProduct(withApiKey(writerKey), &productpb.AddProductRequest{Name: "Echo Dot", Price: 50.0, Store: "Amazon"})
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Empty(t, status.Convert(err).Message())
+
+		_, err = client.UpdatePrice(withApiKey(writerKey), &productpb.UpdatePriceRequest{Id: 1, NewPrice: 90.0})
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Empty(t, status.Convert(err).Message())
+	})
+}
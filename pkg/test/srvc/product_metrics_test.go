@@ -0,0 +1,59 @@
+package srvc
+
+import (
+	"bytes"
+	"github.com/erkindilekci/product-api/pkg/common/metrics"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestProductMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	productRepository := repository.NewInstrumentedProductRepository(NewFakeProductRepository([]domain.Product{
+		{Id: 1, Name: "AirFryer", Price: 3000.0, Discount: 22.0, Store: "ABC TECH"},
+		{Id: 2, Name: "Iron", Price: 1500.0, Discount: 10.0, Store: "ABC TECH"},
+		{Id: 3, Name: "Desk", Price: 1200.0, Discount: 0.0, Store: "Home \"Plus\""},
+	}), registry)
+	service.RegisterProductMetrics(registry, productRepository, testLogger)
+
+	productRepository.GetAllProducts(testContext)
+
+	var output bytes.Buffer
+	assert.Nil(t, registry.Write(&output))
+
+	t.Run("ProductCountPerStore", func(t *testing.T) {
+		assert.Contains(t, output.String(), "# TYPE products gauge\n")
+		assert.Contains(t, output.String(), "products{store=\"ABC TECH\"} 2\n")
+		assert.Contains(t, output.String(), "products{store=\"Home \\\"Plus\\\"\"} 1\n")
+	})
+
+	t.Run("RepositoryQueryDuration", func(t *testing.T) {
+		assert.Contains(t, output.String(), "# TYPE repository_query_duration_seconds histogram\n")
+		assert.Contains(t, output.String(), "repository_query_duration_seconds_bucket{method=\"GetAllProducts\",le=\"+Inf\"} 1\n")
+		assert.Contains(t, output.String(), "repository_query_duration_seconds_count{method=\"GetAllProducts\"} 1\n")
+		assert.Contains(t, output.String(), "repository_query_duration_seconds_count{method=\"CountProductsByStore\"} 1\n")
+	})
+}
+
+func TestMetricsRegistryHistogram(t *testing.T) {
+	registry := metrics.NewRegistry()
+	histogram := registry.Histogram("request_duration_seconds", "Request duration.", []float64{0.1, 1}, "route")
+	histogram.Observe(0.05, "/a")
+	histogram.Observe(0.5, "/a")
+	histogram.Observe(5, "/a")
+
+	var output bytes.Buffer
+	assert.Nil(t, registry.Write(&output))
+
+	assert.Equal(t, `# HELP request_duration_seconds Request duration.
+# TYPE request_duration_seconds histogram
+request_duration_seconds_bucket{route="/a",le="0.1"} 1
+request_duration_seconds_bucket{route="/a",le="1"} 2
+request_duration_seconds_bucket{route="/a",le="+Inf"} 3
+request_duration_seconds_sum{route="/a"} 5.55
+request_duration_seconds_count{route="/a"} 3
+`, output.String())
+}
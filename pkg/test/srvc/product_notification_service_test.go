@@ -0,0 +1,87 @@
+package srvc
+
+import (
+	"context"
+	"errors"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// newProductNotificationTestSetup runs a notification service until the test
+// ends and returns its first connection.
+func newProductNotificationTestSetup(t *testing.T, pingInterval time.Duration) (service.IProductNotificationService, *FakeProductNotificationRepository, *FakeProductNotificationConnection) {
+	notificationRepository := NewFakeProductNotificationRepository()
+	notificationService := service.NewProductNotificationService(notificationRepository, pingInterval, testLogger)
+
+	ctx, stop := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		notificationService.Run(ctx, time.Millisecond)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		stop()
+		<-stopped
+	})
+	return notificationService, notificationRepository, <-notificationRepository.connections
+}
+
+func nextProductNotification(t *testing.T, notifications <-chan domain.ProductNotification) domain.ProductNotification {
+	select {
+	case notification := <-notifications:
+		return notification
+	case <-time.After(time.Second):
+		t.Fatal("no notification received")
+		return domain.ProductNotification{}
+	}
+}
+
+func TestProductNotifications(t *testing.T) {
+	t.Run("FansOutToSubscribers", func(t *testing.T) {
+		notificationService, _, connection := newProductNotificationTestSetup(t, time.Hour)
+		first := make(chan domain.ProductNotification, 1)
+		second := make(chan domain.ProductNotification, 1)
+		notificationService.Subscribe(func(notification domain.ProductNotification) { first <- notification })
+		unsubscribe := notificationService.Subscribe(func(notification domain.ProductNotification) { second <- notification })
+
+		notification := domain.ProductNotification{Operation: domain.ProductNotificationUpdate, ProductId: 1, Store: "Amazon"}
+		connection.notifications <- notification
+		assert.Equal(t, notification, nextProductNotification(t, first))
+		assert.Equal(t, notification, nextProductNotification(t, second))
+
+		unsubscribe()
+		connection.notifications <- domain.ProductNotification{Operation: domain.ProductNotificationDelete, ProductId: 1, Store: "Amazon"}
+		assert.Equal(t, domain.ProductNotificationDelete, nextProductNotification(t, first).Operation)
+		assert.Empty(t, second)
+	})
+
+	t.Run("ReconnectsAndResyncs", func(t *testing.T) {
+		notificationService, notificationRepository, connection := newProductNotificationTestSetup(t, time.Hour)
+		notifications := make(chan domain.ProductNotification, 1)
+		notificationService.Subscribe(func(notification domain.ProductNotification) { notifications <- notification })
+
+		connection.broken <- errors.New("connection reset by peer")
+		connection = <-notificationRepository.connections
+		assert.Equal(t, domain.ProductNotificationResync, nextProductNotification(t, notifications).Operation)
+
+		connection.notifications <- domain.ProductNotification{Operation: domain.ProductNotificationInsert, ProductId: 3, Store: "Apple"}
+		assert.Equal(t, int64(3), nextProductNotification(t, notifications).ProductId)
+	})
+
+	t.Run("PingsIdleConnection", func(t *testing.T) {
+		notificationService, notificationRepository, connection := newProductNotificationTestSetup(t, 10*time.Millisecond)
+		notifications := make(chan domain.ProductNotification, 1)
+		notificationService.Subscribe(func(notification domain.ProductNotification) { notifications <- notification })
+
+		connection.pings <- nil
+		connection.notifications <- domain.ProductNotification{Operation: domain.ProductNotificationInsert, ProductId: 3, Store: "Apple"}
+		assert.Equal(t, int64(3), nextProductNotification(t, notifications).ProductId)
+
+		// An unanswered ping times out and replaces the connection.
+		<-notificationRepository.connections
+		assert.Equal(t, domain.ProductNotificationResync, nextProductNotification(t, notifications).Operation)
+	})
+}
@@ -1,30 +1,45 @@
 package srvc
 
 import (
+	"context"
+	"errors"
 	"github.com/erkindilekci/product-api/pkg/domain"
 	"github.com/erkindilekci/product-api/pkg/service"
 	"github.com/erkindilekci/product-api/pkg/service/dto"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"log/slog"
+	"strings"
 	"testing"
 )
 
 var productService service.IProductService
+var testContext context.Context
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
 
 func TestMain(m *testing.M) {
+	testContext = principalContext("jane.doe", domain.ScopeProductsRead, domain.ScopeProductsWrite, domain.ScopeProductsDelete)
 	initialData := []domain.Product{
-		{1, "XBOX Series X", 1000.0, 10.0, "Microsoft"},
-		{2, "Steelseries Rival 500", 100.0, 20.0, "Amazon"},
-		{3, "Asus Vivobook", 600.0, 15.0, "Asus Store"},
-		{4, "Macbook Pro M3 Pro", 3000.0, 0.0, "Apple"},
+		{Id: 1, Name: "XBOX Series X", Price: 1000.0, Discount: 10.0, Store: "Microsoft"},
+		{Id: 2, Name: "Steelseries Rival 500", Price: 100.0, Discount: 20.0, Store: "Amazon"},
+		{Id: 3, Name: "Asus Vivobook", Price: 600.0, Discount: 15.0, Store: "Asus Store"},
+		{Id: 4, Name: "Macbook Pro M3 Pro", Price: 3000.0, Discount: 0.0, Store: "Apple"},
 	}
 	fakeRepo := NewFakeProductRepository(initialData)
-	productService = service.NewProductService(fakeRepo)
+	roleBindingService := service.NewRoleBindingService(NewFakeRoleBindingRepository(globalRoleBindings("jane.doe")))
+	productService = service.NewProductService(fakeRepo, roleBindingService, testLogger)
 
 	m.Run()
 }
 
+func allProducts(t *testing.T) []domain.Product {
+	products, err := productService.GetAllProducts(testContext)
+	assert.Nil(t, err)
+	return products
+}
+
 func TestGetAllProducts(t *testing.T) {
-	actualProducts := productService.GetAllProducts()
+	actualProducts := allProducts(t)
 	assert.Equal(t, 4, len(actualProducts))
 }
 
@@ -36,7 +51,7 @@ func TestAddProduct(t *testing.T) {
 			Discount: 5.0,
 			Store:    "Sony",
 		}
-		err := productService.Add(productCreate)
+		err := productService.Add(testContext, productCreate)
 		assert.Nil(t, err)
 	})
 
@@ -46,62 +61,238 @@ func TestAddProduct(t *testing.T) {
 			Price:    -100.0,
 			Discount: -10.0,
 			Store:    "",
+			ExternalIds: []domain.ExternalId{
+				{System: "erp", Id: ""},
+			},
 		}
-		err := productService.Add(productCreate)
-		assert.NotNil(t, err)
+		err := productService.Add(testContext, productCreate)
+
+		var validationError *domain.ValidationError
+		assert.True(t, errors.As(err, &validationError))
+		var pointers []string
+		for _, violation := range validationError.Violations {
+			pointers = append(pointers, violation.Pointer)
+		}
+		assert.Equal(t, []string{"/name", "/price", "/discount", "/store", "/external_ids/0/id"}, pointers)
+	})
+
+	t.Run("NameTooLong", func(t *testing.T) {
+		productCreate := dto.ProductCreate{
+			Name:  strings.Repeat("ü", 256),
+			Price: 10.0,
+			Store: "Sony",
+		}
+		err := productService.Add(testContext, productCreate)
+		assert.EqualError(t, err, "name can't be longer than 255 characters")
+	})
+
+	t.Run("DuplicateProduct", func(t *testing.T) {
+		productCreate := dto.ProductCreate{
+			Name:     "playstation 5",
+			Price:    450.0,
+			Discount: 0.0,
+			Store:    "Sony",
+		}
+		err := productService.Add(testContext, productCreate)
+		assert.ErrorIs(t, err, domain.ErrProductAlreadyExists)
+	})
+}
+
+func TestUpsertBySku(t *testing.T) {
+	productCreate := dto.ProductCreate{
+		Name:     "DualSense Controller",
+		Price:    70.0,
+		Discount: 0.0,
+		Store:    "Sony",
+		Sku:      "CFI-ZCT1",
+	}
+
+	t.Run("Insert", func(t *testing.T) {
+		product, created, err := productService.UpsertBySku(testContext, productCreate)
+		assert.Nil(t, err)
+		assert.True(t, created)
+		assert.Equal(t, "CFI-ZCT1", product.Sku)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		countBefore := len(allProducts(t))
+		productCreate.Price = 65.0
+		product, created, err := productService.UpsertBySku(testContext, productCreate)
+		assert.Nil(t, err)
+		assert.False(t, created)
+		assert.Equal(t, float32(65.0), product.Price)
+		assert.Equal(t, countBefore, len(allProducts(t)))
+	})
+
+	t.Run("ExternalIds", func(t *testing.T) {
+		erpId := domain.ExternalId{System: "erp", Id: "DS-1"}
+		shopId := domain.ExternalId{System: "shop", Id: "77"}
+		productCreate := dto.ProductCreate{Name: "DualSense Edge", Price: 200.0, Store: "Sony", Sku: "CFI-ZCP1", ExternalIds: []domain.ExternalId{erpId}}
+		product, created, err := productService.UpsertBySku(testContext, productCreate)
+		assert.Nil(t, err)
+		assert.True(t, created)
+		assert.Equal(t, []domain.ExternalId{erpId}, product.ExternalIds)
+
+		productCreate.ExternalIds = []domain.ExternalId{erpId, shopId}
+		product, created, err = productService.UpsertBySku(testContext, productCreate)
+		assert.Nil(t, err)
+		assert.False(t, created)
+		assert.Equal(t, []domain.ExternalId{erpId, shopId}, product.ExternalIds)
+
+		productCreate.ExternalIds = nil
+		productCreate.Price = 190.0
+		product, _, err = productService.UpsertBySku(testContext, productCreate)
+		assert.Nil(t, err)
+		assert.Equal(t, []domain.ExternalId{erpId, shopId}, product.ExternalIds)
+
+		foundProduct, err := productService.GetByExternalId(testContext, shopId)
+		assert.Nil(t, err)
+		assert.Equal(t, product.Id, foundProduct.Id)
+		assert.Equal(t, float32(190.0), foundProduct.Price)
+
+		productCreate.Sku = "CFI-ZCP2"
+		productCreate.Name = "DualSense Edge White"
+		productCreate.ExternalIds = []domain.ExternalId{shopId}
+		_, _, err = productService.UpsertBySku(testContext, productCreate)
+		assert.ErrorIs(t, err, domain.ErrExternalIdAlreadyExists)
+	})
+
+	t.Run("MissingSku", func(t *testing.T) {
+		productCreate.Sku = ""
+		_, _, err := productService.UpsertBySku(testContext, productCreate)
+		var validationError *domain.ValidationError
+		assert.True(t, errors.As(err, &validationError))
 	})
 }
 
 func TestGetAllProductsByStore(t *testing.T) {
 	t.Run("ValidStore", func(t *testing.T) {
-		products := productService.GetProductsByStore("Microsoft")
+		products, err := productService.GetProductsByStore(testContext, "Microsoft")
+		assert.Nil(t, err)
 		assert.Equal(t, 1, len(products))
 	})
 
 	t.Run("InvalidStore", func(t *testing.T) {
-		products := productService.GetProductsByStore("NonExistentStore")
+		products, err := productService.GetProductsByStore(testContext, "NonExistentStore")
+		assert.Nil(t, err)
 		assert.Equal(t, 0, len(products))
 	})
 }
 
 func TestGetById(t *testing.T) {
 	t.Run("ValidId", func(t *testing.T) {
-		product, err := productService.GetById(1)
+		product, err := productService.GetById(testContext, 1)
 		assert.Nil(t, err)
 		assert.Equal(t, "XBOX Series X", product.Name)
 	})
 
 	t.Run("InvalidId", func(t *testing.T) {
-		_, err := productService.GetById(999)
-		assert.NotNil(t, err)
+		_, err := productService.GetById(testContext, 999)
+		assert.ErrorIs(t, err, domain.ErrProductNotFound)
 	})
 }
 
 func TestDeleteById(t *testing.T) {
 	t.Run("ValidId", func(t *testing.T) {
-		err := productService.DeleteById(1)
+		err := productService.DeleteById(testContext, 1)
 		assert.Nil(t, err)
 	})
 
 	t.Run("InvalidId", func(t *testing.T) {
-		err := productService.DeleteById(999)
-		assert.NotNil(t, err)
+		err := productService.DeleteById(testContext, 999)
+		assert.ErrorIs(t, err, domain.ErrProductNotFound)
 	})
 }
 
 func TestUpdatePrice(t *testing.T) {
 	t.Run("ValidId", func(t *testing.T) {
-		err := productService.UpdatePrice(2, 1200.0)
+		err := productService.UpdatePrice(testContext, 2, 1200.0)
 		assert.Nil(t, err)
 	})
 
 	t.Run("InvalidId", func(t *testing.T) {
-		err := productService.UpdatePrice(999, 1200.0)
-		assert.NotNil(t, err)
+		err := productService.UpdatePrice(testContext, 999, 1200.0)
+		assert.ErrorIs(t, err, domain.ErrProductNotFound)
 	})
 
 	t.Run("InvalidPrice", func(t *testing.T) {
-		err := productService.UpdatePrice(2, -100.0)
+		err := productService.UpdatePrice(testContext, 2, -100.0)
+		var validationError *domain.ValidationError
+		assert.True(t, errors.As(err, &validationError))
+		assert.Equal(t, "newPrice", validationError.Violations[0].Pointer)
+	})
+}
+
+func TestWithTx(t *testing.T) {
+	productCreate := dto.ProductCreate{
+		Name:     "Nintendo Switch",
+		Price:    300.0,
+		Discount: 0.0,
+		Store:    "Nintendo",
+	}
+
+	t.Run("Commit", func(t *testing.T) {
+		countBefore := len(allProducts(t))
+		err := productService.WithTx(testContext, func(txService service.IProductService) error {
+			return txService.Add(testContext, productCreate)
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, countBefore+1, len(allProducts(t)))
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		countBefore := len(allProducts(t))
+		productCreate.Name = "Nintendo Switch OLED"
+		err := productService.WithTx(testContext, func(txService service.IProductService) error {
+			if err := txService.Add(testContext, productCreate); err != nil {
+				return err
+			}
+			return errors.New("abort")
+		})
+		assert.NotNil(t, err)
+		assert.Equal(t, countBefore, len(allProducts(t)))
+	})
+}
+
+func TestExternalIds(t *testing.T) {
+	externalId := domain.ExternalId{System: "bestbuy", Id: "6539991"}
+	productCreate := dto.ProductCreate{
+		Name:        "Logitech G Pro X",
+		Price:       130.0,
+		Discount:    0.0,
+		Store:       "Best Buy",
+		ExternalIds: []domain.ExternalId{externalId},
+	}
+
+	t.Run("AddWithExternalId", func(t *testing.T) {
+		err := productService.Add(testContext, productCreate)
+		assert.Nil(t, err)
+	})
+
+	t.Run("GetByExternalId", func(t *testing.T) {
+		product, err := productService.GetByExternalId(testContext, externalId)
+		assert.Nil(t, err)
+		assert.Equal(t, "Logitech G Pro X", product.Name)
+	})
+
+	t.Run("DuplicateExternalId", func(t *testing.T) {
+		countBefore := len(allProducts(t))
+		productCreate.Name = "Logitech G Pro X Superlight"
+		err := productService.Add(testContext, productCreate)
+		assert.ErrorIs(t, err, domain.ErrExternalIdAlreadyExists)
+		assert.Equal(t, countBefore, len(allProducts(t)))
+	})
+
+	t.Run("UpdatePriceByExternalId", func(t *testing.T) {
+		err := productService.UpdatePriceByExternalId(testContext, externalId, 120.0)
+		assert.Nil(t, err)
+
+		product, _ := productService.GetByExternalId(testContext, externalId)
+		assert.Equal(t, float32(120.0), product.Price)
+	})
+
+	t.Run("UnknownExternalId", func(t *testing.T) {
+		err := productService.UpdatePriceByExternalId(testContext, domain.ExternalId{System: "bestbuy", Id: "0"}, 120.0)
 		assert.NotNil(t, err)
 	})
 }
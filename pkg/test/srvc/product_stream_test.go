@@ -0,0 +1,305 @@
+package srvc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/erkindilekci/product-api/pkg/controller"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newProductStreamTestSetup runs a product stream until stop is called.
+func newProductStreamTestSetup(t *testing.T) (service.IProductService, service.IProductStreamService, context.CancelFunc) {
+	productService, streamService, _, stop := newProductStreamTestSetupWith(t, 5*time.Millisecond)
+	return productService, streamService, stop
+}
+
+// newProductStreamTestSetupWith also returns the outbox the stream follows,
+// which it polls every interval. jane.doe may read every store and
+// apple.viewer only Apple.
+func newProductStreamTestSetupWith(t *testing.T, interval time.Duration) (service.IProductService, service.IProductStreamService, *FakeOutboxRepository, context.CancelFunc) {
+	initialData := []domain.Product{
+		{Id: 1, Name: "Kindle", Price: 100.0, Store: "Amazon"},
+		{Id: 2, Name: "iPhone", Price: 1000.0, Store: "Apple"},
+	}
+	roleBindings := append(globalRoleBindings("jane.doe"), domain.RoleBinding{Id: 2, Subject: "apple.viewer", Role: domain.RoleStoreViewer, Store: "Apple"})
+	roleBindingService := service.NewRoleBindingService(NewFakeRoleBindingRepository(roleBindings))
+	productRepository := NewFakeProductRepository(initialData)
+	outbox := productRepository.Outbox().(*FakeOutboxRepository)
+	productService := service.NewProductService(productRepository, roleBindingService, testLogger)
+	streamService := service.NewProductStreamService(outbox, roleBindingService, testLogger)
+
+	ctx, stop := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		streamService.Run(ctx, interval)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		stop()
+		<-stopped
+	})
+	assert.Eventually(t, func() bool {
+		subscription, err := streamService.Subscribe(testContext, domain.ProductStreamFilter{}, 0)
+		if err != nil {
+			return false
+		}
+		subscription.Close()
+		return true
+	}, time.Second, time.Millisecond)
+	return productService, streamService, outbox, stop
+}
+
+func nextStreamEvent(t *testing.T, subscription *service.ProductSubscription) domain.OutboxEvent {
+	select {
+	case outboxEvent, ok := <-subscription.Events():
+		assert.True(t, ok)
+		return outboxEvent
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return domain.OutboxEvent{}
+	}
+}
+
+func TestProductStream(t *testing.T) {
+	ctx := principalContext("jane.doe", domain.ScopeProductsWrite, domain.ScopeProductsDelete)
+
+	t.Run("FiltersLiveEvents", func(t *testing.T) {
+		productService, streamService, _ := newProductStreamTestSetup(t)
+		subscription, err := streamService.Subscribe(testContext, domain.ProductStreamFilter{Store: "Apple"}, 0)
+		assert.Nil(t, err)
+		defer subscription.Close()
+
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 90.0))
+		assert.Nil(t, productService.DeleteById(ctx, 2))
+
+		outboxEvent := nextStreamEvent(t, subscription)
+		assert.Equal(t, int64(2), outboxEvent.Id)
+		assert.Equal(t, domain.AuditActionProductDeleted, outboxEvent.EventType)
+	})
+
+	t.Run("ResumesAfterLastEventId", func(t *testing.T) {
+		productService, streamService, _ := newProductStreamTestSetup(t)
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 90.0))
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 80.0))
+		assert.Nil(t, productService.UpdatePrice(ctx, 2, 900.0))
+		assert.Eventually(t, func() bool { return streamService.Poll(testContext) == 0 }, time.Second, time.Millisecond)
+
+		subscription, err := streamService.Subscribe(testContext, domain.ProductStreamFilter{ProductId: 1}, 1)
+		assert.Nil(t, err)
+		defer subscription.Close()
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 70.0))
+
+		assert.Equal(t, int64(2), nextStreamEvent(t, subscription).Id)
+		assert.Equal(t, int64(4), nextStreamEvent(t, subscription).Id)
+	})
+
+	t.Run("PollsWhenWoken", func(t *testing.T) {
+		productService, streamService, _, _ := newProductStreamTestSetupWith(t, time.Hour)
+		subscription, err := streamService.Subscribe(testContext, domain.ProductStreamFilter{}, 0)
+		assert.Nil(t, err)
+		defer subscription.Close()
+
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 90.0))
+		streamService.Wake()
+		assert.Equal(t, int64(1), nextStreamEvent(t, subscription).Id)
+	})
+
+	t.Run("OnlyAccessibleStores", func(t *testing.T) {
+		productService, streamService, _ := newProductStreamTestSetup(t)
+		viewerCtx := principalContext("apple.viewer", domain.ScopeProductsRead)
+		subscription, err := streamService.Subscribe(viewerCtx, domain.ProductStreamFilter{}, 0)
+		assert.Nil(t, err)
+		defer subscription.Close()
+
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 90.0))
+		assert.Nil(t, productService.UpdatePrice(ctx, 2, 900.0))
+		assert.Equal(t, int64(2), nextStreamEvent(t, subscription).Id)
+
+		_, err = streamService.Subscribe(viewerCtx, domain.ProductStreamFilter{Store: "Amazon"}, 0)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+
+	t.Run("HoldsEventsBehindRunningTransactions", func(t *testing.T) {
+		productService, streamService, outbox, _ := newProductStreamTestSetupWith(t, time.Hour)
+		subscription, err := streamService.Subscribe(testContext, domain.ProductStreamFilter{}, 0)
+		assert.Nil(t, err)
+		defer subscription.Close()
+
+		// Id 1 belongs to a transaction that is still running.
+		outbox.setTransactionBounds(5, 10)
+		runningId := outbox.skipId()
+		assert.Nil(t, productService.UpdatePrice(ctx, 2, 900.0))
+		assert.Equal(t, 0, streamService.Poll(testContext))
+		assert.Equal(t, 0, streamService.Poll(testContext))
+
+		assert.Nil(t, outbox.AddOutboxEvent(testContext, domain.OutboxEvent{Id: runningId, EventType: domain.AuditActionProductPriceChanged, ProductId: 1, Payload: []byte(`{}`)}))
+		assert.Equal(t, 2, streamService.Poll(testContext))
+		assert.Equal(t, runningId, nextStreamEvent(t, subscription).Id)
+		assert.Equal(t, int64(2), nextStreamEvent(t, subscription).Id)
+
+		// Id 3 is never committed; the gap is skipped once every transaction
+		// that was running when it was seen has ended.
+		outbox.skipId()
+		assert.Nil(t, productService.UpdatePrice(ctx, 2, 800.0))
+		assert.Equal(t, 0, streamService.Poll(testContext))
+		outbox.setTransactionBounds(10, 12)
+		assert.Equal(t, 1, streamService.Poll(testContext))
+		assert.Equal(t, int64(4), nextStreamEvent(t, subscription).Id)
+	})
+
+	t.Run("ExpiredLastEventId", func(t *testing.T) {
+		productService, streamService, outbox, _ := newProductStreamTestSetupWith(t, time.Hour)
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 90.0))
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 80.0))
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 70.0))
+		assert.Equal(t, 3, streamService.Poll(testContext))
+		publishOutboxEvents(t, outbox, 2)
+
+		_, err := streamService.Subscribe(testContext, domain.ProductStreamFilter{}, 1)
+		assert.ErrorIs(t, err, service.ErrProductStreamExpired)
+		subscription, err := streamService.Subscribe(testContext, domain.ProductStreamFilter{}, 2)
+		assert.Nil(t, err)
+		defer subscription.Close()
+		assert.Equal(t, int64(3), nextStreamEvent(t, subscription).Id)
+	})
+
+	t.Run("EndsSubscriptionsOnShutdown", func(t *testing.T) {
+		_, streamService, stop := newProductStreamTestSetup(t)
+		subscription, err := streamService.Subscribe(testContext, domain.ProductStreamFilter{}, 0)
+		assert.Nil(t, err)
+
+		stop()
+		_, open := <-subscription.Events()
+		assert.False(t, open)
+		assert.ErrorIs(t, subscription.Err(), service.ErrProductStreamClosed)
+
+		_, err = streamService.Subscribe(testContext, domain.ProductStreamFilter{}, 0)
+		assert.ErrorIs(t, err, service.ErrProductStreamClosed)
+	})
+}
+
+// publishOutboxEvents marks the first count events published and purges
+// them, as the relay does once they are older than the retention.
+func publishOutboxEvents(t *testing.T, outbox *FakeOutboxRepository, count int) {
+	outboxEvents, err := outbox.GetOutboxEventsAfter(testContext, 0, count)
+	assert.Nil(t, err)
+	publishedAt := time.Now().Add(-time.Hour)
+	for _, outboxEvent := range outboxEvents {
+		outboxEvent.PublishedAt = &publishedAt
+		assert.Nil(t, outbox.UpdateOutboxEvent(testContext, outboxEvent))
+	}
+	deleted, err := outbox.DeletePublishedOutboxEvents(testContext, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(count), deleted)
+}
+
+func newProductStreamTestServer(t *testing.T, streamService service.IProductStreamService) *httptest.Server {
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal := domain.Principal{Subject: "jane.doe", Scopes: []string{domain.ScopeProductsRead}}
+			c.SetRequest(c.Request().WithContext(domain.ContextWithPrincipal(c.Request().Context(), principal)))
+			return next(c)
+		}
+	})
+	controller.NewProductStreamController(streamService, time.Minute, testLogger).RegisterRoutes(e)
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProductStreamEndpoints(t *testing.T) {
+	ctx := principalContext("jane.doe", domain.ScopeProductsWrite)
+
+	t.Run("ServerSentEvents", func(t *testing.T) {
+		productService, streamService, stop := newProductStreamTestSetup(t)
+		server := newProductStreamTestServer(t, streamService)
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 90.0))
+		assert.Nil(t, productService.UpdatePrice(ctx, 2, 900.0))
+		assert.Eventually(t, func() bool { return streamService.Poll(testContext) == 0 }, time.Second, time.Millisecond)
+
+		httpRequest, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v1/products/stream?store=Apple", nil)
+		httpRequest.Header.Set("Last-Event-ID", "1")
+		httpResponse, err := http.DefaultClient.Do(httpRequest)
+		assert.Nil(t, err)
+		defer httpResponse.Body.Close()
+		assert.Equal(t, "text/event-stream", httpResponse.Header.Get(echo.HeaderContentType))
+
+		reader := bufio.NewReader(httpResponse.Body)
+		var lines []string
+		for len(lines) < 5 {
+			line, err := reader.ReadString('\n')
+			assert.Nil(t, err)
+			lines = append(lines, strings.TrimSuffix(line, "\n"))
+		}
+		assert.Equal(t, []string{"retry: 3000", "", "id: 2", "event: product.price_changed"}, lines[:4])
+		assert.True(t, strings.HasPrefix(lines[4], `data: {"id":"evt_`))
+
+		stop()
+		_, err = reader.ReadString('\n')
+		assert.Nil(t, err)
+		_, err = reader.ReadString('\n')
+		assert.NotNil(t, err)
+	})
+
+	t.Run("WebSocket", func(t *testing.T) {
+		productService, streamService, stop := newProductStreamTestSetup(t)
+		server := newProductStreamTestServer(t, streamService)
+		connection, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/products/stream/ws?product_id=1", nil)
+		assert.Nil(t, err)
+		defer connection.Close()
+
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 90.0))
+		var streamEvent response.ProductStreamEventResponse
+		assert.Nil(t, connection.ReadJSON(&streamEvent))
+		assert.Equal(t, int64(1), streamEvent.Id)
+		assert.Equal(t, domain.AuditActionProductPriceChanged, streamEvent.Event)
+		var payload map[string]interface{}
+		assert.Nil(t, json.Unmarshal(streamEvent.Data, &payload))
+		assert.Equal(t, 90.0, payload["product"].(map[string]interface{})["price"])
+
+		stop()
+		_, _, err = connection.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway))
+	})
+
+	t.Run("ExpiredLastEventId", func(t *testing.T) {
+		productService, streamService, outbox, _ := newProductStreamTestSetupWith(t, time.Hour)
+		server := newProductStreamTestServer(t, streamService)
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 90.0))
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 80.0))
+		assert.Equal(t, 2, streamService.Poll(testContext))
+		publishOutboxEvents(t, outbox, 2)
+
+		httpResponse, err := http.Get(server.URL + "/api/v1/products/stream?last_event_id=1")
+		assert.Nil(t, err)
+		defer httpResponse.Body.Close()
+		assert.Equal(t, http.StatusGone, httpResponse.StatusCode)
+		var problem response.Problem
+		assert.Nil(t, json.NewDecoder(httpResponse.Body).Decode(&problem))
+		assert.Equal(t, response.CodeEventsExpired, problem.Code)
+		assert.Equal(t, "Events expired", problem.Title)
+	})
+
+	t.Run("ShuttingDown", func(t *testing.T) {
+		_, streamService, stop := newProductStreamTestSetup(t)
+		server := newProductStreamTestServer(t, streamService)
+		stop()
+
+		httpResponse, err := http.Get(server.URL + "/api/v1/products/stream")
+		assert.Nil(t, err)
+		defer httpResponse.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, httpResponse.StatusCode)
+	})
+}
@@ -0,0 +1,182 @@
+package srvc
+
+import (
+	"errors"
+	"github.com/erkindilekci/product-api/pkg/common/ratelimit"
+	"github.com/erkindilekci/product-api/pkg/controller/middleware"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type failingRateLimitRepository struct{}
+
+func (repository failingRateLimitRepository) TakeToken(key string, capacity int, refillPerSecond float64) (float64, bool, error) {
+	return 0, false, errors.New("database unavailable")
+}
+
+func (repository failingRateLimitRepository) DeleteIdleBuckets(idleFor time.Duration) (int64, error) {
+	return 0, errors.New("database unavailable")
+}
+
+func TestRateLimitAllow(t *testing.T) {
+	config := ratelimit.Config{
+		Enabled: true,
+		Backend: ratelimit.BackendMemory,
+		Default: ratelimit.Rule{Requests: 2, Period: time.Minute},
+		Routes: map[string]ratelimit.Rule{
+			"POST /api/v1/batch": {Requests: 1, Period: time.Minute},
+			"GET /healthz":       {},
+		},
+	}
+	rateLimitService := service.NewRateLimitService(repository.NewMemoryRateLimitRepository(), config, testLogger)
+
+	t.Run("WithinLimit", func(t *testing.T) {
+		decision := rateLimitService.Allow("GET /api/v1/products", "api-key:1")
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 2, decision.Limit)
+		assert.Equal(t, 1, decision.Remaining)
+
+		decision = rateLimitService.Allow("GET /api/v1/products", "api-key:1")
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 0, decision.Remaining)
+	})
+
+	t.Run("Exceeded", func(t *testing.T) {
+		decision := rateLimitService.Allow("GET /api/v1/products", "api-key:1")
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, 0, decision.Remaining)
+		assert.True(t, decision.RetryAfter > 0 && decision.RetryAfter <= 30*time.Second)
+		assert.True(t, decision.ResetAfter > 30*time.Second && decision.ResetAfter <= time.Minute)
+	})
+
+	t.Run("SeparateClients", func(t *testing.T) {
+		decision := rateLimitService.Allow("GET /api/v1/products", "ip:10.0.0.1")
+		assert.True(t, decision.Allowed)
+	})
+
+	t.Run("UnlimitedRoute", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			assert.True(t, rateLimitService.Allow("GET /healthz", "api-key:1").Allowed)
+		}
+	})
+
+	t.Run("RouteRule", func(t *testing.T) {
+		decision := rateLimitService.Allow("POST /api/v1/batch", "api-key:1")
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, 1, decision.Limit)
+
+		decision = rateLimitService.Allow("POST /api/v1/batch", "api-key:1")
+		assert.False(t, decision.Allowed)
+	})
+}
+
+func TestRateLimitRefill(t *testing.T) {
+	config := ratelimit.Config{Enabled: true, Default: ratelimit.Rule{Requests: 1, Period: 50 * time.Millisecond}}
+	rateLimitService := service.NewRateLimitService(repository.NewMemoryRateLimitRepository(), config, testLogger)
+
+	assert.True(t, rateLimitService.Allow("GET /api/v1/products", "api-key:1").Allowed)
+	assert.False(t, rateLimitService.Allow("GET /api/v1/products", "api-key:1").Allowed)
+
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, rateLimitService.Allow("GET /api/v1/products", "api-key:1").Allowed)
+}
+
+func TestRateLimitRepositoryFailure(t *testing.T) {
+	config := ratelimit.Config{Enabled: true, Default: ratelimit.Rule{Requests: 5, Period: time.Minute}}
+	rateLimitService := service.NewRateLimitService(failingRateLimitRepository{}, config, testLogger)
+
+	decision := rateLimitService.Allow("GET /api/v1/products", "api-key:1")
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 5, decision.Remaining)
+}
+
+func TestRateLimitAllowIp(t *testing.T) {
+	config := ratelimit.Config{
+		Enabled: true,
+		Default: ratelimit.Rule{Requests: 5, Period: time.Minute},
+		PerIp:   ratelimit.Rule{Requests: 2, Period: time.Minute},
+		Routes:  map[string]ratelimit.Rule{"GET /healthz": {}},
+	}
+	rateLimitService := service.NewRateLimitService(repository.NewMemoryRateLimitRepository(), config, testLogger)
+
+	assert.True(t, rateLimitService.AllowIp("GET /api/v1/products", "10.0.0.1").Allowed)
+	assert.True(t, rateLimitService.AllowIp("POST /api/v1/products", "10.0.0.1").Allowed)
+	assert.False(t, rateLimitService.AllowIp("GET /api/v1/audit", "10.0.0.1").Allowed)
+	assert.True(t, rateLimitService.AllowIp("GET /healthz", "10.0.0.1").Allowed)
+	assert.True(t, rateLimitService.AllowIp("GET /api/v1/products", "10.0.0.2").Allowed)
+}
+
+func TestIpRateLimitClientAddress(t *testing.T) {
+	newServer := func(trustedProxies []*net.IPNet) *echo.Echo {
+		config := ratelimit.Config{Enabled: true, Default: ratelimit.Rule{Requests: 5, Period: time.Minute}, PerIp: ratelimit.Rule{Requests: 1, Period: time.Minute}}
+		e := echo.New()
+		e.IPExtractor = middleware.ClientIpExtractor(trustedProxies)
+		e.Use(middleware.IpRateLimit(service.NewRateLimitService(repository.NewMemoryRateLimitRepository(), config, testLogger)))
+		e.GET("/api/v1/products", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+		return e
+	}
+	get := func(e *echo.Echo, remoteAddr string, forwardedFor string) int {
+		httpRequest := httptest.NewRequest(http.MethodGet, "/api/v1/products", nil)
+		httpRequest.RemoteAddr = remoteAddr
+		httpRequest.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+		httpRequest.Header.Set(echo.HeaderXRealIP, forwardedFor)
+		recorder := httptest.NewRecorder()
+		e.ServeHTTP(recorder, httpRequest)
+		return recorder.Code
+	}
+
+	t.Run("SpoofedForwardedForIgnored", func(t *testing.T) {
+		e := newServer(nil)
+		assert.Equal(t, http.StatusOK, get(e, "203.0.113.7:40000", "198.51.100.1"))
+		assert.Equal(t, http.StatusTooManyRequests, get(e, "203.0.113.7:40001", "198.51.100.2"))
+	})
+
+	t.Run("ForwardedForOfTrustedProxy", func(t *testing.T) {
+		_, proxyNetwork, _ := net.ParseCIDR("10.0.0.0/8")
+		e := newServer([]*net.IPNet{proxyNetwork})
+		assert.Equal(t, http.StatusOK, get(e, "10.0.0.5:40000", "198.51.100.1"))
+		assert.Equal(t, http.StatusOK, get(e, "10.0.0.5:40001", "198.51.100.2"))
+		assert.Equal(t, http.StatusTooManyRequests, get(e, "10.0.0.5:40002", "198.51.100.2"))
+
+		assert.Equal(t, http.StatusOK, get(e, "203.0.113.7:40000", "198.51.100.3"))
+		assert.Equal(t, http.StatusTooManyRequests, get(e, "203.0.113.7:40001", "198.51.100.4"))
+	})
+}
+
+func TestParseRateLimitRules(t *testing.T) {
+	t.Run("Rule", func(t *testing.T) {
+		rule, err := ratelimit.ParseRule("20/1m")
+		assert.Nil(t, err)
+		assert.Equal(t, ratelimit.Rule{Requests: 20, Period: time.Minute}, rule)
+
+		rule, err = ratelimit.ParseRule("0")
+		assert.Nil(t, err)
+		assert.True(t, rule.Unlimited())
+	})
+
+	t.Run("InvalidRule", func(t *testing.T) {
+		for _, value := range []string{"20", "-1/1m", "20/0s", "twenty/1m"} {
+			_, err := ratelimit.ParseRule(value)
+			assert.NotNil(t, err, value)
+		}
+	})
+
+	t.Run("Routes", func(t *testing.T) {
+		routes, err := ratelimit.ParseRoutes("POST /api/v1/batch=10/30s, GET /healthz=0")
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]ratelimit.Rule{
+			"POST /api/v1/batch": {Requests: 10, Period: 30 * time.Second},
+			"GET /healthz":       {},
+		}, routes)
+
+		_, err = ratelimit.ParseRoutes("POST /api/v1/batch")
+		assert.NotNil(t, err)
+	})
+}
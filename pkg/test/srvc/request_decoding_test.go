@@ -0,0 +1,64 @@
+package srvc
+
+import (
+	"github.com/erkindilekci/product-api/pkg/controller/request"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newJsonContext(contentType string, body string) echo.Context {
+	httpRequest := httptest.NewRequest("POST", "/api/v1/products", strings.NewReader(body))
+	httpRequest.Header.Set(echo.HeaderContentType, contentType)
+	return echo.New().NewContext(httpRequest, httptest.NewRecorder())
+}
+
+func TestDecodeJson(t *testing.T) {
+	t.Run("NormalizesStrings", func(t *testing.T) {
+		var addProductRequest request.AddProductRequest
+		// the name uses a combining acute accent, which NFC composes into one rune
+		err := request.DecodeJson(newJsonContext("application/json; charset=utf-8", `{"name":"  Cafe\u0301 ","store":"Amazon\t","external_ids":[{"system":" erp","id":"A-1 "}]}`), &addProductRequest)
+		assert.Nil(t, err)
+		assert.Equal(t, "Caf\u00e9", addProductRequest.Name)
+		assert.Equal(t, "Amazon", addProductRequest.Store)
+		assert.Equal(t, request.ExternalIdRequest{System: "erp", Id: "A-1"}, addProductRequest.ExternalIds[0])
+	})
+
+	t.Run("UnknownField", func(t *testing.T) {
+		var addProductRequest request.AddProductRequest
+		err := request.DecodeJson(newJsonContext("application/json", `{"name":"Kindle","prices":100}`), &addProductRequest)
+		assert.Equal(t, &request.FieldError{Pointer: "/prices", Message: "is not a known field"}, err)
+	})
+
+	t.Run("WrongType", func(t *testing.T) {
+		var batchRequest request.BatchRequest
+		err := request.DecodeJson(newJsonContext("application/json", `{"operations":[{"op":"get","id":"1"}]}`), &batchRequest)
+		assert.Equal(t, &request.FieldError{Pointer: "/operations/0/id", Message: "must be an integer"}, err)
+	})
+
+	t.Run("TrailingData", func(t *testing.T) {
+		var addProductRequest request.AddProductRequest
+		err := request.DecodeJson(newJsonContext("application/json", `{"name":"Kindle"} {"name":"Echo"}`), &addProductRequest)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("TrailingDelimiter", func(t *testing.T) {
+		var addProductRequest request.AddProductRequest
+		err := request.DecodeJson(newJsonContext("application/json", `{"name":"Kindle"}}`), &addProductRequest)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("TrailingWhitespace", func(t *testing.T) {
+		var addProductRequest request.AddProductRequest
+		err := request.DecodeJson(newJsonContext("application/json", "{\"name\":\"Kindle\"}\n"), &addProductRequest)
+		assert.Nil(t, err)
+	})
+
+	t.Run("FormBody", func(t *testing.T) {
+		var addProductRequest request.AddProductRequest
+		err := request.DecodeJson(newJsonContext("application/x-www-form-urlencoded", `name=Kindle&store=Amazon`), &addProductRequest)
+		assert.ErrorIs(t, err, request.ErrUnsupportedMediaType)
+	})
+}
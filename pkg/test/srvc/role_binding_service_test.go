@@ -0,0 +1,166 @@
+package srvc
+
+import (
+	"context"
+	"errors"
+	"github.com/erkindilekci/product-api/pkg/controller/response"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/repository"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/erkindilekci/product-api/pkg/service/dto"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func newStoreScopedProductService() service.IProductService {
+	initialData := []domain.Product{
+		{Id: 1, Name: "Surface Pro", Price: 1200.0, Discount: 0.0, Store: "Microsoft"},
+		{Id: 2, Name: "Echo Dot", Price: 50.0, Discount: 10.0, Store: "Amazon"},
+		{Id: 3, Name: "Kindle", Price: 100.0, Discount: 0.0, Store: "Amazon"},
+	}
+	roleBindings := []domain.RoleBinding{
+		{Id: 1, Subject: "amazon.manager", Role: domain.RoleStoreManager, Store: "Amazon"},
+		{Id: 2, Subject: "microsoft.editor", Role: domain.RoleStoreEditor, Store: "Microsoft"},
+	}
+	roleBindingService := service.NewRoleBindingService(NewFakeRoleBindingRepository(roleBindings))
+	return service.NewProductService(NewFakeProductRepository(initialData), roleBindingService, testLogger)
+}
+
+func principalContext(subject string, scopes ...string) context.Context {
+	return domain.ContextWithPrincipal(context.Background(), domain.Principal{Subject: subject, Scopes: scopes})
+}
+
+// globalRoleBindings lets the subjects manage the products of every store.
+func globalRoleBindings(subjects ...string) []domain.RoleBinding {
+	var roleBindings []domain.RoleBinding
+	for i, subject := range subjects {
+		roleBindings = append(roleBindings, domain.RoleBinding{Id: int64(i + 1), Subject: subject, Role: domain.RoleStoreManager, Store: domain.AllStores})
+	}
+	return roleBindings
+}
+
+func TestRoleBindingCreate(t *testing.T) {
+	roleBindingService := service.NewRoleBindingService(NewFakeRoleBindingRepository(nil))
+
+	t.Run("ValidRoleBinding", func(t *testing.T) {
+		_, err := roleBindingService.Create(dto.RoleBindingCreate{Subject: "jane.doe", Role: domain.RoleStoreEditor, Store: "Apple"})
+		assert.Nil(t, err)
+		roleBindings, err := roleBindingService.GetAll("jane.doe")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(roleBindings))
+	})
+
+	t.Run("UnknownRole", func(t *testing.T) {
+		_, err := roleBindingService.Create(dto.RoleBindingCreate{Subject: "jane.doe", Role: "owner", Store: "Apple"})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("DuplicateRoleBinding", func(t *testing.T) {
+		_, err := roleBindingService.Create(dto.RoleBindingCreate{Subject: "jane.doe", Role: domain.RoleStoreEditor, Store: "Apple"})
+		assert.ErrorIs(t, err, domain.ErrRoleBindingAlreadyExists)
+		assert.Equal(t, http.StatusConflict, response.ServiceProblem(http.StatusInternalServerError, err).Status)
+	})
+}
+
+func TestStoreScopedAuthorization(t *testing.T) {
+	productService := newStoreScopedProductService()
+	amazonManager := principalContext("amazon.manager", domain.ScopeProductsWrite, domain.ScopeProductsDelete)
+	microsoftEditor := principalContext("microsoft.editor", domain.ScopeProductsWrite, domain.ScopeProductsDelete)
+
+	t.Run("AddOwnStore", func(t *testing.T) {
+		err := productService.Add(amazonManager, dto.ProductCreate{Name: "Fire TV Stick", Price: 40.0, Store: "Amazon"})
+		assert.Nil(t, err)
+	})
+
+	t.Run("AddOtherStore", func(t *testing.T) {
+		err := productService.Add(amazonManager, dto.ProductCreate{Name: "Xbox Controller", Price: 60.0, Store: "Microsoft"})
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+
+	t.Run("UpdatePriceOtherStore", func(t *testing.T) {
+		err := productService.UpdatePrice(amazonManager, 1, 1100.0)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+
+	t.Run("DeleteWithoutPermission", func(t *testing.T) {
+		err := productService.DeleteById(microsoftEditor, 1)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+
+	t.Run("DeleteOwnStore", func(t *testing.T) {
+		err := productService.DeleteById(amazonManager, 3)
+		assert.Nil(t, err)
+	})
+
+	t.Run("AdminUnrestricted", func(t *testing.T) {
+		err := productService.UpdatePrice(principalContext("amazon.manager", domain.ScopeAdmin), 1, 1100.0)
+		assert.Nil(t, err)
+	})
+
+	t.Run("UnboundPrincipalDenied", func(t *testing.T) {
+		err := productService.UpdatePrice(principalContext("api-key:7", domain.ScopeProductsWrite), 1, 1000.0)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+
+		products, err := productService.GetAllProducts(principalContext("api-key:7", domain.ScopeProductsRead))
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(products))
+	})
+
+	t.Run("NoPrincipalDenied", func(t *testing.T) {
+		_, err := productService.GetById(context.Background(), 1)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+
+	t.Run("GlobalBinding", func(t *testing.T) {
+		globalService := service.NewProductService(NewFakeProductRepository([]domain.Product{
+			{Id: 1, Name: "Surface Pro", Price: 1200.0, Store: "Microsoft"},
+		}), service.NewRoleBindingService(NewFakeRoleBindingRepository(globalRoleBindings("jane.doe"))), testLogger)
+		ctx := principalContext("jane.doe", domain.ScopeProductsWrite)
+
+		assert.Nil(t, globalService.UpdatePrice(ctx, 1, 1100.0))
+		products, err := globalService.GetAllProducts(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(products))
+	})
+
+	t.Run("ReadOtherStore", func(t *testing.T) {
+		_, err := productService.GetById(amazonManager, 1)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+
+		product, err := productService.GetById(amazonManager, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, "Amazon", product.Store)
+	})
+
+	t.Run("ListingRestricted", func(t *testing.T) {
+		products, err := productService.GetAllProducts(amazonManager)
+		assert.Nil(t, err)
+		for _, product := range products {
+			assert.Equal(t, "Amazon", product.Store)
+		}
+		assert.Equal(t, 2, len(products))
+
+		products, err = productService.GetProductsByStore(amazonManager, "Microsoft")
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(products))
+	})
+
+	t.Run("RepositoryError", func(t *testing.T) {
+		failingService := service.NewProductService(NewFakeProductRepository(nil),
+			service.NewRoleBindingService(&failingRoleBindingRepository{}), testLogger)
+
+		_, err := failingService.GetAllProducts(amazonManager)
+		assert.NotNil(t, err)
+		err = failingService.Add(amazonManager, dto.ProductCreate{Name: "Fire TV Stick", Price: 40.0, Store: "Amazon"})
+		assert.NotNil(t, err)
+	})
+}
+
+type failingRoleBindingRepository struct {
+	repository.IRoleBindingRepository
+}
+
+func (repository *failingRoleBindingRepository) GetRoleBindingsBySubject(subject string) ([]domain.RoleBinding, error) {
+	return nil, errors.New("connection refused")
+}
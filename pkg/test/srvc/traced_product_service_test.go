@@ -0,0 +1,59 @@
+package srvc
+
+import (
+	"errors"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func TestTracedProductService(t *testing.T) {
+	spanRecorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+	previousTracerProvider := otel.GetTracerProvider()
+	otel.SetTracerProvider(tracerProvider)
+	defer otel.SetTracerProvider(previousTracerProvider)
+
+	tracedService := service.NewTracedProductService(service.NewProductService(NewFakeProductRepository([]domain.Product{
+		{Id: 1, Name: "AirFryer", Price: 3000.0, Discount: 22.0, Store: "ABC TECH"},
+	}), service.NewRoleBindingService(NewFakeRoleBindingRepository(globalRoleBindings("jane.doe"))), testLogger))
+
+	t.Run("MethodSpan", func(t *testing.T) {
+		_, err := tracedService.GetById(testContext, 1)
+		assert.Nil(t, err)
+
+		spans := spanRecorder.Ended()
+		assert.Equal(t, "ProductService.GetById", spans[len(spans)-1].Name())
+		assert.Equal(t, codes.Unset, spans[len(spans)-1].Status().Code)
+	})
+
+	t.Run("ErrorStatus", func(t *testing.T) {
+		err := tracedService.UpdatePrice(testContext, 1, -1)
+		assert.NotNil(t, err)
+
+		spans := spanRecorder.Ended()
+		assert.Equal(t, codes.Error, spans[len(spans)-1].Status().Code)
+	})
+
+	t.Run("TransactionChildren", func(t *testing.T) {
+		err := tracedService.WithTx(testContext, func(txService service.IProductService) error {
+			if err := txService.UpdatePrice(testContext, 1, 10); err != nil {
+				return err
+			}
+			return errors.New("rollback")
+		})
+		assert.NotNil(t, err)
+
+		spans := spanRecorder.Ended()
+		updateSpan, txSpan := spans[len(spans)-2], spans[len(spans)-1]
+		assert.Equal(t, "ProductService.UpdatePrice", updateSpan.Name())
+		assert.Equal(t, "ProductService.WithTx", txSpan.Name())
+		assert.Equal(t, txSpan.SpanContext().SpanID(), updateSpan.Parent().SpanID())
+		assert.Equal(t, codes.Error, txSpan.Status().Code)
+	})
+}
@@ -0,0 +1,271 @@
+package srvc
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/erkindilekci/product-api/pkg/common/webhook"
+	"github.com/erkindilekci/product-api/pkg/domain"
+	"github.com/erkindilekci/product-api/pkg/service"
+	"github.com/erkindilekci/product-api/pkg/service/dto"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testWebhookSecret = "whsec_0123456789abcdef"
+
+var testWebhookConfig = webhook.Config{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     4 * time.Millisecond,
+	Timeout:        time.Second,
+	BatchSize:      10,
+
+	AllowPrivateNetworks: true,
+}
+
+// webhookReceiver records the deliveries it accepts and answers with status.
+type webhookReceiver struct {
+	mutex    sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (receiver *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	receiver.requests = append(receiver.requests, r)
+	receiver.bodies = append(receiver.bodies, body)
+	w.WriteHeader(receiver.status)
+}
+
+func (receiver *webhookReceiver) setStatus(status int) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	receiver.status = status
+}
+
+// newWebhookTestSetup returns a product service whose events reach the
+// webhook service once relay publishes them from the outbox.
+func newWebhookTestSetup(t *testing.T, eventTypes []string) (service.IProductService, service.IOutboxRelay, service.IWebhookService, *webhookReceiver) {
+	receiver := &webhookReceiver{status: http.StatusNoContent}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	webhookService := service.NewWebhookService(NewFakeWebhookRepository(), testWebhookConfig, testLogger)
+	_, err := webhookService.CreateSubscription(testContext, dto.WebhookSubscriptionCreate{Url: server.URL, EventTypes: eventTypes, Secret: testWebhookSecret})
+	assert.Nil(t, err)
+
+	productService, relay := newWebhookTestRelay(webhookService)
+	return productService, relay, webhookService, receiver
+}
+
+// newWebhookTestRelay returns a product service whose events reach
+// webhookService once relay publishes them from the outbox.
+func newWebhookTestRelay(webhookService service.IWebhookService) (service.IProductService, service.IOutboxRelay) {
+	initialData := []domain.Product{{Id: 1, Name: "Kindle", Price: 100.0, Store: "Amazon"}}
+	productRepository := NewFakeProductRepository(initialData)
+	productService := service.NewProductService(productRepository, service.NewRoleBindingService(NewFakeRoleBindingRepository(globalRoleBindings("jane.doe"))), testLogger)
+	relay := service.NewOutboxRelay(productRepository.Outbox(), webhookService, testOutboxConfig, testLogger)
+	return productService, relay
+}
+
+func TestWebhookSubscriptionValidation(t *testing.T) {
+	webhookService := service.NewWebhookService(NewFakeWebhookRepository(), testWebhookConfig, testLogger)
+
+	_, err := webhookService.CreateSubscription(testContext, dto.WebhookSubscriptionCreate{Url: "ftp://example.com", EventTypes: []string{"product.renamed"}, Secret: "short"})
+
+	var validationError *domain.ValidationError
+	assert.True(t, errors.As(err, &validationError))
+	var pointers []string
+	for _, violation := range validationError.Violations {
+		pointers = append(pointers, violation.Pointer)
+	}
+	assert.Equal(t, []string{"/url", "/event_types/0", "/secret"}, pointers)
+
+	privateConfig := testWebhookConfig
+	privateConfig.AllowPrivateNetworks = false
+	webhookService = service.NewWebhookService(NewFakeWebhookRepository(), privateConfig, testLogger)
+	for _, subscriptionUrl := range []string{"http://localhost:8080/hook", "http://127.0.0.1/hook", "http://10.0.0.7/hook", "http://169.254.169.254/latest/meta-data", "http://[::1]/hook"} {
+		_, err = webhookService.CreateSubscription(testContext, dto.WebhookSubscriptionCreate{Url: subscriptionUrl, EventTypes: domain.WebhookEventTypes, Secret: testWebhookSecret})
+		assert.True(t, errors.As(err, &validationError), subscriptionUrl)
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	ctx := principalContext("jane.doe", domain.ScopeProductsWrite)
+
+	t.Run("SignedDelivery", func(t *testing.T) {
+		productService, relay, webhookService, receiver := newWebhookTestSetup(t, domain.WebhookEventTypes)
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 90.0))
+
+		assert.Equal(t, 1, relay.RelayDue(testContext))
+		assert.Equal(t, 1, webhookService.DeliverDue(testContext))
+		assert.Equal(t, 1, len(receiver.requests))
+
+		request, body := receiver.requests[0], receiver.bodies[0]
+		assert.Equal(t, domain.WebhookEventProductPriceChanged, request.Header.Get(webhook.HeaderEvent))
+		assert.True(t, webhook.Verify(testWebhookSecret, request.Header.Get(webhook.HeaderTimestamp), body, request.Header.Get(webhook.HeaderSignature)))
+
+		var payload map[string]interface{}
+		assert.Nil(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, request.Header.Get(webhook.HeaderEventId), payload["id"])
+		assert.Equal(t, 90.0, payload["product"].(map[string]interface{})["price"])
+		assert.Equal(t, 100.0, payload["previous"].(map[string]interface{})["price"])
+
+		deliveries, _ := webhookService.GetDeliveries(testContext, domain.WebhookDeliveryFilter{})
+		assert.Equal(t, domain.WebhookDeliveryDelivered, deliveries[0].Status)
+		assert.Equal(t, http.StatusNoContent, deliveries[0].LastStatusCode)
+	})
+
+	t.Run("OnlySubscribedEvents", func(t *testing.T) {
+		productService, relay, webhookService, receiver := newWebhookTestSetup(t, []string{domain.WebhookEventProductDeleted})
+		assert.Nil(t, productService.Add(ctx, dto.ProductCreate{Name: "Echo Dot", Price: 50.0, Store: "Amazon"}))
+		assert.Nil(t, productService.DeleteById(principalContext("jane.doe", domain.ScopeProductsDelete), 1))
+
+		assert.Equal(t, 2, relay.RelayDue(testContext))
+		assert.Equal(t, 1, webhookService.DeliverDue(testContext))
+		assert.Equal(t, domain.WebhookEventProductDeleted, receiver.requests[0].Header.Get(webhook.HeaderEvent))
+	})
+
+	t.Run("RepublishedEventDeliveredOnce", func(t *testing.T) {
+		_, _, webhookService, receiver := newWebhookTestSetup(t, domain.WebhookEventTypes)
+		outboxEvent := domain.OutboxEvent{
+			EventId:   "evt-1",
+			EventType: domain.AuditActionProductCreated,
+			Payload:   json.RawMessage(`{"id":"evt-1","type":"product.created","product_id":1,"product":{"id":1,"name":"Kindle","price":100}}`),
+		}
+		assert.Nil(t, webhookService.Publish(testContext, outboxEvent))
+		assert.Nil(t, webhookService.Publish(testContext, outboxEvent))
+
+		assert.Equal(t, 1, webhookService.DeliverDue(testContext))
+		assert.Equal(t, 1, len(receiver.requests))
+	})
+
+	t.Run("RolledBackChangesAreNotPublished", func(t *testing.T) {
+		productService, relay, webhookService, _ := newWebhookTestSetup(t, domain.WebhookEventTypes)
+		err := productService.WithTx(ctx, func(txService service.IProductService) error {
+			assert.Nil(t, txService.UpdatePrice(ctx, 1, 80.0))
+			return errors.New("abort")
+		})
+		assert.NotNil(t, err)
+
+		assert.Equal(t, 0, relay.RelayDue(testContext))
+		assert.Equal(t, 0, webhookService.DeliverDue(testContext))
+	})
+
+	t.Run("RetriesAndDeadLetter", func(t *testing.T) {
+		productService, relay, webhookService, receiver := newWebhookTestSetup(t, domain.WebhookEventTypes)
+		receiver.setStatus(http.StatusInternalServerError)
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 90.0))
+		relay.RelayDue(testContext)
+
+		for attempt := 0; attempt < 20 && len(receiver.requests) < testWebhookConfig.MaxAttempts; attempt++ {
+			webhookService.DeliverDue(testContext)
+			time.Sleep(2 * time.Millisecond)
+		}
+		assert.Equal(t, testWebhookConfig.MaxAttempts, len(receiver.requests))
+		assert.Equal(t, receiver.requests[0].Header.Get(webhook.HeaderEventId), receiver.requests[2].Header.Get(webhook.HeaderEventId))
+
+		deadDeliveries, _ := webhookService.GetDeliveries(testContext, domain.WebhookDeliveryFilter{Status: domain.WebhookDeliveryDead})
+		assert.Equal(t, 1, len(deadDeliveries))
+		assert.Equal(t, testWebhookConfig.MaxAttempts, deadDeliveries[0].Attempts)
+		assert.Equal(t, http.StatusInternalServerError, deadDeliveries[0].LastStatusCode)
+
+		receiver.setStatus(http.StatusOK)
+		retried, err := webhookService.RetryDelivery(testContext, deadDeliveries[0].Id)
+		assert.Nil(t, err)
+		assert.Equal(t, domain.WebhookDeliveryPending, retried.Status)
+		assert.Equal(t, 1, webhookService.DeliverDue(testContext))
+
+		delivered, _ := webhookService.GetDeliveries(testContext, domain.WebhookDeliveryFilter{Status: domain.WebhookDeliveryDelivered})
+		assert.Equal(t, 1, len(delivered))
+	})
+}
+
+func TestWebhookDeliveryTargets(t *testing.T) {
+	ctx := principalContext("jane.doe", domain.ScopeProductsWrite)
+
+	t.Run("PrivateAddressRefusedWhenDialing", func(t *testing.T) {
+		receiver := &webhookReceiver{status: http.StatusNoContent}
+		server := httptest.NewServer(receiver)
+		t.Cleanup(server.Close)
+
+		// The subscription is added to the repository directly, as if its
+		// host name had resolved to a public address when it was created.
+		webhookRepository := NewFakeWebhookRepository()
+		_, err := webhookRepository.AddSubscription(testContext, domain.WebhookSubscription{Url: server.URL, EventTypes: domain.WebhookEventTypes, Secret: testWebhookSecret})
+		assert.Nil(t, err)
+		config := testWebhookConfig
+		config.AllowPrivateNetworks = false
+		webhookService := service.NewWebhookService(webhookRepository, config, testLogger)
+		productService, relay := newWebhookTestRelay(webhookService)
+
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 90.0))
+		relay.RelayDue(testContext)
+		assert.Equal(t, 1, webhookService.DeliverDue(testContext))
+
+		assert.Equal(t, 0, len(receiver.requests))
+		deliveries, _ := webhookService.GetDeliveries(testContext, domain.WebhookDeliveryFilter{})
+		assert.Equal(t, domain.WebhookDeliveryPending, deliveries[0].Status)
+		assert.Contains(t, deliveries[0].LastError, "not allowed")
+	})
+
+	t.Run("RedirectNotFollowed", func(t *testing.T) {
+		productService, relay, webhookService, receiver := newWebhookTestSetup(t, domain.WebhookEventTypes)
+		receiver.setStatus(http.StatusTemporaryRedirect)
+		assert.Nil(t, productService.UpdatePrice(ctx, 1, 90.0))
+		relay.RelayDue(testContext)
+		assert.Equal(t, 1, webhookService.DeliverDue(testContext))
+
+		assert.Equal(t, 1, len(receiver.requests))
+		deliveries, _ := webhookService.GetDeliveries(testContext, domain.WebhookDeliveryFilter{})
+		assert.Equal(t, domain.WebhookDeliveryPending, deliveries[0].Status)
+		assert.Equal(t, http.StatusTemporaryRedirect, deliveries[0].LastStatusCode)
+	})
+}
+
+func TestWebhookDeliveryLease(t *testing.T) {
+	ctx := principalContext("jane.doe", domain.ScopeProductsWrite)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(60 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	config := testWebhookConfig
+	config.Timeout = 50 * time.Millisecond
+	config.BatchSize = 2
+	webhookService := service.NewWebhookService(NewFakeWebhookRepository(), config, testLogger)
+	for i := 0; i < 2; i++ {
+		_, err := webhookService.CreateSubscription(testContext, dto.WebhookSubscriptionCreate{Url: server.URL, EventTypes: domain.WebhookEventTypes, Secret: testWebhookSecret})
+		assert.Nil(t, err)
+	}
+	productService, relay := newWebhookTestRelay(webhookService)
+	assert.Nil(t, productService.UpdatePrice(ctx, 1, 90.0))
+	relay.RelayDue(testContext)
+
+	// The first attempt times out and uses up the lease, so the second
+	// delivery is left for a later poll.
+	assert.Equal(t, 1, webhookService.DeliverDue(testContext))
+	deliveries, _ := webhookService.GetDeliveries(testContext, domain.WebhookDeliveryFilter{})
+	attempts := 0
+	for _, delivery := range deliveries {
+		attempts += delivery.Attempts
+	}
+	assert.Equal(t, 1, attempts)
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	config := webhook.Config{InitialBackoff: 30 * time.Second, MaxBackoff: 5 * time.Minute}
+	assert.Equal(t, 30*time.Second, config.Backoff(1))
+	assert.Equal(t, time.Minute, config.Backoff(2))
+	assert.Equal(t, 4*time.Minute, config.Backoff(4))
+	assert.Equal(t, 5*time.Minute, config.Backoff(5))
+}
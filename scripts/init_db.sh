@@ -17,4 +17,232 @@ CREATE TABLE IF NOT EXISTS products (
   store VARCHAR(255) NOT NULL
 );"
 sleep 3
-echo "Table products created"
\ No newline at end of file
+echo "Table products created"
+
+docker exec -it postgres-go psql -U postgres -d productapp -c "
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  key VARCHAR(255) NOT NULL PRIMARY KEY,
+  request_hash CHAR(64) NOT NULL,
+  status_code INTEGER,
+  content_type VARCHAR(255),
+  response_body BYTEA,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  expires_at TIMESTAMPTZ NOT NULL
+);"
+sleep 3
+echo "Table idempotency_keys created"
+
+docker exec -it postgres-go psql -U postgres -d productapp -c "
+ALTER TABLE products ADD COLUMN IF NOT EXISTS sku VARCHAR(255);
+DO \$\$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'products_store_sku_key') THEN
+    ALTER TABLE products ADD CONSTRAINT products_store_sku_key UNIQUE (store, sku);
+  END IF;
+END
+\$\$;"
+sleep 3
+echo "Product sku constraint created"
+
+# Product names are unique per store, ignoring case, unless the script is run
+# with UNIQUE_PRODUCT_NAMES=false.
+if [ "${UNIQUE_PRODUCT_NAMES:-true}" = "true" ]; then
+  docker exec -it postgres-go psql -U postgres -d productapp -c "
+CREATE UNIQUE INDEX IF NOT EXISTS products_store_name_key ON products (store, lower(name));"
+  echo "Product name constraint created"
+else
+  docker exec -it postgres-go psql -U postgres -d productapp -c "DROP INDEX IF EXISTS products_store_name_key;"
+  echo "Product name constraint dropped"
+fi
+sleep 3
+
+docker exec -it postgres-go psql -U postgres -d productapp -c "
+CREATE TABLE IF NOT EXISTS product_external_ids (
+  product_id BIGINT NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+  system VARCHAR(255) NOT NULL,
+  external_id VARCHAR(255) NOT NULL,
+  PRIMARY KEY (system, external_id)
+);
+CREATE INDEX IF NOT EXISTS product_external_ids_product_id_idx ON product_external_ids (product_id);"
+sleep 3
+echo "Table product_external_ids created"
+
+docker exec -it postgres-go psql -U postgres -d productapp -c "
+CREATE TABLE IF NOT EXISTS api_keys (
+  id BIGSERIAL NOT NULL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  prefix VARCHAR(16) NOT NULL UNIQUE,
+  key_hash CHAR(64) NOT NULL,
+  scopes TEXT[] NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  revoked_at TIMESTAMPTZ
+);"
+sleep 3
+echo "Table api_keys created"
+
+docker exec -it postgres-go psql -U postgres -d productapp -c "
+CREATE TABLE IF NOT EXISTS role_bindings (
+  id BIGSERIAL NOT NULL PRIMARY KEY,
+  subject VARCHAR(255) NOT NULL,
+  role VARCHAR(64) NOT NULL,
+  store VARCHAR(255) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (subject, role, store)
+);"
+sleep 3
+echo "Table role_bindings created"
+
+docker exec -it postgres-go psql -U postgres -d productapp -c "
+CREATE TABLE IF NOT EXISTS audit_events (
+  id BIGSERIAL NOT NULL PRIMARY KEY,
+  action VARCHAR(64) NOT NULL,
+  product_id BIGINT NOT NULL,
+  actor VARCHAR(255) NOT NULL,
+  request_id VARCHAR(255),
+  client_ip VARCHAR(64),
+  before JSONB,
+  after JSONB,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS audit_events_product_id_idx ON audit_events (product_id);
+CREATE INDEX IF NOT EXISTS audit_events_actor_idx ON audit_events (actor);
+CREATE INDEX IF NOT EXISTS audit_events_created_at_idx ON audit_events (created_at);
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS \$\$
+BEGIN
+  RAISE EXCEPTION 'audit_events is append-only';
+END;
+\$\$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+  FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();"
+sleep 3
+echo "Table audit_events created"
+
+docker exec -it postgres-go psql -U postgres -d productapp -c "
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+  key VARCHAR(512) NOT NULL PRIMARY KEY,
+  tokens DOUBLE PRECISION NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);"
+sleep 3
+echo "Table rate_limit_buckets created"
+
+docker exec -it postgres-go psql -U postgres -d productapp -c "
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+  id BIGSERIAL NOT NULL PRIMARY KEY,
+  url TEXT NOT NULL,
+  event_types TEXT[] NOT NULL,
+  secret VARCHAR(255) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id BIGSERIAL NOT NULL PRIMARY KEY,
+  subscription_id BIGINT NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+  event_id VARCHAR(64) NOT NULL,
+  event_type VARCHAR(64) NOT NULL,
+  payload JSONB NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL,
+  last_status_code INT,
+  last_error TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  delivered_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_id_idx ON webhook_deliveries (subscription_id);"
+sleep 3
+echo "Tables webhook_subscriptions and webhook_deliveries created"
+
+# An event that is published again, e.g. after the relay's lease ran out,
+# must not be delivered twice to the same subscription.
+docker exec -it postgres-go psql -U postgres -d productapp -c "
+DO \$\$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'webhook_deliveries_subscription_event_key') THEN
+    DELETE FROM webhook_deliveries duplicate USING webhook_deliveries original
+    WHERE duplicate.subscription_id = original.subscription_id AND duplicate.event_id = original.event_id AND duplicate.id > original.id;
+    ALTER TABLE webhook_deliveries ADD CONSTRAINT webhook_deliveries_subscription_event_key UNIQUE (subscription_id, event_id);
+  END IF;
+END
+\$\$;"
+sleep 3
+echo "Webhook delivery constraint created"
+
+docker exec -it postgres-go psql -U postgres -d productapp -c "
+CREATE TABLE IF NOT EXISTS outbox (
+  id BIGSERIAL NOT NULL PRIMARY KEY,
+  event_id VARCHAR(64) NOT NULL UNIQUE,
+  event_type VARCHAR(64) NOT NULL,
+  product_id BIGINT NOT NULL,
+  payload JSONB NOT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  last_error TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  published_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS outbox_unpublished_idx ON outbox (product_id, id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_published_at_idx ON outbox (published_at);"
+sleep 3
+echo "Table outbox created"
+
+docker exec -it postgres-go psql -U postgres -d productapp -c "
+CREATE OR REPLACE FUNCTION products_notify() RETURNS trigger AS \$\$
+DECLARE
+  product products;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    product := OLD;
+  ELSE
+    product := NEW;
+  END IF;
+  PERFORM pg_notify('product_changes', json_build_object('operation', lower(TG_OP), 'product_id', product.id, 'store', product.store)::text);
+  RETURN NULL;
+END;
+\$\$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS products_notify ON products;
+CREATE TRIGGER products_notify AFTER INSERT OR UPDATE OR DELETE ON products
+  FOR EACH ROW EXECUTE FUNCTION products_notify();"
+sleep 3
+echo "Trigger products_notify created"
+
+# The change feed takes a product's change sequence when the transaction
+# that changed it commits, one committing transaction at a time, so that a
+# change only becomes visible after every change with a lower sequence. A
+# product's store never changes, so the store of its last change is the store
+# it was deleted from.
+docker exec -it postgres-go psql -U postgres -d productapp -c "
+CREATE SEQUENCE IF NOT EXISTS product_change_seq;
+CREATE TABLE IF NOT EXISTS product_changes (
+  product_id BIGINT NOT NULL PRIMARY KEY,
+  store VARCHAR(255) NOT NULL,
+  change_sequence BIGINT NOT NULL,
+  changed_at TIMESTAMPTZ NOT NULL
+);
+CREATE OR REPLACE FUNCTION products_track_change() RETURNS trigger AS \$\$
+DECLARE
+  changed products%ROWTYPE;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    changed := OLD;
+  ELSE
+    changed := NEW;
+  END IF;
+  PERFORM pg_advisory_xact_lock(hashtext('product_changes'));
+  INSERT INTO product_changes (product_id, store, change_sequence, changed_at)
+  VALUES (changed.id, changed.store, nextval('product_change_seq'), clock_timestamp())
+  ON CONFLICT (product_id) DO UPDATE SET store = EXCLUDED.store, change_sequence = EXCLUDED.change_sequence, changed_at = EXCLUDED.changed_at;
+  RETURN NULL;
+END;
+\$\$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS products_track_change ON products;
+CREATE CONSTRAINT TRIGGER products_track_change AFTER INSERT OR UPDATE OR DELETE ON products
+  DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE FUNCTION products_track_change();
+SELECT pg_advisory_xact_lock(hashtext('product_changes'));
+INSERT INTO product_changes (product_id, store, change_sequence, changed_at)
+SELECT id, store, nextval('product_change_seq'), now() FROM products ORDER BY id
+ON CONFLICT (product_id) DO NOTHING;
+CREATE INDEX IF NOT EXISTS product_changes_change_sequence_idx ON product_changes (change_sequence);"
+sleep 3
+echo "Table product_changes and trigger products_track_change created"
\ No newline at end of file